@@ -1,17 +1,23 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
 	"strconv"
+	"syscall"
 	"time"
 
 	"github.com/Masterminds/squirrel"
 	"github.com/anoobz/dualread/auth/internal/httpserver"
+	"github.com/anoobz/dualread/auth/internal/mailer"
+	"github.com/anoobz/dualread/auth/internal/model"
 	"github.com/anoobz/dualread/auth/internal/store"
 	"github.com/anoobz/dualread/auth/internal/store/psqlstore"
 	"github.com/joho/godotenv"
+	"github.com/twinj/uuid"
 )
 
 func main() {
@@ -38,16 +44,99 @@ func main() {
 
 	statementBuilder := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar).
 		RunWith(db)
-	store := psqlstore.NewSqlStore(db, statementBuilder)
+	// The signing keys are encrypted at rest under the key encryption key
+	keyCipher, err := model.NewKeyCipher(os.Getenv("SIGNING_KEY_ENCRYPTION_KEY"))
+	if err != nil {
+		logger.Fatal(err)
+	}
+	sqlStore := psqlstore.NewSqlStore(db, statementBuilder, keyCipher)
 
 	port, err := strconv.Atoi(os.Getenv("SERVER_PORT"))
 	if err != nil {
 		logger.Fatal(err)
 	}
 
-	server := httpserver.NewServer(store, logger, port)
-	err = server.Start()
+	config, err := httpserver.LoadConfig()
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	accessKey, err := loadAccessKey(config.AccessKeyAlg)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	accessKeys, err := store.LoadKeyRing(
+		sqlStore.SigningKey(),
+		model.AccessKeyPurpose,
+		accessKey,
+		config.TokenLifetime(model.AccessKeyPurpose),
+		time.Now(),
+	)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	// Without rotation the configured key is expected to sign the tokens
+	if config.KeyRotationInterval == 0 && !accessKeys.Active().SameKey(accessKey) {
+		logger.Printf(
+			"warning: the configured access key is not the active signing key %s, "+
+				"the stored key is used until the ring is rotated",
+			accessKeys.Active().Kid,
+		)
+	}
+	// The refresh tokens are only verified by this service, the first key is
+	// generated like the rotated ones and stored in the ring
+	refreshKey, err := model.GenerateSigningKey("HS256", time.Now())
 	if err != nil {
 		logger.Fatal(err)
 	}
+	refreshKeys, err := store.LoadKeyRing(
+		sqlStore.SigningKey(),
+		model.RefreshKeyPurpose,
+		refreshKey,
+		config.TokenLifetime(model.RefreshKeyPurpose),
+		time.Now(),
+	)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	m, err := mailer.Load()
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	server := httpserver.NewServer(sqlStore, accessKeys, refreshKeys, m, config, logger, port)
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.Start()
+	}()
+
+	// Stop on SIGINT or SIGTERM once the requests in flight and the queued
+	// emails are done
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	select {
+	case err := <-serverErr:
+		if err != nil {
+			logger.Fatal(err)
+		}
+	case <-ctx.Done():
+		logger.Print("Shutting down")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			logger.Print(err)
+		}
+	}
+}
+
+// loadAccessKey reads the private key of JWT_ACCESS_PRIVATE_KEY. The key seeds
+// the key ring the first time the service starts and replaces the active key
+// when the algorithm changes, later keys are produced by rotation.
+func loadAccessKey(alg string) (*model.SigningKey, error) {
+	return model.LoadSigningKey(
+		uuid.NewV4().String(),
+		alg,
+		os.Getenv("JWT_ACCESS_PRIVATE_KEY"),
+	)
 }
@@ -0,0 +1,221 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/anoobz/dualread/auth/internal/model"
+	"github.com/gorilla/mux"
+)
+
+// apiKeyTouchInterval limits the writes recording the last use of API keys,
+// which would otherwise happen on every request.
+const apiKeyTouchInterval = time.Minute
+
+var errInvalidApiKey = errors.New("invalid api key")
+
+// authenticateApiKey returns the claims of a request authenticated with the
+// key. The permissions are the current ones of the user, limited by the scope
+// of the key.
+func (s *server) authenticateApiKey(key string) (*model.AccessClaims, error) {
+	prefix, err := model.ApiKeyPrefix(key)
+	if err != nil {
+		return nil, errInvalidApiKey
+	}
+	apiKey, err := s.store.ApiKey().GetByPrefix(prefix)
+	now := time.Now()
+	if err != nil || !apiKey.Verify(key) || apiKey.Expired(now) {
+		return nil, errInvalidApiKey
+	}
+	user, err := s.store.User().GetById(apiKey.UserId)
+	if err != nil || !user.Active {
+		return nil, errInvalidApiKey
+	}
+	grants, err := s.userGrants(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if now.Unix()-apiKey.LastUsed >= int64(apiKeyTouchInterval.Seconds()) {
+		if err := s.store.ApiKey().Touch(apiKey.Id, now.Unix()); err != nil {
+			s.logger.Printf("failed to record the use of api key %s: %v", apiKey.Id, err)
+		}
+	}
+
+	return apiKey.Claims(grants), nil
+}
+
+type apiKeyPayload struct {
+	Name  string `json:"name"`
+	Scope string `json:"scope"`
+	// ExpiresIn is the lifetime of the key in seconds, keys without one do
+	// not expire
+	ExpiresIn int64 `json:"expires_in"`
+}
+
+// issueApiKey issues a key for the user within the allowed scopes and
+// responds with the key, which is only ever shown then.
+func (s *server) issueApiKey(
+	w http.ResponseWriter,
+	r *http.Request,
+	userId int64,
+	allowedScopes []string,
+) {
+	p := &apiKeyPayload{}
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		s.error(w, r, http.StatusBadRequest, err)
+		return
+	}
+	scope, err := model.GrantScope(allowedScopes, p.Scope)
+	if err != nil {
+		s.error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	k, err := model.NewApiKey(
+		userId,
+		p.Name,
+		scope,
+		time.Duration(p.ExpiresIn)*time.Second,
+		time.Now(),
+	)
+	if err != nil {
+		s.error(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if err := s.store.ApiKey().Insert(k); err != nil {
+		s.error(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	s.respond(w, r, http.StatusCreated, k)
+}
+
+func (s *server) getMyApiKeys() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId, err := getAccessClaims(r).UserId()
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		keys, err := s.store.ApiKey().GetByUser(userId)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		s.respond(w, r, http.StatusOK, keys)
+	}
+}
+
+// insertMyApiKey cannot be called with an API key, so that a leaked key
+// cannot be used to issue others. The scope of the new key is bounded by the
+// scope of the access token.
+func (s *server) insertMyApiKey() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := getAccessClaims(r)
+		if claims.TokenUse == model.ApiKeyUse {
+			s.error(w, r, http.StatusForbidden, errors.New("api keys cannot issue api keys"))
+			return
+		}
+		userId, err := claims.UserId()
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		grants, err := s.userGrants(userId)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		s.issueApiKey(w, r, userId, model.FilterScopes(grants.Scopes(), claims.Scope))
+	}
+}
+
+func (s *server) deleteMyApiKey() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId, err := getAccessClaims(r).UserId()
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		k, err := s.store.ApiKey().GetById(mux.Vars(r)["id"])
+		if err != nil || k.UserId != userId {
+			s.error(w, r, http.StatusNotFound, errors.New("api key not found"))
+			return
+		}
+		if err := s.store.ApiKey().Delete(k.Id); err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		s.respond(w, r, http.StatusOK, nil)
+	}
+}
+
+func (s *server) getUserApiKeys() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		keys, err := s.store.ApiKey().GetByUser(id)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		s.respond(w, r, http.StatusOK, keys)
+	}
+}
+
+// insertUserApiKey issues the keys of service accounts, users created for the
+// integrations. The caller must hold every permission of the user, like for
+// updating it, and the key can be granted the scopes held by both the user and
+// the caller, so that it cannot be used to gain permissions the caller lacks.
+// Like insertMyApiKey, it cannot be called with an API key.
+func (s *server) insertUserApiKey() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := getAccessClaims(r)
+		if claims.TokenUse == model.ApiKeyUse {
+			s.error(w, r, http.StatusForbidden, errors.New("api keys cannot issue api keys"))
+			return
+		}
+		id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		if _, err := s.store.User().GetById(id); err != nil {
+			s.error(w, r, http.StatusNotFound, errors.New("user not found"))
+			return
+		}
+		grants, err := s.userGrants(id)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		if err := checkGrantable(r, grants.Permissions); err != nil {
+			s.error(w, r, http.StatusForbidden, err)
+			return
+		}
+
+		s.issueApiKey(w, r, id, model.FilterScopes(grants.Scopes(), claims.Scope))
+	}
+}
+
+func (s *server) deleteApiKey() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := s.store.ApiKey().Delete(mux.Vars(r)["id"]); err != nil {
+			s.error(w, r, http.StatusNotFound, errors.New("api key not found"))
+			return
+		}
+
+		s.respond(w, r, http.StatusOK, nil)
+	}
+}
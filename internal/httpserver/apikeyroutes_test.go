@@ -0,0 +1,521 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anoobz/dualread/auth/internal/model"
+	"github.com/anoobz/dualread/auth/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+// requestTestApiKeyRoute sends the request with the authorization header,
+// "Bearer <token>" or "ApiKey <key>".
+func requestTestApiKeyRoute(
+	t *testing.T,
+	s *server,
+	method string,
+	url string,
+	authorization string,
+	payload map[string]interface{},
+) (*httptest.ResponseRecorder, string) {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	req := s.CreateTestRequest(t, method, url, payload)
+	req.Header.Add("Authorization", authorization)
+	s.ServeHTTP(rec, req)
+
+	if rec.Code == http.StatusOK || rec.Code == http.StatusCreated {
+		return rec, ""
+	}
+	res := struct {
+		ErrorMsg string `json:"error"`
+	}{}
+	json.NewDecoder(rec.Body).Decode(&res)
+	return rec, res.ErrorMsg
+}
+
+func TestServer_InsertMyApiKey(t *testing.T) {
+	s := NewTestServer(t)
+
+	s.CreateTestUser(t, 1, false)
+	accessToken := s.LoginTestUser(t, "test0@test.test", "test_password0")
+
+	testCases := []struct {
+		name             string
+		payload          map[string]interface{}
+		expectedStatus   int
+		expectedScope    string
+		expectedErrorMsg string
+	}{
+		{
+			name: "scope of the token",
+			payload: map[string]interface{}{
+				"name": "backup script",
+			},
+			expectedStatus: http.StatusCreated,
+			expectedScope: "api-keys:read api-keys:write email openid profile profile:read " +
+				"profile:write sessions:read sessions:write",
+		},
+		{
+			name: "narrower scope with expiry",
+			payload: map[string]interface{}{
+				"name":       "sync script",
+				"scope":      "profile:read",
+				"expires_in": 3600,
+			},
+			expectedStatus: http.StatusCreated,
+			expectedScope:  "profile:read",
+		},
+		{
+			name: "scope the user lacks",
+			payload: map[string]interface{}{
+				"name":  "admin script",
+				"scope": "users:read",
+			},
+			expectedStatus:   http.StatusBadRequest,
+			expectedErrorMsg: "invalid scope: users:read",
+		},
+		{
+			name:             "missing name",
+			payload:          map[string]interface{}{},
+			expectedStatus:   http.StatusBadRequest,
+			expectedErrorMsg: "a required field is empty",
+		},
+	}
+
+	for _, tc := range testCases {
+		rec, errorMsg := requestTestApiKeyRoute(
+			t, s,
+			http.MethodPost,
+			"/auth/me/api-keys",
+			"Bearer "+accessToken,
+			tc.payload,
+		)
+		assert.Equal(t, tc.expectedStatus, rec.Code, tc.name)
+		assert.Equal(t, tc.expectedErrorMsg, errorMsg, tc.name)
+		if tc.expectedErrorMsg != "" {
+			continue
+		}
+
+		k := &model.ApiKey{}
+		json.NewDecoder(rec.Body).Decode(&k)
+		assert.Equal(t, tc.expectedScope, k.Scope, tc.name)
+		storedKey, err := s.store.ApiKey().GetById(k.Id)
+		if assert.NoError(t, err, tc.name) {
+			assert.True(t, storedKey.Verify(k.Key), tc.name)
+			assert.Empty(t, storedKey.Key, tc.name)
+		}
+	}
+
+	// The key is only shown when it is issued
+	rec, _ := requestTestApiKeyRoute(
+		t, s,
+		http.MethodGet,
+		"/auth/me/api-keys",
+		"Bearer "+accessToken,
+		nil,
+	)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	keys := []*model.ApiKey{}
+	json.NewDecoder(rec.Body).Decode(&keys)
+	if assert.Len(t, keys, 2) {
+		assert.Equal(t, "backup script", keys[0].Name)
+		assert.Empty(t, keys[0].Key)
+		assert.Zero(t, keys[0].Expires)
+		assert.NotZero(t, keys[1].Expires)
+	}
+
+	// The key cannot be granted more than the scope of the token
+	rec = httptest.NewRecorder()
+	req := s.CreateTestRequest(
+		t, http.MethodPost, "/auth/login",
+		map[string]interface{}{
+			"email":    "test0@test.test",
+			"password": "test_password0",
+			"scope":    "api-keys:write profile:read",
+		},
+	)
+	s.ServeHTTP(rec, req)
+	token := &model.AuthToken{}
+	json.NewDecoder(rec.Body).Decode(&token)
+	rec, errorMsg := requestTestApiKeyRoute(
+		t, s,
+		http.MethodPost,
+		"/auth/me/api-keys",
+		"Bearer "+token.TokenString,
+		map[string]interface{}{"name": "sessions script", "scope": "sessions:read"},
+	)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "invalid scope: sessions:read", errorMsg)
+}
+
+func TestServer_ApiKeyAuthentication(t *testing.T) {
+	s := NewTestServer(t)
+
+	users := s.CreateTestUser(t, 2, false)
+	accessToken := s.LoginTestUser(t, "test0@test.test", "test_password0")
+
+	issue := func(payload map[string]interface{}) *model.ApiKey {
+		rec, _ := requestTestApiKeyRoute(
+			t, s,
+			http.MethodPost,
+			"/auth/me/api-keys",
+			"Bearer "+accessToken,
+			payload,
+		)
+		k := &model.ApiKey{}
+		json.NewDecoder(rec.Body).Decode(&k)
+		return k
+	}
+	fullKey := issue(map[string]interface{}{"name": "full"})
+	profileKey := issue(map[string]interface{}{"name": "profile", "scope": "profile:read"})
+	expiredKey, err := model.NewApiKey(
+		users[0].ID,
+		"expired",
+		"profile:read",
+		time.Minute,
+		time.Now().Add(-time.Hour),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expiredPlainKey := expiredKey.Key
+	expiredKey.Key = ""
+	if err := s.store.ApiKey().Insert(expiredKey); err != nil {
+		t.Fatal(err)
+	}
+	_, inactivePlainKeys := store.CreateTestApiKey(t, s.store, users[1], 1, 0)
+	if err := s.store.User().Update(users[1].ID, map[string]interface{}{"active": false}); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name             string
+		method           string
+		url              string
+		key              string
+		expectedStatus   int
+		expectedErrorMsg string
+	}{
+		{
+			name:           "key in scope",
+			method:         http.MethodGet,
+			url:            "/auth/me",
+			key:            profileKey.Key,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:             "key out of scope",
+			method:           http.MethodGet,
+			url:              "/auth/me/sessions",
+			key:              profileKey.Key,
+			expectedStatus:   http.StatusForbidden,
+			expectedErrorMsg: "insufficient scope",
+		},
+		{
+			name:             "key issuing a key",
+			method:           http.MethodPost,
+			url:              "/auth/me/api-keys",
+			key:              fullKey.Key,
+			expectedStatus:   http.StatusForbidden,
+			expectedErrorMsg: "api keys cannot issue api keys",
+		},
+		{
+			name:           "key listing the keys",
+			method:         http.MethodGet,
+			url:            "/auth/me/api-keys",
+			key:            fullKey.Key,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:             "wrong secret",
+			method:           http.MethodGet,
+			url:              "/auth/userinfo",
+			key:              profileKey.Key + "x",
+			expectedStatus:   http.StatusUnauthorized,
+			expectedErrorMsg: "invalid api key",
+		},
+		{
+			name:             "malformed key",
+			method:           http.MethodGet,
+			url:              "/auth/userinfo",
+			key:              "invalid",
+			expectedStatus:   http.StatusUnauthorized,
+			expectedErrorMsg: "invalid api key",
+		},
+		{
+			name:             "expired key",
+			method:           http.MethodGet,
+			url:              "/auth/userinfo",
+			key:              expiredPlainKey,
+			expectedStatus:   http.StatusUnauthorized,
+			expectedErrorMsg: "invalid api key",
+		},
+		{
+			name:             "key of an inactive user",
+			method:           http.MethodGet,
+			url:              "/auth/userinfo",
+			key:              inactivePlainKeys[0],
+			expectedStatus:   http.StatusUnauthorized,
+			expectedErrorMsg: "invalid api key",
+		},
+	}
+
+	for _, tc := range testCases {
+		rec, errorMsg := requestTestApiKeyRoute(
+			t, s,
+			tc.method,
+			tc.url,
+			"ApiKey "+tc.key,
+			map[string]interface{}{"name": "from key"},
+		)
+
+		assert.Equal(t, tc.expectedStatus, rec.Code, tc.name)
+		assert.Equal(t, tc.expectedErrorMsg, errorMsg, tc.name)
+	}
+
+	// The use of a key is recorded
+	k, err := s.store.ApiKey().GetById(profileKey.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.InDelta(t, time.Now().Unix(), k.LastUsed, 5)
+}
+
+func TestServer_DeleteMyApiKey(t *testing.T) {
+	s := NewTestServer(t)
+
+	users := s.CreateTestUser(t, 2, false)
+	accessToken := s.LoginTestUser(t, "test0@test.test", "test_password0")
+	keys, plainKeys := store.CreateTestApiKey(t, s.store, users[0], 1, 0)
+	otherKeys, _ := store.CreateTestApiKey(t, s.store, users[1], 1, 0)
+
+	testCases := []struct {
+		name             string
+		id               string
+		expectedStatus   int
+		expectedErrorMsg string
+	}{
+		{
+			name:           "own key",
+			id:             keys[0].Id,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:             "deleted key",
+			id:               keys[0].Id,
+			expectedStatus:   http.StatusNotFound,
+			expectedErrorMsg: "api key not found",
+		},
+		{
+			name:             "key of another user",
+			id:               otherKeys[0].Id,
+			expectedStatus:   http.StatusNotFound,
+			expectedErrorMsg: "api key not found",
+		},
+	}
+
+	for _, tc := range testCases {
+		rec, errorMsg := requestTestApiKeyRoute(
+			t, s,
+			http.MethodDelete,
+			"/auth/me/api-keys/"+tc.id,
+			"Bearer "+accessToken,
+			nil,
+		)
+
+		assert.Equal(t, tc.expectedStatus, rec.Code, tc.name)
+		assert.Equal(t, tc.expectedErrorMsg, errorMsg, tc.name)
+	}
+
+	rec, errorMsg := requestTestApiKeyRoute(
+		t, s,
+		http.MethodGet,
+		"/auth/userinfo",
+		"ApiKey "+plainKeys[0],
+		nil,
+	)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Equal(t, "invalid api key", errorMsg)
+	_, err := s.store.ApiKey().GetById(otherKeys[0].Id)
+	assert.NoError(t, err)
+}
+
+func TestServer_UserApiKeys(t *testing.T) {
+	s := NewTestServer(t)
+
+	s.CreateTestUser(t, 1, true)
+	users := s.CreateTestUser(t, 2, false)
+	role := s.CreateTestRole(t, 1, []string{model.PermissionReadUsers})[0]
+	if err := s.store.Role().SetUserRoles(users[0].ID, []string{role.Name}); err != nil {
+		t.Fatal(err)
+	}
+	adminToken := s.LoginTestUser(t, "test0@test.test", "test_password0")
+	userToken := s.LoginTestUser(t, "test2@test.test", "test_password2")
+	userUrl := fmt.Sprintf("/auth/admin/user/%d/api-key", users[0].ID)
+
+	// A service account is issued a key with a permission of its role
+	rec, errorMsg := requestTestApiKeyRoute(
+		t, s,
+		http.MethodPost,
+		userUrl,
+		"Bearer "+adminToken,
+		map[string]interface{}{"name": "reporting", "scope": "users:read"},
+	)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Empty(t, errorMsg)
+	k := &model.ApiKey{}
+	json.NewDecoder(rec.Body).Decode(&k)
+	assert.Equal(t, users[0].ID, k.UserId)
+
+	rec, _ = requestTestApiKeyRoute(t, s, http.MethodGet, "/auth/admin/user", "ApiKey "+k.Key, nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	rec, _ = requestTestApiKeyRoute(
+		t, s,
+		http.MethodDelete,
+		fmt.Sprintf("/auth/admin/user/%d", users[1].ID),
+		"ApiKey "+k.Key,
+		nil,
+	)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec, errorMsg = requestTestApiKeyRoute(
+		t, s,
+		http.MethodPost,
+		userUrl,
+		"Bearer "+adminToken,
+		map[string]interface{}{"name": "reporting", "scope": "users:write"},
+	)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "invalid scope: users:write", errorMsg)
+
+	rec, errorMsg = requestTestApiKeyRoute(
+		t, s,
+		http.MethodPost,
+		"/auth/admin/user/42/api-key",
+		"Bearer "+adminToken,
+		map[string]interface{}{"name": "reporting"},
+	)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, "user not found", errorMsg)
+
+	rec, errorMsg = requestTestApiKeyRoute(
+		t, s,
+		http.MethodPost,
+		userUrl,
+		"Bearer "+userToken,
+		map[string]interface{}{"name": "reporting"},
+	)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Equal(t, "unauthorized", errorMsg)
+
+	rec, _ = requestTestApiKeyRoute(t, s, http.MethodGet, userUrl, "Bearer "+adminToken, nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	keys := []*model.ApiKey{}
+	json.NewDecoder(rec.Body).Decode(&keys)
+	if assert.Len(t, keys, 1) {
+		assert.Equal(t, k.Id, keys[0].Id)
+		assert.Empty(t, keys[0].Key)
+	}
+
+	rec, _ = requestTestApiKeyRoute(
+		t, s,
+		http.MethodDelete,
+		"/auth/admin/api-key/"+k.Id,
+		"Bearer "+adminToken,
+		nil,
+	)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	rec, errorMsg = requestTestApiKeyRoute(
+		t, s,
+		http.MethodDelete,
+		"/auth/admin/api-key/"+k.Id,
+		"Bearer "+adminToken,
+		nil,
+	)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, "api key not found", errorMsg)
+}
+
+func TestServer_InsertUserApiKey_BoundedByCaller(t *testing.T) {
+	s := NewTestServer(t)
+
+	admins := s.CreateTestUser(t, 1, true)
+	users := s.CreateTestUser(t, 2, false)
+	role := s.CreateTestRole(t, 1, []string{model.PermissionWriteUsers})[0]
+	for _, user := range users {
+		if err := s.store.Role().SetUserRoles(user.ID, []string{role.Name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	managerToken := s.LoginTestUser(t, "test1@test.test", "test_password1")
+	userUrl := fmt.Sprintf("/auth/admin/user/%d/api-key", users[1].ID)
+
+	// The manager lacks permissions of the admin, so it cannot act as them
+	rec, errorMsg := requestTestApiKeyRoute(
+		t, s,
+		http.MethodPost,
+		fmt.Sprintf("/auth/admin/user/%d/api-key", admins[0].ID),
+		"Bearer "+managerToken,
+		map[string]interface{}{"name": "escalation"},
+	)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Contains(t, errorMsg, "cannot grant permission: ")
+	keys, err := s.store.ApiKey().GetByUser(admins[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, keys)
+
+	rec, errorMsg = requestTestApiKeyRoute(
+		t, s,
+		http.MethodPost,
+		userUrl,
+		"Bearer "+managerToken,
+		map[string]interface{}{"name": "escalation", "scope": model.PermissionWriteRoles},
+	)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "invalid scope: "+model.PermissionWriteRoles, errorMsg)
+
+	// Without a scope, the key gets the scopes held by both
+	rec, errorMsg = requestTestApiKeyRoute(
+		t, s,
+		http.MethodPost,
+		userUrl,
+		"Bearer "+managerToken,
+		map[string]interface{}{"name": "shared"},
+	)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Empty(t, errorMsg)
+	k := &model.ApiKey{}
+	json.NewDecoder(rec.Body).Decode(&k)
+	assert.Contains(t, strings.Fields(k.Scope), model.PermissionWriteUsers)
+	assert.NotContains(t, strings.Fields(k.Scope), model.PermissionWriteRoles)
+
+	// A key of the manager cannot issue keys for other users either
+	managerKey := &model.ApiKey{}
+	rec, _ = requestTestApiKeyRoute(
+		t, s,
+		http.MethodPost,
+		"/auth/me/api-keys",
+		"Bearer "+managerToken,
+		map[string]interface{}{"name": "manager"},
+	)
+	json.NewDecoder(rec.Body).Decode(&managerKey)
+	rec, errorMsg = requestTestApiKeyRoute(
+		t, s,
+		http.MethodPost,
+		userUrl,
+		"ApiKey "+managerKey.Key,
+		map[string]interface{}{"name": "from key"},
+	)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Equal(t, "api keys cannot issue api keys", errorMsg)
+}
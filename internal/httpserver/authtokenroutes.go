@@ -4,9 +4,47 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/anoobz/dualread/auth/internal/model"
 	"github.com/gorilla/mux"
 )
 
+// authTokenResponse describes a persisted refresh token without exposing the
+// token or its hash, an admin must not be able to replay a session.
+type authTokenResponse struct {
+	Uuid      string `json:"uuid"`
+	Expires   int64  `json:"exp"`
+	UserId    int64  `json:"user_id"`
+	Family    string `json:"family"`
+	Consumed  bool   `json:"consumed"`
+	Created   int64  `json:"created"`
+	LastUsed  int64  `json:"last_used"`
+	ClientIp  string `json:"client_ip"`
+	UserAgent string `json:"user_agent"`
+}
+
+func newAuthTokenResponse(t *model.AuthToken) *authTokenResponse {
+	return &authTokenResponse{
+		Uuid:      t.Uuid,
+		Expires:   t.Expires,
+		UserId:    t.UserId,
+		Family:    t.Family,
+		Consumed:  t.Consumed,
+		Created:   t.Created,
+		LastUsed:  t.LastUsed,
+		ClientIp:  t.ClientIp,
+		UserAgent: t.UserAgent,
+	}
+}
+
+func newAuthTokenResponses(tokens []*model.AuthToken) []*authTokenResponse {
+	res := []*authTokenResponse{}
+	for _, t := range tokens {
+		res = append(res, newAuthTokenResponse(t))
+	}
+
+	return res
+}
+
 func (s *server) getAuthToken() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		id := mux.Vars(r)["id"]
@@ -16,7 +54,7 @@ func (s *server) getAuthToken() http.HandlerFunc {
 			return
 		}
 
-		s.respond(w, r, http.StatusOK, t)
+		s.respond(w, r, http.StatusOK, newAuthTokenResponse(t))
 	}
 }
 
@@ -33,7 +71,7 @@ func (s *server) getAuthTokenPage() http.HandlerFunc {
 			return
 		}
 
-		s.respond(w, r, http.StatusOK, t)
+		s.respond(w, r, http.StatusOK, newAuthTokenResponses(t))
 	}
 }
 
@@ -45,7 +83,7 @@ func (s *server) getAllAuthTokens() http.HandlerFunc {
 			return
 		}
 
-		s.respond(w, r, http.StatusOK, t)
+		s.respond(w, r, http.StatusOK, newAuthTokenResponses(t))
 	}
 }
 
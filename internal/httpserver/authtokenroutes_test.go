@@ -87,9 +87,11 @@ func TestServer_GetAuthToken(t *testing.T) {
 
 		assert.Equal(t, tc.expectedStatus, rec.Code, tc.name)
 		if tc.expectedErrorMsg == "" {
-			token := &model.AuthToken{}
-			json.NewDecoder(rec.Body).Decode(&token)
-			assert.Equal(t, token, token, tc.name)
+			body := map[string]interface{}{}
+			json.NewDecoder(rec.Body).Decode(&body)
+			assert.Equal(t, token.Uuid, body["uuid"], tc.name)
+			assert.NotContains(t, body, "token", tc.name)
+			assert.NotContains(t, body, "token_hash", tc.name)
 		} else {
 			res := struct {
 				ErrorMsg string `json:"error"`
@@ -171,9 +173,9 @@ func TestServer_GetAllAuthToken(t *testing.T) {
 
 		assert.Equal(t, tc.expectedStatus, rec.Code, tc.name)
 		if tc.expectedErrorMsg == "" {
-			tokens := []*model.AuthToken{}
+			tokens := []*authTokenResponse{}
 			json.NewDecoder(rec.Body).Decode(&tokens)
-			assert.EqualValues(t, testTokens, tokens, tc.name)
+			assert.EqualValues(t, newAuthTokenResponses(testTokens), tokens, tc.name)
 		} else {
 			res := struct {
 				ErrorMsg string `json:"error"`
@@ -284,9 +286,9 @@ func TestServer_GetAuthTokenPage(t *testing.T) {
 
 		assert.Equal(t, tc.expectedStatus, rec.Code, tc.name)
 		if tc.expectedErrorMsg == "" {
-			tokens := []*model.AuthToken{}
+			tokens := []*authTokenResponse{}
 			json.NewDecoder(rec.Body).Decode(&tokens)
-			assert.Equal(t, tc.expectedTokens, tokens, tc.name)
+			assert.Equal(t, newAuthTokenResponses(tc.expectedTokens), tokens, tc.name)
 		} else {
 			res := struct {
 				ErrorMsg string `json:"error"`
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"strconv"
@@ -35,37 +36,53 @@ func (s *server) register() http.HandlerFunc {
 			return
 		}
 
-		bcryptCost, err := strconv.Atoi(os.Getenv("BCRYPT_COST"))
-		if err != nil {
-			s.error(w, r, http.StatusInternalServerError, err)
-			return
-		}
-		encryptedPassword, err := bcrypt.GenerateFromPassword(
-			[]byte(req.Password),
-			bcryptCost,
-		)
+		encryptedPassword, err := hashPassword(req.Password)
 		if err != nil {
 			s.error(w, r, http.StatusInternalServerError, err)
 			return
 		}
 		user, err := s.store.User().Insert(
-			req.Email, string(encryptedPassword),
-			false,
+			req.Email, encryptedPassword,
 			time.Now(),
 		)
 		if err != nil {
 			s.error(w, r, http.StatusBadRequest, err)
 			return
 		}
+		// The user can ask for another email, failing to send this one does not
+		// fail the registration
+		s.queueVerificationEmail(user)
 
-		s.respond(w, r, http.StatusCreated, user)
+		s.respond(w, r, http.StatusCreated, newUserResponse(user))
 	}
 }
 
+// Browsers keep the refresh token in a cookie, native clients such as the
+// mobile and e-reader apps are handed it in the body of the response.
+const (
+	clientModeBrowser = "browser"
+	clientModeNative  = "native"
+)
+
+// nativeTokenResponse is the access token along with the refresh token, the
+// response of the login and refresh routes to native clients.
+type nativeTokenResponse struct {
+	*model.AuthToken
+	RefreshToken        string `json:"refresh_token"`
+	RefreshTokenExpires int64  `json:"refresh_token_exp"`
+}
+
 func (s *server) login() http.HandlerFunc {
 	type payload struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
+		Email      string         `json:"email"`
+		Password   string         `json:"password"`
+		RememberMe bool           `json:"remember_me"`
+		Audience   model.Audience `json:"audience"`
+		// Scope is space separated, every scope the user can obtain is
+		// granted when it is empty
+		Scope string `json:"scope"`
+		// ClientMode is either browser, the default, or native
+		ClientMode string `json:"client_mode"`
 	}
 	return func(w http.ResponseWriter, r *http.Request) {
 		payload := payload{}
@@ -73,6 +90,22 @@ func (s *server) login() http.HandlerFunc {
 			s.error(w, r, http.StatusBadRequest, err)
 			return
 		}
+		if payload.ClientMode != "" &&
+			payload.ClientMode != clientModeBrowser &&
+			payload.ClientMode != clientModeNative {
+			s.error(
+				w,
+				r,
+				http.StatusBadRequest,
+				fmt.Errorf("invalid client mode: %s", payload.ClientMode),
+			)
+			return
+		}
+		accessTokenParams, err := s.accessTokenParams(payload.Audience)
+		if err != nil {
+			s.error(w, r, http.StatusBadRequest, err)
+			return
+		}
 
 		user, err := s.store.User().GetByEmail(payload.Email)
 		if err != nil {
@@ -86,71 +119,233 @@ func (s *server) login() http.HandlerFunc {
 			s.error(w, r, http.StatusNotFound, err)
 			return
 		}
+		if !user.Active {
+			s.error(w, r, http.StatusNotFound, errors.New("user not found"))
+			return
+		}
 
-		at, err := model.NewAccessToken(user)
+		grants, err := s.userGrants(user.ID)
 		if err != nil {
 			s.error(w, r, http.StatusInternalServerError, err)
 			return
 		}
-		rt, err := model.NewRefreshToken(user)
+		scope, err := model.GrantScope(grants.Scopes(), payload.Scope)
 		if err != nil {
-			s.error(w, r, http.StatusInternalServerError, err)
+			s.error(w, r, http.StatusBadRequest, err)
 			return
 		}
+		accessTokenParams.Scope = scope
 
-		err = s.store.AuthToken().Insert(rt.Uuid, rt.TokenString, rt.Expires)
+		refreshTokenLifetime := s.config.RefreshTokenLifetime
+		if payload.RememberMe && s.config.RememberMeLifetime > 0 {
+			refreshTokenLifetime = s.config.RememberMeLifetime
+		}
+		refreshTokenParams := s.refreshTokenParams(refreshTokenLifetime)
+		refreshTokenParams.Scope = scope
+		rt, err := model.NewRefreshToken(user, s.refreshKeys.Active(), refreshTokenParams)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		s.setSessionMetadata(rt, r)
+		at, err := model.NewAccessToken(
+			user,
+			grants,
+			rt.Family,
+			s.accessKeys.Active(),
+			accessTokenParams,
+		)
 		if err != nil {
 			s.error(w, r, http.StatusInternalServerError, err)
 			return
 		}
 
-		http.SetCookie(w, &http.Cookie{
-			Name:     "refresh_token",
-			Value:    rt.TokenString,
-			Expires:  time.Unix(rt.Expires, 0),
-			HttpOnly: true,
-		})
+		err = s.insertRefreshToken(rt)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
 
-		s.respond(w, r, http.StatusOK, at)
+		s.respondTokens(w, r, at, rt, payload.ClientMode == clientModeNative)
 	}
 }
 
+// refreshAccessToken accepts an optional body requesting the audience and the
+// scope of the new access token. The scope is bounded by the scope granted at
+// login, which the rotated refresh token keeps. Native clients present their
+// refresh token in the body or the Authorization header and are handed the
+// rotated one in the response.
 func (s *server) refreshAccessToken() http.HandlerFunc {
+	type payload struct {
+		Audience     model.Audience `json:"audience"`
+		Scope        string         `json:"scope"`
+		RefreshToken string         `json:"refresh_token"`
+	}
 	return func(w http.ResponseWriter, r *http.Request) {
-		cookie, err := r.Cookie("refresh_token")
+		payload := payload{}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil && err != io.EOF {
+			s.error(w, r, http.StatusBadRequest, err)
+			return
+		}
+		accessTokenParams, err := s.accessTokenParams(payload.Audience)
+		if err != nil {
+			s.error(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		storedToken, claims, native, err := s.getPresentedRefreshToken(r, payload.RefreshToken)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		userId, err := claims.UserId()
 		if err != nil {
 			s.error(w, r, http.StatusInternalServerError, err)
 			return
 		}
-		claims, err := getRefreshTokenClaims(cookie.Value)
+		user, err := s.store.User().GetById(userId)
 		if err != nil {
 			s.error(w, r, http.StatusInternalServerError, err)
 			return
 		}
+		// The sessions of a deactivated user end with their next refresh
+		if !user.Active {
+			if err := s.store.AuthToken().DeleteFamily(storedToken.Family); err != nil {
+				s.error(w, r, http.StatusInternalServerError, err)
+				return
+			}
+			s.error(w, r, http.StatusUnauthorized, errors.New("user not found"))
+			return
+		}
+		grants, err := s.userGrants(user.ID)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		// The scope is checked before the token is consumed so that a bad
+		// request does not end the session
+		allowedScopes := grants.Scopes()
+		if claims.Scope != "" {
+			allowedScopes = model.FilterScopes(allowedScopes, claims.Scope)
+		}
+		scope, err := model.GrantScope(allowedScopes, payload.Scope)
+		if err != nil {
+			s.error(w, r, http.StatusBadRequest, err)
+			return
+		}
+		accessTokenParams.Scope = scope
 
-		refresh_uuid := fmt.Sprintf("%s", claims["refresh_uuid"])
-		_, err = s.store.AuthToken().GetById(refresh_uuid)
+		refreshToken, err := s.rotateRefreshToken(r, user, storedToken, claims)
+		if err == errRefreshTokenReused {
+			s.error(w, r, http.StatusUnauthorized, err)
+			return
+		}
 		if err != nil {
 			s.error(w, r, http.StatusInternalServerError, err)
 			return
 		}
-		user_id, err := strconv.ParseInt(fmt.Sprintf("%v", claims["user_id"]), 10, 64)
+		accessToken, err := model.NewAccessToken(
+			user,
+			grants,
+			storedToken.Family,
+			s.accessKeys.Active(),
+			accessTokenParams,
+		)
 		if err != nil {
 			s.error(w, r, http.StatusInternalServerError, err)
 			return
 		}
-		user, err := s.store.User().GetById(user_id)
+
+		s.respondTokens(w, r, accessToken, refreshToken, native)
+	}
+}
+
+// logout ends the session of the refresh token cookie. The whole token family
+// is deleted so that the consumed ancestors of the token go with it.
+func (s *server) logout() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		storedToken, _, _, err := s.getPresentedRefreshToken(r, "")
+		if err != nil {
+			s.error(w, r, http.StatusUnauthorized, err)
+			return
+		}
+
+		err = s.store.AuthToken().DeleteFamily(storedToken.Family)
 		if err != nil {
 			s.error(w, r, http.StatusInternalServerError, err)
 			return
 		}
 
-		accessToken, err := model.NewAccessToken(user)
+		s.clearRefreshTokenCookie(w)
+		s.respond(w, r, http.StatusOK, nil)
+	}
+}
+
+func (s *server) logoutAll() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		storedToken, _, _, err := s.getPresentedRefreshToken(r, "")
+		if err != nil {
+			s.error(w, r, http.StatusUnauthorized, err)
+			return
+		}
+
+		err = s.store.AuthToken().DeleteByUser(storedToken.UserId)
 		if err != nil {
 			s.error(w, r, http.StatusInternalServerError, err)
 			return
 		}
 
-		s.respond(w, r, http.StatusOK, accessToken)
+		s.clearRefreshTokenCookie(w)
+		s.respond(w, r, http.StatusOK, nil)
+	}
+}
+
+// respondTokens responds with the access token, the refresh token is set in
+// the cookie of browsers and added to the body for native clients.
+func (s *server) respondTokens(
+	w http.ResponseWriter,
+	r *http.Request,
+	at *model.AuthToken,
+	rt *model.AuthToken,
+	native bool,
+) {
+	if native {
+		s.respond(w, r, http.StatusOK, &nativeTokenResponse{
+			AuthToken:           at,
+			RefreshToken:        rt.TokenString,
+			RefreshTokenExpires: rt.Expires,
+		})
+		return
+	}
+
+	if err := s.setRefreshTokenCookie(w, rt); err != nil {
+		s.error(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	s.respond(w, r, http.StatusOK, at)
+}
+
+// hashPassword hashes the password with the bcrypt cost of BCRYPT_COST.
+func hashPassword(password string) (string, error) {
+	bcryptCost, err := strconv.Atoi(os.Getenv("BCRYPT_COST"))
+	if err != nil {
+		return "", err
 	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost)
+	if err != nil {
+		return "", err
+	}
+
+	return string(hash), nil
+}
+
+// refreshTokenLifetime is the lifetime granted to the session at login, so that
+// remembered sessions stay remembered when their refresh token is rotated.
+func (s *server) refreshTokenLifetime(claims *model.RefreshClaims) time.Duration {
+	lifetime := time.Duration(claims.ExpiresAt-claims.IssuedAt) * time.Second
+	if lifetime <= 0 || lifetime > s.config.maxRefreshTokenLifetime() {
+		return s.config.RefreshTokenLifetime
+	}
+
+	return lifetime
 }
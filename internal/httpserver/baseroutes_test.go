@@ -8,6 +8,7 @@ import (
 	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/anoobz/dualread/auth/internal/httpserver"
 	"github.com/anoobz/dualread/auth/internal/model"
@@ -89,11 +90,22 @@ func TestServer_Register(t *testing.T) {
 		)
 
 		if rec.Code == http.StatusCreated {
-			u := &model.User{}
+			assert.NotContains(
+				t, rec.Body.String(), `"password"`,
+				fmt.Sprintf("Case name: %s", tc.name),
+			)
+			u := &struct {
+				Id    int64  `json:"id"`
+				Email string `json:"email"`
+			}{}
 			if err := json.NewDecoder(rec.Body).Decode(&u); err != nil {
 				t.Fatal(err)
 			}
-			assert.NotEmpty(t, u.ID, fmt.Sprintf("Case name: %s", tc.name))
+			assert.NotEmpty(t, u.Id, fmt.Sprintf("Case name: %s", tc.name))
+			assert.Equal(
+				t, tc.payload["email"], u.Email,
+				fmt.Sprintf("Case name: %s", tc.name),
+			)
 		} else {
 			res := &struct {
 				Error string `json:"error"`
@@ -243,6 +255,29 @@ func TestServer_LoginError(t *testing.T) {
 	}
 }
 
+func TestServer_Login_InactiveUser(t *testing.T) {
+	s := httpserver.NewTestServer(t)
+
+	users := s.CreateTestUser(t, 1, false)
+	s.SetTestUserActive(t, users[0].ID, false)
+
+	payload := map[string]interface{}{
+		"email":    "test0@test.test",
+		"password": "test_password0",
+	}
+	req := s.CreateTestRequest(t, http.MethodPost, "/auth/login", payload)
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	res := struct {
+		ErrorMsg string `json:"error"`
+	}{}
+	json.NewDecoder(rec.Body).Decode(&res)
+	assert.Equal(t, "user not found", res.ErrorMsg)
+	assert.Empty(t, rec.Result().Cookies())
+}
+
 func TestServer_RefreshAccessToken(t *testing.T) {
 	s := httpserver.NewTestServer(t)
 
@@ -268,7 +303,7 @@ func TestServer_RefreshAccessToken(t *testing.T) {
 	// Append refresh token cookie from the response of the login request
 	for _, c := range rec.Result().Cookies() {
 		if c.Name == "refresh_token" {
-			req.AddCookie(c)
+			httpserver.AddTestSessionCookie(req, c)
 		}
 	}
 
@@ -304,7 +339,7 @@ func TestServer_RefreshAccessToken_RefreshTokenDoesNotExist(t *testing.T) {
 	// Append refresh token cookie from the response of the login request
 	for _, c := range rec.Result().Cookies() {
 		if c.Name == "refresh_token" {
-			req.AddCookie(c)
+			httpserver.AddTestSessionCookie(req, c)
 			// Delete refresh token from database to make the validation fail
 			s.DeleteTestRefreshToken(t, c)
 		}
@@ -342,7 +377,7 @@ func TestServer_RefreshAccessToken_RefreshTokenUserDoesNotExist(t *testing.T) {
 	// Append refresh token cookie from the response of the login request
 	for _, c := range rec.Result().Cookies() {
 		if c.Name == "refresh_token" {
-			req.AddCookie(c)
+			httpserver.AddTestSessionCookie(req, c)
 			// Delete refresh token from database to make the validation fail
 			s.DeleteTestRefreshTokenUser(t, c)
 		}
@@ -355,3 +390,473 @@ func TestServer_RefreshAccessToken_RefreshTokenUserDoesNotExist(t *testing.T) {
 	json.NewDecoder(rec.Body).Decode(&res)
 	assert.Equal(t, "sql: no rows in result set", res.ErrorMsg)
 }
+
+func getRefreshTokenCookie(t *testing.T, rec *httptest.ResponseRecorder) *http.Cookie {
+	t.Helper()
+
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == "refresh_token" {
+			return c
+		}
+	}
+
+	t.Fatal("refresh token cookie not set")
+	return nil
+}
+
+func TestServer_RefreshAccessToken_RotatesRefreshToken(t *testing.T) {
+	s := httpserver.NewTestServer(t)
+
+	s.CreateTestUser(t, 1, false)
+
+	payload := map[string]interface{}{
+		"email":    "test0@test.test",
+		"password": "test_password0",
+	}
+	req := s.CreateTestRequest(t, http.MethodPost, "/auth/login", payload)
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	cookie := getRefreshTokenCookie(t, rec)
+
+	// Every refresh hands out a new refresh token which is usable in turn
+	for i := 0; i < 3; i++ {
+		req = s.CreateTestRequest(t, http.MethodPost, "/auth/refresh-access-token", nil)
+		httpserver.AddTestSessionCookie(req, cookie)
+		rec = httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		nextCookie := getRefreshTokenCookie(t, rec)
+		assert.NotEqual(t, cookie.Value, nextCookie.Value)
+		cookie = nextCookie
+	}
+}
+
+func TestServer_RefreshAccessToken_ReuseRevokesFamily(t *testing.T) {
+	s := httpserver.NewTestServer(t)
+
+	s.CreateTestUser(t, 1, false)
+
+	payload := map[string]interface{}{
+		"email":    "test0@test.test",
+		"password": "test_password0",
+	}
+	req := s.CreateTestRequest(t, http.MethodPost, "/auth/login", payload)
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	stolenCookie := getRefreshTokenCookie(t, rec)
+
+	// A second, independent session must survive the revocation
+	req = s.CreateTestRequest(t, http.MethodPost, "/auth/login", payload)
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	otherSessionCookie := getRefreshTokenCookie(t, rec)
+
+	req = s.CreateTestRequest(t, http.MethodPost, "/auth/refresh-access-token", nil)
+	httpserver.AddTestSessionCookie(req, stolenCookie)
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	rotatedCookie := getRefreshTokenCookie(t, rec)
+
+	testCases := []struct {
+		name             string
+		cookie           *http.Cookie
+		expectedStatus   int
+		expectedErrorMsg string
+	}{
+		{
+			name:             "consumed token reused",
+			cookie:           stolenCookie,
+			expectedStatus:   http.StatusUnauthorized,
+			expectedErrorMsg: "refresh token reused",
+		},
+		{
+			name:             "rotated token revoked with its family",
+			cookie:           rotatedCookie,
+			expectedStatus:   http.StatusInternalServerError,
+			expectedErrorMsg: "sql: no rows in result set",
+		},
+		{
+			name:             "other session",
+			cookie:           otherSessionCookie,
+			expectedStatus:   http.StatusOK,
+			expectedErrorMsg: "",
+		},
+	}
+
+	for _, tc := range testCases {
+		req = s.CreateTestRequest(t, http.MethodPost, "/auth/refresh-access-token", nil)
+		httpserver.AddTestSessionCookie(req, tc.cookie)
+		rec = httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+
+		assert.Equal(t, tc.expectedStatus, rec.Code, tc.name)
+		res := struct {
+			ErrorMsg string `json:"error"`
+		}{}
+		json.NewDecoder(rec.Body).Decode(&res)
+		assert.Equal(t, tc.expectedErrorMsg, res.ErrorMsg, tc.name)
+	}
+}
+
+func TestServer_Logout(t *testing.T) {
+	s := httpserver.NewTestServer(t)
+
+	s.CreateTestUser(t, 1, false)
+	cookie := s.LoginTestSession(t, "test0@test.test", "test_password0")
+	otherCookie := s.LoginTestSession(t, "test0@test.test", "test_password0")
+
+	testCases := []struct {
+		name             string
+		cookie           *http.Cookie
+		expectedStatus   int
+		expectedErrorMsg string
+	}{
+		{
+			name:             "success",
+			cookie:           cookie,
+			expectedStatus:   http.StatusOK,
+			expectedErrorMsg: "",
+		},
+		{
+			name:             "already logged out",
+			cookie:           cookie,
+			expectedStatus:   http.StatusUnauthorized,
+			expectedErrorMsg: "sql: no rows in result set",
+		},
+		{
+			name:             "missing cookie",
+			cookie:           nil,
+			expectedStatus:   http.StatusUnauthorized,
+			expectedErrorMsg: "refresh token not presented",
+		},
+	}
+
+	for _, tc := range testCases {
+		req := s.CreateTestRequest(t, http.MethodPost, "/auth/logout", nil)
+		if tc.cookie != nil {
+			httpserver.AddTestSessionCookie(req, tc.cookie)
+		}
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+
+		assert.Equal(t, tc.expectedStatus, rec.Code, tc.name)
+		if tc.expectedErrorMsg == "" {
+			clearedCookie := getRefreshTokenCookie(t, rec)
+			assert.Empty(t, clearedCookie.Value, tc.name)
+			assert.True(t, clearedCookie.MaxAge < 0, tc.name)
+		} else {
+			res := struct {
+				ErrorMsg string `json:"error"`
+			}{}
+			json.NewDecoder(rec.Body).Decode(&res)
+			assert.Equal(t, tc.expectedErrorMsg, res.ErrorMsg, tc.name)
+		}
+	}
+
+	// Logging out ends only the current session
+	req := s.CreateTestRequest(t, http.MethodPost, "/auth/refresh-access-token", nil)
+	httpserver.AddTestSessionCookie(req, otherCookie)
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestServer_LogoutAll(t *testing.T) {
+	s := httpserver.NewTestServer(t)
+
+	s.CreateTestUser(t, 2, false)
+	cookies := []*http.Cookie{
+		s.LoginTestSession(t, "test0@test.test", "test_password0"),
+		s.LoginTestSession(t, "test0@test.test", "test_password0"),
+	}
+	otherUserCookie := s.LoginTestSession(t, "test1@test.test", "test_password1")
+
+	req := s.CreateTestRequest(t, http.MethodPost, "/auth/logout-all", nil)
+	httpserver.AddTestSessionCookie(req, cookies[0])
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, getRefreshTokenCookie(t, rec).Value)
+
+	testCases := []struct {
+		name           string
+		cookie         *http.Cookie
+		expectedStatus int
+	}{
+		{
+			name:           "current session",
+			cookie:         cookies[0],
+			expectedStatus: http.StatusInternalServerError,
+		},
+		{
+			name:           "other session of the same user",
+			cookie:         cookies[1],
+			expectedStatus: http.StatusInternalServerError,
+		},
+		{
+			name:           "session of another user",
+			cookie:         otherUserCookie,
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	for _, tc := range testCases {
+		req := s.CreateTestRequest(t, http.MethodPost, "/auth/refresh-access-token", nil)
+		httpserver.AddTestSessionCookie(req, tc.cookie)
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+
+		assert.Equal(t, tc.expectedStatus, rec.Code, tc.name)
+	}
+}
+
+func TestServer_Login_RememberMe(t *testing.T) {
+	s := httpserver.NewTestServer(t)
+	config := httpserver.NewDefaultConfig()
+
+	s.CreateTestUser(t, 1, false)
+
+	testCases := []struct {
+		name             string
+		rememberMe       bool
+		expectedLifetime time.Duration
+	}{
+		{
+			name:             "default session",
+			rememberMe:       false,
+			expectedLifetime: config.RefreshTokenLifetime,
+		},
+		{
+			name:             "remembered session",
+			rememberMe:       true,
+			expectedLifetime: config.RememberMeLifetime,
+		},
+	}
+
+	for _, tc := range testCases {
+		payload := map[string]interface{}{
+			"email":       "test0@test.test",
+			"password":    "test_password0",
+			"remember_me": tc.rememberMe,
+		}
+		rec := httptest.NewRecorder()
+		req := s.CreateTestRequest(t, http.MethodPost, "/auth/login", payload)
+		s.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code, tc.name)
+
+		res := &model.AuthToken{}
+		json.NewDecoder(rec.Body).Decode(&res)
+		assert.WithinDuration(
+			t,
+			time.Now().Add(config.AccessTokenLifetime),
+			time.Unix(res.Expires, 0),
+			5*time.Second,
+			tc.name,
+		)
+
+		cookie := getRefreshTokenCookie(t, rec)
+		assert.WithinDuration(
+			t,
+			time.Now().Add(tc.expectedLifetime),
+			cookie.Expires,
+			5*time.Second,
+			tc.name,
+		)
+
+		// The rotated refresh token keeps the lifetime granted at login
+		rec = httptest.NewRecorder()
+		req = s.CreateTestRequest(t, http.MethodPost, "/auth/refresh-access-token", nil)
+		httpserver.AddTestSessionCookie(req, cookie)
+		s.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code, tc.name)
+		assert.WithinDuration(
+			t,
+			time.Now().Add(tc.expectedLifetime),
+			getRefreshTokenCookie(t, rec).Expires,
+			5*time.Second,
+			tc.name,
+		)
+	}
+}
+
+type nativeTokenResponse struct {
+	TokenString         string `json:"token"`
+	Expires             int64  `json:"exp"`
+	RefreshToken        string `json:"refresh_token"`
+	RefreshTokenExpires int64  `json:"refresh_token_exp"`
+}
+
+func TestServer_Login_NativeClient(t *testing.T) {
+	s := httpserver.NewTestServer(t)
+
+	s.CreateTestUser(t, 1, false)
+
+	testCases := []struct {
+		name             string
+		clientMode       string
+		expectedStatus   int
+		expectedErrorMsg string
+		expectedCookie   bool
+	}{
+		{
+			name:           "native client",
+			clientMode:     "native",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "browser client",
+			clientMode:     "browser",
+			expectedStatus: http.StatusOK,
+			expectedCookie: true,
+		},
+		{
+			name:             "unknown client mode",
+			clientMode:       "tv",
+			expectedStatus:   http.StatusBadRequest,
+			expectedErrorMsg: "invalid client mode: tv",
+		},
+	}
+
+	for _, tc := range testCases {
+		rec := httptest.NewRecorder()
+		req := s.CreateTestRequest(
+			t, http.MethodPost, "/auth/login",
+			map[string]interface{}{
+				"email":       "test0@test.test",
+				"password":    "test_password0",
+				"client_mode": tc.clientMode,
+			},
+		)
+		s.ServeHTTP(rec, req)
+
+		assert.Equal(t, tc.expectedStatus, rec.Code, tc.name)
+		assert.Equal(t, tc.expectedCookie, len(rec.Result().Cookies()) > 0, tc.name)
+		if tc.expectedErrorMsg != "" {
+			res := struct {
+				ErrorMsg string `json:"error"`
+			}{}
+			json.NewDecoder(rec.Body).Decode(&res)
+			assert.Equal(t, tc.expectedErrorMsg, res.ErrorMsg, tc.name)
+			continue
+		}
+
+		res := &nativeTokenResponse{}
+		json.NewDecoder(rec.Body).Decode(&res)
+		assert.NotEmpty(t, res.TokenString, tc.name)
+		assert.Equal(t, !tc.expectedCookie, res.RefreshToken != "", tc.name)
+		assert.Equal(t, !tc.expectedCookie, res.RefreshTokenExpires != 0, tc.name)
+	}
+}
+
+func TestServer_RefreshAccessToken_NativeClient(t *testing.T) {
+	s := httpserver.NewTestServer(t)
+
+	s.CreateTestUser(t, 1, false)
+	rec := httptest.NewRecorder()
+	req := s.CreateTestRequest(
+		t, http.MethodPost, "/auth/login",
+		map[string]interface{}{
+			"email":       "test0@test.test",
+			"password":    "test_password0",
+			"client_mode": "native",
+		},
+	)
+	s.ServeHTTP(rec, req)
+	login := &nativeTokenResponse{}
+	json.NewDecoder(rec.Body).Decode(&login)
+
+	// The refresh token is presented in the body
+	rec = httptest.NewRecorder()
+	req = s.CreateTestRequest(
+		t, http.MethodPost, "/auth/refresh-access-token",
+		map[string]interface{}{"refresh_token": login.RefreshToken},
+	)
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Result().Cookies())
+	bodyRefresh := &nativeTokenResponse{}
+	json.NewDecoder(rec.Body).Decode(&bodyRefresh)
+	assert.NotEmpty(t, bodyRefresh.TokenString)
+	assert.NotEmpty(t, bodyRefresh.RefreshToken)
+	assert.NotEqual(t, login.RefreshToken, bodyRefresh.RefreshToken)
+
+	// Or in the Authorization header
+	rec = httptest.NewRecorder()
+	req = s.CreateTestRequest(t, http.MethodPost, "/auth/refresh-access-token", nil)
+	req.Header.Add("Authorization", "Bearer "+bodyRefresh.RefreshToken)
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	headerRefresh := &nativeTokenResponse{}
+	json.NewDecoder(rec.Body).Decode(&headerRefresh)
+	assert.NotEmpty(t, headerRefresh.RefreshToken)
+
+	// Browsers sending their access token along with the cookie keep the
+	// cookie flow
+	cookie := s.LoginTestSession(t, "test0@test.test", "test_password0")
+	rec = httptest.NewRecorder()
+	req = s.CreateTestRequest(t, http.MethodPost, "/auth/refresh-access-token", nil)
+	httpserver.AddTestSessionCookie(req, cookie)
+	req.Header.Add("Authorization", "Bearer "+headerRefresh.TokenString)
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotEmpty(t, getRefreshTokenCookie(t, rec).Value)
+
+	// Native clients log out with the header
+	rec = httptest.NewRecorder()
+	req = s.CreateTestRequest(t, http.MethodPost, "/auth/logout", nil)
+	req.Header.Add("Authorization", "Bearer "+headerRefresh.RefreshToken)
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = httptest.NewRecorder()
+	req = s.CreateTestRequest(
+		t, http.MethodPost, "/auth/refresh-access-token",
+		map[string]interface{}{"refresh_token": headerRefresh.RefreshToken},
+	)
+	s.ServeHTTP(rec, req)
+	assert.NotEqual(t, http.StatusOK, rec.Code)
+}
+
+func TestServer_RefreshAccessToken_InactiveUser(t *testing.T) {
+	s := httpserver.NewTestServer(t)
+
+	users := s.CreateTestUser(t, 1, false)
+
+	payload := map[string]interface{}{
+		"email":    "test0@test.test",
+		"password": "test_password0",
+	}
+	req := s.CreateTestRequest(t, http.MethodPost, "/auth/login", payload)
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	cookie := getRefreshTokenCookie(t, rec)
+
+	s.SetTestUserActive(t, users[0].ID, false)
+
+	req = s.CreateTestRequest(t, http.MethodPost, "/auth/refresh-access-token", nil)
+	httpserver.AddTestSessionCookie(req, cookie)
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	res := struct {
+		ErrorMsg string `json:"error"`
+	}{}
+	json.NewDecoder(rec.Body).Decode(&res)
+	assert.Equal(t, "user not found", res.ErrorMsg)
+
+	// The session is gone even once the user is reactivated
+	s.SetTestUserActive(t, users[0].ID, true)
+
+	req = s.CreateTestRequest(t, http.MethodPost, "/auth/refresh-access-token", nil)
+	httpserver.AddTestSessionCookie(req, cookie)
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	json.NewDecoder(rec.Body).Decode(&res)
+	assert.Equal(t, "sql: no rows in result set", res.ErrorMsg)
+}
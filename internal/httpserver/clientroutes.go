@@ -0,0 +1,186 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/anoobz/dualread/auth/internal/model"
+	"github.com/gorilla/mux"
+)
+
+// clientResponse describes a registered client. The secret of a confidential
+// client is only returned when it is generated, afterwards only its hash is
+// kept.
+type clientResponse struct {
+	Id           string   `json:"id"`
+	Name         string   `json:"name"`
+	RedirectUris []string `json:"redirect_uris"`
+	Scopes       []string `json:"scopes"`
+	Confidential bool     `json:"confidential"`
+	Created      int64    `json:"created"`
+	Secret       string   `json:"client_secret,omitempty"`
+}
+
+func newClientResponse(c *model.Client, secret string) *clientResponse {
+	return &clientResponse{
+		Id:           c.Id,
+		Name:         c.Name,
+		RedirectUris: c.RedirectUris,
+		Scopes:       c.Scopes,
+		Confidential: c.Confidential(),
+		Created:      c.Created,
+		Secret:       secret,
+	}
+}
+
+func (s *server) getClient() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		c, err := s.store.Client().GetById(mux.Vars(r)["id"])
+		if err != nil {
+			s.error(w, r, http.StatusNotFound, errors.New("client not found"))
+			return
+		}
+
+		s.respond(w, r, http.StatusOK, newClientResponse(c, ""))
+	}
+}
+
+func (s *server) getAllClients() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		clients, err := s.store.Client().GetAll()
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		res := []*clientResponse{}
+		for _, c := range clients {
+			res = append(res, newClientResponse(c, ""))
+		}
+
+		s.respond(w, r, http.StatusOK, res)
+	}
+}
+
+func (s *server) insertClient() http.HandlerFunc {
+	type payload struct {
+		Name         string   `json:"name"`
+		RedirectUris []string `json:"redirect_uris"`
+		Scopes       []string `json:"scopes"`
+		Confidential bool     `json:"confidential"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		p := &payload{}
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			s.error(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		c, secret, err := model.NewClient(
+			p.Name,
+			p.RedirectUris,
+			p.Scopes,
+			p.Confidential,
+			time.Now(),
+		)
+		if err != nil {
+			s.error(w, r, http.StatusBadRequest, err)
+			return
+		}
+		if err := s.store.Client().Insert(c); err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		s.respond(w, r, http.StatusCreated, newClientResponse(c, secret))
+	}
+}
+
+// updateClient only changes the fields present in the payload. Whether a
+// client is confidential cannot be changed, a new client must be registered.
+func (s *server) updateClient() http.HandlerFunc {
+	type payload struct {
+		Name         *string   `json:"name"`
+		RedirectUris *[]string `json:"redirect_uris"`
+		Scopes       *[]string `json:"scopes"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		c, err := s.store.Client().GetById(mux.Vars(r)["id"])
+		if err != nil {
+			s.error(w, r, http.StatusNotFound, errors.New("client not found"))
+			return
+		}
+
+		p := &payload{}
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			s.error(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		updatedClient := *c
+		if p.Name != nil {
+			updatedClient.Name = *p.Name
+		}
+		if p.RedirectUris != nil {
+			updatedClient.RedirectUris = append([]string{}, *p.RedirectUris...)
+		}
+		if p.Scopes != nil {
+			updatedClient.Scopes = append([]string{}, *p.Scopes...)
+		}
+		if err := updatedClient.Validate(); err != nil {
+			s.error(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		if err := s.store.Client().Update(&updatedClient); err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		s.respond(w, r, http.StatusOK, newClientResponse(&updatedClient, ""))
+	}
+}
+
+// rotateClientSecret replaces the secret of a confidential client, the tokens
+// already issued to the client stay valid until they expire.
+func (s *server) rotateClientSecret() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		c, err := s.store.Client().GetById(mux.Vars(r)["id"])
+		if err != nil {
+			s.error(w, r, http.StatusNotFound, errors.New("client not found"))
+			return
+		}
+		if !c.Confidential() {
+			s.error(w, r, http.StatusBadRequest, errors.New("public clients have no secret"))
+			return
+		}
+
+		updatedClient := *c
+		secret, err := updatedClient.RotateSecret()
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		if err := s.store.Client().Update(&updatedClient); err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		s.respond(w, r, http.StatusOK, newClientResponse(&updatedClient, secret))
+	}
+}
+
+// deleteClient also deletes the pending authorization codes of the client,
+// the sessions it opened for users are left to the users.
+func (s *server) deleteClient() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := s.store.Client().Delete(mux.Vars(r)["id"]); err != nil {
+			s.error(w, r, http.StatusNotFound, errors.New("client not found"))
+			return
+		}
+
+		s.respond(w, r, http.StatusOK, nil)
+	}
+}
@@ -0,0 +1,273 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func requestTestClientRoute(
+	t *testing.T,
+	s *server,
+	method string,
+	url string,
+	accessToken string,
+	payload map[string]interface{},
+) (*httptest.ResponseRecorder, *clientResponse, string) {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	req := s.CreateTestRequest(t, method, url, payload)
+	req.Header.Add("Authorization", "Bearer "+accessToken)
+	s.ServeHTTP(rec, req)
+
+	res := struct {
+		clientResponse
+		ErrorMsg string `json:"error"`
+	}{}
+	json.NewDecoder(rec.Body).Decode(&res)
+	return rec, &res.clientResponse, res.ErrorMsg
+}
+
+func TestServer_InsertClient(t *testing.T) {
+	s := NewTestServer(t)
+
+	s.CreateTestUser(t, 1, true)
+	s.CreateTestUser(t, 1, false)
+	adminToken := s.LoginTestUser(t, "test0@test.test", "test_password0")
+	userToken := s.LoginTestUser(t, "test1@test.test", "test_password1")
+
+	testCases := []struct {
+		name                 string
+		accessToken          string
+		payload              map[string]interface{}
+		expectedStatus       int
+		expectedConfidential bool
+		expectedErrorMsg     string
+	}{
+		{
+			name:        "public client",
+			accessToken: adminToken,
+			payload: map[string]interface{}{
+				"name":          "extension",
+				"redirect_uris": []string{"https://extension.test/callback"},
+			},
+			expectedStatus:       http.StatusCreated,
+			expectedConfidential: false,
+		},
+		{
+			name:        "confidential client",
+			accessToken: adminToken,
+			payload: map[string]interface{}{
+				"name":         "translation",
+				"scopes":       []string{"tts"},
+				"confidential": true,
+			},
+			expectedStatus:       http.StatusCreated,
+			expectedConfidential: true,
+		},
+		{
+			name:        "public client without redirect uri",
+			accessToken: adminToken,
+			payload: map[string]interface{}{
+				"name": "extension",
+			},
+			expectedStatus:   http.StatusBadRequest,
+			expectedErrorMsg: "a public client needs at least one redirect uri",
+		},
+		{
+			name:        "non-admin",
+			accessToken: userToken,
+			payload: map[string]interface{}{
+				"name":          "extension",
+				"redirect_uris": []string{"https://extension.test/callback"},
+			},
+			expectedStatus:   http.StatusUnauthorized,
+			expectedErrorMsg: "unauthorized",
+		},
+	}
+
+	for _, tc := range testCases {
+		rec, res, errorMsg := requestTestClientRoute(
+			t, s,
+			http.MethodPost, "/auth/admin/client",
+			tc.accessToken, tc.payload,
+		)
+		assert.Equal(t, tc.expectedStatus, rec.Code, tc.name)
+		assert.Equal(t, tc.expectedErrorMsg, errorMsg, tc.name)
+		if tc.expectedStatus != http.StatusCreated {
+			continue
+		}
+
+		assert.Equal(t, tc.expectedConfidential, res.Confidential, tc.name)
+		assert.Equal(t, tc.expectedConfidential, res.Secret != "", tc.name)
+		client, err := s.store.Client().GetById(res.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, tc.payload["name"], client.Name, tc.name)
+		if tc.expectedConfidential {
+			assert.True(t, client.VerifySecret(res.Secret), tc.name)
+		}
+	}
+}
+
+func TestServer_GetClient(t *testing.T) {
+	s := NewTestServer(t)
+
+	s.CreateTestUser(t, 1, true)
+	adminToken := s.LoginTestUser(t, "test0@test.test", "test_password0")
+	clients := s.CreateTestClient(t, 2)
+
+	rec, res, _ := requestTestClientRoute(
+		t, s,
+		http.MethodGet, "/auth/admin/client/"+clients[1].Id,
+		adminToken, nil,
+	)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, newClientResponse(clients[1], ""), res)
+
+	rec, _, errorMsg := requestTestClientRoute(
+		t, s,
+		http.MethodGet, "/auth/admin/client/unknown",
+		adminToken, nil,
+	)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, "client not found", errorMsg)
+
+	rec = httptest.NewRecorder()
+	req := s.CreateTestRequest(t, http.MethodGet, "/auth/admin/client", nil)
+	req.Header.Add("Authorization", "Bearer "+adminToken)
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	allRes := []*clientResponse{}
+	json.NewDecoder(rec.Body).Decode(&allRes)
+	assert.Equal(
+		t,
+		[]*clientResponse{newClientResponse(clients[0], ""), newClientResponse(clients[1], "")},
+		allRes,
+	)
+}
+
+func TestServer_UpdateClient(t *testing.T) {
+	s := NewTestServer(t)
+
+	s.CreateTestUser(t, 1, true)
+	adminToken := s.LoginTestUser(t, "test0@test.test", "test_password0")
+	client := s.CreateTestClient(t, 1)[0]
+
+	testCases := []struct {
+		name             string
+		clientId         string
+		payload          map[string]interface{}
+		expectedStatus   int
+		expectedErrorMsg string
+	}{
+		{
+			name:     "success",
+			clientId: client.Id,
+			payload: map[string]interface{}{
+				"redirect_uris": []string{"https://updated.test/callback"},
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:     "invalid redirect uri",
+			clientId: client.Id,
+			payload: map[string]interface{}{
+				"redirect_uris": []string{"/callback"},
+			},
+			expectedStatus:   http.StatusBadRequest,
+			expectedErrorMsg: "invalid redirect uri: /callback",
+		},
+		{
+			name:             "client not found",
+			clientId:         "unknown",
+			payload:          map[string]interface{}{"name": "updated"},
+			expectedStatus:   http.StatusNotFound,
+			expectedErrorMsg: "client not found",
+		},
+	}
+
+	for _, tc := range testCases {
+		rec, _, errorMsg := requestTestClientRoute(
+			t, s,
+			http.MethodPost, "/auth/admin/client/"+tc.clientId,
+			adminToken, tc.payload,
+		)
+		assert.Equal(t, tc.expectedStatus, rec.Code, tc.name)
+		assert.Equal(t, tc.expectedErrorMsg, errorMsg, tc.name)
+	}
+
+	updatedClient, err := s.store.Client().GetById(client.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, client.Name, updatedClient.Name)
+	assert.Equal(t, []string{"https://updated.test/callback"}, updatedClient.RedirectUris)
+}
+
+func TestServer_RotateClientSecret(t *testing.T) {
+	s := NewTestServer(t)
+
+	s.CreateTestUser(t, 1, true)
+	adminToken := s.LoginTestUser(t, "test0@test.test", "test_password0")
+	publicClient := s.CreateTestClient(t, 1)[0]
+	client, secret := s.CreateTestServiceClient(t, []string{})
+
+	rec, res, _ := requestTestClientRoute(
+		t, s,
+		http.MethodPost, "/auth/admin/client/"+client.Id+"/secret",
+		adminToken, nil,
+	)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotEmpty(t, res.Secret)
+
+	rotatedClient, err := s.store.Client().GetById(client.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, rotatedClient.VerifySecret(res.Secret))
+	assert.False(t, rotatedClient.VerifySecret(secret))
+
+	rec, _, errorMsg := requestTestClientRoute(
+		t, s,
+		http.MethodPost, "/auth/admin/client/"+publicClient.Id+"/secret",
+		adminToken, nil,
+	)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "public clients have no secret", errorMsg)
+}
+
+func TestServer_DeleteClient(t *testing.T) {
+	s := NewTestServer(t)
+
+	s.CreateTestUser(t, 1, true)
+	adminToken := s.LoginTestUser(t, "test0@test.test", "test_password0")
+	clients := s.CreateTestClient(t, 2)
+
+	rec, _, _ := requestTestClientRoute(
+		t, s,
+		http.MethodDelete, "/auth/admin/client/"+clients[0].Id,
+		adminToken, nil,
+	)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	remainingClients, err := s.store.Client().GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, clients[1:], remainingClients)
+
+	rec, _, errorMsg := requestTestClientRoute(
+		t, s,
+		http.MethodDelete, "/auth/admin/client/"+clients[0].Id,
+		adminToken, nil,
+	)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, "client not found", errorMsg)
+}
@@ -0,0 +1,304 @@
+package httpserver
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anoobz/dualread/auth/internal/model"
+)
+
+// Config holds the settings of the server that are read from the environment.
+type Config struct {
+	// Issuer is the iss claim of the tokens issued by the service. It is the
+	// https url the OpenID Connect discovery document is served under.
+	Issuer string
+	// Audience identifies the service itself, its routes only accept access
+	// tokens intended for it.
+	Audience string
+	// Audiences are the other services clients can request tokens for.
+	Audiences []string
+	// PublicUrl is the address the service is reached at by its clients, used
+	// in the discovery document.
+	PublicUrl string
+	// LoginUrl is the page of the web app users without a session are sent to
+	// by the authorize endpoint, with the authorize url in its return_to
+	// parameter.
+	LoginUrl             string
+	AccessTokenLifetime  time.Duration
+	RefreshTokenLifetime time.Duration
+	// RememberMeLifetime replaces RefreshTokenLifetime for the sessions of
+	// users asking to be remembered at login, zero disables the option.
+	RememberMeLifetime time.Duration
+	// RefreshTokenHashKey keys the hash under which refresh tokens and
+	// authorization codes are persisted, changing it ends every session.
+	RefreshTokenHashKey []byte
+	// TrustProxyHeaders takes the client address of sessions from the
+	// X-Forwarded-For header set by the reverse proxy.
+	TrustProxyHeaders bool
+	// EmailVerificationUrl is the page of the web app the verification links
+	// lead to, with the token in its token parameter.
+	EmailVerificationUrl      string
+	EmailVerificationLifetime time.Duration
+	// PasswordResetUrl is the page of the web app the password reset links
+	// lead to, with the token in its token parameter.
+	PasswordResetUrl      string
+	PasswordResetLifetime time.Duration
+	// JanitorInterval is the period at which expired rows are purged.
+	JanitorInterval time.Duration
+	// KeyRotationInterval is the age at which the active signing keys are
+	// replaced, zero disables the rotation.
+	KeyRotationInterval time.Duration
+	// AccessKeyAlg is the algorithm of the access token keys, the keys
+	// produced by rotation use it. Access and id tokens are verified with the
+	// published public keys, so the algorithm is asymmetric.
+	AccessKeyAlg string
+	// CookiePath scopes the refresh token cookie to the routes reading it, the
+	// refresh, logout and authorize routes all live under /auth.
+	CookiePath string
+	// CookieDomain is left empty to send the cookies to the service host only.
+	CookieDomain   string
+	CookieSecure   bool
+	CookieSameSite http.SameSite
+	// ServiceCredentials maps the id of the back-end services allowed to
+	// introspect tokens to their secret. The id of a service is its audience.
+	ServiceCredentials map[string]string
+}
+
+func NewDefaultConfig() *Config {
+	return &Config{
+		Audience:                  "dualread-auth",
+		Audiences:                 []string{},
+		AccessTokenLifetime:       15 * time.Minute,
+		RefreshTokenLifetime:      7 * 24 * time.Hour,
+		RememberMeLifetime:        30 * 24 * time.Hour,
+		JanitorInterval:           time.Hour,
+		EmailVerificationLifetime: 24 * time.Hour,
+		PasswordResetLifetime:     time.Hour,
+		CookiePath:                "/auth",
+		CookieSecure:              true,
+		CookieSameSite:            http.SameSiteLaxMode,
+		ServiceCredentials:        map[string]string{},
+		AccessKeyAlg:              "RS256",
+	}
+}
+
+// LoadConfig reads the configuration from the environment, unset variables
+// keep their default value.
+func LoadConfig() (*Config, error) {
+	config := NewDefaultConfig()
+
+	config.Issuer = os.Getenv("JWT_ISSUER")
+	if !validIssuer(config.Issuer) {
+		return nil, fmt.Errorf("invalid JWT_ISSUER: %q is not an https url", config.Issuer)
+	}
+	if audience := os.Getenv("JWT_AUDIENCE"); audience != "" {
+		config.Audience = audience
+	}
+	config.PublicUrl = os.Getenv("PUBLIC_URL")
+	config.LoginUrl = os.Getenv("LOGIN_URL")
+	config.EmailVerificationUrl = os.Getenv("EMAIL_VERIFICATION_URL")
+	if config.EmailVerificationUrl == "" {
+		config.EmailVerificationUrl = config.PublicUrl + "/verify-email"
+	}
+	config.PasswordResetUrl = os.Getenv("PASSWORD_RESET_URL")
+	if config.PasswordResetUrl == "" {
+		config.PasswordResetUrl = config.PublicUrl + "/reset-password"
+	}
+	for _, audience := range strings.Split(os.Getenv("JWT_AUDIENCES"), ",") {
+		if audience = strings.TrimSpace(audience); audience != "" {
+			config.Audiences = append(config.Audiences, audience)
+		}
+	}
+
+	durations := map[string]*time.Duration{
+		"JWT_ACCESS_TOKEN_LIFETIME":   &config.AccessTokenLifetime,
+		"JWT_REFRESH_TOKEN_LIFETIME":  &config.RefreshTokenLifetime,
+		"JWT_REMEMBER_ME_LIFETIME":    &config.RememberMeLifetime,
+		"JANITOR_INTERVAL":            &config.JanitorInterval,
+		"EMAIL_VERIFICATION_LIFETIME": &config.EmailVerificationLifetime,
+		"PASSWORD_RESET_LIFETIME":     &config.PasswordResetLifetime,
+		"JWT_KEY_ROTATION_INTERVAL":   &config.KeyRotationInterval,
+	}
+	for name, duration := range durations {
+		value := os.Getenv(name)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", name, err)
+		}
+		*duration = parsed
+	}
+	if config.AccessTokenLifetime <= 0 ||
+		config.RefreshTokenLifetime <= 0 ||
+		config.EmailVerificationLifetime <= 0 ||
+		config.PasswordResetLifetime <= 0 {
+		return nil, errors.New("token lifetimes must be positive")
+	}
+	if config.JanitorInterval <= 0 {
+		return nil, errors.New("JANITOR_INTERVAL must be positive")
+	}
+	if config.KeyRotationInterval < 0 {
+		return nil, errors.New("JWT_KEY_ROTATION_INTERVAL must not be negative")
+	}
+
+	if alg := os.Getenv("JWT_ACCESS_ALG"); alg != "" {
+		if !model.Contains(signingAlgs, alg) {
+			return nil, fmt.Errorf("invalid JWT_ACCESS_ALG: %q", alg)
+		}
+		config.AccessKeyAlg = alg
+	}
+
+	config.RefreshTokenHashKey = []byte(os.Getenv("REFRESH_TOKEN_HASH_KEY"))
+	if len(config.RefreshTokenHashKey) == 0 {
+		return nil, errors.New("REFRESH_TOKEN_HASH_KEY is not set")
+	}
+
+	if value := os.Getenv("TRUST_PROXY_HEADERS"); value != "" {
+		trust, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TRUST_PROXY_HEADERS: %v", err)
+		}
+		config.TrustProxyHeaders = trust
+	}
+
+	if err := config.loadCookieConfig(); err != nil {
+		return nil, err
+	}
+
+	credentials, err := parseServiceCredentials(os.Getenv("SERVICE_CREDENTIALS"))
+	if err != nil {
+		return nil, err
+	}
+	config.ServiceCredentials = credentials
+
+	return config, nil
+}
+
+// signingAlgs are the values of JWT_ACCESS_ALG.
+var signingAlgs = []string{"RS256", "ES256", "EdDSA"}
+
+// validIssuer reports whether the issuer is an https url without path, query
+// nor fragment, since the discovery document is served at the root of the
+// host.
+func validIssuer(issuer string) bool {
+	u, err := url.Parse(issuer)
+	return err == nil &&
+		u.Scheme == "https" &&
+		u.Host != "" &&
+		u.Path == "" &&
+		u.RawQuery == "" &&
+		!u.ForceQuery &&
+		u.Fragment == ""
+}
+
+// sameSiteModes are the values of COOKIE_SAMESITE. Lax is the default as the
+// authorize route is reached by navigating from the sites of the clients.
+var sameSiteModes = map[string]http.SameSite{
+	"lax":    http.SameSiteLaxMode,
+	"strict": http.SameSiteStrictMode,
+	"none":   http.SameSiteNoneMode,
+}
+
+func (c *Config) loadCookieConfig() error {
+	if path := os.Getenv("COOKIE_PATH"); path != "" {
+		if !strings.HasPrefix(path, "/") {
+			return fmt.Errorf("invalid COOKIE_PATH: %q", path)
+		}
+		c.CookiePath = path
+	}
+	c.CookieDomain = os.Getenv("COOKIE_DOMAIN")
+
+	if value := os.Getenv("COOKIE_SECURE"); value != "" {
+		secure, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid COOKIE_SECURE: %v", err)
+		}
+		c.CookieSecure = secure
+	}
+
+	if value := os.Getenv("COOKIE_SAMESITE"); value != "" {
+		sameSite, ok := sameSiteModes[strings.ToLower(value)]
+		if !ok {
+			return fmt.Errorf("invalid COOKIE_SAMESITE: %q", value)
+		}
+		c.CookieSameSite = sameSite
+	}
+	// Browsers drop SameSite=None cookies that are not secure
+	if c.CookieSameSite == http.SameSiteNoneMode && !c.CookieSecure {
+		return errors.New("COOKIE_SAMESITE=none requires COOKIE_SECURE")
+	}
+
+	return nil
+}
+
+// parseServiceCredentials reads SERVICE_CREDENTIALS, comma separated id:secret
+// pairs.
+func parseServiceCredentials(value string) (map[string]string, error) {
+	credentials := map[string]string{}
+	for _, pair := range strings.Split(value, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, ":", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, errors.New("invalid SERVICE_CREDENTIALS: expected id:secret pairs")
+		}
+		if _, found := credentials[parts[0]]; found {
+			return nil, fmt.Errorf("invalid SERVICE_CREDENTIALS: duplicate id %q", parts[0])
+		}
+		credentials[parts[0]] = parts[1]
+	}
+
+	return credentials, nil
+}
+
+// validAudience reports whether tokens can be issued for the audience.
+func (c *Config) validAudience(audience string) bool {
+	if audience == c.Audience {
+		return true
+	}
+	for _, registered := range c.Audiences {
+		if audience == registered {
+			return true
+		}
+	}
+
+	return false
+}
+
+// signingAlg is the algorithm of the keys of the purpose, refresh tokens are
+// only verified by the service itself and stay signed with a shared secret.
+func (c *Config) signingAlg(purpose string) string {
+	if purpose == model.RefreshKeyPurpose {
+		return "HS256"
+	}
+
+	return c.AccessKeyAlg
+}
+
+// TokenLifetime is the longest lifetime of the tokens signed by the keys of
+// the purpose, retired keys are kept that long to verify them.
+func (c *Config) TokenLifetime(purpose string) time.Duration {
+	if purpose == model.RefreshKeyPurpose {
+		return c.maxRefreshTokenLifetime()
+	}
+
+	return c.AccessTokenLifetime
+}
+
+// maxRefreshTokenLifetime is the longest time a refresh token can be valid for.
+func (c *Config) maxRefreshTokenLifetime() time.Duration {
+	if c.RememberMeLifetime > c.RefreshTokenLifetime {
+		return c.RememberMeLifetime
+	}
+
+	return c.RefreshTokenLifetime
+}
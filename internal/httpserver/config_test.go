@@ -0,0 +1,109 @@
+package httpserver
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/anoobz/dualread/auth/internal/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadConfig(t *testing.T) {
+	t.Setenv("REFRESH_TOKEN_HASH_KEY", "hash_key")
+	t.Setenv("JWT_ACCESS_TOKEN_LIFETIME", "5m")
+	t.Setenv("JWT_REFRESH_TOKEN_LIFETIME", "")
+	t.Setenv("JWT_REMEMBER_ME_LIFETIME", "0")
+	t.Setenv("JANITOR_INTERVAL", "10m")
+	t.Setenv("JWT_KEY_ROTATION_INTERVAL", "720h")
+	t.Setenv("JWT_ACCESS_ALG", "ES256")
+	t.Setenv("JWT_ISSUER", "https://auth.dualread.test")
+	t.Setenv("JWT_AUDIENCES", "reader, billing,")
+	t.Setenv("PUBLIC_URL", "https://dualread.test")
+	t.Setenv("EMAIL_VERIFICATION_LIFETIME", "1h")
+	t.Setenv("COOKIE_DOMAIN", "dualread.test")
+	t.Setenv("COOKIE_SECURE", "false")
+	t.Setenv("COOKIE_SAMESITE", "Strict")
+	t.Setenv("SERVICE_CREDENTIALS", "reader:reader_secret, billing:billing:secret,")
+
+	config, err := LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 5*time.Minute, config.AccessTokenLifetime)
+	assert.Equal(t, NewDefaultConfig().RefreshTokenLifetime, config.RefreshTokenLifetime)
+	assert.Zero(t, config.RememberMeLifetime)
+	assert.Equal(t, 10*time.Minute, config.JanitorInterval)
+	assert.Equal(t, 720*time.Hour, config.KeyRotationInterval)
+	assert.Equal(t, "ES256", config.signingAlg(model.AccessKeyPurpose))
+	assert.Equal(t, "HS256", config.signingAlg(model.RefreshKeyPurpose))
+	assert.Equal(t, "https://auth.dualread.test", config.Issuer)
+	assert.Equal(t, NewDefaultConfig().Audience, config.Audience)
+	assert.Equal(t, []string{"reader", "billing"}, config.Audiences)
+	assert.True(t, config.validAudience("billing"))
+	assert.True(t, config.validAudience(config.Audience))
+	assert.False(t, config.validAudience("unknown"))
+	assert.Equal(t, []byte("hash_key"), config.RefreshTokenHashKey)
+	assert.Equal(t, config.RefreshTokenLifetime, config.maxRefreshTokenLifetime())
+	assert.Equal(t, "https://dualread.test/verify-email", config.EmailVerificationUrl)
+	assert.Equal(t, time.Hour, config.EmailVerificationLifetime)
+	assert.Equal(t, "https://dualread.test/reset-password", config.PasswordResetUrl)
+	assert.Equal(t, "/auth", config.CookiePath)
+	assert.Equal(t, "dualread.test", config.CookieDomain)
+	assert.False(t, config.CookieSecure)
+	assert.Equal(t, http.SameSiteStrictMode, config.CookieSameSite)
+	assert.Equal(
+		t,
+		map[string]string{"reader": "reader_secret", "billing": "billing:secret"},
+		config.ServiceCredentials,
+	)
+}
+
+func TestLoadConfig_Invalid(t *testing.T) {
+	testCases := []struct {
+		name     string
+		variable string
+		value    string
+	}{
+		{name: "malformed duration", variable: "JWT_ACCESS_TOKEN_LIFETIME", value: "soon"},
+		{name: "zero access lifetime", variable: "JWT_ACCESS_TOKEN_LIFETIME", value: "0"},
+		{name: "negative refresh lifetime", variable: "JWT_REFRESH_TOKEN_LIFETIME", value: "-1h"},
+		{name: "missing hash key", variable: "REFRESH_TOKEN_HASH_KEY", value: ""},
+		{name: "malformed proxy setting", variable: "TRUST_PROXY_HEADERS", value: "maybe"},
+		{name: "zero verification lifetime", variable: "EMAIL_VERIFICATION_LIFETIME", value: "0s"},
+		{name: "negative reset lifetime", variable: "PASSWORD_RESET_LIFETIME", value: "-1h"},
+		{name: "zero janitor interval", variable: "JANITOR_INTERVAL", value: "0s"},
+		{name: "malformed rotation interval", variable: "JWT_KEY_ROTATION_INTERVAL", value: "monthly"},
+		{name: "negative rotation interval", variable: "JWT_KEY_ROTATION_INTERVAL", value: "-1h"},
+		{name: "unknown signing algorithm", variable: "JWT_ACCESS_ALG", value: "none"},
+		{name: "symmetric signing algorithm", variable: "JWT_ACCESS_ALG", value: "HS256"},
+		{name: "missing issuer", variable: "JWT_ISSUER", value: ""},
+		{name: "issuer not a url", variable: "JWT_ISSUER", value: "dualread-auth"},
+		{name: "http issuer", variable: "JWT_ISSUER", value: "http://auth.dualread.test"},
+		{name: "issuer with a path", variable: "JWT_ISSUER", value: "https://dualread.test/auth"},
+		{name: "relative cookie path", variable: "COOKIE_PATH", value: "auth"},
+		{name: "malformed secure setting", variable: "COOKIE_SECURE", value: "maybe"},
+		{name: "unknown samesite mode", variable: "COOKIE_SAMESITE", value: "loose"},
+		{name: "service without secret", variable: "SERVICE_CREDENTIALS", value: "reader"},
+		{name: "service without id", variable: "SERVICE_CREDENTIALS", value: ":secret"},
+		{name: "duplicate service", variable: "SERVICE_CREDENTIALS", value: "reader:a,reader:b"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("REFRESH_TOKEN_HASH_KEY", "hash_key")
+			t.Setenv("JWT_ISSUER", "https://auth.dualread.test")
+			t.Setenv(tc.variable, tc.value)
+			_, err := LoadConfig()
+			assert.Error(t, err)
+		})
+	}
+
+	// Browsers drop SameSite=None cookies that are not secure
+	t.Setenv("REFRESH_TOKEN_HASH_KEY", "hash_key")
+	t.Setenv("JWT_ISSUER", "https://auth.dualread.test")
+	t.Setenv("COOKIE_SAMESITE", "none")
+	t.Setenv("COOKIE_SECURE", "false")
+	_, err := LoadConfig()
+	assert.Error(t, err)
+}
@@ -0,0 +1,92 @@
+package httpserver
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/anoobz/dualread/auth/internal/model"
+)
+
+const (
+	refreshTokenCookieName = "refresh_token"
+	// The CSRF token is a double submit cookie, the web app reads it and sends
+	// it back in the header, which other sites cannot do
+	csrfTokenCookieName = "csrf_token"
+	csrfTokenHeader     = "X-CSRF-Token"
+)
+
+var errInvalidCsrfToken = errors.New("invalid csrf token")
+
+// setRefreshTokenCookie sets the refresh token cookie of the session along
+// with a new CSRF token expiring with it.
+func (s *server) setRefreshTokenCookie(w http.ResponseWriter, rt *model.AuthToken) error {
+	csrfToken, err := model.GenerateSecret()
+	if err != nil {
+		return err
+	}
+
+	expires := time.Unix(rt.Expires, 0)
+	http.SetCookie(w, s.newCookie(refreshTokenCookieName, rt.TokenString, expires))
+	http.SetCookie(w, s.newCsrfCookie(csrfToken, expires))
+	return nil
+}
+
+func (s *server) clearRefreshTokenCookie(w http.ResponseWriter) {
+	refreshTokenCookie := s.newCookie(refreshTokenCookieName, "", time.Unix(0, 0))
+	refreshTokenCookie.MaxAge = -1
+	http.SetCookie(w, refreshTokenCookie)
+
+	csrfCookie := s.newCsrfCookie("", time.Unix(0, 0))
+	csrfCookie.MaxAge = -1
+	http.SetCookie(w, csrfCookie)
+}
+
+func (s *server) newCookie(name string, value string, expires time.Time) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     s.config.CookiePath,
+		Domain:   s.config.CookieDomain,
+		Expires:  expires,
+		Secure:   s.config.CookieSecure,
+		HttpOnly: true,
+		SameSite: s.config.CookieSameSite,
+	}
+}
+
+// newCsrfCookie is readable by the scripts of every page of the web app.
+func (s *server) newCsrfCookie(value string, expires time.Time) *http.Cookie {
+	c := s.newCookie(csrfTokenCookieName, value, expires)
+	c.Path = "/"
+	c.HttpOnly = false
+	return c
+}
+
+// requireCsrfToken rejects the requests authenticated by the refresh token
+// cookie whose CSRF header does not match the CSRF cookie. Requests without
+// the cookie are left to the handler.
+func (s *server) requireCsrfToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := r.Cookie(refreshTokenCookieName); err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		csrfCookie, err := r.Cookie(csrfTokenCookieName)
+		if err != nil || csrfCookie.Value == "" {
+			s.error(w, r, http.StatusForbidden, errInvalidCsrfToken)
+			return
+		}
+		if subtle.ConstantTimeCompare(
+			[]byte(csrfCookie.Value),
+			[]byte(r.Header.Get(csrfTokenHeader)),
+		) != 1 {
+			s.error(w, r, http.StatusForbidden, errInvalidCsrfToken)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,141 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_RefreshTokenCookie(t *testing.T) {
+	s := NewTestServer(t)
+	s.config.CookieDomain = "dualread.test"
+
+	s.CreateTestUser(t, 1, false)
+	rec := httptest.NewRecorder()
+	req := s.CreateTestRequest(
+		t, http.MethodPost, "/auth/login",
+		map[string]interface{}{"email": "test0@test.test", "password": "test_password0"},
+	)
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	cookies := map[string]*http.Cookie{}
+	for _, c := range rec.Result().Cookies() {
+		cookies[c.Name] = c
+	}
+	if assert.Contains(t, cookies, refreshTokenCookieName) {
+		c := cookies[refreshTokenCookieName]
+		assert.Equal(t, "/auth", c.Path)
+		assert.Equal(t, "dualread.test", c.Domain)
+		assert.True(t, c.Secure)
+		assert.True(t, c.HttpOnly)
+		assert.Equal(t, http.SameSiteLaxMode, c.SameSite)
+	}
+	if assert.Contains(t, cookies, csrfTokenCookieName) {
+		c := cookies[csrfTokenCookieName]
+		assert.NotEmpty(t, c.Value)
+		assert.Equal(t, "/", c.Path)
+		assert.True(t, c.Secure)
+		assert.False(t, c.HttpOnly)
+		assert.Equal(t, cookies[refreshTokenCookieName].Expires, c.Expires)
+	}
+
+	// Logging out clears both cookies
+	rec = httptest.NewRecorder()
+	req = s.CreateTestRequest(t, http.MethodPost, "/auth/logout", nil)
+	AddTestSessionCookie(req, cookies[refreshTokenCookieName])
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	cleared := map[string]*http.Cookie{}
+	for _, c := range rec.Result().Cookies() {
+		cleared[c.Name] = c
+	}
+	for _, name := range []string{refreshTokenCookieName, csrfTokenCookieName} {
+		if assert.Contains(t, cleared, name) {
+			assert.Empty(t, cleared[name].Value, name)
+			assert.Equal(t, -1, cleared[name].MaxAge, name)
+			assert.Equal(t, cookies[name].Path, cleared[name].Path, name)
+		}
+	}
+}
+
+func TestServer_RequireCsrfToken(t *testing.T) {
+	s := NewTestServer(t)
+
+	s.CreateTestUser(t, 1, false)
+	cookie := s.LoginTestSession(t, "test0@test.test", "test_password0")
+
+	testCases := []struct {
+		name             string
+		url              string
+		csrfCookie       string
+		csrfHeader       string
+		expectedStatus   int
+		expectedErrorMsg string
+	}{
+		{
+			name:             "missing csrf cookie",
+			url:              "/auth/refresh-access-token",
+			csrfHeader:       "csrf",
+			expectedStatus:   http.StatusForbidden,
+			expectedErrorMsg: "invalid csrf token",
+		},
+		{
+			name:             "missing csrf header",
+			url:              "/auth/refresh-access-token",
+			csrfCookie:       "csrf",
+			expectedStatus:   http.StatusForbidden,
+			expectedErrorMsg: "invalid csrf token",
+		},
+		{
+			name:             "mismatched csrf header",
+			url:              "/auth/refresh-access-token",
+			csrfCookie:       "csrf",
+			csrfHeader:       "other",
+			expectedStatus:   http.StatusForbidden,
+			expectedErrorMsg: "invalid csrf token",
+		},
+		{
+			name:             "logout without csrf token",
+			url:              "/auth/logout",
+			expectedStatus:   http.StatusForbidden,
+			expectedErrorMsg: "invalid csrf token",
+		},
+		{
+			name:             "logout of every session without csrf token",
+			url:              "/auth/logout-all",
+			expectedStatus:   http.StatusForbidden,
+			expectedErrorMsg: "invalid csrf token",
+		},
+		{
+			name:           "matching csrf token",
+			url:            "/auth/refresh-access-token",
+			csrfCookie:     "csrf",
+			csrfHeader:     "csrf",
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	for _, tc := range testCases {
+		rec := httptest.NewRecorder()
+		req := s.CreateTestRequest(t, http.MethodPost, tc.url, nil)
+		req.AddCookie(cookie)
+		if tc.csrfCookie != "" {
+			req.AddCookie(&http.Cookie{Name: csrfTokenCookieName, Value: tc.csrfCookie})
+		}
+		if tc.csrfHeader != "" {
+			req.Header.Set(csrfTokenHeader, tc.csrfHeader)
+		}
+		s.ServeHTTP(rec, req)
+
+		assert.Equal(t, tc.expectedStatus, rec.Code, tc.name)
+		res := struct {
+			ErrorMsg string `json:"error"`
+		}{}
+		json.NewDecoder(rec.Body).Decode(&res)
+		assert.Equal(t, tc.expectedErrorMsg, res.ErrorMsg, tc.name)
+	}
+}
@@ -0,0 +1,182 @@
+package httpserver
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+
+	"github.com/anoobz/dualread/auth/internal/model"
+)
+
+// introspectionResponse follows RFC 7662. Inactive tokens only carry the
+// active field so that nothing is disclosed about them.
+type introspectionResponse struct {
+	Active    bool           `json:"active"`
+	Sub       string         `json:"sub,omitempty"`
+	Exp       int64          `json:"exp,omitempty"`
+	Iat       int64          `json:"iat,omitempty"`
+	Iss       string         `json:"iss,omitempty"`
+	Aud       model.Audience `json:"aud,omitempty"`
+	Scope     string         `json:"scope,omitempty"`
+	ClientId  string         `json:"client_id,omitempty"`
+	TokenType string         `json:"token_type,omitempty"`
+	// Roles and Permissions are the current ones of the user, which may
+	// differ from the claims of the token
+	Roles       []string `json:"roles,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
+}
+
+const serviceIdKey = ctxKey("service_id")
+
+func getServiceId(r *http.Request) string {
+	serviceId, _ := r.Context().Value(serviceIdKey).(string)
+	return serviceId
+}
+
+func (s *server) introspect() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tokenString := r.PostFormValue("token")
+		if tokenString == "" {
+			s.error(w, r, http.StatusBadRequest, errors.New("a required field is empty"))
+			return
+		}
+
+		// Access tokens are only active for the service of their audience,
+		// the id of the calling service
+		introspectors := []func(string) (*introspectionResponse, error){
+			func(tokenString string) (*introspectionResponse, error) {
+				return s.introspectAccessToken(tokenString, getServiceId(r))
+			},
+			s.introspectRefreshToken,
+		}
+		if r.PostFormValue("token_type_hint") == "refresh_token" {
+			introspectors[0], introspectors[1] = introspectors[1], introspectors[0]
+		}
+
+		for _, introspector := range introspectors {
+			res, err := introspector(tokenString)
+			if err != nil {
+				s.error(w, r, http.StatusInternalServerError, err)
+				return
+			}
+			if res.Active {
+				s.respond(w, r, http.StatusOK, res)
+				return
+			}
+		}
+
+		s.respond(w, r, http.StatusOK, &introspectionResponse{Active: false})
+	}
+}
+
+// introspectAccessToken only returns an error when the token could not be
+// checked, an invalid token is reported as inactive.
+func (s *server) introspectAccessToken(
+	tokenString string,
+	audience string,
+) (*introspectionResponse, error) {
+	inactive := &introspectionResponse{Active: false}
+
+	claims, err := s.parseAccessTokenFor(tokenString, audience)
+	if err != nil {
+		return inactive, nil
+	}
+
+	revoked, err := s.isAccessTokenRevoked(claims)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return inactive, nil
+	}
+	if claims.ClientId != "" {
+		return s.newClientIntrospectionResponse(claims)
+	}
+
+	return s.newIntrospectionResponse(&claims.RegisteredClaims, claims.Scope, "access_token")
+}
+
+func (s *server) introspectRefreshToken(tokenString string) (*introspectionResponse, error) {
+	inactive := &introspectionResponse{Active: false}
+
+	storedToken, claims, err := s.findRefreshToken(tokenString)
+	if err != nil || storedToken.Consumed {
+		return inactive, nil
+	}
+
+	return s.newIntrospectionResponse(&claims.RegisteredClaims, claims.Scope, "refresh_token")
+}
+
+func (s *server) newIntrospectionResponse(
+	claims *model.RegisteredClaims,
+	scope string,
+	tokenType string,
+) (*introspectionResponse, error) {
+	userId, err := claims.UserId()
+	if err != nil {
+		return &introspectionResponse{Active: false}, nil
+	}
+	user, err := s.store.User().GetById(userId)
+	if err != nil || !user.Active {
+		return &introspectionResponse{Active: false}, nil
+	}
+	grants, err := s.userGrants(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &introspectionResponse{
+		Active:      true,
+		Sub:         claims.Subject,
+		Exp:         claims.ExpiresAt,
+		Iat:         claims.IssuedAt,
+		Iss:         claims.Issuer,
+		Aud:         claims.Audience,
+		Scope:       scope,
+		TokenType:   tokenType,
+		Roles:       grants.Roles,
+		Permissions: grants.Permissions,
+	}, nil
+}
+
+// newClientIntrospectionResponse describes the token of a client, which stays
+// active as long as the client is registered.
+func (s *server) newClientIntrospectionResponse(
+	claims *model.AccessClaims,
+) (*introspectionResponse, error) {
+	if _, err := s.store.Client().GetById(claims.ClientId); err != nil {
+		return &introspectionResponse{Active: false}, nil
+	}
+
+	return &introspectionResponse{
+		Active:    true,
+		Sub:       claims.Subject,
+		Exp:       claims.ExpiresAt,
+		Iat:       claims.IssuedAt,
+		Iss:       claims.Issuer,
+		Aud:       claims.Audience,
+		Scope:     claims.Scope,
+		ClientId:  claims.ClientId,
+		TokenType: "access_token",
+	}, nil
+}
+
+// authenticateService restricts a route to the back-end services of the
+// configuration, presenting their credentials with HTTP basic authentication.
+func (s *server) authenticateService(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, secret, ok := r.BasicAuth()
+		expectedSecret, found := s.config.ServiceCredentials[id]
+		// The comparison runs even for unknown ids to keep timing uniform
+		valid := subtle.ConstantTimeCompare([]byte(secret), []byte(expectedSecret)) == 1
+		if !ok || !found || !valid {
+			w.Header().Set("WWW-Authenticate", `Basic realm="dualread"`)
+			s.error(w, r, http.StatusUnauthorized, errors.New("invalid service credentials"))
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), serviceIdKey, id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,243 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anoobz/dualread/auth/internal/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func introspectTestToken(
+	t *testing.T,
+	s *server,
+	form url.Values,
+	id string,
+	secret string,
+) (*httptest.ResponseRecorder, *introspectionResponse) {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	req, err := http.NewRequest(
+		http.MethodPost,
+		"/auth/introspect",
+		strings.NewReader(form.Encode()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(id, secret)
+	s.ServeHTTP(rec, req)
+
+	res := &introspectionResponse{}
+	json.NewDecoder(rec.Body).Decode(&res)
+	return rec, res
+}
+
+func TestServer_Introspect(t *testing.T) {
+	s := NewTestServer(t)
+	s.config.ServiceCredentials = map[string]string{
+		"reader": "reader_secret",
+		"writer": "writer_secret",
+	}
+	s.config.Audiences = []string{"reader", "writer"}
+
+	users := s.CreateTestUser(t, 2, true)
+	accessToken := s.LoginTestUserFor(t, "test0@test.test", "test_password0", "writer")
+	readerToken := s.LoginTestUserFor(t, "test0@test.test", "test_password0", "reader")
+	cookie := s.LoginTestSession(t, "test0@test.test", "test_password0")
+	revokedToken := s.LoginTestUserFor(t, "test0@test.test", "test_password0", "writer")
+	if err := s.store.Revocation().RevokeAccessToken(
+		getTestAccessUuid(t, revokedToken),
+		time.Now().Add(time.Hour).Unix(),
+	); err != nil {
+		t.Fatal(err)
+	}
+	inactiveToken := s.LoginTestUserFor(t, "test1@test.test", "test_password1", "writer")
+	if err := s.store.User().Update(
+		users[1].ID,
+		map[string]interface{}{"active": false},
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name              string
+		token             string
+		hint              string
+		expectedActive    bool
+		expectedTokenType string
+	}{
+		{
+			name:              "access token",
+			token:             accessToken,
+			expectedActive:    true,
+			expectedTokenType: "access_token",
+		},
+		{
+			name:              "refresh token",
+			token:             cookie.Value,
+			expectedActive:    true,
+			expectedTokenType: "refresh_token",
+		},
+		{
+			name:              "refresh token with hint",
+			token:             cookie.Value,
+			hint:              "refresh_token",
+			expectedActive:    true,
+			expectedTokenType: "refresh_token",
+		},
+		{
+			name:           "access token for another service",
+			token:          readerToken,
+			expectedActive: false,
+		},
+		{
+			name:           "revoked access token",
+			token:          revokedToken,
+			expectedActive: false,
+		},
+		{
+			name:           "inactive user",
+			token:          inactiveToken,
+			expectedActive: false,
+		},
+		{
+			name:           "invalid token",
+			token:          "invalid",
+			expectedActive: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		form := url.Values{"token": {tc.token}}
+		if tc.hint != "" {
+			form.Set("token_type_hint", tc.hint)
+		}
+		rec, res := introspectTestToken(t, s, form, "writer", "writer_secret")
+
+		assert.Equal(t, http.StatusOK, rec.Code, tc.name)
+		assert.Equal(t, tc.expectedActive, res.Active, tc.name)
+		assert.Equal(t, tc.expectedTokenType, res.TokenType, tc.name)
+		if tc.expectedActive {
+			assert.Equal(t, strconv.FormatInt(users[0].ID, 10), res.Sub, tc.name)
+			assert.Equal(t, []string{model.AdminRoleName}, res.Roles, tc.name)
+			assert.Contains(t, res.Permissions, model.PermissionWriteUsers, tc.name)
+			assert.Contains(t, res.Scope, model.ScopeProfileRead, tc.name)
+			assert.NotZero(t, res.Exp, tc.name)
+		} else {
+			assert.Empty(t, res.Sub, tc.name)
+		}
+	}
+
+	// A consumed refresh token is no longer active
+	rec := httptest.NewRecorder()
+	req := s.CreateTestRequest(t, http.MethodPost, "/auth/refresh-access-token", nil)
+	AddTestSessionCookie(req, cookie)
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	_, res := introspectTestToken(
+		t, s,
+		url.Values{"token": {cookie.Value}},
+		"writer", "writer_secret",
+	)
+	assert.False(t, res.Active)
+
+	rec, _ = introspectTestToken(t, s, url.Values{}, "writer", "writer_secret")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestServer_Introspect_ServiceCredentials(t *testing.T) {
+	s := NewTestServer(t)
+	s.config.ServiceCredentials = map[string]string{"reader": "reader_secret"}
+
+	s.CreateTestUser(t, 1, false)
+	accessToken := s.LoginTestUser(t, "test0@test.test", "test_password0")
+
+	testCases := []struct {
+		name           string
+		id             string
+		secret         string
+		expectedStatus int
+	}{
+		{
+			name:           "valid credentials",
+			id:             "reader",
+			secret:         "reader_secret",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "wrong secret",
+			id:             "reader",
+			secret:         "wrong",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "unknown service",
+			id:             "unknown",
+			secret:         "",
+			expectedStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tc := range testCases {
+		rec, _ := introspectTestToken(
+			t, s,
+			url.Values{"token": {accessToken}},
+			tc.id, tc.secret,
+		)
+		assert.Equal(t, tc.expectedStatus, rec.Code, tc.name)
+	}
+
+	// Access tokens of users are not service credentials
+	rec := httptest.NewRecorder()
+	req := s.CreateTestRequest(t, http.MethodPost, "/auth/introspect", nil)
+	req.Header.Add("Authorization", "Bearer "+accessToken)
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.NotEmpty(t, rec.Header().Get("WWW-Authenticate"))
+}
+
+func TestServer_Introspect_ClientToken(t *testing.T) {
+	s := NewTestServer(t)
+	s.config.ServiceCredentials = map[string]string{"reader": "reader_secret"}
+	s.config.Audiences = []string{"reader"}
+
+	client, secret := s.CreateTestServiceClient(t, []string{"tts"})
+	_, tokenRes, _ := requestTestToken(
+		t, s,
+		url.Values{"grant_type": {"client_credentials"}, "audience": {"reader"}},
+		client.Id, secret,
+	)
+
+	rec, res := introspectTestToken(
+		t, s,
+		url.Values{"token": {tokenRes.AccessToken}},
+		"reader", "reader_secret",
+	)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.True(t, res.Active)
+	assert.Equal(t, client.Id, res.Sub)
+	assert.Equal(t, client.Id, res.ClientId)
+	assert.Equal(t, "tts", res.Scope)
+	assert.Empty(t, res.Roles)
+	assert.Empty(t, res.Permissions)
+
+	// The tokens of a deleted client are no longer active
+	if err := s.store.Client().Delete(client.Id); err != nil {
+		t.Fatal(err)
+	}
+	_, res = introspectTestToken(
+		t, s,
+		url.Values{"token": {tokenRes.AccessToken}},
+		"reader", "reader_secret",
+	)
+	assert.False(t, res.Active)
+}
@@ -0,0 +1,82 @@
+package httpserver
+
+import (
+	"time"
+)
+
+// purgeCounts are the numbers of rows deleted by a purge.
+type purgeCounts struct {
+	refreshTokens  int64
+	revocations    int64
+	authCodes      int64
+	apiKeys        int64
+	passwordResets int64
+	verifications  int64
+}
+
+// purgeExpired deletes the refresh tokens, the deny list entries, the
+// authorization codes, the API keys, the password resets and the email
+// verifications which have expired on their own.
+func (s *server) purgeExpired(now time.Time) (purgeCounts, error) {
+	counts := purgeCounts{}
+
+	var err error
+	counts.refreshTokens, err = s.store.AuthToken().DeleteExpired(now.Unix())
+	if err != nil {
+		return counts, err
+	}
+	counts.revocations, err = s.store.Revocation().DeleteExpired(now.Unix())
+	if err != nil {
+		return counts, err
+	}
+	counts.authCodes, err = s.store.AuthCode().DeleteExpired(now.Unix())
+	if err != nil {
+		return counts, err
+	}
+	counts.apiKeys, err = s.store.ApiKey().DeleteExpired(now.Unix())
+	if err != nil {
+		return counts, err
+	}
+	counts.passwordResets, err = s.store.PasswordReset().DeleteExpired(now.Unix())
+	if err != nil {
+		return counts, err
+	}
+	counts.verifications, err = s.store.EmailVerification().DeleteExpired(now.Unix())
+	if err != nil {
+		return counts, err
+	}
+
+	return counts, nil
+}
+
+// runJanitor purges the expired rows every interval until done is closed.
+func (s *server) runJanitor(interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		var now time.Time
+		select {
+		case <-done:
+			return
+		case now = <-ticker.C:
+		}
+
+		counts, err := s.purgeExpired(now)
+		if err != nil {
+			s.logger.Printf("failed to purge expired rows: %v", err)
+			continue
+		}
+		s.logger.Printf(
+			"purged %d expired refresh tokens, %d expired revocations, "+
+				"%d expired authorization codes, %d expired api keys, "+
+				"%d expired password resets and %d expired email verifications",
+			counts.refreshTokens,
+			counts.revocations,
+			counts.authCodes,
+			counts.apiKeys,
+			counts.passwordResets,
+			counts.verifications,
+		)
+	}
+}
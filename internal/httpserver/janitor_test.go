@@ -0,0 +1,126 @@
+package httpserver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/anoobz/dualread/auth/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_PurgeExpired(t *testing.T) {
+	s := NewTestServer(t)
+
+	user := s.CreateTestUser(t, 1, false)[0]
+	tokens := s.CreateTestToken(t, 2, user)
+	cookie := s.LoginTestSession(t, "test0@test.test", "test_password0")
+	for _, err := range []error{
+		s.store.Revocation().RevokeAccessToken("5c7f6d5e-8bd9-4a3e-9a39-1f3f0b1f5a01", 100),
+		s.store.Revocation().RevokeUser(user.ID, 50, 100),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	counts, err := s.purgeExpired(time.Unix(100, 0))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), counts.refreshTokens)
+	assert.Equal(t, int64(2), counts.revocations)
+
+	// Test tokens expire before the session opened by the login
+	counts, err = s.purgeExpired(time.Unix(tokens[1].Expires, 0))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), counts.refreshTokens)
+	assert.Equal(t, int64(0), counts.revocations)
+
+	_, _, err = s.findRefreshToken(cookie.Value)
+	assert.NoError(t, err)
+}
+
+func TestServer_PurgeExpired_AuthCodes(t *testing.T) {
+	s := NewTestServer(t)
+
+	user := s.CreateTestUser(t, 1, false)[0]
+	client := s.CreateTestClient(t, 1)[0]
+	expiredCode, _ := store.CreateTestAuthCode(t, s.store, client, user, -time.Minute)
+	activeCode, _ := store.CreateTestAuthCode(t, s.store, client, user, time.Minute)
+
+	counts, err := s.purgeExpired(time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), counts.authCodes)
+
+	_, err = s.store.AuthCode().Consume(expiredCode.CodeHash)
+	assert.Error(t, err)
+	_, err = s.store.AuthCode().Consume(activeCode.CodeHash)
+	assert.NoError(t, err)
+}
+
+func TestServer_PurgeExpired_ApiKeys(t *testing.T) {
+	s := NewTestServer(t)
+
+	user := s.CreateTestUser(t, 1, false)[0]
+	expiredKeys, _ := store.CreateTestApiKey(t, s.store, user, 1, time.Minute)
+	permanentKeys, _ := store.CreateTestApiKey(t, s.store, user, 1, 0)
+
+	counts, err := s.purgeExpired(time.Now().Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), counts.apiKeys)
+
+	_, err = s.store.ApiKey().GetById(expiredKeys[0].Id)
+	assert.Error(t, err)
+	_, err = s.store.ApiKey().GetById(permanentKeys[0].Id)
+	assert.NoError(t, err)
+}
+
+func TestServer_PurgeExpired_PasswordResets(t *testing.T) {
+	s := NewTestServer(t)
+
+	user := s.CreateTestUser(t, 1, false)[0]
+	expiredReset, _ := store.CreateTestPasswordReset(t, s.store, user, -time.Minute)
+	activeReset, _ := store.CreateTestPasswordReset(t, s.store, user, time.Minute)
+
+	counts, err := s.purgeExpired(time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), counts.passwordResets)
+
+	_, err = s.store.PasswordReset().Consume(expiredReset.TokenHash)
+	assert.Error(t, err)
+	_, err = s.store.PasswordReset().Consume(activeReset.TokenHash)
+	assert.NoError(t, err)
+}
+
+func TestServer_PurgeExpired_EmailVerifications(t *testing.T) {
+	s := NewTestServer(t)
+
+	user := s.CreateTestUser(t, 1, false)[0]
+	expiredVerification := store.CreateTestEmailVerification(t, s.store, user, -time.Minute)
+	activeVerification := store.CreateTestEmailVerification(t, s.store, user, time.Minute)
+
+	counts, err := s.purgeExpired(time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), counts.verifications)
+
+	_, err = s.store.EmailVerification().Consume(expiredVerification.TokenId)
+	assert.Error(t, err)
+	_, err = s.store.EmailVerification().Consume(activeVerification.TokenId)
+	assert.NoError(t, err)
+}
+
+func TestServer_RunJanitor_StopsOnShutdown(t *testing.T) {
+	s := NewTestServer(t)
+
+	stopped := make(chan struct{})
+	go func() {
+		s.runJanitor(time.Millisecond, s.done)
+		close(stopped)
+	}()
+	assert.NoError(t, s.Shutdown(context.Background()))
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("janitor still running after shutdown")
+	}
+}
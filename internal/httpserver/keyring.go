@@ -0,0 +1,119 @@
+package httpserver
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/anoobz/dualread/auth/internal/model"
+	"github.com/anoobz/dualread/auth/internal/store"
+)
+
+// keyRingRefreshPeriod is how often the key rings are reloaded from the store
+// so that rotations performed by other instances are picked up.
+const keyRingRefreshPeriod = time.Minute
+
+func (s *server) keyRing(purpose string) (*model.KeyRing, error) {
+	switch purpose {
+	case model.AccessKeyPurpose:
+		return s.accessKeys, nil
+	case model.RefreshKeyPurpose:
+		return s.refreshKeys, nil
+	}
+
+	return nil, fmt.Errorf("invalid signing key purpose: %s", purpose)
+}
+
+// rotateKeyRing generates a new key with the algorithm of the configuration,
+// promotes it and retires the previous key once every token it signed has
+// expired. When another instance rotated the key first, its key is loaded
+// instead.
+func (s *server) rotateKeyRing(purpose string, now time.Time) (*model.SigningKey, error) {
+	ring, err := s.keyRing(purpose)
+	if err != nil {
+		return nil, err
+	}
+
+	previous := ring.Active()
+	next, err := model.GenerateSigningKey(s.config.signingAlg(purpose), now)
+	if err != nil {
+		return nil, err
+	}
+	err = s.store.SigningKey().Rotate(
+		purpose,
+		previous.Kid,
+		next,
+		now.Add(s.config.TokenLifetime(purpose)),
+	)
+	if err != nil && !errors.Is(err, store.ErrKeyRotated) {
+		return nil, err
+	}
+
+	keys, err := s.store.SigningKey().GetAll(purpose)
+	if err != nil {
+		return nil, err
+	}
+	if err := ring.Set(keys); err != nil {
+		return nil, err
+	}
+
+	s.logger.Printf("rotated %s signing key %s to %s", purpose, previous.Kid, ring.Active().Kid)
+	return ring.Active(), nil
+}
+
+// refreshKeyRing reloads the ring from the store, rotates it when the active
+// key is older than rotationInterval and purges the expired keys.
+func (s *server) refreshKeyRing(
+	purpose string,
+	rotationInterval time.Duration,
+	now time.Time,
+) error {
+	ring, err := s.keyRing(purpose)
+	if err != nil {
+		return err
+	}
+
+	keys, err := s.store.SigningKey().GetAll(purpose)
+	if err != nil {
+		return err
+	}
+	if err := ring.Set(keys); err != nil {
+		return err
+	}
+
+	if rotationInterval > 0 && now.Sub(ring.Active().Created) >= rotationInterval {
+		if _, err := s.rotateKeyRing(purpose, now); err != nil {
+			return err
+		}
+	}
+
+	for _, k := range ring.Prune(now) {
+		if err := s.store.SigningKey().Delete(k.Kid); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// maintainKeyRings reloads, rotates and prunes the key rings every
+// keyRingRefreshPeriod until done is closed.
+func (s *server) maintainKeyRings(rotationInterval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(keyRingRefreshPeriod)
+	defer ticker.Stop()
+
+	for {
+		var now time.Time
+		select {
+		case <-done:
+			return
+		case now = <-ticker.C:
+		}
+
+		for _, purpose := range []string{model.AccessKeyPurpose, model.RefreshKeyPurpose} {
+			if err := s.refreshKeyRing(purpose, rotationInterval, now); err != nil {
+				s.logger.Printf("failed to refresh %s key ring: %v", purpose, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/anoobz/dualread/auth/internal/model"
+	"github.com/gorilla/mux"
+)
+
+type signingKeyResponse struct {
+	Kid     string     `json:"kid"`
+	Purpose string     `json:"purpose"`
+	Alg     string     `json:"alg"`
+	Active  bool       `json:"active"`
+	Created time.Time  `json:"created"`
+	Expires *time.Time `json:"expires,omitempty"`
+}
+
+func newSigningKeyResponse(purpose string, k *model.SigningKey) *signingKeyResponse {
+	res := &signingKeyResponse{
+		Kid:     k.Kid,
+		Purpose: purpose,
+		Alg:     k.Method.Alg(),
+		Active:  !k.Retired(),
+		Created: k.Created,
+	}
+	if k.Retired() {
+		res.Expires = &k.Expires
+	}
+
+	return res
+}
+
+func (s *server) getJwks() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		jwks := &model.JWKS{Keys: []*model.JWK{}}
+
+		// A shared HMAC secret can mint tokens, so it is never published
+		now := time.Now()
+		for _, k := range s.accessKeys.Keys() {
+			if k.Symmetric() || k.Expired(now) {
+				continue
+			}
+			jwk, err := k.JWK()
+			if err != nil {
+				s.error(w, r, http.StatusInternalServerError, err)
+				return
+			}
+			jwks.Keys = append(jwks.Keys, jwk)
+		}
+
+		s.respond(w, r, http.StatusOK, jwks)
+	}
+}
+
+func (s *server) getSigningKeys() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		keys := []*signingKeyResponse{}
+		for _, purpose := range []string{model.AccessKeyPurpose, model.RefreshKeyPurpose} {
+			ring, err := s.keyRing(purpose)
+			if err != nil {
+				s.error(w, r, http.StatusInternalServerError, err)
+				return
+			}
+			for _, k := range ring.Keys() {
+				keys = append(keys, newSigningKeyResponse(purpose, k))
+			}
+		}
+
+		s.respond(w, r, http.StatusOK, keys)
+	}
+}
+
+func (s *server) rotateSigningKey() http.HandlerFunc {
+	type payload struct {
+		Purpose string `json:"purpose"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		p := &payload{}
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			s.error(w, r, http.StatusBadRequest, err)
+			return
+		}
+		if _, err := s.keyRing(p.Purpose); err != nil {
+			s.error(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		k, err := s.rotateKeyRing(p.Purpose, time.Now())
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		s.respond(w, r, http.StatusCreated, newSigningKeyResponse(p.Purpose, k))
+	}
+}
+
+func (s *server) deleteSigningKey() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		kid := mux.Vars(r)["kid"]
+
+		for _, ring := range []*model.KeyRing{s.accessKeys, s.refreshKeys} {
+			if _, err := ring.Get(kid); err != nil {
+				continue
+			}
+			if err := ring.Remove(kid); err != nil {
+				s.error(w, r, http.StatusBadRequest, err)
+				return
+			}
+			if err := s.store.SigningKey().Delete(kid); err != nil {
+				s.error(w, r, http.StatusInternalServerError, err)
+				return
+			}
+
+			s.respond(w, r, http.StatusOK, nil)
+			return
+		}
+
+		s.error(w, r, http.StatusNotFound, errors.New("signing key not found"))
+	}
+}
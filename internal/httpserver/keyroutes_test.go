@@ -0,0 +1,302 @@
+package httpserver
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/anoobz/dualread/auth/internal/model"
+	"github.com/anoobz/dualread/auth/internal/store/mockstore"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_GetJwks(t *testing.T) {
+	s := NewTestServer(t)
+
+	s.CreateTestUser(t, 1, false)
+	accessToken := s.LoginTestUser(t, "test0@test.test", "test_password0")
+
+	rec := httptest.NewRecorder()
+	req := s.CreateTestRequest(t, http.MethodGet, "/auth/.well-known/jwks.json", nil)
+	s.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	jwks := &model.JWKS{}
+	if err := json.NewDecoder(rec.Body).Decode(&jwks); err != nil {
+		t.Fatal(err)
+	}
+	if !assert.Len(t, jwks.Keys, 1) {
+		return
+	}
+	jwk := jwks.Keys[0]
+	assert.Equal(t, "EC", jwk.Kty)
+	assert.Equal(t, "P-256", jwk.Crv)
+	assert.Equal(t, "ES256", jwk.Alg)
+
+	// A downstream service must be able to verify access tokens with the
+	// published public key alone
+	x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+	if err != nil {
+		t.Fatal(err)
+	}
+	y, err := base64.RawURLEncoding.DecodeString(jwk.Y)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey := &ecdsa.PublicKey{
+		Curve: s.accessKeys.Active().PublicKey.(*ecdsa.PublicKey).Curve,
+		X:     new(big.Int).SetBytes(x),
+		Y:     new(big.Int).SetBytes(y),
+	}
+	token, err := jwt.Parse(accessToken, func(token *jwt.Token) (interface{}, error) {
+		return publicKey, nil
+	})
+	assert.NoError(t, err)
+	assert.True(t, token.Valid)
+}
+
+func TestServer_GetJwks_SymmetricKey(t *testing.T) {
+	s := NewTestServer(t)
+	s.accessKeys = NewTestKeyRing(
+		t,
+		mockstore.CreateTestStore(t),
+		model.AccessKeyPurpose,
+		"HS256",
+	)
+
+	rec := httptest.NewRecorder()
+	req := s.CreateTestRequest(t, http.MethodGet, "/auth/.well-known/jwks.json", nil)
+	s.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	jwks := &model.JWKS{}
+	if err := json.NewDecoder(rec.Body).Decode(&jwks); err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, jwks.Keys)
+}
+
+func TestServer_RotateSigningKey(t *testing.T) {
+	s := NewTestServer(t)
+
+	s.CreateTestUser(t, 1, true)
+	s.CreateTestUser(t, 1, false)
+	initialKid := s.accessKeys.Active().Kid
+
+	testCases := []struct {
+		name             string
+		loginPayload     map[string]string
+		purpose          string
+		expectedStatus   int
+		expectedErrorMsg string
+	}{
+		{
+			name: "rotate access key",
+			loginPayload: map[string]string{
+				"email":    "test0@test.test",
+				"password": "test_password0",
+			},
+			purpose:          model.AccessKeyPurpose,
+			expectedStatus:   http.StatusCreated,
+			expectedErrorMsg: "",
+		},
+		{
+			name: "rotate refresh key",
+			loginPayload: map[string]string{
+				"email":    "test0@test.test",
+				"password": "test_password0",
+			},
+			purpose:          model.RefreshKeyPurpose,
+			expectedStatus:   http.StatusCreated,
+			expectedErrorMsg: "",
+		},
+		{
+			name: "invalid purpose",
+			loginPayload: map[string]string{
+				"email":    "test0@test.test",
+				"password": "test_password0",
+			},
+			purpose:          "invalid",
+			expectedStatus:   http.StatusBadRequest,
+			expectedErrorMsg: "invalid signing key purpose: invalid",
+		},
+		{
+			name: "non-admin",
+			loginPayload: map[string]string{
+				"email":    "test1@test.test",
+				"password": "test_password1",
+			},
+			purpose:          model.AccessKeyPurpose,
+			expectedStatus:   http.StatusUnauthorized,
+			expectedErrorMsg: "unauthorized",
+		},
+	}
+
+	for _, tc := range testCases {
+		accessToken := s.LoginTestUser(
+			t,
+			tc.loginPayload["email"],
+			tc.loginPayload["password"],
+		)
+
+		rec := httptest.NewRecorder()
+		req := s.CreateTestRequest(
+			t, http.MethodPost,
+			"/auth/admin/signing-key/rotate",
+			map[string]interface{}{"purpose": tc.purpose},
+		)
+		req.Header.Add("Authorization", "Bearer "+accessToken)
+		s.ServeHTTP(rec, req)
+
+		assert.Equal(t, tc.expectedStatus, rec.Code, tc.name)
+		if tc.expectedErrorMsg == "" {
+			res := &signingKeyResponse{}
+			json.NewDecoder(rec.Body).Decode(&res)
+			ring, _ := s.keyRing(tc.purpose)
+			assert.Equal(t, ring.Active().Kid, res.Kid, tc.name)
+			assert.True(t, res.Active, tc.name)
+		} else {
+			res := struct {
+				ErrorMsg string `json:"error"`
+			}{}
+			json.NewDecoder(rec.Body).Decode(&res)
+			assert.Equal(t, tc.expectedErrorMsg, res.ErrorMsg, tc.name)
+		}
+	}
+
+	// The rotated key is persisted as retired but still verifies old tokens
+	oldToken := s.LoginTestUser(t, "test0@test.test", "test_password0")
+	s.rotateKeyRing(model.AccessKeyPurpose, time.Now())
+	assert.NotEqual(t, initialKid, s.accessKeys.Active().Kid)
+
+	rec := httptest.NewRecorder()
+	req := s.CreateTestRequest(t, http.MethodGet, "/auth/admin/signing-key", nil)
+	req.Header.Add("Authorization", "Bearer "+oldToken)
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	keys := []*signingKeyResponse{}
+	json.NewDecoder(rec.Body).Decode(&keys)
+	assert.Len(t, keys, 5)
+
+	persistedKeys, err := s.store.SigningKey().GetAll(model.AccessKeyPurpose)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, persistedKeys, 3)
+
+	rec = httptest.NewRecorder()
+	req = s.CreateTestRequest(t, http.MethodGet, "/auth/.well-known/jwks.json", nil)
+	s.ServeHTTP(rec, req)
+	jwks := &model.JWKS{}
+	json.NewDecoder(rec.Body).Decode(&jwks)
+	assert.Len(t, jwks.Keys, 3)
+}
+
+func TestServer_RotateSigningKey_ConfiguredAlg(t *testing.T) {
+	s := NewTestServer(t)
+	s.config.AccessKeyAlg = "EdDSA"
+
+	active, err := s.rotateKeyRing(model.AccessKeyPurpose, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "EdDSA", active.Method.Alg())
+	assert.Equal(t, "ES256", s.accessKeys.Keys()[1].Method.Alg())
+
+	refreshKey, err := s.rotateKeyRing(model.RefreshKeyPurpose, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "HS256", refreshKey.Method.Alg())
+}
+
+func TestServer_DeleteSigningKey(t *testing.T) {
+	s := NewTestServer(t)
+
+	s.CreateTestUser(t, 1, true)
+	oldToken := s.LoginTestUser(t, "test0@test.test", "test_password0")
+	active, err := s.rotateKeyRing(model.AccessKeyPurpose, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	retiredKid := s.accessKeys.Keys()[1].Kid
+	accessToken := s.LoginTestUser(t, "test0@test.test", "test_password0")
+
+	testCases := []struct {
+		name             string
+		kid              string
+		expectedStatus   int
+		expectedErrorMsg string
+	}{
+		{
+			name:             "active key",
+			kid:              active.Kid,
+			expectedStatus:   http.StatusBadRequest,
+			expectedErrorMsg: "cannot remove the active signing key",
+		},
+		{
+			name:             "key not found",
+			kid:              "not-found",
+			expectedStatus:   http.StatusNotFound,
+			expectedErrorMsg: "signing key not found",
+		},
+		{
+			name:             "success",
+			kid:              retiredKid,
+			expectedStatus:   http.StatusOK,
+			expectedErrorMsg: "",
+		},
+	}
+
+	for _, tc := range testCases {
+		rec := httptest.NewRecorder()
+		req := s.CreateTestRequest(
+			t, http.MethodDelete,
+			"/auth/admin/signing-key/"+tc.kid,
+			nil,
+		)
+		req.Header.Add("Authorization", "Bearer "+accessToken)
+		s.ServeHTTP(rec, req)
+
+		assert.Equal(t, tc.expectedStatus, rec.Code, tc.name)
+		res := struct {
+			ErrorMsg string `json:"error"`
+		}{}
+		json.NewDecoder(rec.Body).Decode(&res)
+		assert.Equal(t, tc.expectedErrorMsg, res.ErrorMsg, tc.name)
+	}
+
+	// Tokens signed by the deleted key are rejected immediately
+	rec := httptest.NewRecorder()
+	req := s.CreateTestRequest(t, http.MethodGet, "/auth/admin/signing-key", nil)
+	req.Header.Add("Authorization", "Bearer "+oldToken)
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestServer_MaintainKeyRings_StopsOnShutdown(t *testing.T) {
+	s := NewTestServer(t)
+
+	stopped := make(chan struct{})
+	go func() {
+		s.maintainKeyRings(time.Hour, s.done)
+		close(stopped)
+	}()
+	assert.NoError(t, s.Shutdown(context.Background()))
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("key ring maintenance still running after shutdown")
+	}
+}
@@ -0,0 +1,110 @@
+package httpserver
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// mailQueueSize bounds the emails waiting to be sent, further requests
+	// are dropped until the workers catch up.
+	mailQueueSize    = 100
+	mailQueueWorkers = 2
+	// mailThrottle is the shortest time between two emails of a kind to an
+	// address.
+	mailThrottle = time.Minute
+)
+
+// The kinds of emails, each throttled on its own.
+const (
+	mailPasswordReset = "password-reset"
+	mailVerification  = "verification"
+)
+
+// mailQueue sends the emails requested by the routes through a fixed number
+// of workers, so that the requests neither wait for the mail server nor start
+// an unbounded number of sends.
+type mailQueue struct {
+	tasks   chan func()
+	workers sync.WaitGroup
+
+	mu         sync.Mutex
+	closed     bool
+	throttle   time.Duration
+	lastQueued map[string]time.Time
+}
+
+func newMailQueue(size int, workers int, throttle time.Duration) *mailQueue {
+	q := &mailQueue{
+		tasks:      make(chan func(), size),
+		throttle:   throttle,
+		lastQueued: map[string]time.Time{},
+	}
+
+	q.workers.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer q.workers.Done()
+			for task := range q.tasks {
+				task()
+			}
+		}()
+	}
+
+	return q
+}
+
+// enqueue queues the task sending an email of the kind to the address. The
+// task is dropped when an email of the kind to the address was queued less
+// than throttle ago, or when the queue is full or closed. It reports whether
+// the task was queued.
+func (q *mailQueue) enqueue(kind string, address string, now time.Time, task func()) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return false
+	}
+	for k, queuedAt := range q.lastQueued {
+		if now.Sub(queuedAt) >= q.throttle {
+			delete(q.lastQueued, k)
+		}
+	}
+	key := kind + " " + strings.ToLower(strings.TrimSpace(address))
+	if _, throttled := q.lastQueued[key]; throttled {
+		return false
+	}
+
+	select {
+	case q.tasks <- task:
+		q.lastQueued[key] = now
+		return true
+	default:
+		return false
+	}
+}
+
+// close stops accepting tasks and waits for the queued ones to be done, or
+// for ctx to be done.
+func (q *mailQueue) close(ctx context.Context) error {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.tasks)
+	}
+	q.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		q.workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
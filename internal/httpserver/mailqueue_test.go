@@ -0,0 +1,105 @@
+package httpserver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/anoobz/dualread/auth/internal/mailer"
+	"github.com/stretchr/testify/assert"
+)
+
+// testOutbox is the outbox of the tests sending emails through the mail
+// queue. Every message kept is also handed to sent, so that the tests wait
+// for the emails rather than for the queue.
+type testOutbox struct {
+	*mailer.Outbox
+	sent chan *mailer.Message
+}
+
+// newTestOutbox replaces the mailer of the server.
+func newTestOutbox(s *server) *testOutbox {
+	o := &testOutbox{
+		Outbox: mailer.NewOutbox(""),
+		sent:   make(chan *mailer.Message, mailQueueSize),
+	}
+	s.mailer = o
+
+	return o
+}
+
+func (o *testOutbox) Send(msg *mailer.Message) error {
+	if err := o.Outbox.Send(msg); err != nil {
+		return err
+	}
+	o.sent <- msg
+
+	return nil
+}
+
+// waitSent returns the next message sent, the test fails when none is sent
+// within a second.
+func (o *testOutbox) waitSent(t *testing.T) *mailer.Message {
+	t.Helper()
+
+	select {
+	case msg := <-o.sent:
+		return msg
+	case <-time.After(time.Second):
+		t.Fatal("no message sent")
+		return nil
+	}
+}
+
+func TestMailQueue_Enqueue(t *testing.T) {
+	q := newMailQueue(1, 1, time.Minute)
+	release := make(chan struct{})
+	started := make(chan struct{})
+	now := time.Now()
+
+	assert.True(t, q.enqueue(mailVerification, "a@test.test", now, func() {
+		close(started)
+		<-release
+	}))
+	<-started
+
+	// The worker is busy and the queue holds a single task
+	done := make(chan struct{})
+	assert.True(t, q.enqueue(mailVerification, "b@test.test", now, func() { close(done) }))
+	assert.False(t, q.enqueue(mailVerification, "c@test.test", now, func() {}))
+
+	// The throttle is per address, case insensitive, and expires
+	assert.False(t, q.enqueue(mailVerification, "A@test.test", now.Add(time.Second), func() {}))
+	close(release)
+	<-done
+	// Each kind of email has its own throttle
+	assert.True(t, q.enqueue(mailPasswordReset, "a@test.test", now.Add(time.Second), func() {}))
+	assert.False(t, q.enqueue(mailPasswordReset, "a@test.test", now.Add(2*time.Second), func() {}))
+	assert.True(t, q.enqueue(mailVerification, "a@test.test", now.Add(time.Minute), func() {}))
+	assert.NoError(t, q.close(context.Background()))
+}
+
+func TestMailQueue_Close(t *testing.T) {
+	q := newMailQueue(10, 1, time.Minute)
+	sent := 0
+	for _, address := range []string{"a@test.test", "b@test.test", "c@test.test"} {
+		q.enqueue(mailVerification, address, time.Now(), func() {
+			time.Sleep(10 * time.Millisecond)
+			sent++
+		})
+	}
+
+	// The queued emails are sent before close returns
+	assert.NoError(t, q.close(context.Background()))
+	assert.Equal(t, 3, sent)
+	assert.False(t, q.enqueue(mailVerification, "d@test.test", time.Now(), func() {}))
+
+	// close gives up on the emails when ctx is done
+	q = newMailQueue(10, 1, time.Minute)
+	release := make(chan struct{})
+	q.enqueue(mailVerification, "a@test.test", time.Now(), func() { <-release })
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, q.close(ctx), context.DeadlineExceeded)
+	close(release)
+}
@@ -0,0 +1,254 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/anoobz/dualread/auth/internal/model"
+	"github.com/gorilla/mux"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// sessionResponse describes a login of the user, identified by the family of
+// its refresh tokens.
+type sessionResponse struct {
+	Id        string `json:"id"`
+	Created   int64  `json:"created"`
+	LastUsed  int64  `json:"last_used"`
+	Expires   int64  `json:"exp"`
+	ClientIp  string `json:"client_ip"`
+	UserAgent string `json:"user_agent"`
+	Current   bool   `json:"current"`
+}
+
+func (s *server) getMySessions() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := getAccessClaims(r)
+		userId, err := claims.UserId()
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		tokens, err := s.store.AuthToken().GetByUser(userId)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		// Consumed tokens are the past of a session, its live token is the
+		// only one that has not been consumed
+		now := time.Now().Unix()
+		sessions := []*sessionResponse{}
+		for _, t := range tokens {
+			if t.Consumed || t.Expires <= now {
+				continue
+			}
+			sessions = append(sessions, &sessionResponse{
+				Id:        t.Family,
+				Created:   t.Created,
+				LastUsed:  t.LastUsed,
+				Expires:   t.Expires,
+				ClientIp:  t.ClientIp,
+				UserAgent: t.UserAgent,
+				Current:   t.Family == claims.SessionId,
+			})
+		}
+		sort.Slice(sessions, func(i, j int) bool {
+			return sessions[i].LastUsed > sessions[j].LastUsed
+		})
+
+		s.respond(w, r, http.StatusOK, sessions)
+	}
+}
+
+// deleteMySession ends a session of the user. Access tokens already issued to
+// the session stay valid until they expire.
+func (s *server) deleteMySession() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId, err := getAccessClaims(r).UserId()
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		tokens, err := s.store.AuthToken().GetByUser(userId)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		family := mux.Vars(r)["id"]
+		for _, t := range tokens {
+			if t.Family != family {
+				continue
+			}
+			if err := s.store.AuthToken().DeleteFamily(family); err != nil {
+				s.error(w, r, http.StatusInternalServerError, err)
+				return
+			}
+
+			s.respond(w, r, http.StatusOK, nil)
+			return
+		}
+
+		// Sessions of other users are reported as missing to not disclose them
+		s.error(w, r, http.StatusNotFound, errors.New("session not found"))
+	}
+}
+
+func (s *server) getMe() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.respond(w, r, http.StatusOK, newUserResponse(getUser(r)))
+	}
+}
+
+// updateMe changes the fields of the account users are free to change on
+// their own. Other fields are refused rather than ignored.
+func (s *server) updateMe() http.HandlerFunc {
+	type payload struct {
+		EmailSubscribed *bool `json:"email_subscribed"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		payload := payload{}
+		decoder := json.NewDecoder(r.Body)
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&payload); err != nil {
+			s.error(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		user := getUser(r)
+		clauses := map[string]interface{}{}
+		if payload.EmailSubscribed != nil {
+			clauses["email_subscribed"] = *payload.EmailSubscribed
+		}
+		if len(clauses) > 0 {
+			if err := s.store.User().Update(user.ID, clauses); err != nil {
+				s.error(w, r, http.StatusInternalServerError, err)
+				return
+			}
+		}
+
+		user, err := s.store.User().GetById(user.ID)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		s.respond(w, r, http.StatusOK, newUserResponse(user))
+	}
+}
+
+// changeMyPassword replaces the password of the user, who must prove they
+// know the current one. The access tokens issued so far are revoked, the API
+// keys are deleted and the other sessions of the user and the pending
+// password resets are ended. The session of the request is kept and
+// responded with a new access token in place of the revoked one.
+func (s *server) changeMyPassword() http.HandlerFunc {
+	type payload struct {
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		payload := payload{}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			s.error(w, r, http.StatusBadRequest, err)
+			return
+		}
+		if payload.CurrentPassword == "" || payload.NewPassword == "" {
+			s.error(w, r, http.StatusBadRequest, errors.New("a required field is empty"))
+			return
+		}
+
+		user := getUser(r)
+		if err := bcrypt.CompareHashAndPassword(
+			[]byte(user.Password), []byte(payload.CurrentPassword),
+		); err != nil {
+			s.error(w, r, http.StatusForbidden, errors.New("invalid current password"))
+			return
+		}
+
+		encryptedPassword, err := hashPassword(payload.NewPassword)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		err = s.store.User().Update(user.ID, map[string]interface{}{"password": encryptedPassword})
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		tokens, err := s.store.AuthToken().GetByUser(user.ID)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		// The consumed tokens of a session share its family
+		ended := map[string]bool{getAccessClaims(r).SessionId: true}
+		for _, t := range tokens {
+			if ended[t.Family] {
+				continue
+			}
+			if err := s.store.AuthToken().DeleteFamily(t.Family); err != nil {
+				s.error(w, r, http.StatusInternalServerError, err)
+				return
+			}
+			ended[t.Family] = true
+		}
+		if err := s.store.PasswordReset().DeleteByUser(user.ID); err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		if err := s.store.ApiKey().DeleteByUser(user.ID); err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		// The access tokens are not persisted, so those of the other sessions
+		// are revoked with every token of the user
+		now := time.Now()
+		err = s.store.Revocation().RevokeUser(
+			user.ID,
+			now.UnixMilli(),
+			now.Add(s.config.AccessTokenLifetime).Unix(),
+		)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		claims := getAccessClaims(r)
+		if claims.TokenUse != model.AccessTokenUse {
+			s.respond(w, r, http.StatusOK, nil)
+			return
+		}
+		accessTokenParams, err := s.accessTokenParams(claims.Audience)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		accessTokenParams.Scope = claims.Scope
+		// Tokens issued in the millisecond of the revocation are revoked too
+		accessTokenParams.IssuedAt = now.Add(time.Millisecond)
+		grants, err := s.userGrants(user.ID)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		at, err := model.NewAccessToken(
+			user,
+			grants,
+			claims.SessionId,
+			s.accessKeys.Active(),
+			accessTokenParams,
+		)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		s.respond(w, r, http.StatusOK, at)
+	}
+}
@@ -0,0 +1,426 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/anoobz/dualread/auth/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func getTestSessionId(t *testing.T, s *server, cookie *http.Cookie) string {
+	t.Helper()
+
+	storedToken, _, err := s.findRefreshToken(cookie.Value)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return storedToken.Family
+}
+
+func TestServer_GetMySessions(t *testing.T) {
+	s := NewTestServer(t)
+
+	s.CreateTestUser(t, 2, false)
+	phoneToken := s.LoginTestUser(t, "test0@test.test", "test_password0")
+	libraryCookie := s.LoginTestSession(t, "test0@test.test", "test_password0")
+	s.LoginTestSession(t, "test1@test.test", "test_password1")
+
+	claims, err := s.parseAccessToken(phoneToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	req := s.CreateTestRequest(t, http.MethodGet, "/auth/me/sessions", nil)
+	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", phoneToken))
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	sessions := []*sessionResponse{}
+	json.NewDecoder(rec.Body).Decode(&sessions)
+	if !assert.Len(t, sessions, 2) {
+		return
+	}
+	for _, session := range sessions {
+		assert.Equal(t, session.Id == claims.SessionId, session.Current)
+	}
+	assert.ElementsMatch(
+		t,
+		[]string{claims.SessionId, getTestSessionId(t, s, libraryCookie)},
+		[]string{sessions[0].Id, sessions[1].Id},
+	)
+
+	// A rotated session is still listed once
+	rec = httptest.NewRecorder()
+	req = s.CreateTestRequest(t, http.MethodPost, "/auth/refresh-access-token", nil)
+	AddTestSessionCookie(req, libraryCookie)
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = httptest.NewRecorder()
+	req = s.CreateTestRequest(t, http.MethodGet, "/auth/me/sessions", nil)
+	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", phoneToken))
+	s.ServeHTTP(rec, req)
+	sessions = []*sessionResponse{}
+	json.NewDecoder(rec.Body).Decode(&sessions)
+	assert.Len(t, sessions, 2)
+
+	rec = httptest.NewRecorder()
+	req = s.CreateTestRequest(t, http.MethodGet, "/auth/me/sessions", nil)
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestServer_DeleteMySession(t *testing.T) {
+	s := NewTestServer(t)
+
+	s.CreateTestUser(t, 2, false)
+	phoneToken := s.LoginTestUser(t, "test0@test.test", "test_password0")
+	libraryCookie := s.LoginTestSession(t, "test0@test.test", "test_password0")
+	otherUserCookie := s.LoginTestSession(t, "test1@test.test", "test_password1")
+
+	testCases := []struct {
+		name             string
+		sessionId        string
+		expectedStatus   int
+		expectedErrorMsg string
+	}{
+		{
+			name:             "success",
+			sessionId:        getTestSessionId(t, s, libraryCookie),
+			expectedStatus:   http.StatusOK,
+			expectedErrorMsg: "",
+		},
+		{
+			name:             "session of another user",
+			sessionId:        getTestSessionId(t, s, otherUserCookie),
+			expectedStatus:   http.StatusNotFound,
+			expectedErrorMsg: "session not found",
+		},
+		{
+			name:             "session not found",
+			sessionId:        "not-found",
+			expectedStatus:   http.StatusNotFound,
+			expectedErrorMsg: "session not found",
+		},
+	}
+
+	for _, tc := range testCases {
+		rec := httptest.NewRecorder()
+		req := s.CreateTestRequest(
+			t, http.MethodDelete,
+			"/auth/me/sessions/"+tc.sessionId,
+			nil,
+		)
+		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", phoneToken))
+		s.ServeHTTP(rec, req)
+
+		assert.Equal(t, tc.expectedStatus, rec.Code, tc.name)
+		res := struct {
+			ErrorMsg string `json:"error"`
+		}{}
+		json.NewDecoder(rec.Body).Decode(&res)
+		assert.Equal(t, tc.expectedErrorMsg, res.ErrorMsg, tc.name)
+	}
+
+	// The deleted session can no longer be refreshed, the others can
+	rec := httptest.NewRecorder()
+	req := s.CreateTestRequest(t, http.MethodPost, "/auth/refresh-access-token", nil)
+	AddTestSessionCookie(req, libraryCookie)
+	s.ServeHTTP(rec, req)
+	assert.NotEqual(t, http.StatusOK, rec.Code)
+
+	rec = httptest.NewRecorder()
+	req = s.CreateTestRequest(t, http.MethodPost, "/auth/refresh-access-token", nil)
+	AddTestSessionCookie(req, otherUserCookie)
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+// loginTestSessionUser logs the user in and returns the access token along with
+// the refresh token cookie of the same session.
+func loginTestSessionUser(
+	t *testing.T,
+	s *server,
+	email string,
+	password string,
+) (string, *http.Cookie) {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	req := s.CreateTestRequest(
+		t, http.MethodPost, "/auth/login",
+		map[string]interface{}{"email": email, "password": password},
+	)
+	s.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("login failed with status %d", rec.Code)
+	}
+
+	res := struct {
+		TokenString string `json:"token"`
+	}{}
+	json.NewDecoder(rec.Body).Decode(&res)
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == refreshTokenCookieName {
+			return res.TokenString, c
+		}
+	}
+
+	t.Fatal("refresh token cookie not set")
+	return "", nil
+}
+
+func requestTestMeRoute(
+	t *testing.T,
+	s *server,
+	method string,
+	url string,
+	accessToken string,
+	payload map[string]interface{},
+) (*httptest.ResponseRecorder, string) {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	req := s.CreateTestRequest(t, method, url, payload)
+	req.Header.Add("Authorization", "Bearer "+accessToken)
+	s.ServeHTTP(rec, req)
+
+	res := struct {
+		ErrorMsg string `json:"error"`
+	}{}
+	json.Unmarshal(rec.Body.Bytes(), &res)
+	return rec, res.ErrorMsg
+}
+
+func TestServer_GetMe(t *testing.T) {
+	s := NewTestServer(t)
+
+	users := s.CreateTestUser(t, 2, false)
+	accessToken := s.LoginTestUser(t, "test0@test.test", "test_password0")
+	inactiveToken := s.LoginTestUser(t, "test1@test.test", "test_password1")
+	if err := s.store.User().Update(users[1].ID, map[string]interface{}{"active": false}); err != nil {
+		t.Fatal(err)
+	}
+	client, secret := s.CreateTestServiceClient(t, []string{"tts"})
+	_, clientToken, _ := requestTestToken(
+		t, s,
+		url.Values{"grant_type": {"client_credentials"}},
+		client.Id, secret,
+	)
+
+	testCases := []struct {
+		name             string
+		accessToken      string
+		expectedStatus   int
+		expectedErrorMsg string
+	}{
+		{
+			name:           "user",
+			accessToken:    accessToken,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:             "inactive user",
+			accessToken:      inactiveToken,
+			expectedStatus:   http.StatusUnauthorized,
+			expectedErrorMsg: "user not found",
+		},
+		{
+			name:             "client",
+			accessToken:      clientToken.AccessToken,
+			expectedStatus:   http.StatusUnauthorized,
+			expectedErrorMsg: "token has no user",
+		},
+	}
+
+	for _, tc := range testCases {
+		rec, errorMsg := requestTestMeRoute(t, s, http.MethodGet, "/auth/me", tc.accessToken, nil)
+		assert.Equal(t, tc.expectedStatus, rec.Code, tc.name)
+		assert.Equal(t, tc.expectedErrorMsg, errorMsg, tc.name)
+		if tc.expectedErrorMsg != "" {
+			continue
+		}
+
+		res := map[string]interface{}{}
+		json.NewDecoder(rec.Body).Decode(&res)
+		assert.Equal(t, "test0@test.test", res["email"], tc.name)
+		assert.Equal(t, float64(users[0].ID), res["id"], tc.name)
+		assert.NotContains(t, res, "password", tc.name)
+	}
+}
+
+func TestServer_UpdateMe(t *testing.T) {
+	s := NewTestServer(t)
+
+	users := s.CreateTestUser(t, 1, false)
+	accessToken := s.LoginTestUser(t, "test0@test.test", "test_password0")
+
+	testCases := []struct {
+		name               string
+		payload            map[string]interface{}
+		expectedStatus     int
+		expectedErrorMsg   string
+		expectedSubscribed bool
+	}{
+		{
+			name:               "unsubscribe",
+			payload:            map[string]interface{}{"email_subscribed": false},
+			expectedStatus:     http.StatusOK,
+			expectedSubscribed: false,
+		},
+		{
+			name:               "no change",
+			payload:            map[string]interface{}{},
+			expectedStatus:     http.StatusOK,
+			expectedSubscribed: false,
+		},
+		{
+			name:             "field the user cannot change",
+			payload:          map[string]interface{}{"email_verified": true},
+			expectedStatus:   http.StatusBadRequest,
+			expectedErrorMsg: `json: unknown field "email_verified"`,
+		},
+		{
+			name:               "subscribe",
+			payload:            map[string]interface{}{"email_subscribed": true},
+			expectedStatus:     http.StatusOK,
+			expectedSubscribed: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		rec, errorMsg := requestTestMeRoute(
+			t, s,
+			http.MethodPatch,
+			"/auth/me",
+			accessToken,
+			tc.payload,
+		)
+		assert.Equal(t, tc.expectedStatus, rec.Code, tc.name)
+		assert.Equal(t, tc.expectedErrorMsg, errorMsg, tc.name)
+		if tc.expectedErrorMsg != "" {
+			continue
+		}
+
+		assert.NotContains(t, rec.Body.String(), `"password"`, tc.name)
+		res := &userResponse{}
+		json.NewDecoder(rec.Body).Decode(&res)
+		assert.Equal(t, tc.expectedSubscribed, res.EmailSubscribed, tc.name)
+	}
+
+	user, err := s.store.User().GetById(users[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, user.EmailVerified)
+	assert.True(t, user.EmailSubscribed)
+}
+
+func TestServer_ChangeMyPassword(t *testing.T) {
+	s := NewTestServer(t)
+
+	users := s.CreateTestUser(t, 1, false)
+	accessToken, cookie := loginTestSessionUser(t, s, "test0@test.test", "test_password0")
+	otherAccessToken, otherCookie := loginTestSessionUser(t, s, "test0@test.test", "test_password0")
+	reset, _ := store.CreateTestPasswordReset(t, s.store, users[0], time.Hour)
+	_, apiKeys := store.CreateTestApiKey(t, s.store, users[0], 1, 0)
+
+	testCases := []struct {
+		name             string
+		payload          map[string]interface{}
+		expectedStatus   int
+		expectedErrorMsg string
+	}{
+		{
+			name:             "missing current password",
+			payload:          map[string]interface{}{"new_password": "new_password"},
+			expectedStatus:   http.StatusBadRequest,
+			expectedErrorMsg: "a required field is empty",
+		},
+		{
+			name: "wrong current password",
+			payload: map[string]interface{}{
+				"current_password": "wrong_password",
+				"new_password":     "new_password",
+			},
+			expectedStatus:   http.StatusForbidden,
+			expectedErrorMsg: "invalid current password",
+		},
+		{
+			name: "success",
+			payload: map[string]interface{}{
+				"current_password": "test_password0",
+				"new_password":     "new_password",
+			},
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	newAccessToken := ""
+	for _, tc := range testCases {
+		rec, errorMsg := requestTestMeRoute(
+			t, s,
+			http.MethodPost,
+			"/auth/me/password",
+			accessToken,
+			tc.payload,
+		)
+		assert.Equal(t, tc.expectedStatus, rec.Code, tc.name)
+		assert.Equal(t, tc.expectedErrorMsg, errorMsg, tc.name)
+
+		if rec.Code == http.StatusOK {
+			res := struct {
+				TokenString string `json:"token"`
+			}{}
+			json.Unmarshal(rec.Body.Bytes(), &res)
+			newAccessToken = res.TokenString
+		}
+	}
+
+	s.LoginTestUser(t, "test0@test.test", "new_password")
+
+	// The access tokens issued so far are revoked, the request is handed a
+	// new one and the api keys are deleted
+	for _, tc := range []struct {
+		name          string
+		authorization string
+		expectedOk    bool
+	}{
+		{name: "revoked", authorization: "Bearer " + accessToken, expectedOk: false},
+		{name: "other session", authorization: "Bearer " + otherAccessToken, expectedOk: false},
+		{name: "api key", authorization: "ApiKey " + apiKeys[0], expectedOk: false},
+		{name: "new", authorization: "Bearer " + newAccessToken, expectedOk: true},
+	} {
+		rec := httptest.NewRecorder()
+		req := s.CreateTestRequest(t, http.MethodGet, "/auth/me", nil)
+		req.Header.Add("Authorization", tc.authorization)
+		s.ServeHTTP(rec, req)
+		assert.Equal(t, tc.expectedOk, rec.Code == http.StatusOK, tc.name)
+	}
+
+	// The session of the request is kept and the others are ended
+	for _, tc := range []struct {
+		cookie     *http.Cookie
+		expectedOk bool
+	}{
+		{cookie: cookie, expectedOk: true},
+		{cookie: otherCookie, expectedOk: false},
+	} {
+		rec := httptest.NewRecorder()
+		req := s.CreateTestRequest(t, http.MethodPost, "/auth/refresh-access-token", nil)
+		AddTestSessionCookie(req, tc.cookie)
+		s.ServeHTTP(rec, req)
+		assert.Equal(t, tc.expectedOk, rec.Code == http.StatusOK)
+	}
+
+	_, err := s.store.PasswordReset().Consume(reset.TokenHash)
+	assert.Error(t, err)
+}
@@ -0,0 +1,443 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/anoobz/dualread/auth/internal/model"
+)
+
+// authCodeLifetime is short since the code is exchanged by the client right
+// after the redirect.
+const authCodeLifetime = time.Minute
+
+// oauthErrorResponse is the error body of the OAuth endpoints, as defined in
+// RFC 6749 section 5.2.
+type oauthErrorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+	IdToken      string `json:"id_token,omitempty"`
+}
+
+// authorize issues an authorization code to the client for the user of the
+// refresh token cookie, the session opened by logging in to the web app.
+// Errors are only redirected to the client once its redirect uri is known to
+// be registered.
+//
+// The client obtains the user scopes unless it requests a scope, the
+// permissions of the user's roles must be requested explicitly.
+func (s *server) authorize() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		client, err := s.store.Client().GetById(query.Get("client_id"))
+		if err != nil {
+			s.oauthError(w, r, http.StatusBadRequest, "invalid_client", "unknown client")
+			return
+		}
+		redirectUri := query.Get("redirect_uri")
+		if !client.HasRedirectUri(redirectUri) {
+			s.oauthError(
+				w, r,
+				http.StatusBadRequest,
+				"invalid_request",
+				"invalid redirect uri",
+			)
+			return
+		}
+
+		state := query.Get("state")
+		if query.Get("response_type") != "code" {
+			redirectError(w, r, redirectUri, state, "unsupported_response_type")
+			return
+		}
+		codeChallenge := query.Get("code_challenge")
+		if query.Get("code_challenge_method") != model.CodeChallengeMethodS256 ||
+			!model.ValidCodeChallenge(codeChallenge) {
+			redirectError(w, r, redirectUri, state, "invalid_request")
+			return
+		}
+		audience := model.Audience(query["audience"])
+		if _, err := s.accessTokenParams(audience); err != nil {
+			redirectError(w, r, redirectUri, state, "invalid_request")
+			return
+		}
+
+		storedToken, _, err := s.getStoredRefreshToken(r)
+		if err != nil || storedToken.Consumed {
+			if s.config.LoginUrl != "" {
+				s.redirectToLogin(w, r)
+				return
+			}
+			redirectError(w, r, redirectUri, state, "login_required")
+			return
+		}
+		user, err := s.store.User().GetById(storedToken.UserId)
+		if err != nil || !user.Active {
+			redirectError(w, r, redirectUri, state, "access_denied")
+			return
+		}
+		grants, err := s.userGrants(user.ID)
+		if err != nil {
+			redirectError(w, r, redirectUri, state, "server_error")
+			return
+		}
+		requestedScope := query.Get("scope")
+		if strings.TrimSpace(requestedScope) == "" {
+			requestedScope = strings.Join(model.UserScopes, " ")
+		}
+		scope, err := model.GrantScope(grants.Scopes(), requestedScope)
+		if err != nil {
+			redirectError(w, r, redirectUri, state, "invalid_scope")
+			return
+		}
+
+		code, err := model.NewAuthCode(
+			client.Id,
+			user.ID,
+			redirectUri,
+			codeChallenge,
+			audience,
+			scope,
+			authCodeLifetime,
+		)
+		if err != nil {
+			redirectError(w, r, redirectUri, state, "server_error")
+			return
+		}
+		code.Nonce = query.Get("nonce")
+		err = s.store.AuthCode().Insert(code.Hashed(s.config.RefreshTokenHashKey))
+		if err != nil {
+			redirectError(w, r, redirectUri, state, "server_error")
+			return
+		}
+
+		redirect(w, r, redirectUri, url.Values{"code": {code.Code}, "state": {state}})
+	}
+}
+
+// token is the token endpoint of the OAuth flows, its parameters are form
+// encoded.
+func (s *server) token() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		if err := r.ParseForm(); err != nil {
+			s.oauthError(w, r, http.StatusBadRequest, "invalid_request", err.Error())
+			return
+		}
+
+		switch r.PostForm.Get("grant_type") {
+		case "authorization_code":
+			s.exchangeAuthCode(w, r)
+		case "refresh_token":
+			s.grantRefreshToken(w, r)
+		case "client_credentials":
+			s.grantClientCredentials(w, r)
+		default:
+			s.oauthError(w, r, http.StatusBadRequest, "unsupported_grant_type", "")
+		}
+	}
+}
+
+// exchangeAuthCode opens a new session for the client, separate from the
+// session of the web app the code was issued from. Confidential clients must
+// authenticate on top of presenting the code verifier. The codes granting the
+// openid scope are exchanged for an id token as well.
+func (s *server) exchangeAuthCode(w http.ResponseWriter, r *http.Request) {
+	clientId, clientSecret := clientCredentials(r)
+	code, err := s.store.AuthCode().Consume(
+		model.HashToken(s.config.RefreshTokenHashKey, r.PostForm.Get("code")),
+	)
+	if err != nil || code.Expired(time.Now()) {
+		s.oauthError(
+			w, r,
+			http.StatusBadRequest,
+			"invalid_grant",
+			"invalid authorization code",
+		)
+		return
+	}
+	if code.ClientId != clientId || code.RedirectUri != r.PostForm.Get("redirect_uri") {
+		s.oauthError(
+			w, r,
+			http.StatusBadRequest,
+			"invalid_grant",
+			"authorization code issued to another client",
+		)
+		return
+	}
+	client, err := s.store.Client().GetById(clientId)
+	if err != nil || (client.Confidential() && !client.VerifySecret(clientSecret)) {
+		s.invalidClient(w, r)
+		return
+	}
+	if !code.VerifyCodeVerifier(r.PostForm.Get("code_verifier")) {
+		s.oauthError(w, r, http.StatusBadRequest, "invalid_grant", "invalid code verifier")
+		return
+	}
+
+	user, err := s.store.User().GetById(code.UserId)
+	if err != nil || !user.Active {
+		s.oauthError(w, r, http.StatusBadRequest, "invalid_grant", "user not found")
+		return
+	}
+	accessTokenParams, err := s.accessTokenParams(code.Audience)
+	if err != nil {
+		s.oauthError(w, r, http.StatusBadRequest, "invalid_grant", err.Error())
+		return
+	}
+
+	grants, err := s.userGrants(user.ID)
+	if err != nil {
+		s.oauthError(w, r, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+	// The scope is checked again since the user may have lost a role since
+	// authorizing the client. A code without a scope would grant every scope.
+	if code.Scope == "" {
+		s.oauthError(w, r, http.StatusBadRequest, "invalid_grant", "invalid authorization code")
+		return
+	}
+	scope, err := model.GrantScope(grants.Scopes(), code.Scope)
+	if err != nil {
+		s.oauthError(w, r, http.StatusBadRequest, "invalid_scope", err.Error())
+		return
+	}
+	accessTokenParams.Scope = scope
+
+	refreshTokenParams := s.refreshTokenParams(s.config.RefreshTokenLifetime)
+	refreshTokenParams.Scope = scope
+	refreshTokenParams.ClientId = client.Id
+	rt, err := model.NewRefreshToken(user, s.refreshKeys.Active(), refreshTokenParams)
+	if err != nil {
+		s.oauthError(w, r, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+	s.setSessionMetadata(rt, r)
+	at, err := model.NewAccessToken(user, grants, rt.Family, s.accessKeys.Active(), accessTokenParams)
+	if err != nil {
+		s.oauthError(w, r, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+	idToken := ""
+	if model.Contains(strings.Fields(scope), model.ScopeOpenId) {
+		idToken, err = model.NewIdToken(
+			user,
+			client.Id,
+			code.Nonce,
+			s.accessKeys.Active(),
+			model.TokenParams{
+				Issuer:   s.config.Issuer,
+				Lifetime: s.config.AccessTokenLifetime,
+				Scope:    scope,
+			},
+		)
+		if err != nil {
+			s.oauthError(w, r, http.StatusInternalServerError, "server_error", "")
+			return
+		}
+	}
+	if err := s.insertRefreshToken(rt); err != nil {
+		s.oauthError(w, r, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+
+	s.respond(w, r, http.StatusOK, &tokenResponse{
+		AccessToken:  at.TokenString,
+		TokenType:    "Bearer",
+		ExpiresIn:    at.Expires - time.Now().Unix(),
+		RefreshToken: rt.TokenString,
+		Scope:        scope,
+		IdToken:      idToken,
+	})
+}
+
+// grantRefreshToken rotates the refresh token of a session opened by the
+// authorization_code grant, like refreshAccessToken does for the sessions of
+// the web app. The token is only accepted from the client it was issued to,
+// which must authenticate when confidential. The scope of the new access
+// token is bounded by the scope granted to the client and by the current
+// grants of the user.
+func (s *server) grantRefreshToken(w http.ResponseWriter, r *http.Request) {
+	clientId, clientSecret := clientCredentials(r)
+	client, err := s.store.Client().GetById(clientId)
+	if err != nil || (client.Confidential() && !client.VerifySecret(clientSecret)) {
+		s.invalidClient(w, r)
+		return
+	}
+	storedToken, claims, err := s.findRefreshToken(r.PostForm.Get("refresh_token"))
+	if err != nil || claims.ClientId != client.Id {
+		s.oauthError(w, r, http.StatusBadRequest, "invalid_grant", "invalid refresh token")
+		return
+	}
+
+	user, err := s.store.User().GetById(storedToken.UserId)
+	if err != nil || !user.Active {
+		s.oauthError(w, r, http.StatusBadRequest, "invalid_grant", "user not found")
+		return
+	}
+	accessTokenParams, err := s.accessTokenParams(model.Audience(r.PostForm["audience"]))
+	if err != nil {
+		s.oauthError(w, r, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+
+	grants, err := s.userGrants(user.ID)
+	if err != nil {
+		s.oauthError(w, r, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+	// The scope is checked before the token is consumed so that a bad
+	// request does not end the session
+	scope, err := model.GrantScope(
+		model.FilterScopes(grants.Scopes(), claims.Scope),
+		r.PostForm.Get("scope"),
+	)
+	if err != nil {
+		s.oauthError(w, r, http.StatusBadRequest, "invalid_scope", err.Error())
+		return
+	}
+	accessTokenParams.Scope = scope
+
+	rt, err := s.rotateRefreshToken(r, user, storedToken, claims)
+	if err == errRefreshTokenReused {
+		s.oauthError(w, r, http.StatusBadRequest, "invalid_grant", err.Error())
+		return
+	}
+	if err != nil {
+		s.oauthError(w, r, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+	at, err := model.NewAccessToken(user, grants, rt.Family, s.accessKeys.Active(), accessTokenParams)
+	if err != nil {
+		s.oauthError(w, r, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+
+	s.respond(w, r, http.StatusOK, &tokenResponse{
+		AccessToken:  at.TokenString,
+		TokenType:    "Bearer",
+		ExpiresIn:    at.Expires - time.Now().Unix(),
+		RefreshToken: rt.TokenString,
+		Scope:        scope,
+	})
+}
+
+// grantClientCredentials issues an access token to a confidential client for
+// itself. No refresh token is issued since the client can authenticate again.
+func (s *server) grantClientCredentials(w http.ResponseWriter, r *http.Request) {
+	clientId, clientSecret := clientCredentials(r)
+	client, err := s.store.Client().GetById(clientId)
+	if err != nil || !client.VerifySecret(clientSecret) {
+		s.invalidClient(w, r)
+		return
+	}
+
+	scope, err := client.GrantScope(r.PostForm.Get("scope"))
+	if err != nil {
+		s.oauthError(w, r, http.StatusBadRequest, "invalid_scope", err.Error())
+		return
+	}
+	accessTokenParams, err := s.accessTokenParams(model.Audience(r.PostForm["audience"]))
+	if err != nil {
+		s.oauthError(w, r, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+
+	accessTokenParams.Scope = scope
+	at, err := model.NewClientAccessToken(client, s.accessKeys.Active(), accessTokenParams)
+	if err != nil {
+		s.oauthError(w, r, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+
+	s.respond(w, r, http.StatusOK, &tokenResponse{
+		AccessToken: at.TokenString,
+		TokenType:   "Bearer",
+		ExpiresIn:   at.Expires - time.Now().Unix(),
+		Scope:       scope,
+	})
+}
+
+// clientCredentials reads the credentials of the client from the basic
+// authorization header, or from the form for the clients which cannot set it.
+func clientCredentials(r *http.Request) (string, string) {
+	if id, secret, ok := r.BasicAuth(); ok {
+		return id, secret
+	}
+
+	return r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+}
+
+func (s *server) invalidClient(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("WWW-Authenticate", `Basic realm="dualread"`)
+	s.oauthError(
+		w, r,
+		http.StatusUnauthorized,
+		"invalid_client",
+		"invalid client credentials",
+	)
+}
+
+func (s *server) oauthError(
+	w http.ResponseWriter,
+	r *http.Request,
+	code int,
+	errorCode string,
+	description string,
+) {
+	s.respond(w, r, code, &oauthErrorResponse{
+		Error:            errorCode,
+		ErrorDescription: description,
+	})
+}
+
+// redirectToLogin sends the user to the login page of the web app, which
+// returns to the authorize request once the user is logged in.
+func (s *server) redirectToLogin(w http.ResponseWriter, r *http.Request) {
+	redirect(
+		w, r,
+		s.config.LoginUrl,
+		url.Values{"return_to": {s.baseUrl(r) + r.URL.RequestURI()}},
+	)
+}
+
+func redirectError(
+	w http.ResponseWriter,
+	r *http.Request,
+	redirectUri string,
+	state string,
+	errorCode string,
+) {
+	redirect(w, r, redirectUri, url.Values{"error": {errorCode}, "state": {state}})
+}
+
+// redirect adds the params to the query of the uri, empty params are left out.
+func redirect(w http.ResponseWriter, r *http.Request, uri string, params url.Values) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	query := u.Query()
+	for key, values := range params {
+		for _, value := range values {
+			if value != "" {
+				query.Add(key, value)
+			}
+		}
+	}
+	u.RawQuery = query.Encode()
+
+	http.Redirect(w, r, u.String(), http.StatusFound)
+}
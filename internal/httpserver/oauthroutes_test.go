@@ -0,0 +1,779 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anoobz/dualread/auth/internal/model"
+	"github.com/anoobz/dualread/auth/internal/store"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func authorizeTestQuery(client *model.Client) url.Values {
+	return url.Values{
+		"response_type":         {"code"},
+		"client_id":             {client.Id},
+		"redirect_uri":          {client.RedirectUris[0]},
+		"code_challenge":        {store.GetTestCodeChallenge()},
+		"code_challenge_method": {model.CodeChallengeMethodS256},
+		"state":                 {"test_state"},
+	}
+}
+
+func authorizeTestClient(
+	t *testing.T,
+	s *server,
+	query url.Values,
+	cookie *http.Cookie,
+) *httptest.ResponseRecorder {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	req := s.CreateTestRequest(
+		t, http.MethodGet,
+		"http://auth.local/auth/authorize?"+query.Encode(),
+		nil,
+	)
+	if cookie != nil {
+		AddTestSessionCookie(req, cookie)
+	}
+	s.ServeHTTP(rec, req)
+
+	return rec
+}
+
+// requestTestToken authenticates the client with basic authentication when
+// an id is given.
+func requestTestToken(
+	t *testing.T,
+	s *server,
+	form url.Values,
+	id string,
+	secret string,
+) (*httptest.ResponseRecorder, *tokenResponse, *oauthErrorResponse) {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	req, err := http.NewRequest(
+		http.MethodPost,
+		"/auth/token",
+		strings.NewReader(form.Encode()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if id != "" {
+		req.SetBasicAuth(id, secret)
+	}
+	s.ServeHTTP(rec, req)
+
+	res := &tokenResponse{}
+	errRes := &oauthErrorResponse{}
+	if rec.Code == http.StatusOK {
+		json.NewDecoder(rec.Body).Decode(&res)
+	} else {
+		json.NewDecoder(rec.Body).Decode(&errRes)
+	}
+	return rec, res, errRes
+}
+
+func TestServer_Authorize(t *testing.T) {
+	s := NewTestServer(t)
+	s.config.Audiences = []string{"reader"}
+
+	s.CreateTestUser(t, 1, false)
+	client := s.CreateTestClient(t, 1)[0]
+	cookie := s.LoginTestSession(t, "test0@test.test", "test_password0")
+
+	testCases := []struct {
+		name             string
+		query            map[string]string
+		cookie           *http.Cookie
+		expectedStatus   int
+		expectedErrorMsg string
+	}{
+		{
+			name:             "success",
+			query:            map[string]string{},
+			cookie:           cookie,
+			expectedStatus:   http.StatusFound,
+			expectedErrorMsg: "",
+		},
+		{
+			name:             "audience",
+			query:            map[string]string{"audience": "reader"},
+			cookie:           cookie,
+			expectedStatus:   http.StatusFound,
+			expectedErrorMsg: "",
+		},
+		{
+			name:             "unknown client",
+			query:            map[string]string{"client_id": "unknown"},
+			cookie:           cookie,
+			expectedStatus:   http.StatusBadRequest,
+			expectedErrorMsg: "invalid_client",
+		},
+		{
+			name:             "unregistered redirect uri",
+			query:            map[string]string{"redirect_uri": "https://attacker.test"},
+			cookie:           cookie,
+			expectedStatus:   http.StatusBadRequest,
+			expectedErrorMsg: "invalid_request",
+		},
+		{
+			name:             "unsupported response type",
+			query:            map[string]string{"response_type": "token"},
+			cookie:           cookie,
+			expectedStatus:   http.StatusFound,
+			expectedErrorMsg: "unsupported_response_type",
+		},
+		{
+			name:             "plain code challenge",
+			query:            map[string]string{"code_challenge_method": "plain"},
+			cookie:           cookie,
+			expectedStatus:   http.StatusFound,
+			expectedErrorMsg: "invalid_request",
+		},
+		{
+			name:             "missing code challenge",
+			query:            map[string]string{"code_challenge": ""},
+			cookie:           cookie,
+			expectedStatus:   http.StatusFound,
+			expectedErrorMsg: "invalid_request",
+		},
+		{
+			name:             "invalid audience",
+			query:            map[string]string{"audience": "unknown"},
+			cookie:           cookie,
+			expectedStatus:   http.StatusFound,
+			expectedErrorMsg: "invalid_request",
+		},
+		{
+			name:             "scope",
+			query:            map[string]string{"scope": "profile:read"},
+			cookie:           cookie,
+			expectedStatus:   http.StatusFound,
+			expectedErrorMsg: "",
+		},
+		{
+			name:             "scope not held by the user",
+			query:            map[string]string{"scope": "profile:read users:write"},
+			cookie:           cookie,
+			expectedStatus:   http.StatusFound,
+			expectedErrorMsg: "invalid_scope",
+		},
+		{
+			name:             "no session",
+			query:            map[string]string{},
+			cookie:           nil,
+			expectedStatus:   http.StatusFound,
+			expectedErrorMsg: "login_required",
+		},
+	}
+
+	for _, tc := range testCases {
+		query := authorizeTestQuery(client)
+		for key, value := range tc.query {
+			query.Set(key, value)
+		}
+		rec := authorizeTestClient(t, s, query, tc.cookie)
+		assert.Equal(t, tc.expectedStatus, rec.Code, tc.name)
+
+		if rec.Code != http.StatusFound {
+			res := &oauthErrorResponse{}
+			json.NewDecoder(rec.Body).Decode(&res)
+			assert.Equal(t, tc.expectedErrorMsg, res.Error, tc.name)
+			assert.Empty(t, rec.Header().Get("Location"), tc.name)
+			continue
+		}
+
+		location, err := url.Parse(rec.Header().Get("Location"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, client.RedirectUris[0], location.Scheme+"://"+location.Host+location.Path, tc.name)
+		assert.Equal(t, "test_state", location.Query().Get("state"), tc.name)
+		assert.Equal(t, tc.expectedErrorMsg, location.Query().Get("error"), tc.name)
+		if tc.expectedErrorMsg == "" {
+			assert.NotEmpty(t, location.Query().Get("code"), tc.name)
+		} else {
+			assert.Empty(t, location.Query().Get("code"), tc.name)
+		}
+	}
+}
+
+func TestServer_Authorize_LoginUrl(t *testing.T) {
+	s := NewTestServer(t)
+	s.config.LoginUrl = "https://dualread.test/login"
+
+	client := s.CreateTestClient(t, 1)[0]
+	query := authorizeTestQuery(client)
+
+	rec := authorizeTestClient(t, s, query, nil)
+	assert.Equal(t, http.StatusFound, rec.Code)
+
+	location, err := url.Parse(rec.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "dualread.test", location.Host)
+	assert.Equal(
+		t,
+		"http://auth.local/auth/authorize?"+query.Encode(),
+		location.Query().Get("return_to"),
+	)
+}
+
+func TestServer_Token_AuthorizationCode(t *testing.T) {
+	s := NewTestServer(t)
+	s.config.Audiences = []string{"reader"}
+
+	user := s.CreateTestUser(t, 1, false)[0]
+	clients := s.CreateTestClient(t, 2)
+	cookie := s.LoginTestSession(t, "test0@test.test", "test_password0")
+
+	getCode := func(audience ...string) string {
+		query := authorizeTestQuery(clients[0])
+		query["audience"] = audience
+		rec := authorizeTestClient(t, s, query, cookie)
+		location, err := url.Parse(rec.Header().Get("Location"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return location.Query().Get("code")
+	}
+	tokenForm := func(code string) url.Values {
+		return url.Values{
+			"grant_type":    {"authorization_code"},
+			"code":          {code},
+			"client_id":     {clients[0].Id},
+			"redirect_uri":  {clients[0].RedirectUris[0]},
+			"code_verifier": {store.GetTestCodeVerifier()},
+		}
+	}
+
+	// A successful exchange opens a new session for the client
+	code := getCode("reader")
+	rec, res, _ := requestTestToken(t, s, tokenForm(code), "", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+	assert.Equal(t, "Bearer", res.TokenType)
+	assert.InDelta(t, s.config.AccessTokenLifetime.Seconds(), res.ExpiresIn, 5)
+
+	claims, err := s.parseAccessTokenFor(res.AccessToken, "reader")
+	if err != nil {
+		t.Fatal(err)
+	}
+	userId, err := claims.UserId()
+	assert.NoError(t, err)
+	assert.Equal(t, user.ID, userId)
+	assert.Equal(
+		t,
+		"api-keys:read api-keys:write email openid profile profile:read profile:write "+
+			"sessions:read sessions:write",
+		res.Scope,
+	)
+	assert.Equal(t, res.Scope, claims.Scope)
+
+	storedToken, _, err := s.findRefreshToken(res.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessionToken, _, err := s.findRefreshToken(cookie.Value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, storedToken.Family, claims.SessionId)
+	assert.NotEqual(t, sessionToken.Family, storedToken.Family)
+
+	// Codes are single use
+	rec, _, errRes := requestTestToken(t, s, tokenForm(code), "", "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "invalid_grant", errRes.Error)
+
+	testCases := []struct {
+		name             string
+		form             map[string]string
+		expectedErrorMsg string
+	}{
+		{
+			name:             "wrong code verifier",
+			form:             map[string]string{"code_verifier": strings.Repeat("a", 43)},
+			expectedErrorMsg: "invalid_grant",
+		},
+		{
+			name:             "missing code verifier",
+			form:             map[string]string{"code_verifier": ""},
+			expectedErrorMsg: "invalid_grant",
+		},
+		{
+			name:             "other client",
+			form:             map[string]string{"client_id": clients[1].Id},
+			expectedErrorMsg: "invalid_grant",
+		},
+		{
+			name:             "other redirect uri",
+			form:             map[string]string{"redirect_uri": clients[1].RedirectUris[0]},
+			expectedErrorMsg: "invalid_grant",
+		},
+		{
+			name:             "invalid code",
+			form:             map[string]string{"code": "invalid"},
+			expectedErrorMsg: "invalid_grant",
+		},
+		{
+			name:             "unsupported grant type",
+			form:             map[string]string{"grant_type": "password"},
+			expectedErrorMsg: "unsupported_grant_type",
+		},
+	}
+
+	for _, tc := range testCases {
+		form := tokenForm(getCode())
+		for key, value := range tc.form {
+			form.Set(key, value)
+		}
+		rec, _, errRes := requestTestToken(t, s, form, "", "")
+		assert.Equal(t, http.StatusBadRequest, rec.Code, tc.name)
+		assert.Equal(t, tc.expectedErrorMsg, errRes.Error, tc.name)
+	}
+
+	// Expired codes are rejected
+	_, expiredCode := store.CreateTestAuthCode(t, s.store, clients[0], user, -time.Minute)
+	rec, _, errRes = requestTestToken(t, s, tokenForm(expiredCode), "", "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "invalid_grant", errRes.Error)
+}
+
+func TestServer_Token_AuthorizationCode_Scope(t *testing.T) {
+	s := NewTestServer(t)
+
+	s.CreateTestUser(t, 1, true)
+	client := s.CreateTestClient(t, 1)[0]
+	cookie := s.LoginTestSession(t, "test0@test.test", "test_password0")
+
+	exchange := func(scope string) *tokenResponse {
+		query := authorizeTestQuery(client)
+		if scope != "" {
+			query.Set("scope", scope)
+		}
+		rec := authorizeTestClient(t, s, query, cookie)
+		location, err := url.Parse(rec.Header().Get("Location"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, res, _ := requestTestToken(t, s, url.Values{
+			"grant_type":    {"authorization_code"},
+			"code":          {location.Query().Get("code")},
+			"client_id":     {client.Id},
+			"redirect_uri":  {client.RedirectUris[0]},
+			"code_verifier": {store.GetTestCodeVerifier()},
+		}, "", "")
+		return res
+	}
+
+	// The permissions of an admin are only granted when requested
+	res := exchange("")
+	assert.ElementsMatch(t, model.UserScopes, strings.Fields(res.Scope))
+	claims, err := s.parseAccessToken(res.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, claims.HasScope(model.PermissionWriteRoles))
+
+	res = exchange("profile:read users:read")
+	assert.Equal(t, "profile:read users:read", res.Scope)
+	claims, err = s.parseAccessToken(res.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, claims.HasScope(model.PermissionReadUsers))
+	assert.False(t, claims.HasScope(model.PermissionWriteUsers))
+}
+
+func TestServer_Token_AuthorizationCode_IdToken(t *testing.T) {
+	s := NewTestServer(t)
+
+	user := s.CreateTestUser(t, 1, false)[0]
+	client := s.CreateTestClient(t, 1)[0]
+	cookie := s.LoginTestSession(t, "test0@test.test", "test_password0")
+
+	exchange := func(scope string) *tokenResponse {
+		query := authorizeTestQuery(client)
+		query.Set("scope", scope)
+		query.Set("nonce", "test_nonce")
+		rec := authorizeTestClient(t, s, query, cookie)
+		location, err := url.Parse(rec.Header().Get("Location"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, res, _ := requestTestToken(t, s, url.Values{
+			"grant_type":    {"authorization_code"},
+			"code":          {location.Query().Get("code")},
+			"client_id":     {client.Id},
+			"redirect_uri":  {client.RedirectUris[0]},
+			"code_verifier": {store.GetTestCodeVerifier()},
+		}, "", "")
+		return res
+	}
+
+	// The id token is intended for the client and verified with the JWKS keys
+	res := exchange("openid email profile:read")
+	assert.Equal(t, "email openid profile:read", res.Scope)
+	claims := &model.IdClaims{}
+	token, err := jwt.ParseWithClaims(res.IdToken, claims, s.accessKeys.Keyfunc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "ES256", token.Method.Alg())
+	assert.Equal(t, s.config.Issuer, claims.Issuer)
+	assert.Equal(t, model.Audience{client.Id}, claims.Audience)
+	assert.Equal(t, strconv.FormatInt(user.ID, 10), claims.Subject)
+	assert.Equal(t, "test_nonce", claims.Nonce)
+	if assert.NotNil(t, claims.EmailClaims) {
+		assert.Equal(t, user.Email, claims.Email)
+		assert.False(t, claims.EmailVerified)
+	}
+
+	// An id token is not an access token
+	_, err = s.parseAccessToken(res.IdToken)
+	assert.Error(t, err)
+
+	// The email claims are left out without the email scope
+	res = exchange("openid")
+	claims = &model.IdClaims{}
+	if _, err := jwt.ParseWithClaims(res.IdToken, claims, s.accessKeys.Keyfunc); err != nil {
+		t.Fatal(err)
+	}
+	assert.Nil(t, claims.EmailClaims)
+
+	res = exchange("profile:read")
+	assert.NotEmpty(t, res.AccessToken)
+	assert.Empty(t, res.IdToken)
+}
+
+func TestServer_Token_AuthorizationCode_ConfidentialClient(t *testing.T) {
+	s := NewTestServer(t)
+
+	s.CreateTestUser(t, 1, false)
+	client, secret, err := model.NewClient(
+		"test_web",
+		[]string{"https://web.test/callback"},
+		nil,
+		true,
+		time.Now(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.store.Client().Insert(client); err != nil {
+		t.Fatal(err)
+	}
+	cookie := s.LoginTestSession(t, "test0@test.test", "test_password0")
+
+	testCases := []struct {
+		name           string
+		secret         string
+		expectedStatus int
+	}{
+		{
+			name:           "valid secret",
+			secret:         secret,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "wrong secret",
+			secret:         "wrong",
+			expectedStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tc := range testCases {
+		rec := authorizeTestClient(t, s, authorizeTestQuery(client), cookie)
+		location, err := url.Parse(rec.Header().Get("Location"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		form := url.Values{
+			"grant_type":    {"authorization_code"},
+			"code":          {location.Query().Get("code")},
+			"redirect_uri":  {client.RedirectUris[0]},
+			"code_verifier": {store.GetTestCodeVerifier()},
+		}
+		rec, _, errRes := requestTestToken(t, s, form, client.Id, tc.secret)
+		assert.Equal(t, tc.expectedStatus, rec.Code, tc.name)
+		if tc.expectedStatus != http.StatusOK {
+			assert.Equal(t, "invalid_client", errRes.Error, tc.name)
+		}
+	}
+}
+
+func TestServer_Token_RefreshToken(t *testing.T) {
+	s := NewTestServer(t)
+
+	s.CreateTestUser(t, 1, false)
+	clients := s.CreateTestClient(t, 2)
+	cookie := s.LoginTestSession(t, "test0@test.test", "test_password0")
+
+	query := authorizeTestQuery(clients[0])
+	query.Set("scope", "profile:read sessions:read")
+	location, err := url.Parse(authorizeTestClient(t, s, query, cookie).Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, codeRes, _ := requestTestToken(t, s, url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {location.Query().Get("code")},
+		"client_id":     {clients[0].Id},
+		"redirect_uri":  {clients[0].RedirectUris[0]},
+		"code_verifier": {store.GetTestCodeVerifier()},
+	}, "", "")
+	refreshForm := func(refreshToken string) url.Values {
+		return url.Values{
+			"grant_type":    {"refresh_token"},
+			"refresh_token": {refreshToken},
+			"client_id":     {clients[0].Id},
+		}
+	}
+
+	// Bad requests do not consume the refresh token
+	testCases := []struct {
+		name             string
+		form             map[string]string
+		expectedStatus   int
+		expectedErrorMsg string
+	}{
+		{
+			name:             "other client",
+			form:             map[string]string{"client_id": clients[1].Id},
+			expectedStatus:   http.StatusBadRequest,
+			expectedErrorMsg: "invalid_grant",
+		},
+		{
+			name:             "unknown client",
+			form:             map[string]string{"client_id": "unknown"},
+			expectedStatus:   http.StatusUnauthorized,
+			expectedErrorMsg: "invalid_client",
+		},
+		{
+			name:             "invalid refresh token",
+			form:             map[string]string{"refresh_token": "invalid"},
+			expectedStatus:   http.StatusBadRequest,
+			expectedErrorMsg: "invalid_grant",
+		},
+		{
+			name:             "web app session",
+			form:             map[string]string{"refresh_token": cookie.Value},
+			expectedStatus:   http.StatusBadRequest,
+			expectedErrorMsg: "invalid_grant",
+		},
+		{
+			name:             "scope not granted to the client",
+			form:             map[string]string{"scope": "profile:write"},
+			expectedStatus:   http.StatusBadRequest,
+			expectedErrorMsg: "invalid_scope",
+		},
+	}
+
+	for _, tc := range testCases {
+		form := refreshForm(codeRes.RefreshToken)
+		for key, value := range tc.form {
+			form.Set(key, value)
+		}
+		rec, _, errRes := requestTestToken(t, s, form, "", "")
+		assert.Equal(t, tc.expectedStatus, rec.Code, tc.name)
+		assert.Equal(t, tc.expectedErrorMsg, errRes.Error, tc.name)
+	}
+
+	// The refresh token is rotated in the session of the client, the scope
+	// may be narrowed
+	form := refreshForm(codeRes.RefreshToken)
+	form.Set("scope", "profile:read")
+	rec, res, _ := requestTestToken(t, s, form, "", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "profile:read", res.Scope)
+	assert.NotEqual(t, codeRes.RefreshToken, res.RefreshToken)
+
+	claims, err := s.parseAccessToken(res.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "profile:read", claims.Scope)
+	storedToken, _, err := s.findRefreshToken(codeRes.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, storedToken.Family, claims.SessionId)
+
+	// The rotated token keeps the scope granted to the client
+	rec, rotatedRes, _ := requestTestToken(t, s, refreshForm(res.RefreshToken), "", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "profile:read sessions:read", rotatedRes.Scope)
+
+	// The refresh tokens of clients are refused by the web app routes
+	rec = httptest.NewRecorder()
+	req := s.CreateTestRequest(
+		t, http.MethodPost, "/auth/refresh-access-token",
+		map[string]interface{}{"refresh_token": rotatedRes.RefreshToken},
+	)
+	s.ServeHTTP(rec, req)
+	assert.NotEqual(t, http.StatusOK, rec.Code)
+
+	// Presenting a consumed token again ends the session of the client
+	rec, _, errRes := requestTestToken(t, s, refreshForm(codeRes.RefreshToken), "", "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "invalid_grant", errRes.Error)
+	rec, _, errRes = requestTestToken(t, s, refreshForm(rotatedRes.RefreshToken), "", "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "invalid_grant", errRes.Error)
+
+	// The web app session is kept
+	_, _, err = s.findRefreshToken(cookie.Value)
+	assert.NoError(t, err)
+}
+
+func TestServer_Token_ClientCredentials(t *testing.T) {
+	s := NewTestServer(t)
+	s.config.Audiences = []string{"reader"}
+
+	client, secret := s.CreateTestServiceClient(t, []string{"tts", "billing"})
+	publicClient := s.CreateTestClient(t, 1)[0]
+
+	testCases := []struct {
+		name             string
+		form             url.Values
+		id               string
+		secret           string
+		expectedStatus   int
+		expectedScope    string
+		expectedErrorMsg string
+	}{
+		{
+			name:           "basic authentication",
+			form:           url.Values{},
+			id:             client.Id,
+			secret:         secret,
+			expectedStatus: http.StatusOK,
+			expectedScope:  "tts billing",
+		},
+		{
+			name: "form authentication",
+			form: url.Values{
+				"client_id":     {client.Id},
+				"client_secret": {secret},
+				"scope":         {"billing"},
+			},
+			expectedStatus: http.StatusOK,
+			expectedScope:  "billing",
+		},
+		{
+			name:           "audience",
+			form:           url.Values{"audience": {"reader"}},
+			id:             client.Id,
+			secret:         secret,
+			expectedStatus: http.StatusOK,
+			expectedScope:  "tts billing",
+		},
+		{
+			name:             "invalid scope",
+			form:             url.Values{"scope": {"tts admin"}},
+			id:               client.Id,
+			secret:           secret,
+			expectedStatus:   http.StatusBadRequest,
+			expectedErrorMsg: "invalid_scope",
+		},
+		{
+			name:             "invalid audience",
+			form:             url.Values{"audience": {"unknown"}},
+			id:               client.Id,
+			secret:           secret,
+			expectedStatus:   http.StatusBadRequest,
+			expectedErrorMsg: "invalid_request",
+		},
+		{
+			name:             "wrong secret",
+			form:             url.Values{},
+			id:               client.Id,
+			secret:           "wrong",
+			expectedStatus:   http.StatusUnauthorized,
+			expectedErrorMsg: "invalid_client",
+		},
+		{
+			name:             "public client",
+			form:             url.Values{"client_id": {publicClient.Id}},
+			expectedStatus:   http.StatusUnauthorized,
+			expectedErrorMsg: "invalid_client",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc.form.Set("grant_type", "client_credentials")
+		rec, res, errRes := requestTestToken(t, s, tc.form, tc.id, tc.secret)
+		assert.Equal(t, tc.expectedStatus, rec.Code, tc.name)
+		assert.Equal(t, tc.expectedErrorMsg, errRes.Error, tc.name)
+		if tc.expectedStatus != http.StatusOK {
+			continue
+		}
+
+		assert.Equal(t, tc.expectedScope, res.Scope, tc.name)
+		assert.Empty(t, res.RefreshToken, tc.name)
+		audience := s.config.Audience
+		if aud := tc.form.Get("audience"); aud != "" {
+			audience = aud
+		}
+		claims, err := s.parseAccessTokenFor(res.AccessToken, audience)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, client.Id, claims.Subject, tc.name)
+		assert.Equal(t, client.Id, claims.ClientId, tc.name)
+		assert.Equal(t, tc.expectedScope, claims.Scope, tc.name)
+	}
+}
+
+func TestServer_ClientAccessToken(t *testing.T) {
+	s := NewTestServer(t)
+
+	client, secret := s.CreateTestServiceClient(t, []string{"tts"})
+	_, res, _ := requestTestToken(
+		t, s,
+		url.Values{"grant_type": {"client_credentials"}},
+		client.Id, secret,
+	)
+
+	// The token authenticates the client but has no user
+	rec := httptest.NewRecorder()
+	req := s.CreateTestRequest(t, http.MethodGet, "/auth/userinfo", nil)
+	req.Header.Add("Authorization", "Bearer "+res.AccessToken)
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = httptest.NewRecorder()
+	req = s.CreateTestRequest(t, http.MethodGet, "/auth/admin/client", nil)
+	req.Header.Add("Authorization", "Bearer "+res.AccessToken)
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	claims, err := s.parseAccessToken(res.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	revoked, err := s.isAccessTokenRevoked(claims)
+	assert.NoError(t, err)
+	assert.False(t, revoked)
+	if err := s.store.Revocation().RevokeAccessToken(claims.Id, claims.ExpiresAt); err != nil {
+		t.Fatal(err)
+	}
+	revoked, err = s.isAccessTokenRevoked(claims)
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+}
@@ -0,0 +1,103 @@
+package httpserver
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/anoobz/dualread/auth/internal/model"
+)
+
+// openIdConfiguration is the OpenID Connect discovery document. Id tokens are
+// issued by the authorization code flow to the clients requesting the openid
+// scope, and are signed by the active access token key.
+type openIdConfiguration struct {
+	Issuer                           string   `json:"issuer"`
+	AuthorizationEndpoint            string   `json:"authorization_endpoint"`
+	TokenEndpoint                    string   `json:"token_endpoint"`
+	JwksUri                          string   `json:"jwks_uri"`
+	UserinfoEndpoint                 string   `json:"userinfo_endpoint"`
+	IntrospectionEndpoint            string   `json:"introspection_endpoint"`
+	ResponseTypesSupported           []string `json:"response_types_supported"`
+	GrantTypesSupported              []string `json:"grant_types_supported"`
+	CodeChallengeMethodsSupported    []string `json:"code_challenge_methods_supported"`
+	TokenEndpointAuthMethods         []string `json:"token_endpoint_auth_methods_supported"`
+	ScopesSupported                  []string `json:"scopes_supported"`
+	SubjectTypesSupported            []string `json:"subject_types_supported"`
+	IdTokenSigningAlgValuesSupported []string `json:"id_token_signing_alg_values_supported"`
+	ClaimsSupported                  []string `json:"claims_supported"`
+}
+
+// userInfoResponse holds the claims of the scopes of the access token.
+type userInfoResponse struct {
+	Sub string `json:"sub"`
+	*model.EmailClaims
+}
+
+// baseUrl is the public address of the service, taken from the request when
+// PUBLIC_URL is not configured.
+func (s *server) baseUrl(r *http.Request) string {
+	if s.config.PublicUrl != "" {
+		return strings.TrimSuffix(s.config.PublicUrl, "/")
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); s.config.TrustProxyHeaders && proto != "" {
+		scheme = proto
+	}
+
+	return scheme + "://" + r.Host
+}
+
+func (s *server) getOpenIdConfiguration() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		baseUrl := s.baseUrl(r)
+
+		s.respond(w, r, http.StatusOK, &openIdConfiguration{
+			Issuer:                 s.config.Issuer,
+			AuthorizationEndpoint:  baseUrl + "/auth/authorize",
+			TokenEndpoint:          baseUrl + "/auth/token",
+			JwksUri:                baseUrl + "/auth/.well-known/jwks.json",
+			UserinfoEndpoint:       baseUrl + "/auth/userinfo",
+			IntrospectionEndpoint:  baseUrl + "/auth/introspect",
+			ResponseTypesSupported: []string{"code"},
+			GrantTypesSupported: []string{
+				"authorization_code", "refresh_token", "client_credentials",
+			},
+			CodeChallengeMethodsSupported: []string{model.CodeChallengeMethodS256},
+			TokenEndpointAuthMethods: []string{
+				"none", "client_secret_basic", "client_secret_post",
+			},
+			ScopesSupported:                  model.NewGrants([]*model.Role{model.NewAdminRole()}).Scopes(),
+			SubjectTypesSupported:            []string{"public"},
+			IdTokenSigningAlgValuesSupported: []string{s.accessKeys.Active().Method.Alg()},
+			ClaimsSupported: []string{
+				"sub", "iss", "aud", "exp", "iat", "nonce", "email", "email_verified",
+			},
+		})
+	}
+}
+
+func (s *server) getUserInfo() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := getAccessClaims(r)
+		userId, err := claims.UserId()
+		if err != nil {
+			s.error(w, r, http.StatusUnauthorized, err)
+			return
+		}
+		user, err := s.store.User().GetById(userId)
+		if err != nil || !user.Active {
+			s.error(w, r, http.StatusUnauthorized, errors.New("user not found"))
+			return
+		}
+
+		s.respond(w, r, http.StatusOK, &userInfoResponse{
+			Sub:         claims.Subject,
+			EmailClaims: model.NewEmailClaims(user, claims.Scope),
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_GetOpenIdConfiguration(t *testing.T) {
+	s := NewTestServer(t)
+
+	testCases := []struct {
+		name            string
+		publicUrl       string
+		expectedBaseUrl string
+	}{
+		{
+			name:            "configured public url",
+			publicUrl:       "https://auth.dualread.test/",
+			expectedBaseUrl: "https://auth.dualread.test",
+		},
+		{
+			name:            "request host",
+			publicUrl:       "",
+			expectedBaseUrl: "http://auth.local",
+		},
+	}
+
+	for _, tc := range testCases {
+		s.config.PublicUrl = tc.publicUrl
+
+		rec := httptest.NewRecorder()
+		req := s.CreateTestRequest(
+			t, http.MethodGet,
+			"http://auth.local/.well-known/openid-configuration",
+			nil,
+		)
+		s.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code, tc.name)
+
+		res := &openIdConfiguration{}
+		json.NewDecoder(rec.Body).Decode(&res)
+		assert.Equal(t, "https://auth.dualread.test", res.Issuer, tc.name)
+		assert.Equal(t, tc.expectedBaseUrl+"/auth/.well-known/jwks.json", res.JwksUri, tc.name)
+		assert.Equal(t, tc.expectedBaseUrl+"/auth/userinfo", res.UserinfoEndpoint, tc.name)
+		assert.Equal(t, []string{"ES256"}, res.IdTokenSigningAlgValuesSupported, tc.name)
+		assert.Contains(t, res.ScopesSupported, "openid", tc.name)
+		assert.Contains(t, res.ScopesSupported, "profile:read", tc.name)
+		assert.Contains(t, res.ScopesSupported, "users:write", tc.name)
+	}
+}
+
+func TestServer_GetUserInfo(t *testing.T) {
+	s := NewTestServer(t)
+
+	users := s.CreateTestUser(t, 2, false)
+	accessToken := s.LoginTestUser(t, "test0@test.test", "test_password0")
+	inactiveToken := s.LoginTestUser(t, "test1@test.test", "test_password1")
+	if err := s.store.User().Update(
+		users[1].ID,
+		map[string]interface{}{"active": false},
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name             string
+		accessToken      string
+		expectedStatus   int
+		expectedErrorMsg string
+	}{
+		{
+			name:             "success",
+			accessToken:      accessToken,
+			expectedStatus:   http.StatusOK,
+			expectedErrorMsg: "",
+		},
+		{
+			name:             "inactive user",
+			accessToken:      inactiveToken,
+			expectedStatus:   http.StatusUnauthorized,
+			expectedErrorMsg: "user not found",
+		},
+		{
+			name:             "invalid authorization token",
+			accessToken:      "invalid",
+			expectedStatus:   http.StatusUnauthorized,
+			expectedErrorMsg: "token contains an invalid number of segments",
+		},
+	}
+
+	for _, tc := range testCases {
+		rec := httptest.NewRecorder()
+		req := s.CreateTestRequest(t, http.MethodGet, "/auth/userinfo", nil)
+		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", tc.accessToken))
+		s.ServeHTTP(rec, req)
+
+		assert.Equal(t, tc.expectedStatus, rec.Code, tc.name)
+		if tc.expectedErrorMsg == "" {
+			body := map[string]interface{}{}
+			json.NewDecoder(rec.Body).Decode(&body)
+			assert.Equal(t, strconv.FormatInt(users[0].ID, 10), body["sub"], tc.name)
+			assert.Equal(t, users[0].Email, body["email"], tc.name)
+			assert.NotContains(t, body, "password", tc.name)
+		} else {
+			res := struct {
+				ErrorMsg string `json:"error"`
+			}{}
+			json.NewDecoder(rec.Body).Decode(&res)
+			assert.Equal(t, tc.expectedErrorMsg, res.ErrorMsg, tc.name)
+		}
+	}
+}
+
+func TestServer_GetUserInfo_Scope(t *testing.T) {
+	s := NewTestServer(t)
+
+	users := s.CreateTestUser(t, 1, false)
+
+	testCases := []struct {
+		name           string
+		scope          string
+		expectedStatus int
+		expectedEmail  bool
+	}{
+		{
+			name:           "openid email",
+			scope:          "openid email",
+			expectedStatus: http.StatusOK,
+			expectedEmail:  true,
+		},
+		{
+			name:           "openid profile",
+			scope:          "openid profile",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "without openid",
+			scope:          "profile:read email",
+			expectedStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tc := range testCases {
+		rec := httptest.NewRecorder()
+		req := s.CreateTestRequest(
+			t, http.MethodPost, "/auth/login",
+			map[string]interface{}{
+				"email":    "test0@test.test",
+				"password": "test_password0",
+				"scope":    tc.scope,
+			},
+		)
+		s.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code, tc.name)
+		token := struct {
+			TokenString string `json:"token"`
+		}{}
+		json.NewDecoder(rec.Body).Decode(&token)
+
+		rec = httptest.NewRecorder()
+		req = s.CreateTestRequest(t, http.MethodGet, "/auth/userinfo", nil)
+		req.Header.Add("Authorization", "Bearer "+token.TokenString)
+		s.ServeHTTP(rec, req)
+		assert.Equal(t, tc.expectedStatus, rec.Code, tc.name)
+		if tc.expectedStatus != http.StatusOK {
+			continue
+		}
+
+		body := map[string]interface{}{}
+		json.NewDecoder(rec.Body).Decode(&body)
+		assert.Equal(t, strconv.FormatInt(users[0].ID, 10), body["sub"], tc.name)
+		if tc.expectedEmail {
+			assert.Equal(t, users[0].Email, body["email"], tc.name)
+			assert.Equal(t, false, body["email_verified"], tc.name)
+		} else {
+			assert.NotContains(t, body, "email", tc.name)
+			assert.NotContains(t, body, "email_verified", tc.name)
+		}
+	}
+}
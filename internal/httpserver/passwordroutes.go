@@ -0,0 +1,147 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/anoobz/dualread/auth/internal/mailer"
+	"github.com/anoobz/dualread/auth/internal/model"
+)
+
+var errInvalidResetToken = errors.New("invalid reset token")
+
+// forgotPassword mails a password reset link to the user of the address. The
+// response is the same whether the address belongs to a user or not, and the
+// lookup and the email go through the mail queue so that the response time
+// does not tell them apart either. At most one email per address is queued
+// every mailThrottle, the requests above it or above the queue capacity are
+// dropped. Failures are only logged.
+func (s *server) forgotPassword() http.HandlerFunc {
+	type payload struct {
+		Email string `json:"email"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		payload := payload{}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			s.error(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		queued := s.mails.enqueue(mailPasswordReset, payload.Email, time.Now(), func() {
+			user, err := s.store.User().GetByEmail(payload.Email)
+			if err != nil || !user.Active {
+				return
+			}
+			if err := s.sendPasswordReset(user); err != nil {
+				s.logger.Printf("sending the password reset of user %d: %v", user.ID, err)
+			}
+		})
+		if !queued {
+			s.logger.Printf("dropped the password reset request of %q", payload.Email)
+		}
+
+		s.respond(w, r, http.StatusAccepted, nil)
+	}
+}
+
+// sendPasswordReset replaces the outstanding reset of the user, only the last
+// link mailed to the user can be used.
+func (s *server) sendPasswordReset(user *model.User) error {
+	if err := s.store.PasswordReset().DeleteByUser(user.ID); err != nil {
+		return err
+	}
+	reset, err := model.NewPasswordReset(user.ID, s.config.PasswordResetLifetime, time.Now())
+	if err != nil {
+		return err
+	}
+	err = s.store.PasswordReset().Insert(reset.Hashed(s.config.RefreshTokenHashKey))
+	if err != nil {
+		return err
+	}
+
+	link, err := tokenLink(s.config.PasswordResetUrl, reset.Token)
+	if err != nil {
+		return err
+	}
+	return s.mailer.Send(&mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf(
+			"Open the link below to choose a new password:\n\n%s\n\n"+
+				"The link expires in %s. If you did not ask to reset your "+
+				"password, you can ignore this email.",
+			link,
+			s.config.PasswordResetLifetime,
+		),
+	})
+}
+
+// resetPassword sets the password of the user of the reset token. Every
+// session of the user is ended, the access tokens issued so far are revoked
+// and the API keys are deleted, since whoever knew the previous password may
+// hold them.
+func (s *server) resetPassword() http.HandlerFunc {
+	type payload struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		payload := payload{}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			s.error(w, r, http.StatusBadRequest, err)
+			return
+		}
+		if payload.Token == "" || payload.Password == "" {
+			s.error(w, r, http.StatusBadRequest, errors.New("a required field is empty"))
+			return
+		}
+
+		now := time.Now()
+		reset, err := s.store.PasswordReset().Consume(
+			model.HashToken(s.config.RefreshTokenHashKey, payload.Token),
+		)
+		if err != nil || reset.Expired(now) {
+			s.error(w, r, http.StatusBadRequest, errInvalidResetToken)
+			return
+		}
+		user, err := s.store.User().GetById(reset.UserId)
+		if err != nil || !user.Active {
+			s.error(w, r, http.StatusBadRequest, errInvalidResetToken)
+			return
+		}
+
+		encryptedPassword, err := hashPassword(payload.Password)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		err = s.store.User().Update(user.ID, map[string]interface{}{"password": encryptedPassword})
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		err = s.store.Revocation().RevokeUser(
+			user.ID,
+			now.UnixMilli(),
+			now.Add(s.config.AccessTokenLifetime).Unix(),
+		)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		if err := s.store.AuthToken().DeleteByUser(user.ID); err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		if err := s.store.ApiKey().DeleteByUser(user.ID); err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		s.respond(w, r, http.StatusOK, nil)
+	}
+}
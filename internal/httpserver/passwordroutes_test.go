@@ -0,0 +1,208 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/anoobz/dualread/auth/internal/mailer"
+	"github.com/anoobz/dualread/auth/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func requestTestPasswordRoute(
+	t *testing.T,
+	s *server,
+	url string,
+	payload map[string]interface{},
+) (*httptest.ResponseRecorder, string) {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	req := s.CreateTestRequest(t, http.MethodPost, url, payload)
+	s.ServeHTTP(rec, req)
+
+	res := struct {
+		ErrorMsg string `json:"error"`
+	}{}
+	json.Unmarshal(rec.Body.Bytes(), &res)
+	return rec, res.ErrorMsg
+}
+
+func TestServer_ForgotPassword(t *testing.T) {
+	s := NewTestServer(t)
+
+	s.CreateTestUser(t, 1, false)
+	outbox := newTestOutbox(s)
+
+	knownRec, _ := requestTestPasswordRoute(
+		t, s, "/auth/password/forgot",
+		map[string]interface{}{"email": "test0@test.test"},
+	)
+	assert.Equal(t, http.StatusAccepted, knownRec.Code)
+	msg := outbox.waitSent(t)
+	assert.Equal(t, "test0@test.test", msg.To)
+	assert.Contains(t, msg.Body, "https://dualread.test/reset-password?token=")
+	firstToken := getTestMailedToken(t, s)
+
+	// Unknown addresses get the same response and no email
+	unknownRec, _ := requestTestPasswordRoute(
+		t, s, "/auth/password/forgot",
+		map[string]interface{}{"email": "unknown@test.test"},
+	)
+	assert.Equal(t, knownRec.Code, unknownRec.Code)
+	assert.Equal(t, knownRec.Body.String(), unknownRec.Body.String())
+
+	// A second request for the address within the throttle sends nothing
+	throttledRec, _ := requestTestPasswordRoute(
+		t, s, "/auth/password/forgot",
+		map[string]interface{}{"email": "TEST0@test.test"},
+	)
+	assert.Equal(t, knownRec.Code, throttledRec.Code)
+
+	// Only the last link mailed to the user can be used
+	s.mails.throttle = 0
+	requestTestPasswordRoute(
+		t, s, "/auth/password/forgot",
+		map[string]interface{}{"email": "test0@test.test"},
+	)
+	outbox.waitSent(t)
+	rec, errorMsg := requestTestPasswordRoute(
+		t, s, "/auth/password/reset",
+		map[string]interface{}{"token": firstToken, "password": "new_password"},
+	)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "invalid reset token", errorMsg)
+	rec, _ = requestTestPasswordRoute(
+		t, s, "/auth/password/reset",
+		map[string]interface{}{"token": getTestMailedToken(t, s), "password": "new_password"},
+	)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	// The unknown address and the throttled request got no email
+	assert.NoError(t, s.Shutdown(context.Background()))
+	assert.Len(t, outbox.Messages(), 2)
+}
+
+// blockingMailer holds every message until it is released.
+type blockingMailer struct {
+	release chan struct{}
+}
+
+func (m *blockingMailer) Send(msg *mailer.Message) error {
+	<-m.release
+	return nil
+}
+
+func TestServer_ForgotPassword_RespondsBeforeSending(t *testing.T) {
+	s := NewTestServer(t)
+	m := &blockingMailer{release: make(chan struct{})}
+	s.mailer = m
+
+	s.CreateTestUser(t, 1, false)
+
+	rec := httptest.NewRecorder()
+	req := s.CreateTestRequest(
+		t, http.MethodPost, "/auth/password/forgot",
+		map[string]interface{}{"email": "test0@test.test"},
+	)
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+
+	close(m.release)
+	assert.NoError(t, s.Shutdown(context.Background()))
+}
+
+func TestServer_ResetPassword(t *testing.T) {
+	s := NewTestServer(t)
+
+	users := s.CreateTestUser(t, 1, false)
+	accessToken := s.LoginTestUser(t, "test0@test.test", "test_password0")
+	cookie := s.LoginTestSession(t, "test0@test.test", "test_password0")
+	_, token := store.CreateTestPasswordReset(t, s.store, users[0], time.Hour)
+	_, expiredToken := store.CreateTestPasswordReset(t, s.store, users[0], -time.Minute)
+	apiKeys, _ := store.CreateTestApiKey(t, s.store, users[0], 1, 0)
+
+	testCases := []struct {
+		name             string
+		token            string
+		password         string
+		expectedStatus   int
+		expectedErrorMsg string
+	}{
+		{
+			name:             "missing password",
+			token:            token,
+			expectedStatus:   http.StatusBadRequest,
+			expectedErrorMsg: "a required field is empty",
+		},
+		{
+			name:             "invalid token",
+			token:            "invalid",
+			password:         "new_password",
+			expectedStatus:   http.StatusBadRequest,
+			expectedErrorMsg: "invalid reset token",
+		},
+		{
+			name:             "expired token",
+			token:            expiredToken,
+			password:         "new_password",
+			expectedStatus:   http.StatusBadRequest,
+			expectedErrorMsg: "invalid reset token",
+		},
+		{
+			name:           "success",
+			token:          token,
+			password:       "new_password",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:             "used token",
+			token:            token,
+			password:         "other_password",
+			expectedStatus:   http.StatusBadRequest,
+			expectedErrorMsg: "invalid reset token",
+		},
+	}
+
+	for _, tc := range testCases {
+		rec, errorMsg := requestTestPasswordRoute(
+			t, s, "/auth/password/reset",
+			map[string]interface{}{"token": tc.token, "password": tc.password},
+		)
+		assert.Equal(t, tc.expectedStatus, rec.Code, tc.name)
+		assert.Equal(t, tc.expectedErrorMsg, errorMsg, tc.name)
+	}
+
+	// Only the new password is accepted
+	rec, _ := requestTestPasswordRoute(
+		t, s, "/auth/login",
+		map[string]interface{}{"email": "test0@test.test", "password": "test_password0"},
+	)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	rec, _ = requestTestPasswordRoute(
+		t, s, "/auth/login",
+		map[string]interface{}{"email": "test0@test.test", "password": "new_password"},
+	)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	// The tokens issued before the reset are revoked
+	rec = httptest.NewRecorder()
+	req := s.CreateTestRequest(t, http.MethodPost, "/auth/refresh-access-token", nil)
+	AddTestSessionCookie(req, cookie)
+	s.ServeHTTP(rec, req)
+	assert.NotEqual(t, http.StatusOK, rec.Code)
+
+	rec = httptest.NewRecorder()
+	req = s.CreateTestRequest(t, http.MethodGet, "/auth/me/sessions", nil)
+	req.Header.Add("Authorization", "Bearer "+accessToken)
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	// And so are the api keys
+	_, err := s.store.ApiKey().GetById(apiKeys[0].Id)
+	assert.Error(t, err)
+}
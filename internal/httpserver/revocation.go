@@ -0,0 +1,20 @@
+package httpserver
+
+import (
+	"github.com/anoobz/dualread/auth/internal/model"
+)
+
+// isAccessTokenRevoked only looks the tokens of clients up by their id, as
+// they have no user.
+func (s *server) isAccessTokenRevoked(claims *model.AccessClaims) (bool, error) {
+	if claims.ClientId != "" {
+		return s.store.Revocation().IsRevoked(claims.Id, 0, claims.IssuedAtMillis())
+	}
+
+	userId, err := claims.UserId()
+	if err != nil {
+		return false, err
+	}
+
+	return s.store.Revocation().IsRevoked(claims.Id, userId, claims.IssuedAtMillis())
+}
@@ -0,0 +1,83 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/twinj/uuid"
+)
+
+func (s *server) revokeAccessToken() http.HandlerFunc {
+	type payload struct {
+		AccessUuid string `json:"access_uuid"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		p := &payload{}
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			s.error(w, r, http.StatusBadRequest, err)
+			return
+		}
+		if p.AccessUuid == "" {
+			s.error(w, r, http.StatusBadRequest, errors.New("a required field is empty"))
+			return
+		}
+		// The uuid is stored in the canonical form of the jti claim
+		accessUuid, err := uuid.Parse(p.AccessUuid)
+		if err != nil {
+			s.error(w, r, http.StatusBadRequest, errors.New("invalid access uuid"))
+			return
+		}
+
+		// The token itself is not known, so it is kept in the deny list for
+		// the longest lifetime an access token can have
+		expires := time.Now().Add(s.config.AccessTokenLifetime).Unix()
+		err = s.store.Revocation().RevokeAccessToken(accessUuid.String(), expires)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		s.respond(w, r, http.StatusOK, nil)
+	}
+}
+
+// revokeUserTokens revokes every access token issued to the user so far, ends
+// all of its sessions and deletes its API keys.
+func (s *server) revokeUserTokens() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		if _, err := s.store.User().GetById(id); err != nil {
+			s.error(w, r, http.StatusNotFound, err)
+			return
+		}
+
+		now := time.Now()
+		err = s.store.Revocation().RevokeUser(
+			id,
+			now.UnixMilli(),
+			now.Add(s.config.AccessTokenLifetime).Unix(),
+		)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		if err := s.store.AuthToken().DeleteByUser(id); err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		if err := s.store.ApiKey().DeleteByUser(id); err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		s.respond(w, r, http.StatusOK, nil)
+	}
+}
@@ -0,0 +1,181 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/anoobz/dualread/auth/internal/model"
+	"github.com/anoobz/dualread/auth/internal/store"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func getTestAccessUuid(t *testing.T, accessToken string) string {
+	t.Helper()
+
+	claims := &model.AccessClaims{}
+	if _, _, err := new(jwt.Parser).ParseUnverified(accessToken, claims); err != nil {
+		t.Fatal(err)
+	}
+
+	return claims.Id
+}
+
+func TestServer_RevokeAccessToken(t *testing.T) {
+	s := NewTestServer(t)
+
+	s.CreateTestUser(t, 2, true)
+	adminToken := s.LoginTestUser(t, "test0@test.test", "test_password0")
+	revokedToken := s.LoginTestUser(t, "test1@test.test", "test_password1")
+
+	testCases := []struct {
+		name             string
+		payload          map[string]interface{}
+		expectedStatus   int
+		expectedErrorMsg string
+	}{
+		{
+			name: "success",
+			payload: map[string]interface{}{
+				"access_uuid": getTestAccessUuid(t, revokedToken),
+			},
+			expectedStatus:   http.StatusOK,
+			expectedErrorMsg: "",
+		},
+		{
+			name:             "missing access uuid",
+			payload:          map[string]interface{}{},
+			expectedStatus:   http.StatusBadRequest,
+			expectedErrorMsg: "a required field is empty",
+		},
+		{
+			name:             "invalid access uuid",
+			payload:          map[string]interface{}{"access_uuid": "not-a-uuid"},
+			expectedStatus:   http.StatusBadRequest,
+			expectedErrorMsg: "invalid access uuid",
+		},
+	}
+
+	for _, tc := range testCases {
+		rec := httptest.NewRecorder()
+		req := s.CreateTestRequest(
+			t, http.MethodPost,
+			"/auth/admin/revoked-access-token",
+			tc.payload,
+		)
+		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", adminToken))
+		s.ServeHTTP(rec, req)
+
+		assert.Equal(t, tc.expectedStatus, rec.Code, tc.name)
+		res := struct {
+			ErrorMsg string `json:"error"`
+		}{}
+		json.NewDecoder(rec.Body).Decode(&res)
+		assert.Equal(t, tc.expectedErrorMsg, res.ErrorMsg, tc.name)
+	}
+
+	rec := httptest.NewRecorder()
+	req := s.CreateTestRequest(t, http.MethodGet, "/auth/admin/user", nil)
+	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", revokedToken))
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	res := struct {
+		ErrorMsg string `json:"error"`
+	}{}
+	json.NewDecoder(rec.Body).Decode(&res)
+	assert.Equal(t, "access token revoked", res.ErrorMsg)
+
+	// Other tokens of the same user are not affected
+	otherToken := s.LoginTestUser(t, "test1@test.test", "test_password1")
+	rec = httptest.NewRecorder()
+	req = s.CreateTestRequest(t, http.MethodGet, "/auth/admin/user", nil)
+	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", otherToken))
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestServer_RevokeUserTokens(t *testing.T) {
+	s := NewTestServer(t)
+
+	admins := s.CreateTestUser(t, 2, true)
+	adminToken := s.LoginTestUser(t, "test0@test.test", "test_password0")
+	revokedToken := s.LoginTestUser(t, "test1@test.test", "test_password1")
+	revokedSession := s.LoginTestSession(t, "test1@test.test", "test_password1")
+	revokedKeys, _ := store.CreateTestApiKey(t, s.store, admins[1], 1, 0)
+
+	testCases := []struct {
+		name             string
+		userId           int64
+		expectedStatus   int
+		expectedErrorMsg string
+	}{
+		{
+			name:             "success",
+			userId:           admins[1].ID,
+			expectedStatus:   http.StatusOK,
+			expectedErrorMsg: "",
+		},
+		{
+			name:             "user not found",
+			userId:           9999,
+			expectedStatus:   http.StatusNotFound,
+			expectedErrorMsg: "sql: no rows in result set",
+		},
+	}
+
+	for _, tc := range testCases {
+		rec := httptest.NewRecorder()
+		req := s.CreateTestRequest(
+			t, http.MethodPost,
+			fmt.Sprintf("/auth/admin/user/%d/revoke-tokens", tc.userId),
+			nil,
+		)
+		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", adminToken))
+		s.ServeHTTP(rec, req)
+
+		assert.Equal(t, tc.expectedStatus, rec.Code, tc.name)
+		res := struct {
+			ErrorMsg string `json:"error"`
+		}{}
+		json.NewDecoder(rec.Body).Decode(&res)
+		assert.Equal(t, tc.expectedErrorMsg, res.ErrorMsg, tc.name)
+	}
+
+	rec := httptest.NewRecorder()
+	req := s.CreateTestRequest(t, http.MethodGet, "/auth/admin/user", nil)
+	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", revokedToken))
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	// The sessions of the user are ended as well
+	rec = httptest.NewRecorder()
+	req = s.CreateTestRequest(t, http.MethodPost, "/auth/refresh-access-token", nil)
+	AddTestSessionCookie(req, revokedSession)
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+
+	// And so are the api keys
+	_, err := s.store.ApiKey().GetById(revokedKeys[0].Id)
+	assert.Error(t, err)
+
+	// The admin who revoked the tokens keeps access
+	rec = httptest.NewRecorder()
+	req = s.CreateTestRequest(t, http.MethodGet, "/auth/admin/user", nil)
+	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", adminToken))
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	// A token issued right after the revocation, within the same second, is
+	// accepted
+	time.Sleep(time.Millisecond)
+	newToken := s.LoginTestUser(t, "test1@test.test", "test_password1")
+	rec = httptest.NewRecorder()
+	req = s.CreateTestRequest(t, http.MethodGet, "/auth/admin/user", nil)
+	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", newToken))
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
@@ -0,0 +1,275 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/anoobz/dualread/auth/internal/model"
+	"github.com/anoobz/dualread/auth/internal/store"
+	"github.com/gorilla/mux"
+)
+
+// userGrants returns the roles of the user and the permissions they add up
+// to, as carried by the access tokens of the user.
+func (s *server) userGrants(userId int64) (model.Grants, error) {
+	roles, err := s.store.Role().GetByUser(userId)
+	if err != nil {
+		return model.Grants{}, err
+	}
+
+	return model.NewGrants(roles), nil
+}
+
+// checkGrantable refuses the permissions the caller does not hold within the
+// scope of its token, so that managing roles cannot raise the privileges of
+// the caller, like an API key cannot exceed the scope of its issuer.
+func checkGrantable(r *http.Request, permissions []string) error {
+	claims := getAccessClaims(r)
+	grantable := model.FilterScopes(claims.Permissions, claims.Scope)
+	for _, permission := range permissions {
+		if !model.Contains(grantable, permission) {
+			return fmt.Errorf("cannot grant permission: %s", permission)
+		}
+	}
+
+	return nil
+}
+
+func (s *server) getPermissions() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.respond(w, r, http.StatusOK, model.Permissions)
+	}
+}
+
+func (s *server) getRole() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		role, err := s.store.Role().GetByName(mux.Vars(r)["name"])
+		if err != nil {
+			s.error(w, r, http.StatusNotFound, errors.New("role not found"))
+			return
+		}
+
+		s.respond(w, r, http.StatusOK, role)
+	}
+}
+
+func (s *server) getAllRoles() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		roles, err := s.store.Role().GetAll()
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		s.respond(w, r, http.StatusOK, roles)
+	}
+}
+
+func (s *server) insertRole() http.HandlerFunc {
+	type payload struct {
+		Name        string   `json:"name"`
+		Permissions []string `json:"permissions"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		p := &payload{}
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			s.error(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		role, err := model.NewRole(p.Name, p.Permissions)
+		if err != nil {
+			s.error(w, r, http.StatusBadRequest, err)
+			return
+		}
+		if err := checkGrantable(r, role.Permissions); err != nil {
+			s.error(w, r, http.StatusForbidden, err)
+			return
+		}
+		if _, err := s.store.Role().GetByName(role.Name); err == nil {
+			s.error(w, r, http.StatusBadRequest, errors.New("role already exists"))
+			return
+		}
+		if err := s.store.Role().Insert(role); err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		s.respond(w, r, http.StatusCreated, role)
+	}
+}
+
+// updateRole replaces the permissions of the role. The admin role keeps every
+// permission so that its holders cannot lock themselves out. The caller must
+// hold the permissions of the role both before and after the update.
+func (s *server) updateRole() http.HandlerFunc {
+	type payload struct {
+		Permissions []string `json:"permissions"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := mux.Vars(r)["name"]
+		if name == model.AdminRoleName {
+			s.error(w, r, http.StatusBadRequest, errors.New("the admin role cannot be changed"))
+			return
+		}
+		storedRole, err := s.store.Role().GetByName(name)
+		if err != nil {
+			s.error(w, r, http.StatusNotFound, errors.New("role not found"))
+			return
+		}
+		if err := checkGrantable(r, storedRole.Permissions); err != nil {
+			s.error(w, r, http.StatusForbidden, err)
+			return
+		}
+
+		p := &payload{}
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			s.error(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		role, err := model.NewRole(name, p.Permissions)
+		if err != nil {
+			s.error(w, r, http.StatusBadRequest, err)
+			return
+		}
+		if err := checkGrantable(r, role.Permissions); err != nil {
+			s.error(w, r, http.StatusForbidden, err)
+			return
+		}
+		if err := s.store.Role().Update(role); err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		s.respond(w, r, http.StatusOK, role)
+	}
+}
+
+func (s *server) deleteRole() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := mux.Vars(r)["name"]
+		if name == model.AdminRoleName {
+			s.error(w, r, http.StatusBadRequest, errors.New("the admin role cannot be changed"))
+			return
+		}
+		role, err := s.store.Role().GetByName(name)
+		if err != nil {
+			s.error(w, r, http.StatusNotFound, errors.New("role not found"))
+			return
+		}
+		if err := checkGrantable(r, role.Permissions); err != nil {
+			s.error(w, r, http.StatusForbidden, err)
+			return
+		}
+		if err := s.store.Role().Delete(name); err != nil {
+			s.error(w, r, http.StatusNotFound, errors.New("role not found"))
+			return
+		}
+
+		s.respond(w, r, http.StatusOK, nil)
+	}
+}
+
+func (s *server) getUserRoles() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		if _, err := s.store.User().GetById(id); err != nil {
+			s.error(w, r, http.StatusNotFound, errors.New("user not found"))
+			return
+		}
+
+		roles, err := s.store.Role().GetByUser(id)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		s.respond(w, r, http.StatusOK, roles)
+	}
+}
+
+// setUserRoles replaces the roles of the user. The access tokens already
+// issued keep their permissions until they expire or are revoked. The caller
+// must hold the permissions of every role it assigns or unassigns, and the
+// last admin keeps the admin role.
+func (s *server) setUserRoles() http.HandlerFunc {
+	type payload struct {
+		Roles []string `json:"roles"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		if _, err := s.store.User().GetById(id); err != nil {
+			s.error(w, r, http.StatusNotFound, errors.New("user not found"))
+			return
+		}
+
+		p := &payload{}
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			s.error(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		currentRoles, err := s.store.Role().GetByUser(id)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		currentNames := []string{}
+		for _, role := range currentRoles {
+			currentNames = append(currentNames, role.Name)
+			if !model.Contains(p.Roles, role.Name) {
+				if err := checkGrantable(r, role.Permissions); err != nil {
+					s.error(w, r, http.StatusForbidden, err)
+					return
+				}
+			}
+		}
+
+		names := []string{}
+		for _, name := range p.Roles {
+			role, err := s.store.Role().GetByName(name)
+			if err != nil {
+				s.error(w, r, http.StatusBadRequest, fmt.Errorf("unknown role: %s", name))
+				return
+			}
+			if !model.Contains(currentNames, name) {
+				if err := checkGrantable(r, role.Permissions); err != nil {
+					s.error(w, r, http.StatusForbidden, err)
+					return
+				}
+			}
+			if !model.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+		err = s.store.Role().SetUserRoles(id, names)
+		if errors.Is(err, store.ErrLastAdmin) {
+			s.error(w, r, http.StatusBadRequest, err)
+			return
+		}
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		roles, err := s.store.Role().GetByUser(id)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		s.respond(w, r, http.StatusOK, roles)
+	}
+}
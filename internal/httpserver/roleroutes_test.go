@@ -0,0 +1,512 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/anoobz/dualread/auth/internal/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func requestTestRoleRoute(
+	t *testing.T,
+	s *server,
+	method string,
+	url string,
+	accessToken string,
+	payload map[string]interface{},
+) (*httptest.ResponseRecorder, string) {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	req := s.CreateTestRequest(t, method, url, payload)
+	req.Header.Add("Authorization", "Bearer "+accessToken)
+	s.ServeHTTP(rec, req)
+
+	if rec.Code == http.StatusOK || rec.Code == http.StatusCreated {
+		return rec, ""
+	}
+	res := struct {
+		ErrorMsg string `json:"error"`
+	}{}
+	json.NewDecoder(rec.Body).Decode(&res)
+	return rec, res.ErrorMsg
+}
+
+func TestServer_RequirePermission(t *testing.T) {
+	s := NewTestServer(t)
+
+	users := s.CreateTestUser(t, 2, false)
+	role := s.CreateTestRole(t, 1, []string{model.PermissionReadUsers})[0]
+	if err := s.store.Role().SetUserRoles(users[0].ID, []string{role.Name}); err != nil {
+		t.Fatal(err)
+	}
+	readerToken := s.LoginTestUser(t, "test0@test.test", "test_password0")
+	userToken := s.LoginTestUser(t, "test1@test.test", "test_password1")
+
+	userUrl := fmt.Sprintf("/auth/admin/user/%d", users[1].ID)
+	testCases := []struct {
+		name             string
+		method           string
+		accessToken      string
+		expectedStatus   int
+		expectedErrorMsg string
+	}{
+		{
+			name:           "granted permission",
+			method:         http.MethodGet,
+			accessToken:    readerToken,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:             "missing permission",
+			method:           http.MethodDelete,
+			accessToken:      readerToken,
+			expectedStatus:   http.StatusUnauthorized,
+			expectedErrorMsg: "unauthorized",
+		},
+		{
+			name:             "no role",
+			method:           http.MethodGet,
+			accessToken:      userToken,
+			expectedStatus:   http.StatusUnauthorized,
+			expectedErrorMsg: "unauthorized",
+		},
+	}
+
+	for _, tc := range testCases {
+		rec, errorMsg := requestTestRoleRoute(t, s, tc.method, userUrl, tc.accessToken, nil)
+
+		assert.Equal(t, tc.expectedStatus, rec.Code, tc.name)
+		assert.Equal(t, tc.expectedErrorMsg, errorMsg, tc.name)
+	}
+
+	_, err := s.store.User().GetById(users[1].ID)
+	assert.NoError(t, err)
+}
+
+func TestServer_InsertRole(t *testing.T) {
+	s := NewTestServer(t)
+
+	s.CreateTestUser(t, 1, true)
+	s.CreateTestUser(t, 1, false)
+	adminToken := s.LoginTestUser(t, "test0@test.test", "test_password0")
+	userToken := s.LoginTestUser(t, "test1@test.test", "test_password1")
+
+	testCases := []struct {
+		name             string
+		accessToken      string
+		payload          map[string]interface{}
+		expectedStatus   int
+		expectedErrorMsg string
+	}{
+		{
+			name:        "success",
+			accessToken: adminToken,
+			payload: map[string]interface{}{
+				"name":        "support",
+				"permissions": []string{model.PermissionReadUsers, model.PermissionReadTokens},
+			},
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:        "existing role",
+			accessToken: adminToken,
+			payload: map[string]interface{}{
+				"name":        "support",
+				"permissions": []string{},
+			},
+			expectedStatus:   http.StatusBadRequest,
+			expectedErrorMsg: "role already exists",
+		},
+		{
+			name:        "unknown permission",
+			accessToken: adminToken,
+			payload: map[string]interface{}{
+				"name":        "moderator",
+				"permissions": []string{"users:fly"},
+			},
+			expectedStatus:   http.StatusBadRequest,
+			expectedErrorMsg: "unknown permission: users:fly",
+		},
+		{
+			name:        "missing name",
+			accessToken: adminToken,
+			payload: map[string]interface{}{
+				"permissions": []string{model.PermissionReadUsers},
+			},
+			expectedStatus:   http.StatusBadRequest,
+			expectedErrorMsg: `invalid role name: ""`,
+		},
+		{
+			name:        "non-admin",
+			accessToken: userToken,
+			payload: map[string]interface{}{
+				"name": "moderator",
+			},
+			expectedStatus:   http.StatusUnauthorized,
+			expectedErrorMsg: "unauthorized",
+		},
+	}
+
+	for _, tc := range testCases {
+		rec, errorMsg := requestTestRoleRoute(
+			t, s,
+			http.MethodPost,
+			"/auth/admin/role",
+			tc.accessToken,
+			tc.payload,
+		)
+
+		assert.Equal(t, tc.expectedStatus, rec.Code, tc.name)
+		assert.Equal(t, tc.expectedErrorMsg, errorMsg, tc.name)
+	}
+
+	role, err := s.store.Role().GetByName("support")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(
+		t,
+		[]string{model.PermissionReadTokens, model.PermissionReadUsers},
+		role.Permissions,
+	)
+}
+
+func TestServer_UpdateRole(t *testing.T) {
+	s := NewTestServer(t)
+
+	s.CreateTestUser(t, 1, true)
+	adminToken := s.LoginTestUser(t, "test0@test.test", "test_password0")
+	role := s.CreateTestRole(t, 1, []string{model.PermissionReadUsers})[0]
+
+	testCases := []struct {
+		name             string
+		roleName         string
+		permissions      []string
+		expectedStatus   int
+		expectedErrorMsg string
+	}{
+		{
+			name:           "success",
+			roleName:       role.Name,
+			permissions:    []string{model.PermissionWriteUsers},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:             "unknown permission",
+			roleName:         role.Name,
+			permissions:      []string{"users:fly"},
+			expectedStatus:   http.StatusBadRequest,
+			expectedErrorMsg: "unknown permission: users:fly",
+		},
+		{
+			name:             "admin role",
+			roleName:         model.AdminRoleName,
+			permissions:      []string{},
+			expectedStatus:   http.StatusBadRequest,
+			expectedErrorMsg: "the admin role cannot be changed",
+		},
+		{
+			name:             "missing role",
+			roleName:         "missing_role",
+			permissions:      []string{},
+			expectedStatus:   http.StatusNotFound,
+			expectedErrorMsg: "role not found",
+		},
+	}
+
+	for _, tc := range testCases {
+		rec, errorMsg := requestTestRoleRoute(
+			t, s,
+			http.MethodPost,
+			"/auth/admin/role/"+tc.roleName,
+			adminToken,
+			map[string]interface{}{"permissions": tc.permissions},
+		)
+
+		assert.Equal(t, tc.expectedStatus, rec.Code, tc.name)
+		assert.Equal(t, tc.expectedErrorMsg, errorMsg, tc.name)
+	}
+
+	updatedRole, err := s.store.Role().GetByName(role.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{model.PermissionWriteUsers}, updatedRole.Permissions)
+	adminRole, err := s.store.Role().GetByName(model.AdminRoleName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, model.NewAdminRole(), adminRole)
+}
+
+func TestServer_DeleteRole(t *testing.T) {
+	s := NewTestServer(t)
+
+	s.CreateTestUser(t, 1, true)
+	adminToken := s.LoginTestUser(t, "test0@test.test", "test_password0")
+	role := s.CreateTestRole(t, 1, []string{model.PermissionReadUsers})[0]
+
+	testCases := []struct {
+		name             string
+		roleName         string
+		expectedStatus   int
+		expectedErrorMsg string
+	}{
+		{
+			name:           "success",
+			roleName:       role.Name,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:             "missing role",
+			roleName:         role.Name,
+			expectedStatus:   http.StatusNotFound,
+			expectedErrorMsg: "role not found",
+		},
+		{
+			name:             "admin role",
+			roleName:         model.AdminRoleName,
+			expectedStatus:   http.StatusBadRequest,
+			expectedErrorMsg: "the admin role cannot be changed",
+		},
+	}
+
+	for _, tc := range testCases {
+		rec, errorMsg := requestTestRoleRoute(
+			t, s,
+			http.MethodDelete,
+			"/auth/admin/role/"+tc.roleName,
+			adminToken,
+			nil,
+		)
+
+		assert.Equal(t, tc.expectedStatus, rec.Code, tc.name)
+		assert.Equal(t, tc.expectedErrorMsg, errorMsg, tc.name)
+	}
+}
+
+func TestServer_SetUserRoles(t *testing.T) {
+	s := NewTestServer(t)
+
+	s.CreateTestUser(t, 1, true)
+	users := s.CreateTestUser(t, 1, false)
+	adminToken := s.LoginTestUser(t, "test0@test.test", "test_password0")
+	roles := s.CreateTestRole(t, 2, []string{model.PermissionReadUsers})
+
+	testCases := []struct {
+		name             string
+		userId           int64
+		roles            []string
+		expectedStatus   int
+		expectedRoles    []string
+		expectedErrorMsg string
+	}{
+		{
+			name:           "success",
+			userId:         users[0].ID,
+			roles:          []string{roles[1].Name, roles[0].Name, roles[1].Name},
+			expectedStatus: http.StatusOK,
+			expectedRoles:  []string{roles[0].Name, roles[1].Name},
+		},
+		{
+			name:             "unknown role",
+			userId:           users[0].ID,
+			roles:            []string{"missing_role"},
+			expectedStatus:   http.StatusBadRequest,
+			expectedRoles:    []string{roles[0].Name, roles[1].Name},
+			expectedErrorMsg: "unknown role: missing_role",
+		},
+		{
+			name:           "no role",
+			userId:         users[0].ID,
+			roles:          []string{},
+			expectedStatus: http.StatusOK,
+			expectedRoles:  []string{},
+		},
+		{
+			name:             "missing user",
+			userId:           42,
+			roles:            []string{roles[0].Name},
+			expectedStatus:   http.StatusNotFound,
+			expectedRoles:    []string{},
+			expectedErrorMsg: "user not found",
+		},
+	}
+
+	for _, tc := range testCases {
+		url := fmt.Sprintf("/auth/admin/user/%d/role", tc.userId)
+		rec, errorMsg := requestTestRoleRoute(
+			t, s,
+			http.MethodPost,
+			url,
+			adminToken,
+			map[string]interface{}{"roles": tc.roles},
+		)
+
+		assert.Equal(t, tc.expectedStatus, rec.Code, tc.name)
+		assert.Equal(t, tc.expectedErrorMsg, errorMsg, tc.name)
+
+		userRoles, err := s.store.Role().GetByUser(users[0].ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		names := []string{}
+		for _, r := range userRoles {
+			names = append(names, r.Name)
+		}
+		assert.Equal(t, tc.expectedRoles, names, tc.name)
+	}
+
+	// The permissions of the roles are carried by the next access tokens
+	err := s.store.Role().SetUserRoles(users[0].ID, []string{roles[0].Name})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec, _ := requestTestRoleRoute(
+		t, s,
+		http.MethodGet,
+		fmt.Sprintf("/auth/admin/user/%d", users[0].ID),
+		s.LoginTestUser(t, "test1@test.test", "test_password1"),
+		nil,
+	)
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestServer_RoleRoutes_BoundedByCaller(t *testing.T) {
+	s := NewTestServer(t)
+
+	admins := s.CreateTestUser(t, 1, true)
+	users := s.CreateTestUser(t, 2, false)
+	managerRole, err := model.NewRole(
+		"role_manager",
+		[]string{model.PermissionReadRoles, model.PermissionWriteRoles, model.PermissionReadUsers},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.store.Role().Insert(managerRole); err != nil {
+		t.Fatal(err)
+	}
+	keysRole := s.CreateTestRole(t, 1, []string{model.PermissionWriteKeys})[0]
+	if err := s.store.Role().SetUserRoles(users[0].ID, []string{managerRole.Name}); err != nil {
+		t.Fatal(err)
+	}
+	adminToken := s.LoginTestUser(t, "test0@test.test", "test_password0")
+	managerToken := s.LoginTestUser(t, "test1@test.test", "test_password1")
+
+	testCases := []struct {
+		name             string
+		method           string
+		url              string
+		payload          map[string]interface{}
+		expectedStatus   int
+		expectedErrorMsg string
+	}{
+		{
+			name:   "insert role within the caller permissions",
+			method: http.MethodPost,
+			url:    "/auth/admin/role",
+			payload: map[string]interface{}{
+				"name":        "reader",
+				"permissions": []string{model.PermissionReadUsers},
+			},
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:   "insert role beyond the caller permissions",
+			method: http.MethodPost,
+			url:    "/auth/admin/role",
+			payload: map[string]interface{}{
+				"name":        "deleter",
+				"permissions": []string{model.PermissionReadUsers, model.PermissionDeleteUsers},
+			},
+			expectedStatus:   http.StatusForbidden,
+			expectedErrorMsg: "cannot grant permission: users:delete",
+		},
+		{
+			name:   "update role beyond the caller permissions",
+			method: http.MethodPost,
+			url:    "/auth/admin/role/reader",
+			payload: map[string]interface{}{
+				"permissions": model.Permissions,
+			},
+			expectedStatus:   http.StatusForbidden,
+			expectedErrorMsg: "cannot grant permission: clients:read",
+		},
+		{
+			name:   "update role holding other permissions",
+			method: http.MethodPost,
+			url:    "/auth/admin/role/" + keysRole.Name,
+			payload: map[string]interface{}{
+				"permissions": []string{},
+			},
+			expectedStatus:   http.StatusForbidden,
+			expectedErrorMsg: "cannot grant permission: keys:write",
+		},
+		{
+			name:             "delete role holding other permissions",
+			method:           http.MethodDelete,
+			url:              "/auth/admin/role/" + keysRole.Name,
+			expectedStatus:   http.StatusForbidden,
+			expectedErrorMsg: "cannot grant permission: keys:write",
+		},
+		{
+			name:   "assign the admin role to the caller",
+			method: http.MethodPost,
+			url:    fmt.Sprintf("/auth/admin/user/%d/role", users[0].ID),
+			payload: map[string]interface{}{
+				"roles": []string{managerRole.Name, model.AdminRoleName},
+			},
+			expectedStatus:   http.StatusForbidden,
+			expectedErrorMsg: "cannot grant permission: clients:read",
+		},
+		{
+			name:   "unassign the admin role",
+			method: http.MethodPost,
+			url:    fmt.Sprintf("/auth/admin/user/%d/role", admins[0].ID),
+			payload: map[string]interface{}{
+				"roles": []string{},
+			},
+			expectedStatus:   http.StatusForbidden,
+			expectedErrorMsg: "cannot grant permission: clients:read",
+		},
+		{
+			name:   "assign role within the caller permissions",
+			method: http.MethodPost,
+			url:    fmt.Sprintf("/auth/admin/user/%d/role", users[1].ID),
+			payload: map[string]interface{}{
+				"roles": []string{managerRole.Name, "reader"},
+			},
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	for _, tc := range testCases {
+		rec, errorMsg := requestTestRoleRoute(t, s, tc.method, tc.url, managerToken, tc.payload)
+
+		assert.Equal(t, tc.expectedStatus, rec.Code, tc.name)
+		assert.Equal(t, tc.expectedErrorMsg, errorMsg, tc.name)
+	}
+
+	roles, err := s.store.Role().GetByUser(users[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []*model.Role{managerRole}, roles)
+
+	// The last admin keeps the admin role
+	rec, errorMsg := requestTestRoleRoute(
+		t, s,
+		http.MethodPost,
+		fmt.Sprintf("/auth/admin/user/%d/role", admins[0].ID),
+		adminToken,
+		map[string]interface{}{"roles": []string{managerRole.Name}},
+	)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "the last admin cannot lose the admin role", errorMsg)
+}
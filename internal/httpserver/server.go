@@ -1,42 +1,81 @@
 package httpserver
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"sync"
 	"time"
 
+	"github.com/anoobz/dualread/auth/internal/mailer"
+	"github.com/anoobz/dualread/auth/internal/model"
 	"github.com/anoobz/dualread/auth/internal/store"
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
 )
 
 type server struct {
-	routers *routers
-	logger  *log.Logger
-	store   store.Store
-	port    int
+	routers     *routers
+	logger      *log.Logger
+	store       store.Store
+	accessKeys  *model.KeyRing
+	refreshKeys *model.KeyRing
+	mailer      mailer.Mailer
+	config      *Config
+	port        int
+	httpServer  *http.Server
+	// mails sends the emails of the routes which must not wait for them
+	mails *mailQueue
+	// done is closed by Shutdown to stop the background loops
+	done     chan struct{}
+	stopOnce sync.Once
 }
 
 type routers struct {
+	rootRouter  *mux.Router
 	baseRouter  *mux.Router
 	adminRouter *mux.Router
+	meRouter    *mux.Router
 }
 
-func NewServer(store store.Store, logger *log.Logger, port int) *server {
-	baseRouter := mux.NewRouter().PathPrefix("/auth").Subrouter()
+func NewServer(
+	store store.Store,
+	accessKeys *model.KeyRing,
+	refreshKeys *model.KeyRing,
+	mailer mailer.Mailer,
+	config *Config,
+	logger *log.Logger,
+	port int,
+) *server {
+	rootRouter := mux.NewRouter()
+	baseRouter := rootRouter.PathPrefix("/auth").Subrouter()
 	adminRouter := baseRouter.PathPrefix("/admin").Subrouter()
+	meRouter := baseRouter.PathPrefix("/me").Subrouter()
 
 	s := &server{
 		routers: &routers{
+			rootRouter:  rootRouter,
 			baseRouter:  baseRouter,
 			adminRouter: adminRouter,
+			meRouter:    meRouter,
 		},
-		store:  store,
-		logger: logger,
-		port:   port,
+		store:       store,
+		accessKeys:  accessKeys,
+		refreshKeys: refreshKeys,
+		mailer:      mailer,
+		config:      config,
+		logger:      logger,
+		port:        port,
+		mails:       newMailQueue(mailQueueSize, mailQueueWorkers, mailThrottle),
+		done:        make(chan struct{}),
+	}
+	s.httpServer = &http.Server{
+		Addr:    fmt.Sprintf(":%d", port),
+		Handler: s.routers.rootRouter,
 	}
 
 	s.registerRoutes()
@@ -45,42 +84,235 @@ func NewServer(store store.Store, logger *log.Logger, port int) *server {
 }
 
 func (s *server) Start() error {
+	go s.maintainKeyRings(s.config.KeyRotationInterval, s.done)
+	go s.runJanitor(s.config.JanitorInterval, s.done)
+
 	s.logger.Printf("Listening on port: %d", s.port)
-	return http.ListenAndServe(fmt.Sprintf(":%d", s.port), s.routers.baseRouter)
+	err := s.httpServer.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// Shutdown stops the background loops and accepting requests, then waits for
+// the requests in flight and for the queued emails to be sent, until ctx is
+// done.
+func (s *server) Shutdown(ctx context.Context) error {
+	s.stopOnce.Do(func() { close(s.done) })
+	if err := s.httpServer.Shutdown(ctx); err != nil {
+		return err
+	}
+
+	return s.mails.close(ctx)
 }
 
 func (s *server) registerRoutes() {
+	// Discovery documents are looked up at the root of the issuer
+	s.routers.rootRouter.HandleFunc(
+		"/.well-known/openid-configuration",
+		s.getOpenIdConfiguration(),
+	).Methods("Get")
+
 	s.routers.baseRouter.HandleFunc("/login", s.login()).Methods("Post")
 	s.routers.baseRouter.HandleFunc("/register", s.register()).Methods("Post")
-	s.routers.baseRouter.HandleFunc("/refresh-access-token", s.refreshAccessToken()).
+	s.routers.baseRouter.HandleFunc("/verify-email", s.verifyEmail()).Methods("Post")
+	s.routers.baseRouter.HandleFunc("/password/forgot", s.forgotPassword()).Methods("Post")
+	s.routers.baseRouter.HandleFunc("/password/reset", s.resetPassword()).Methods("Post")
+	s.routers.baseRouter.Handle(
+		"/refresh-access-token",
+		s.requireCsrfToken(s.refreshAccessToken()),
+	).Methods("Post")
+	s.routers.baseRouter.Handle("/logout", s.requireCsrfToken(s.logout())).Methods("Post")
+	s.routers.baseRouter.Handle("/logout-all", s.requireCsrfToken(s.logoutAll())).
 		Methods("Post")
-
-	s.routers.adminRouter.HandleFunc("/user/{id:[0-9]+}", s.getUser()).Methods("Get")
-	s.routers.adminRouter.HandleFunc("/user-page/{page:[0-9]+}", s.getUserPage()).
-		Methods("Get")
-	s.routers.adminRouter.HandleFunc("/user", s.getAllUsers()).Methods("Get")
-	s.routers.adminRouter.HandleFunc("/user", s.insertUser()).Methods("Post")
-	s.routers.adminRouter.HandleFunc("/user/{id:[0-9]+}", s.updateUser()).
+	s.routers.baseRouter.HandleFunc("/.well-known/jwks.json", s.getJwks()).Methods("Get")
+	s.routers.baseRouter.Handle("/introspect", s.authenticateService(s.introspect())).
 		Methods("Post")
-	s.routers.adminRouter.HandleFunc("/user/{id:[0-9]+}", s.deleteUser()).
-		Methods("Delete")
+	s.routers.baseRouter.Handle(
+		"/userinfo",
+		s.validateAccessToken(s.requireScope(model.ScopeOpenId, s.getUserInfo())),
+	).Methods("Get", "Post")
+	s.routers.baseRouter.HandleFunc("/authorize", s.authorize()).Methods("Get")
+	s.routers.baseRouter.HandleFunc("/token", s.token()).Methods("Post")
 
-	s.routers.adminRouter.HandleFunc("/auth-token/{id}", s.getAuthToken()).
-		Methods("Get")
-	s.routers.adminRouter.HandleFunc("/auth-token-page/{page:[0-9]+}", s.getAuthTokenPage()).
+	s.routers.adminRouter.Handle(
+		"/user/{id:[0-9]+}",
+		s.requirePermission(model.PermissionReadUsers, s.getUser()),
+	).Methods("Get")
+	s.routers.adminRouter.Handle(
+		"/user-page/{page:[0-9]+}",
+		s.requirePermission(model.PermissionReadUsers, s.getUserPage()),
+	).Methods("Get")
+	s.routers.adminRouter.Handle(
+		"/user",
+		s.requirePermission(model.PermissionReadUsers, s.getAllUsers()),
+	).Methods("Get")
+	s.routers.adminRouter.Handle(
+		"/user",
+		s.requirePermission(model.PermissionWriteUsers, s.insertUser()),
+	).Methods("Post")
+	s.routers.adminRouter.Handle(
+		"/user/{id:[0-9]+}",
+		s.requirePermission(model.PermissionWriteUsers, s.updateUser()),
+	).Methods("Post")
+	s.routers.adminRouter.Handle(
+		"/user/{id:[0-9]+}",
+		s.requirePermission(model.PermissionDeleteUsers, s.deleteUser()),
+	).Methods("Delete")
+
+	s.routers.adminRouter.Handle(
+		"/auth-token/{id}",
+		s.requirePermission(model.PermissionReadTokens, s.getAuthToken()),
+	).Methods("Get")
+	s.routers.adminRouter.Handle(
+		"/auth-token-page/{page:[0-9]+}",
+		s.requirePermission(model.PermissionReadTokens, s.getAuthTokenPage()),
+	).Methods("Get")
+	s.routers.adminRouter.Handle(
+		"/auth-token",
+		s.requirePermission(model.PermissionReadTokens, s.getAllAuthTokens()),
+	).Methods("Get")
+	s.routers.adminRouter.Handle(
+		"/auth-token/{id}",
+		s.requirePermission(model.PermissionDeleteTokens, s.deleteAuthToken()),
+	).Methods("Delete")
+
+	s.routers.adminRouter.Handle(
+		"/revoked-access-token",
+		s.requirePermission(model.PermissionRevokeTokens, s.revokeAccessToken()),
+	).Methods("Post")
+	s.routers.adminRouter.Handle(
+		"/user/{id:[0-9]+}/revoke-tokens",
+		s.requirePermission(model.PermissionRevokeTokens, s.revokeUserTokens()),
+	).Methods("Post")
+
+	s.routers.adminRouter.Handle(
+		"/signing-key",
+		s.requirePermission(model.PermissionReadKeys, s.getSigningKeys()),
+	).Methods("Get")
+	s.routers.adminRouter.Handle(
+		"/signing-key/rotate",
+		s.requirePermission(model.PermissionWriteKeys, s.rotateSigningKey()),
+	).Methods("Post")
+	s.routers.adminRouter.Handle(
+		"/signing-key/{kid}",
+		s.requirePermission(model.PermissionWriteKeys, s.deleteSigningKey()),
+	).Methods("Delete")
+
+	s.routers.adminRouter.Handle(
+		"/user/{id:[0-9]+}/api-key",
+		s.requirePermission(model.PermissionReadTokens, s.getUserApiKeys()),
+	).Methods("Get")
+	s.routers.adminRouter.Handle(
+		"/user/{id:[0-9]+}/api-key",
+		s.requirePermission(model.PermissionWriteUsers, s.insertUserApiKey()),
+	).Methods("Post")
+	s.routers.adminRouter.Handle(
+		"/api-key/{id}",
+		s.requirePermission(model.PermissionRevokeTokens, s.deleteApiKey()),
+	).Methods("Delete")
+
+	s.routers.adminRouter.Handle(
+		"/client",
+		s.requirePermission(model.PermissionReadClients, s.getAllClients()),
+	).Methods("Get")
+	s.routers.adminRouter.Handle(
+		"/client",
+		s.requirePermission(model.PermissionWriteClients, s.insertClient()),
+	).Methods("Post")
+	s.routers.adminRouter.Handle(
+		"/client/{id}",
+		s.requirePermission(model.PermissionReadClients, s.getClient()),
+	).Methods("Get")
+	s.routers.adminRouter.Handle(
+		"/client/{id}",
+		s.requirePermission(model.PermissionWriteClients, s.updateClient()),
+	).Methods("Post")
+	s.routers.adminRouter.Handle(
+		"/client/{id}/secret",
+		s.requirePermission(model.PermissionWriteClients, s.rotateClientSecret()),
+	).Methods("Post")
+	s.routers.adminRouter.Handle(
+		"/client/{id}",
+		s.requirePermission(model.PermissionWriteClients, s.deleteClient()),
+	).Methods("Delete")
+
+	s.routers.adminRouter.Handle(
+		"/permission",
+		s.requirePermission(model.PermissionReadRoles, s.getPermissions()),
+	).Methods("Get")
+	s.routers.adminRouter.Handle(
+		"/role",
+		s.requirePermission(model.PermissionReadRoles, s.getAllRoles()),
+	).Methods("Get")
+	s.routers.adminRouter.Handle(
+		"/role",
+		s.requirePermission(model.PermissionWriteRoles, s.insertRole()),
+	).Methods("Post")
+	s.routers.adminRouter.Handle(
+		"/role/{name}",
+		s.requirePermission(model.PermissionReadRoles, s.getRole()),
+	).Methods("Get")
+	s.routers.adminRouter.Handle(
+		"/role/{name}",
+		s.requirePermission(model.PermissionWriteRoles, s.updateRole()),
+	).Methods("Post")
+	s.routers.adminRouter.Handle(
+		"/role/{name}",
+		s.requirePermission(model.PermissionWriteRoles, s.deleteRole()),
+	).Methods("Delete")
+	s.routers.adminRouter.Handle(
+		"/user/{id:[0-9]+}/role",
+		s.requirePermission(model.PermissionReadRoles, s.getUserRoles()),
+	).Methods("Get")
+	s.routers.adminRouter.Handle(
+		"/user/{id:[0-9]+}/role",
+		s.requirePermission(model.PermissionWriteRoles, s.setUserRoles()),
+	).Methods("Post")
+
+	s.routers.meRouter.Handle("", s.requireScope(model.ScopeProfileRead, s.getMe())).
 		Methods("Get")
-	s.routers.adminRouter.HandleFunc("/auth-token", s.getAllAuthTokens()).Methods("Get")
-	s.routers.adminRouter.HandleFunc("/auth-token/{id}", s.deleteAuthToken()).
-		Methods("Delete")
+	s.routers.meRouter.Handle("", s.requireScope(model.ScopeProfileWrite, s.updateMe())).
+		Methods("Patch")
+	s.routers.meRouter.Handle(
+		"/password",
+		s.requireScope(model.ScopeProfileWrite, s.changeMyPassword()),
+	).Methods("Post")
+	s.routers.meRouter.Handle(
+		"/verification-email",
+		s.requireScope(model.ScopeProfileWrite, s.resendVerificationEmail()),
+	).Methods("Post")
+	s.routers.meRouter.Handle(
+		"/sessions",
+		s.requireScope(model.ScopeSessionsRead, s.getMySessions()),
+	).Methods("Get")
+	s.routers.meRouter.Handle(
+		"/sessions/{id}",
+		s.requireScope(model.ScopeSessionsWrite, s.deleteMySession()),
+	).Methods("Delete")
+	s.routers.meRouter.Handle(
+		"/api-keys",
+		s.requireScope(model.ScopeApiKeysRead, s.getMyApiKeys()),
+	).Methods("Get")
+	s.routers.meRouter.Handle(
+		"/api-keys",
+		s.requireScope(model.ScopeApiKeysWrite, s.insertMyApiKey()),
+	).Methods("Post")
+	s.routers.meRouter.Handle(
+		"/api-keys/{id}",
+		s.requireScope(model.ScopeApiKeysWrite, s.deleteMyApiKey()),
+	).Methods("Delete")
 }
 
 func (s *server) configMiddlewares() {
-	s.routers.baseRouter.Use(s.logRequest)
+	s.routers.rootRouter.Use(s.logRequest)
 
 	corsOrigin := []string{os.Getenv("CORS_ORIGIN")}
-	s.routers.baseRouter.Use(handlers.CORS(handlers.AllowedOrigins(corsOrigin)))
+	s.routers.rootRouter.Use(handlers.CORS(handlers.AllowedOrigins(corsOrigin)))
 
 	s.routers.adminRouter.Use(s.validateAccessToken)
+	s.routers.meRouter.Use(s.validateUserAccessToken)
 }
 
 func (s *server) error(w http.ResponseWriter, r *http.Request, code int, err error) {
@@ -117,5 +349,5 @@ func (s *server) logRequest(next http.Handler) http.Handler {
 }
 
 func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	s.routers.baseRouter.ServeHTTP(w, r)
+	s.routers.rootRouter.ServeHTTP(w, r)
 }
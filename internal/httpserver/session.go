@@ -0,0 +1,54 @@
+package httpserver
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/anoobz/dualread/auth/internal/model"
+)
+
+// maxUserAgentLength bounds the user agent stored with a session, the header
+// is set by the client and can be arbitrarily long.
+const maxUserAgentLength = 512
+
+// clientIp returns the address of the client. Behind a trusted reverse proxy
+// it is the last address of X-Forwarded-For, the one the proxy appended.
+func (s *server) clientIp(r *http.Request) string {
+	if s.config.TrustProxyHeaders {
+		forwarded := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+		if ip := net.ParseIP(strings.TrimSpace(forwarded[len(forwarded)-1])); ip != nil {
+			return ip.String()
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// setSessionMetadata records the client issuing the request the refresh token
+// is created for.
+func (s *server) setSessionMetadata(rt *model.AuthToken, r *http.Request) {
+	rt.ClientIp = s.clientIp(r)
+	rt.UserAgent = truncateUserAgent(r.UserAgent())
+}
+
+// truncateUserAgent cuts the user agent to maxUserAgentLength bytes on a rune
+// boundary, the database rejects invalid UTF-8.
+func truncateUserAgent(userAgent string) string {
+	userAgent = strings.ToValidUTF8(userAgent, "")
+	if len(userAgent) <= maxUserAgentLength {
+		return userAgent
+	}
+
+	end := maxUserAgentLength
+	for end > 0 && !utf8.RuneStart(userAgent[end]) {
+		end--
+	}
+	return userAgent[:end]
+}
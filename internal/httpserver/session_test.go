@@ -0,0 +1,82 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_SessionMetadata(t *testing.T) {
+	s := NewTestServer(t)
+
+	s.CreateTestUser(t, 1, false)
+
+	rec := httptest.NewRecorder()
+	req := s.CreateTestRequest(
+		t, http.MethodPost, "/auth/login",
+		map[string]interface{}{"email": "test0@test.test", "password": "test_password0"},
+	)
+	req.RemoteAddr = "192.0.2.1:4321"
+	req.Header.Set("User-Agent", "library-computer")
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var cookie *http.Cookie
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == "refresh_token" {
+			cookie = c
+		}
+	}
+	loginToken, _, err := s.findRefreshToken(cookie.Value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "192.0.2.1", loginToken.ClientIp)
+	assert.Equal(t, "library-computer", loginToken.UserAgent)
+	assert.NotZero(t, loginToken.Created)
+	assert.Equal(t, loginToken.Created, loginToken.LastUsed)
+
+	// The rotated token keeps the creation time of the session but records the
+	// client that refreshed it
+	s.config.TrustProxyHeaders = true
+	rec = httptest.NewRecorder()
+	req = s.CreateTestRequest(t, http.MethodPost, "/auth/refresh-access-token", nil)
+	req.RemoteAddr = "10.0.0.2:4321"
+	req.Header.Set("X-Forwarded-For", "203.0.113.9, 198.51.100.7")
+	req.Header.Set("User-Agent", strings.Repeat("a", maxUserAgentLength+1))
+	AddTestSessionCookie(req, cookie)
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == "refresh_token" {
+			cookie = c
+		}
+	}
+	rotatedToken, _, err := s.findRefreshToken(cookie.Value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, loginToken.Family, rotatedToken.Family)
+	assert.Equal(t, loginToken.Created, rotatedToken.Created)
+	assert.GreaterOrEqual(t, rotatedToken.LastUsed, loginToken.LastUsed)
+	assert.Equal(t, "198.51.100.7", rotatedToken.ClientIp)
+	assert.Len(t, rotatedToken.UserAgent, maxUserAgentLength)
+}
+
+func TestServer_TruncateUserAgent(t *testing.T) {
+	// The multi-byte rune crossing the limit is dropped whole
+	userAgent := truncateUserAgent(strings.Repeat("a", maxUserAgentLength-1) + "é")
+	assert.True(t, utf8.ValidString(userAgent))
+	assert.Equal(t, strings.Repeat("a", maxUserAgentLength-1), userAgent)
+
+	userAgent = truncateUserAgent(strings.Repeat("é", maxUserAgentLength))
+	assert.True(t, utf8.ValidString(userAgent))
+	assert.Len(t, userAgent, maxUserAgentLength)
+
+	assert.Equal(t, "ab", truncateUserAgent("a\xffb"))
+}
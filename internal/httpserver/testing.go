@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
@@ -12,11 +11,12 @@ import (
 	"os"
 	"strconv"
 	"testing"
+	"time"
 
+	"github.com/anoobz/dualread/auth/internal/mailer"
 	"github.com/anoobz/dualread/auth/internal/model"
 	"github.com/anoobz/dualread/auth/internal/store"
 	"github.com/anoobz/dualread/auth/internal/store/mockstore"
-	"github.com/dgrijalva/jwt-go"
 	_ "github.com/lib/pq"
 )
 
@@ -29,7 +29,7 @@ func NewTestLogger(t *testing.T) *log.Logger {
 func NewTestServer(t *testing.T) *server {
 	t.Helper()
 
-	store := mockstore.CreateTestStore(t)
+	testStore := mockstore.CreateTestStore(t)
 	logger := NewTestLogger(t)
 
 	port, err := strconv.Atoi(os.Getenv("SERVER_PORT"))
@@ -37,7 +37,46 @@ func NewTestServer(t *testing.T) *server {
 		t.Fatal(err)
 	}
 
-	return NewServer(store, logger, port)
+	accessKeys := NewTestKeyRing(t, testStore, model.AccessKeyPurpose, "ES256")
+	refreshKeys := NewTestKeyRing(t, testStore, model.RefreshKeyPurpose, "HS256")
+
+	config := NewDefaultConfig()
+	config.Issuer = "https://auth.dualread.test"
+	config.RefreshTokenHashKey = store.GetTestTokenHashKey()
+	config.AccessKeyAlg = "ES256"
+	config.EmailVerificationUrl = "https://dualread.test/verify-email"
+	config.PasswordResetUrl = "https://dualread.test/reset-password"
+
+	return NewServer(
+		testStore,
+		accessKeys,
+		refreshKeys,
+		mailer.NewOutbox(""),
+		config,
+		logger,
+		port,
+	)
+}
+
+func NewTestKeyRing(t *testing.T, s store.Store, purpose string, alg string) *model.KeyRing {
+	t.Helper()
+
+	key, err := model.GenerateSigningKey(alg, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ring, err := store.LoadKeyRing(
+		s.SigningKey(),
+		purpose,
+		key,
+		NewDefaultConfig().TokenLifetime(purpose),
+		time.Now(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return ring
 }
 
 func (s *server) CreateTestUser(t *testing.T, count int, admin bool) []*model.User {
@@ -46,6 +85,13 @@ func (s *server) CreateTestUser(t *testing.T, count int, admin bool) []*model.Us
 	return store.CreateTestUser(t, s.store, count, admin)
 }
 
+// CreateTestRole inserts roles named test_role%d, each with the permissions.
+func (s *server) CreateTestRole(t *testing.T, count int, permissions []string) []*model.Role {
+	t.Helper()
+
+	return store.CreateTestRole(t, s.store, count, permissions)
+}
+
 func (s *server) CreateTestToken(
 	t *testing.T,
 	count int,
@@ -56,6 +102,21 @@ func (s *server) CreateTestToken(
 	return store.CreateTestToken(t, s.store, count, user)
 }
 
+func (s *server) CreateTestClient(t *testing.T, count int) []*model.Client {
+	t.Helper()
+
+	return store.CreateTestClient(t, s.store, count)
+}
+
+func (s *server) CreateTestServiceClient(
+	t *testing.T,
+	scopes []string,
+) (*model.Client, string) {
+	t.Helper()
+
+	return store.CreateTestServiceClient(t, s.store, scopes)
+}
+
 func (s *server) CreateTestRequest(
 	t *testing.T,
 	method string,
@@ -77,70 +138,37 @@ func (s *server) CreateTestRequest(
 func (s *server) DeleteTestRefreshTokenUser(t *testing.T, cookie *http.Cookie) {
 	t.Helper()
 
-	jwtToken, err := jwt.Parse(
-		cookie.Value,
-		func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf(
-					"unexpected signing method: %v",
-					token.Header["alg"],
-				)
-			}
-			return []byte(os.Getenv("JWT_REFRESH_SECRET")), nil
-		},
-	)
+	claims, err := s.parseRefreshToken(cookie.Value)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	if !jwtToken.Valid {
-		t.Fatal(errors.New("invalid token"))
-	}
-
-	claims, ok := jwtToken.Claims.(jwt.MapClaims)
-	if !ok {
-		t.Fatal(errors.New("invalid token"))
-	}
-
-	user_id, err := strconv.ParseInt(fmt.Sprintf("%v", claims["user_id"]), 10, 64)
+	userId, err := claims.UserId()
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	s.store.User().Delete(user_id)
+	s.store.User().Delete(userId)
 }
 
-func (s *server) DeleteTestRefreshToken(t *testing.T, cookie *http.Cookie) {
+func (s *server) SetTestUserActive(t *testing.T, userId int64, active bool) {
 	t.Helper()
 
-	jwtToken, err := jwt.Parse(
-		cookie.Value,
-		func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf(
-					"unexpected signing method: %v",
-					token.Header["alg"],
-				)
-			}
-			return []byte(os.Getenv("JWT_REFRESH_SECRET")), nil
-		},
-	)
+	err := s.store.User().Update(userId, map[string]interface{}{"active": active})
 	if err != nil {
 		t.Fatal(err)
 	}
+}
 
-	if !jwtToken.Valid {
-		t.Fatal(errors.New("invalid token"))
-	}
+func (s *server) DeleteTestRefreshToken(t *testing.T, cookie *http.Cookie) {
+	t.Helper()
 
-	claims, ok := jwtToken.Claims.(jwt.MapClaims)
-	if !ok {
-		t.Fatal(errors.New("invalid token"))
+	claims, err := s.parseRefreshToken(cookie.Value)
+	if err != nil {
+		t.Fatal(err)
 	}
 
-	refresh_uuid := fmt.Sprintf("%s", claims["refresh_uuid"])
-
-	err = s.store.AuthToken().Delete(refresh_uuid)
+	err = s.store.AuthToken().Delete(claims.Id)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -149,11 +177,25 @@ func (s *server) DeleteTestRefreshToken(t *testing.T, cookie *http.Cookie) {
 func (s *server) LoginTestUser(t *testing.T, email string, password string) string {
 	t.Helper()
 
+	return s.LoginTestUserFor(t, email, password)
+}
+
+// LoginTestUserFor logs the user in and returns an access token intended for
+// the audiences, the service itself when there are none.
+func (s *server) LoginTestUserFor(
+	t *testing.T,
+	email string,
+	password string,
+	audience ...string,
+) string {
+	t.Helper()
+
+	payload := map[string]interface{}{"email": email, "password": password}
+	if len(audience) > 0 {
+		payload["audience"] = audience
+	}
 	rec := httptest.NewRecorder()
-	req := s.CreateTestRequest(
-		t, http.MethodPost, "/auth/login",
-		map[string]interface{}{"email": email, "password": password},
-	)
+	req := s.CreateTestRequest(t, http.MethodPost, "/auth/login", payload)
 	s.ServeHTTP(rec, req)
 
 	res := struct {
@@ -167,3 +209,32 @@ func (s *server) LoginTestUser(t *testing.T, email string, password string) stri
 
 	return res.TokenString
 }
+
+// LoginTestSession logs the user in and returns its refresh token cookie.
+func (s *server) LoginTestSession(t *testing.T, email string, password string) *http.Cookie {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	req := s.CreateTestRequest(
+		t, http.MethodPost, "/auth/login",
+		map[string]interface{}{"email": email, "password": password},
+	)
+	s.ServeHTTP(rec, req)
+
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == "refresh_token" {
+			return c
+		}
+	}
+
+	t.Fatal(errors.New("refresh token cookie not set"))
+	return nil
+}
+
+// AddTestSessionCookie adds the refresh token cookie to the request along with
+// a matching CSRF cookie and header.
+func AddTestSessionCookie(req *http.Request, cookie *http.Cookie) {
+	req.AddCookie(cookie)
+	req.AddCookie(&http.Cookie{Name: csrfTokenCookieName, Value: "test_csrf_token"})
+	req.Header.Set(csrfTokenHeader, "test_csrf_token")
+}
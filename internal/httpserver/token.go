@@ -5,17 +5,40 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
-	"os"
 	"strings"
+	"time"
 
-	"github.com/dgrijalva/jwt-go"
+	"github.com/anoobz/dualread/auth/internal/model"
 )
 
 type ctxKey string
 
+const (
+	accessClaimsKey = ctxKey("claims")
+	userKey         = ctxKey("user")
+)
+
+var errRefreshTokenReused = errors.New("refresh token reused")
+
+// validateAccessToken authenticates the user of the request with its access
+// token and stores the claims of the token in the request context. API keys,
+// presented with the ApiKey scheme, are accepted in place of access tokens.
 func (s *server) validateAccessToken(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		authString := strings.Split(r.Header.Get("Authorization"), "Bearer ")
+		authorization := r.Header.Get("Authorization")
+		if strings.HasPrefix(authorization, "ApiKey ") {
+			claims, err := s.authenticateApiKey(strings.TrimPrefix(authorization, "ApiKey "))
+			if err != nil {
+				s.error(w, r, http.StatusUnauthorized, err)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), accessClaimsKey, claims)
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+
+		authString := strings.Split(authorization, "Bearer ")
 		if len(authString) != 2 {
 			s.error(
 				w,
@@ -25,55 +48,273 @@ func (s *server) validateAccessToken(next http.Handler) http.Handler {
 			)
 			return
 		}
-		token, err := jwt.Parse(
-			authString[1],
-			func(token *jwt.Token) (interface{}, error) {
-				if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-					return nil, fmt.Errorf(
-						"unexpected signing method: %v",
-						token.Header["alg"],
-					)
-				}
-				return []byte(os.Getenv("JWT_ACCESS_SECRET")), nil
-			},
-		)
+		claims, err := s.parseAccessToken(authString[1])
 		if err != nil {
 			s.error(w, r, http.StatusUnauthorized, err)
 			return
 		}
 
-		if !token.Valid {
+		revoked, err := s.isAccessTokenRevoked(claims)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		if revoked {
+			s.error(w, r, http.StatusUnauthorized, errors.New("access token revoked"))
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), accessClaimsKey, claims)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// validateUserAccessToken is the variant of validateAccessToken for the routes
+// of users acting on their own account. The tokens of clients are refused and
+// the active user of the token is stored in the request context.
+func (s *server) validateUserAccessToken(next http.Handler) http.Handler {
+	return s.validateAccessToken(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userId, err := getAccessClaims(r).UserId()
+		if err != nil {
 			s.error(w, r, http.StatusUnauthorized, err)
 			return
 		}
+		user, err := s.store.User().GetById(userId)
+		if err != nil || !user.Active {
+			s.error(w, r, http.StatusUnauthorized, errors.New("user not found"))
+			return
+		}
 
-		claims := token.Claims.(jwt.MapClaims)
-		if !claims["admin"].(bool) {
+		ctx := context.WithValue(r.Context(), userKey, user)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	}))
+}
+
+// requirePermission must run after validateAccessToken. The permissions are
+// read from the token, a change of the roles of a user applies to the access
+// tokens issued afterwards. The permission must also be part of the scope of
+// the token.
+func (s *server) requirePermission(permission string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims := getAccessClaims(r)
+		if claims == nil || !claims.HasPermission(permission) {
 			s.error(w, r, http.StatusUnauthorized, errors.New("unauthorized"))
 			return
 		}
 
-		ctx := context.WithValue(r.Context(), ctxKey("claims"), claims)
-		next.ServeHTTP(w, r.WithContext(ctx))
+		s.requireScope(permission, next).ServeHTTP(w, r)
 	})
 }
 
-func getRefreshTokenClaims(tokenString string) (jwt.MapClaims, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+// requireScope must run after validateAccessToken. Tokens lacking the scope
+// are refused as described in RFC 6750 section 3.1.
+func (s *server) requireScope(scope string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if claims := getAccessClaims(r); claims == nil || !claims.HasScope(scope) {
+			w.Header().Set(
+				"WWW-Authenticate",
+				fmt.Sprintf(`Bearer error="insufficient_scope", scope="%s"`, scope),
+			)
+			s.error(w, r, http.StatusForbidden, errors.New("insufficient scope"))
+			return
 		}
-		return []byte(os.Getenv("JWT_REFRESH_SECRET")), nil
+
+		next.ServeHTTP(w, r)
 	})
+}
+
+// getAccessClaims returns the claims stored by validateAccessToken, or nil on
+// routes which are not authenticated.
+func getAccessClaims(r *http.Request) *model.AccessClaims {
+	claims, _ := r.Context().Value(accessClaimsKey).(*model.AccessClaims)
+	return claims
+}
+
+// getUser returns the user stored by validateUserAccessToken.
+func getUser(r *http.Request) *model.User {
+	user, _ := r.Context().Value(userKey).(*model.User)
+	return user
+}
+
+// parseAccessToken accepts the access tokens intended for the service itself.
+func (s *server) parseAccessToken(tokenString string) (*model.AccessClaims, error) {
+	return s.parseAccessTokenFor(tokenString, s.config.Audience)
+}
+
+func (s *server) parseAccessTokenFor(
+	tokenString string,
+	audience string,
+) (*model.AccessClaims, error) {
+	return model.ParseAccessToken(
+		tokenString,
+		s.accessKeys.Keyfunc,
+		s.config.Issuer,
+		audience,
+	)
+}
+
+// Refresh tokens are only ever presented back to the service.
+func (s *server) parseRefreshToken(tokenString string) (*model.RefreshClaims, error) {
+	return model.ParseRefreshToken(
+		tokenString,
+		s.refreshKeys.Keyfunc,
+		s.config.Issuer,
+		s.config.Audience,
+	)
+}
+
+// accessTokenParams validates the audiences requested by a client, tokens
+// requesting none are intended for the service itself.
+func (s *server) accessTokenParams(audience model.Audience) (model.TokenParams, error) {
+	if len(audience) == 0 {
+		audience = model.Audience{s.config.Audience}
+	}
+	for _, aud := range audience {
+		if !s.config.validAudience(aud) {
+			return model.TokenParams{}, fmt.Errorf("invalid audience: %s", aud)
+		}
+	}
+
+	return model.TokenParams{
+		Issuer:   s.config.Issuer,
+		Audience: audience,
+		Lifetime: s.config.AccessTokenLifetime,
+	}, nil
+}
+
+func (s *server) refreshTokenParams(lifetime time.Duration) model.TokenParams {
+	return model.TokenParams{
+		Issuer:   s.config.Issuer,
+		Audience: model.Audience{s.config.Audience},
+		Lifetime: lifetime,
+	}
+}
+
+// getStoredRefreshToken validates the refresh_token cookie and returns the
+// persisted token it refers to along with its claims.
+func (s *server) getStoredRefreshToken(
+	r *http.Request,
+) (*model.AuthToken, *model.RefreshClaims, error) {
+	cookie, err := r.Cookie(refreshTokenCookieName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return s.findSessionRefreshToken(cookie.Value)
+}
+
+// getPresentedRefreshToken returns the stored refresh token presented by the
+// request, bodyToken, the refresh token cookie or the Authorization header in
+// that order. The header is only read without a cookie so that browsers
+// sending their access token to every route keep the cookie flow. native
+// reports whether the token was presented without the cookie.
+func (s *server) getPresentedRefreshToken(
+	r *http.Request,
+	bodyToken string,
+) (*model.AuthToken, *model.RefreshClaims, bool, error) {
+	if bodyToken != "" {
+		storedToken, claims, err := s.findSessionRefreshToken(bodyToken)
+		return storedToken, claims, true, err
+	}
+	if _, err := r.Cookie(refreshTokenCookieName); err == nil {
+		storedToken, claims, err := s.getStoredRefreshToken(r)
+		return storedToken, claims, false, err
+	}
+
+	authorization := r.Header.Get("Authorization")
+	if !strings.HasPrefix(authorization, "Bearer ") {
+		return nil, nil, false, errors.New("refresh token not presented")
+	}
+	storedToken, claims, err := s.findSessionRefreshToken(
+		strings.TrimPrefix(authorization, "Bearer "),
+	)
+	return storedToken, claims, true, err
+}
+
+// findRefreshToken returns the persisted refresh token matching tokenString,
+// the signature alone does not prove that the token was issued by the store.
+func (s *server) findRefreshToken(
+	tokenString string,
+) (*model.AuthToken, *model.RefreshClaims, error) {
+	claims, err := s.parseRefreshToken(tokenString)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	storedToken, err := s.store.AuthToken().GetById(claims.Id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !storedToken.Matches(s.config.RefreshTokenHashKey, tokenString) {
+		return nil, nil, errors.New("invalid refresh token")
+	}
+
+	return storedToken, claims, nil
+}
+
+// findSessionRefreshToken is the variant of findRefreshToken for the sessions
+// of the web app and the native clients. The refresh tokens issued to a
+// client are refused so that they are only rotated by the refresh_token grant
+// of that client.
+func (s *server) findSessionRefreshToken(
+	tokenString string,
+) (*model.AuthToken, *model.RefreshClaims, error) {
+	storedToken, claims, err := s.findRefreshToken(tokenString)
+	if err != nil {
+		return nil, nil, err
+	}
+	if claims.ClientId != "" {
+		return nil, nil, errors.New("refresh token issued to a client")
+	}
+
+	return storedToken, claims, nil
+}
+
+func (s *server) insertRefreshToken(rt *model.AuthToken) error {
+	return s.store.AuthToken().Insert(rt.Hashed(s.config.RefreshTokenHashKey))
+}
+
+// rotateRefreshToken consumes the stored refresh token and issues its
+// successor in the same family, which keeps the scope and the client of the
+// token. A consumed token being presented again means that it was copied, so
+// every session descending from the same login is revoked and
+// errRefreshTokenReused is returned.
+func (s *server) rotateRefreshToken(
+	r *http.Request,
+	user *model.User,
+	storedToken *model.AuthToken,
+	claims *model.RefreshClaims,
+) (*model.AuthToken, error) {
+	consumed, err := s.store.AuthToken().Consume(storedToken.Uuid)
 	if err != nil {
 		return nil, err
 	}
+	if !consumed {
+		s.logger.Printf(
+			"security: refresh token %s reused, revoking token family %s",
+			storedToken.Uuid,
+			storedToken.Family,
+		)
+		if err := s.store.AuthToken().DeleteFamily(storedToken.Family); err != nil {
+			return nil, err
+		}
+		return nil, errRefreshTokenReused
+	}
 
-	if !token.Valid {
-		return nil, errors.New("invalid refresh token")
+	refreshTokenParams := s.refreshTokenParams(s.refreshTokenLifetime(claims))
+	refreshTokenParams.Scope = claims.Scope
+	refreshTokenParams.ClientId = claims.ClientId
+	refreshToken, err := model.NewRefreshToken(user, s.refreshKeys.Active(), refreshTokenParams)
+	if err != nil {
+		return nil, err
 	}
+	refreshToken.Family = storedToken.Family
+	refreshToken.Created = storedToken.Created
+	s.setSessionMetadata(refreshToken, r)
 
-	claims := token.Claims.(jwt.MapClaims)
+	if err := s.insertRefreshToken(refreshToken); err != nil {
+		return nil, err
+	}
 
-	return claims, nil
+	return refreshToken, nil
 }
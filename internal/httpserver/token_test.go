@@ -0,0 +1,455 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/anoobz/dualread/auth/internal/model"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_FindRefreshToken(t *testing.T) {
+	s := NewTestServer(t)
+
+	s.CreateTestUser(t, 1, false)
+	cookie := s.LoginTestSession(t, "test0@test.test", "test_password0")
+
+	storedToken, claims, err := s.findRefreshToken(cookie.Value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, storedToken.TokenString)
+	assert.NotEqual(t, cookie.Value, storedToken.TokenHash)
+
+	// A validly signed token reusing the id of a stored token was not issued
+	// by the server and must not match
+	forgedClaims := *claims
+	forgedClaims.ExpiresAt = time.Now().Add(time.Hour).Unix()
+	forged, err := s.refreshKeys.Active().Sign(
+		jwt.NewWithClaims(s.refreshKeys.Active().Method, &forgedClaims),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err = s.findRefreshToken(forged)
+	assert.EqualError(t, err, "invalid refresh token")
+
+	rec := httptest.NewRecorder()
+	req := s.CreateTestRequest(t, http.MethodPost, "/auth/refresh-access-token", nil)
+	AddTestSessionCookie(req, &http.Cookie{Name: "refresh_token", Value: forged})
+	s.ServeHTTP(rec, req)
+	assert.NotEqual(t, http.StatusOK, rec.Code)
+
+	// The stored token is left untouched by the forged attempt
+	storedToken, err = s.store.AuthToken().GetById(storedToken.Uuid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, storedToken.Consumed)
+}
+
+func TestServer_ValidateAccessToken_MissingClaims(t *testing.T) {
+	s := NewTestServer(t)
+
+	s.CreateTestUser(t, 1, false)
+	key := s.accessKeys.Active()
+
+	testCases := []struct {
+		name             string
+		claims           jwt.Claims
+		expectedErrorMsg string
+	}{
+		{
+			name: "missing permissions claim",
+			claims: jwt.MapClaims{
+				"sub":       "1",
+				"jti":       "5c7f6d5e-8bd9-4a3e-9a39-1f3f0b1f5a01",
+				"iss":       s.config.Issuer,
+				"aud":       s.config.Audience,
+				"token_use": model.AccessTokenUse,
+				"iat":       time.Now().Unix(),
+				"exp":       time.Now().Add(time.Minute).Unix(),
+			},
+			expectedErrorMsg: "unauthorized",
+		},
+		{
+			name: "missing subject",
+			claims: jwt.MapClaims{
+				"jti":         "5c7f6d5e-8bd9-4a3e-9a39-1f3f0b1f5a01",
+				"token_use":   model.AccessTokenUse,
+				"permissions": []string{model.PermissionReadUsers},
+				"exp":         time.Now().Add(time.Minute).Unix(),
+			},
+			expectedErrorMsg: "token is missing required claims",
+		},
+	}
+
+	for _, tc := range testCases {
+		tokenString, err := key.Sign(jwt.NewWithClaims(key.Method, tc.claims))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rec := httptest.NewRecorder()
+		req := s.CreateTestRequest(t, http.MethodGet, "/auth/admin/user", nil)
+		req.Header.Add("Authorization", "Bearer "+tokenString)
+		s.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code, tc.name)
+		res := struct {
+			ErrorMsg string `json:"error"`
+		}{}
+		json.NewDecoder(rec.Body).Decode(&res)
+		assert.Equal(t, tc.expectedErrorMsg, res.ErrorMsg, tc.name)
+	}
+}
+
+func TestServer_Login_Audience(t *testing.T) {
+	s := NewTestServer(t)
+	s.config.Audiences = []string{"reader"}
+
+	s.CreateTestUser(t, 1, false)
+
+	testCases := []struct {
+		name             string
+		audience         []string
+		expectedStatus   int
+		expectedErrorMsg string
+	}{
+		{
+			name:             "default audience",
+			audience:         nil,
+			expectedStatus:   http.StatusOK,
+			expectedErrorMsg: "",
+		},
+		{
+			name:             "service and registered audience",
+			audience:         []string{s.config.Audience, "reader"},
+			expectedStatus:   http.StatusOK,
+			expectedErrorMsg: "",
+		},
+		{
+			name:             "registered audience only",
+			audience:         []string{"reader"},
+			expectedStatus:   http.StatusUnauthorized,
+			expectedErrorMsg: "invalid token audience",
+		},
+	}
+
+	// The access tokens are checked against the audience of the service by
+	// its own routes
+	for _, tc := range testCases {
+		accessToken := s.LoginTestUserFor(t, "test0@test.test", "test_password0", tc.audience...)
+
+		rec := httptest.NewRecorder()
+		req := s.CreateTestRequest(t, http.MethodGet, "/auth/me/sessions", nil)
+		req.Header.Add("Authorization", "Bearer "+accessToken)
+		s.ServeHTTP(rec, req)
+
+		assert.Equal(t, tc.expectedStatus, rec.Code, tc.name)
+		res := struct {
+			ErrorMsg string `json:"error"`
+		}{}
+		json.NewDecoder(rec.Body).Decode(&res)
+		assert.Equal(t, tc.expectedErrorMsg, res.ErrorMsg, tc.name)
+	}
+
+	rec := httptest.NewRecorder()
+	req := s.CreateTestRequest(
+		t, http.MethodPost, "/auth/login",
+		map[string]interface{}{
+			"email":    "test0@test.test",
+			"password": "test_password0",
+			"audience": "billing",
+		},
+	)
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	res := struct {
+		ErrorMsg string `json:"error"`
+	}{}
+	json.NewDecoder(rec.Body).Decode(&res)
+	assert.Equal(t, "invalid audience: billing", res.ErrorMsg)
+
+	// The audience can be requested again when refreshing
+	cookie := s.LoginTestSession(t, "test0@test.test", "test_password0")
+	rec = httptest.NewRecorder()
+	req = s.CreateTestRequest(
+		t, http.MethodPost, "/auth/refresh-access-token",
+		map[string]interface{}{"audience": "reader"},
+	)
+	AddTestSessionCookie(req, cookie)
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	token := &model.AuthToken{}
+	json.NewDecoder(rec.Body).Decode(&token)
+	claims, err := s.parseAccessTokenFor(token.TokenString, "reader")
+	if assert.NoError(t, err) {
+		assert.Equal(t, model.Audience{"reader"}, claims.Audience)
+		assert.Equal(t, s.config.Issuer, claims.Issuer)
+	}
+}
+
+func TestServer_Login_Scope(t *testing.T) {
+	s := NewTestServer(t)
+
+	s.CreateTestUser(t, 1, true)
+	s.CreateTestUser(t, 1, false)
+
+	testCases := []struct {
+		name             string
+		email            string
+		password         string
+		scope            string
+		expectedStatus   int
+		expectedScope    string
+		expectedErrorMsg string
+	}{
+		{
+			name:           "every scope",
+			email:          "test1@test.test",
+			password:       "test_password1",
+			scope:          "",
+			expectedStatus: http.StatusOK,
+			expectedScope: "api-keys:read api-keys:write email openid profile profile:read " +
+				"profile:write sessions:read sessions:write",
+		},
+		{
+			name:           "user scope",
+			email:          "test1@test.test",
+			password:       "test_password1",
+			scope:          "profile:read",
+			expectedStatus: http.StatusOK,
+			expectedScope:  "profile:read",
+		},
+		{
+			name:           "permission of the user",
+			email:          "test0@test.test",
+			password:       "test_password0",
+			scope:          "users:read profile:read",
+			expectedStatus: http.StatusOK,
+			expectedScope:  "profile:read users:read",
+		},
+		{
+			name:             "permission the user lacks",
+			email:            "test1@test.test",
+			password:         "test_password1",
+			scope:            "profile:read users:read",
+			expectedStatus:   http.StatusBadRequest,
+			expectedErrorMsg: "invalid scope: users:read",
+		},
+	}
+
+	for _, tc := range testCases {
+		rec := httptest.NewRecorder()
+		req := s.CreateTestRequest(
+			t, http.MethodPost, "/auth/login",
+			map[string]interface{}{
+				"email":    tc.email,
+				"password": tc.password,
+				"scope":    tc.scope,
+			},
+		)
+		s.ServeHTTP(rec, req)
+		assert.Equal(t, tc.expectedStatus, rec.Code, tc.name)
+
+		res := struct {
+			model.AuthToken
+			ErrorMsg string `json:"error"`
+		}{}
+		json.NewDecoder(rec.Body).Decode(&res)
+		assert.Equal(t, tc.expectedErrorMsg, res.ErrorMsg, tc.name)
+		if tc.expectedErrorMsg != "" {
+			continue
+		}
+		claims, err := s.parseAccessToken(res.TokenString)
+		if assert.NoError(t, err, tc.name) {
+			assert.Equal(t, tc.expectedScope, claims.Scope, tc.name)
+		}
+	}
+}
+
+func TestServer_RequireScope(t *testing.T) {
+	s := NewTestServer(t)
+
+	s.CreateTestUser(t, 1, true)
+
+	login := func(scope string) string {
+		rec := httptest.NewRecorder()
+		req := s.CreateTestRequest(
+			t, http.MethodPost, "/auth/login",
+			map[string]interface{}{
+				"email":    "test0@test.test",
+				"password": "test_password0",
+				"scope":    scope,
+			},
+		)
+		s.ServeHTTP(rec, req)
+		token := &model.AuthToken{}
+		json.NewDecoder(rec.Body).Decode(&token)
+		return token.TokenString
+	}
+	profileToken := login("profile:read")
+	adminToken := login("users:read")
+
+	testCases := []struct {
+		name           string
+		method         string
+		url            string
+		accessToken    string
+		expectedStatus int
+	}{
+		{
+			name:           "user route in scope",
+			method:         http.MethodGet,
+			url:            "/auth/me",
+			accessToken:    profileToken,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "user route out of scope",
+			method:         http.MethodGet,
+			url:            "/auth/me/sessions",
+			accessToken:    profileToken,
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "admin route in scope",
+			method:         http.MethodGet,
+			url:            "/auth/admin/user",
+			accessToken:    adminToken,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "admin permission out of scope",
+			method:         http.MethodDelete,
+			url:            "/auth/admin/auth-token/unknown",
+			accessToken:    adminToken,
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "user route out of admin scope",
+			method:         http.MethodGet,
+			url:            "/auth/userinfo",
+			accessToken:    adminToken,
+			expectedStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tc := range testCases {
+		rec := httptest.NewRecorder()
+		req := s.CreateTestRequest(t, tc.method, tc.url, nil)
+		req.Header.Add("Authorization", "Bearer "+tc.accessToken)
+		s.ServeHTTP(rec, req)
+
+		assert.Equal(t, tc.expectedStatus, rec.Code, tc.name)
+		if tc.expectedStatus == http.StatusForbidden {
+			res := struct {
+				ErrorMsg string `json:"error"`
+			}{}
+			json.NewDecoder(rec.Body).Decode(&res)
+			assert.Equal(t, "insufficient scope", res.ErrorMsg, tc.name)
+			assert.Contains(
+				t,
+				rec.Header().Get("WWW-Authenticate"),
+				`error="insufficient_scope"`,
+				tc.name,
+			)
+		}
+	}
+}
+
+func TestServer_RefreshAccessToken_Scope(t *testing.T) {
+	s := NewTestServer(t)
+
+	s.CreateTestUser(t, 1, false)
+
+	rec := httptest.NewRecorder()
+	req := s.CreateTestRequest(
+		t, http.MethodPost, "/auth/login",
+		map[string]interface{}{
+			"email":    "test0@test.test",
+			"password": "test_password0",
+			"scope":    "profile:read sessions:read",
+		},
+	)
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var cookie *http.Cookie
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == "refresh_token" {
+			cookie = c
+		}
+	}
+
+	testCases := []struct {
+		name             string
+		scope            string
+		expectedStatus   int
+		expectedScope    string
+		expectedErrorMsg string
+	}{
+		{
+			name:           "scope of the login",
+			scope:          "",
+			expectedStatus: http.StatusOK,
+			expectedScope:  "profile:read sessions:read",
+		},
+		{
+			name:           "narrower scope",
+			scope:          "sessions:read",
+			expectedStatus: http.StatusOK,
+			expectedScope:  "sessions:read",
+		},
+		{
+			name:             "wider scope",
+			scope:            "profile:write",
+			expectedStatus:   http.StatusBadRequest,
+			expectedErrorMsg: "invalid scope: profile:write",
+		},
+		{
+			// The narrower scope of the previous refresh does not stick
+			name:           "scope of the login after narrowing",
+			scope:          "",
+			expectedStatus: http.StatusOK,
+			expectedScope:  "profile:read sessions:read",
+		},
+	}
+
+	for _, tc := range testCases {
+		rec := httptest.NewRecorder()
+		req := s.CreateTestRequest(
+			t, http.MethodPost, "/auth/refresh-access-token",
+			map[string]interface{}{"scope": tc.scope},
+		)
+		AddTestSessionCookie(req, cookie)
+		s.ServeHTTP(rec, req)
+		assert.Equal(t, tc.expectedStatus, rec.Code, tc.name)
+
+		res := struct {
+			model.AuthToken
+			ErrorMsg string `json:"error"`
+		}{}
+		json.NewDecoder(rec.Body).Decode(&res)
+		assert.Equal(t, tc.expectedErrorMsg, res.ErrorMsg, tc.name)
+		if tc.expectedErrorMsg != "" {
+			// The refresh token is not consumed by a refused request
+			continue
+		}
+		for _, c := range rec.Result().Cookies() {
+			if c.Name == "refresh_token" {
+				cookie = c
+			}
+		}
+		claims, err := s.parseAccessToken(res.TokenString)
+		if assert.NoError(t, err, tc.name) {
+			assert.Equal(t, tc.expectedScope, claims.Scope, tc.name)
+		}
+	}
+}
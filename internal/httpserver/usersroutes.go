@@ -2,13 +2,63 @@ package httpserver
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
 
+	"github.com/anoobz/dualread/auth/internal/model"
+	"github.com/anoobz/dualread/auth/internal/store"
 	"github.com/gorilla/mux"
 )
 
+// userResponse is the public view of a user, shown to the user themselves.
+// The password hash never leaves the service.
+type userResponse struct {
+	Id              int64     `json:"id"`
+	Email           string    `json:"email"`
+	EmailVerified   bool      `json:"email_verified"`
+	EmailSubscribed bool      `json:"email_subscribed"`
+	Created         time.Time `json:"created"`
+	LastLogin       time.Time `json:"last_login"`
+}
+
+func newUserResponse(u *model.User) *userResponse {
+	return &userResponse{
+		Id:              u.ID,
+		Email:           u.Email,
+		EmailVerified:   u.EmailVerified,
+		EmailSubscribed: u.EmailSubscribed,
+		Created:         u.Created,
+		LastLogin:       u.LastLogin,
+	}
+}
+
+// adminUserResponse is the view of a user shown to admins, along with the
+// state of the account.
+type adminUserResponse struct {
+	userResponse
+	Active     bool      `json:"active"`
+	LastAction time.Time `json:"last_action"`
+}
+
+func newAdminUserResponse(u *model.User) *adminUserResponse {
+	return &adminUserResponse{
+		userResponse: *newUserResponse(u),
+		Active:       u.Active,
+		LastAction:   u.LastAction,
+	}
+}
+
+func newAdminUserResponses(users []*model.User) []*adminUserResponse {
+	res := []*adminUserResponse{}
+	for _, u := range users {
+		res = append(res, newAdminUserResponse(u))
+	}
+
+	return res
+}
+
 func (s *server) getUser() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
@@ -22,7 +72,7 @@ func (s *server) getUser() http.HandlerFunc {
 			return
 		}
 
-		s.respond(w, r, http.StatusOK, u)
+		s.respond(w, r, http.StatusOK, newAdminUserResponse(u))
 	}
 }
 
@@ -39,7 +89,7 @@ func (s *server) getUserPage() http.HandlerFunc {
 			return
 		}
 
-		s.respond(w, r, http.StatusOK, users)
+		s.respond(w, r, http.StatusOK, newAdminUserResponses(users))
 	}
 }
 
@@ -51,15 +101,16 @@ func (s *server) getAllUsers() http.HandlerFunc {
 			return
 		}
 
-		s.respond(w, r, http.StatusOK, users)
+		s.respond(w, r, http.StatusOK, newAdminUserResponses(users))
 	}
 }
 
+// insertUser creates the user with a password chosen by the caller, hashed
+// like the passwords of registered users.
 func (s *server) insertUser() http.HandlerFunc {
 	type payload struct {
 		Email    string `json:"email"`
 		Password string `json:"password"`
-		Admin    bool   `json:"admin"`
 	}
 	return func(w http.ResponseWriter, r *http.Request) {
 		p := &payload{}
@@ -67,32 +118,102 @@ func (s *server) insertUser() http.HandlerFunc {
 			s.error(w, r, http.StatusInternalServerError, err)
 			return
 		}
+		if p.Password == "" {
+			s.error(w, r, http.StatusBadRequest, errors.New("a required field is empty"))
+			return
+		}
 
-		u, err := s.store.User().Insert(p.Email, p.Password, p.Admin, time.Now())
+		encryptedPassword, err := hashPassword(p.Password)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		u, err := s.store.User().Insert(p.Email, encryptedPassword, time.Now())
 		if err != nil {
 			s.error(w, r, http.StatusInternalServerError, err)
 			return
 		}
 
-		s.respond(w, r, http.StatusCreated, u)
+		s.respond(w, r, http.StatusCreated, newAdminUserResponse(u))
 	}
 }
 
+// updateUser changes the fields of the account set in the payload, other
+// fields are refused rather than ignored. A new password is hashed. The caller
+// must hold every permission of the user, so that taking over the account
+// cannot raise the privileges of the caller, and the last active admin cannot
+// be deactivated.
 func (s *server) updateUser() http.HandlerFunc {
+	type payload struct {
+		Email           *string `json:"email"`
+		Password        *string `json:"password"`
+		Active          *bool   `json:"active"`
+		EmailVerified   *bool   `json:"email_verified"`
+		EmailSubscribed *bool   `json:"email_subscribed"`
+	}
 	return func(w http.ResponseWriter, r *http.Request) {
 		id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
 		if err != nil {
 			s.error(w, r, http.StatusInternalServerError, err)
 			return
 		}
+		if _, err := s.store.User().GetById(id); err != nil {
+			s.error(w, r, http.StatusNotFound, errors.New("user not found"))
+			return
+		}
+		grants, err := s.userGrants(id)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		if err := checkGrantable(r, grants.Permissions); err != nil {
+			s.error(w, r, http.StatusForbidden, err)
+			return
+		}
+
+		p := payload{}
+		decoder := json.NewDecoder(r.Body)
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&p); err != nil {
+			s.error(w, r, http.StatusBadRequest, err)
+			return
+		}
 
 		clauses := map[string]interface{}{}
-		if err := json.NewDecoder(r.Body).Decode(&clauses); err != nil {
-			s.error(w, r, http.StatusInternalServerError, err)
+		if p.Email != nil {
+			clauses["email"] = *p.Email
+		}
+		if p.Password != nil {
+			if *p.Password == "" {
+				s.error(w, r, http.StatusBadRequest, errors.New("password is empty"))
+				return
+			}
+			encryptedPassword, err := hashPassword(*p.Password)
+			if err != nil {
+				s.error(w, r, http.StatusInternalServerError, err)
+				return
+			}
+			clauses["password"] = encryptedPassword
+		}
+		if p.Active != nil {
+			clauses["active"] = *p.Active
+		}
+		if p.EmailVerified != nil {
+			clauses["email_verified"] = *p.EmailVerified
+		}
+		if p.EmailSubscribed != nil {
+			clauses["email_subscribed"] = *p.EmailSubscribed
+		}
+		if len(clauses) == 0 {
+			s.respond(w, r, http.StatusOK, nil)
 			return
 		}
 
 		err = s.store.User().Update(id, clauses)
+		if errors.Is(err, store.ErrLastAdmin) {
+			s.error(w, r, http.StatusBadRequest, err)
+			return
+		}
 		if err != nil {
 			s.error(w, r, http.StatusInternalServerError, err)
 			return
@@ -101,6 +222,9 @@ func (s *server) updateUser() http.HandlerFunc {
 	}
 }
 
+// deleteUser is bounded by the permissions of the caller like updateUser, and
+// refuses to delete the last admin like setUserRoles refuses to take the admin
+// role from them.
 func (s *server) deleteUser() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
@@ -108,8 +232,25 @@ func (s *server) deleteUser() http.HandlerFunc {
 			s.error(w, r, http.StatusInternalServerError, err)
 			return
 		}
+		if _, err := s.store.User().GetById(id); err != nil {
+			s.error(w, r, http.StatusNotFound, errors.New("user not found"))
+			return
+		}
+		grants, err := s.userGrants(id)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		if err := checkGrantable(r, grants.Permissions); err != nil {
+			s.error(w, r, http.StatusForbidden, err)
+			return
+		}
 
 		err = s.store.User().Delete(id)
+		if errors.Is(err, store.ErrLastAdmin) {
+			s.error(w, r, http.StatusBadRequest, err)
+			return
+		}
 		if err != nil {
 			s.error(w, r, http.StatusInternalServerError, err)
 			return
@@ -85,9 +85,10 @@ func TestServer_GetUser(t *testing.T) {
 
 		assert.Equal(t, tc.expectedStatus, rec.Code, tc.name)
 		if tc.expectedErrorMsg == "" {
-			u := &model.User{}
+			assert.NotContains(t, rec.Body.String(), `"password"`, tc.name)
+			u := &adminUserResponse{}
 			json.NewDecoder(rec.Body).Decode(&u)
-			assert.Equal(t, user, u, tc.name)
+			assert.Equal(t, newAdminUserResponse(user), u, tc.name)
 		} else {
 			res := struct {
 				ErrorMsg string `json:"error"`
@@ -157,9 +158,12 @@ func TestServer_GetAllUser(t *testing.T) {
 
 		assert.Equal(t, tc.expectedStatus, rec.Code, tc.name)
 		if tc.expectedErrorMsg == "" {
-			u := []*model.User{}
+			assert.NotContains(t, rec.Body.String(), `"password"`, tc.name)
+			u := []*adminUserResponse{}
 			json.NewDecoder(rec.Body).Decode(&u)
-			assert.EqualValues(t, []*model.User{user, admin}, u, tc.name)
+			assert.EqualValues(
+				t, newAdminUserResponses([]*model.User{user, admin}), u, tc.name,
+			)
 		} else {
 			res := struct {
 				ErrorMsg string `json:"error"`
@@ -259,9 +263,10 @@ func TestServer_GetUserPage(t *testing.T) {
 
 		assert.Equal(t, tc.expectedStatus, rec.Code, tc.name)
 		if tc.expectedErrorMsg == "" {
-			u := []*model.User{}
+			assert.NotContains(t, rec.Body.String(), `"password"`, tc.name)
+			u := []*adminUserResponse{}
 			json.NewDecoder(rec.Body).Decode(&u)
-			assert.EqualValues(t, tc.expectedUsers, u, tc.name)
+			assert.EqualValues(t, newAdminUserResponses(tc.expectedUsers), u, tc.name)
 		} else {
 			res := struct {
 				ErrorMsg string `json:"error"`
@@ -294,7 +299,6 @@ func TestServer_InsertUser(t *testing.T) {
 			insertPayload: map[string]interface{}{
 				"email":    "inserted0@test.test",
 				"password": "inserted_password0",
-				"admin":    false,
 			},
 			expectedStatus:   http.StatusCreated,
 			expectedErrorMsg: "",
@@ -308,7 +312,6 @@ func TestServer_InsertUser(t *testing.T) {
 			insertPayload: map[string]interface{}{
 				"email":    "inserted1@test.test",
 				"password": "inserted_password1",
-				"admin":    false,
 			},
 			expectedStatus:   http.StatusUnauthorized,
 			expectedErrorMsg: "unauthorized",
@@ -322,7 +325,6 @@ func TestServer_InsertUser(t *testing.T) {
 			insertPayload: map[string]interface{}{
 				"email":    "inserted1@test.test",
 				"password": "inserted_password1",
-				"admin":    false,
 			},
 			expectedStatus:   http.StatusUnauthorized,
 			expectedErrorMsg: "token contains an invalid number of segments",
@@ -336,7 +338,6 @@ func TestServer_InsertUser(t *testing.T) {
 			insertPayload: map[string]interface{}{
 				"email":    "",
 				"password": "inserted_password1",
-				"admin":    false,
 			},
 			expectedStatus:   http.StatusInternalServerError,
 			expectedErrorMsg: "mail: no address",
@@ -350,7 +351,6 @@ func TestServer_InsertUser(t *testing.T) {
 			insertPayload: map[string]interface{}{
 				"email":    "invalid",
 				"password": "inserted_password1",
-				"admin":    false,
 			},
 			expectedStatus:   http.StatusInternalServerError,
 			expectedErrorMsg: "mail: missing '@' or angle-addr",
@@ -364,9 +364,8 @@ func TestServer_InsertUser(t *testing.T) {
 			insertPayload: map[string]interface{}{
 				"email":    "inserted1@test.test",
 				"password": "",
-				"admin":    false,
 			},
-			expectedStatus:   http.StatusInternalServerError,
+			expectedStatus:   http.StatusBadRequest,
 			expectedErrorMsg: "a required field is empty",
 		},
 	}
@@ -389,11 +388,10 @@ func TestServer_InsertUser(t *testing.T) {
 
 		assert.Equal(t, tc.expectedStatus, rec.Code, tc.name)
 		if tc.expectedErrorMsg == "" {
-			u := &model.User{}
+			assert.NotContains(t, rec.Body.String(), `"password"`, tc.name)
+			u := &adminUserResponse{}
 			json.NewDecoder(rec.Body).Decode(&u)
 			assert.Equal(t, tc.insertPayload["email"], u.Email, tc.name)
-			assert.Equal(t, tc.insertPayload["password"], u.Password, tc.name)
-			assert.Equal(t, tc.insertPayload["admin"], u.Admin, tc.name)
 		} else {
 			res := struct {
 				ErrorMsg string `json:"error"`
@@ -431,7 +429,6 @@ func TestServer_UpdateUser(t *testing.T) {
 				"active":           false,
 				"email_verified":   true,
 				"email_subscribed": false,
-				"admin":            true,
 			},
 			expectedStatus:   http.StatusOK,
 			expectedErrorMsg: "",
@@ -449,7 +446,6 @@ func TestServer_UpdateUser(t *testing.T) {
 				"active":           false,
 				"email_verified":   true,
 				"email_subscribed": false,
-				"admin":            true,
 			},
 			expectedStatus:   http.StatusUnauthorized,
 			expectedErrorMsg: "unauthorized",
@@ -467,7 +463,6 @@ func TestServer_UpdateUser(t *testing.T) {
 				"active":           false,
 				"email_verified":   true,
 				"email_subscribed": false,
-				"admin":            true,
 			},
 			expectedStatus:   http.StatusUnauthorized,
 			expectedErrorMsg: "token contains an invalid number of segments",
@@ -485,7 +480,6 @@ func TestServer_UpdateUser(t *testing.T) {
 				"active":           false,
 				"email_verified":   true,
 				"email_subscribed": false,
-				"admin":            true,
 			},
 			expectedStatus:   http.StatusInternalServerError,
 			expectedErrorMsg: "mail: no address",
@@ -503,7 +497,6 @@ func TestServer_UpdateUser(t *testing.T) {
 				"active":           false,
 				"email_verified":   true,
 				"email_subscribed": false,
-				"admin":            true,
 			},
 			expectedStatus:   http.StatusInternalServerError,
 			expectedErrorMsg: "mail: missing '@' or angle-addr",
@@ -521,11 +514,36 @@ func TestServer_UpdateUser(t *testing.T) {
 				"active":           false,
 				"email_verified":   true,
 				"email_subscribed": false,
-				"admin":            true,
 			},
-			expectedStatus:   http.StatusInternalServerError,
+			expectedStatus:   http.StatusBadRequest,
 			expectedErrorMsg: "password is empty",
 		},
+		{
+			name: "field not editable",
+			loginPayload: map[string]string{
+				"email":    "test2@test.test",
+				"password": "test_password2",
+			},
+			updateUSerId: user[1].ID,
+			clauses: map[string]interface{}{
+				"created": "2000-01-01T00:00:00Z",
+			},
+			expectedStatus:   http.StatusBadRequest,
+			expectedErrorMsg: `json: unknown field "created"`,
+		},
+		{
+			name: "user not found",
+			loginPayload: map[string]string{
+				"email":    "test2@test.test",
+				"password": "test_password2",
+			},
+			updateUSerId: 9999,
+			clauses: map[string]interface{}{
+				"email_verified": true,
+			},
+			expectedStatus:   http.StatusNotFound,
+			expectedErrorMsg: "user not found",
+		},
 	}
 
 	for _, tc := range testCases {
@@ -553,6 +571,17 @@ func TestServer_UpdateUser(t *testing.T) {
 			assert.Equal(t, tc.expectedErrorMsg, res.ErrorMsg, tc.name)
 		}
 	}
+
+	// The new password is stored hashed, the user logs in with it once
+	// reactivated
+	updatedUser, err := s.store.User().GetById(user[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NotEqual(t, "newPassword", updatedUser.Password)
+	assert.False(t, updatedUser.Active)
+	s.SetTestUserActive(t, user[0].ID, true)
+	assert.NotEmpty(t, s.LoginTestUser(t, "new@test.test", "newPassword"))
 }
 
 func TestServer_DeleteUser(t *testing.T) {
@@ -629,3 +658,160 @@ func TestServer_DeleteUser(t *testing.T) {
 		}
 	}
 }
+
+func TestServer_DeleteUser_LastAdmin(t *testing.T) {
+	s := NewTestServer(t)
+
+	admin := s.CreateTestUser(t, 1, true)[0]
+	accessToken := s.LoginTestUser(t, "test0@test.test", "test_password0")
+
+	rec := httptest.NewRecorder()
+	req := s.CreateTestRequest(
+		t, http.MethodDelete,
+		fmt.Sprintf("/auth/admin/user/%d", admin.ID),
+		nil,
+	)
+	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", accessToken))
+	s.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	res := struct {
+		ErrorMsg string `json:"error"`
+	}{}
+	json.NewDecoder(rec.Body).Decode(&res)
+	assert.Equal(t, store.ErrLastAdmin.Error(), res.ErrorMsg)
+	_, err := s.store.User().GetById(admin.ID)
+	assert.NoError(t, err)
+}
+
+func TestServer_UpdateUser_LastAdmin(t *testing.T) {
+	s := NewTestServer(t)
+
+	admins := s.CreateTestUser(t, 2, true)
+	accessToken := s.LoginTestUser(t, "test0@test.test", "test_password0")
+	// An inactive admin does not count
+	s.SetTestUserActive(t, admins[1].ID, false)
+
+	testCases := []struct {
+		name    string
+		url     string
+		payload map[string]interface{}
+	}{
+		{
+			name:    "deactivate",
+			url:     fmt.Sprintf("/auth/admin/user/%d", admins[0].ID),
+			payload: map[string]interface{}{"active": false},
+		},
+		{
+			name:    "remove the admin role",
+			url:     fmt.Sprintf("/auth/admin/user/%d/role", admins[0].ID),
+			payload: map[string]interface{}{"roles": []string{}},
+		},
+	}
+
+	for _, tc := range testCases {
+		rec := httptest.NewRecorder()
+		req := s.CreateTestRequest(t, http.MethodPost, tc.url, tc.payload)
+		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", accessToken))
+		s.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code, tc.name)
+		res := struct {
+			ErrorMsg string `json:"error"`
+		}{}
+		json.NewDecoder(rec.Body).Decode(&res)
+		assert.Equal(t, store.ErrLastAdmin.Error(), res.ErrorMsg, tc.name)
+	}
+
+	admin, err := s.store.User().GetById(admins[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, admin.Active)
+}
+
+func TestServer_UserRoutes_BoundedByCaller(t *testing.T) {
+	s := NewTestServer(t)
+
+	admins := s.CreateTestUser(t, 1, true)
+	users := s.CreateTestUser(t, 2, false)
+	managerRole, err := model.NewRole(
+		"user_manager",
+		[]string{
+			model.PermissionReadUsers,
+			model.PermissionWriteUsers,
+			model.PermissionDeleteUsers,
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.store.Role().Insert(managerRole); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.store.Role().SetUserRoles(users[0].ID, []string{managerRole.Name}); err != nil {
+		t.Fatal(err)
+	}
+	managerToken := s.LoginTestUser(t, "test1@test.test", "test_password1")
+
+	testCases := []struct {
+		name             string
+		method           string
+		url              string
+		payload          map[string]interface{}
+		expectedStatus   int
+		expectedErrorMsg string
+	}{
+		{
+			name:   "update the password of an admin",
+			method: http.MethodPost,
+			url:    fmt.Sprintf("/auth/admin/user/%d", admins[0].ID),
+			payload: map[string]interface{}{
+				"password": "taken_over",
+			},
+			expectedStatus:   http.StatusForbidden,
+			expectedErrorMsg: "cannot grant permission: clients:read",
+		},
+		{
+			name:             "delete an admin",
+			method:           http.MethodDelete,
+			url:              fmt.Sprintf("/auth/admin/user/%d", admins[0].ID),
+			expectedStatus:   http.StatusForbidden,
+			expectedErrorMsg: "cannot grant permission: clients:read",
+		},
+		{
+			name:   "update a user within the caller permissions",
+			method: http.MethodPost,
+			url:    fmt.Sprintf("/auth/admin/user/%d", users[1].ID),
+			payload: map[string]interface{}{
+				"email_verified": true,
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "delete a user within the caller permissions",
+			method:         http.MethodDelete,
+			url:            fmt.Sprintf("/auth/admin/user/%d", users[1].ID),
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	for _, tc := range testCases {
+		rec := httptest.NewRecorder()
+		req := s.CreateTestRequest(t, tc.method, tc.url, tc.payload)
+		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", managerToken))
+		s.ServeHTTP(rec, req)
+
+		assert.Equal(t, tc.expectedStatus, rec.Code, tc.name)
+		if tc.expectedErrorMsg != "" {
+			res := struct {
+				ErrorMsg string `json:"error"`
+			}{}
+			json.NewDecoder(rec.Body).Decode(&res)
+			assert.Equal(t, tc.expectedErrorMsg, res.ErrorMsg, tc.name)
+		}
+	}
+
+	// The admin can still log in with their own password
+	assert.NotEmpty(t, s.LoginTestUser(t, "test0@test.test", "test_password0"))
+}
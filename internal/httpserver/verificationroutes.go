@@ -0,0 +1,165 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/anoobz/dualread/auth/internal/mailer"
+	"github.com/anoobz/dualread/auth/internal/model"
+)
+
+var errInvalidVerificationToken = errors.New("invalid verification token")
+
+// sendVerificationEmail mails the user a link to verify their address. The
+// token is signed with the refresh key ring, which stays within the service.
+func (s *server) sendVerificationEmail(user *model.User) error {
+	if err := s.store.EmailVerification().DeleteByUser(user.ID); err != nil {
+		return err
+	}
+	verification, token, err := model.NewEmailVerificationToken(
+		user,
+		s.refreshKeys.Active(),
+		model.TokenParams{
+			Issuer:   s.config.Issuer,
+			Audience: model.Audience{s.config.Audience},
+			Lifetime: s.config.EmailVerificationLifetime,
+		},
+	)
+	if err != nil {
+		return err
+	}
+	if err := s.store.EmailVerification().Insert(verification); err != nil {
+		return err
+	}
+
+	link, err := tokenLink(s.config.EmailVerificationUrl, token)
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(&mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf(
+			"Open the link below to verify your email address:\n\n%s\n\n"+
+				"The link expires in %s.",
+			link,
+			s.config.EmailVerificationLifetime,
+		),
+	})
+}
+
+// queueVerificationEmail sends the verification email through the mail
+// queue, so that at most one per address is sent every mailThrottle. It
+// reports whether the email was queued, failures to send are only logged.
+func (s *server) queueVerificationEmail(user *model.User) bool {
+	queued := s.mails.enqueue(mailVerification, user.Email, time.Now(), func() {
+		if err := s.sendVerificationEmail(user); err != nil {
+			s.logger.Printf("sending the verification email of user %d: %v", user.ID, err)
+		}
+	})
+	if !queued {
+		s.logger.Printf("dropped the verification email of user %d", user.ID)
+	}
+
+	return queued
+}
+
+// tokenLink adds the token to the query of the page of the web app at pageUrl.
+func tokenLink(pageUrl string, token string) (string, error) {
+	link, err := url.Parse(pageUrl)
+	if err != nil {
+		return "", err
+	}
+	query := link.Query()
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+
+	return link.String(), nil
+}
+
+// verifyEmail marks the address of the user as verified. Tokens are bound to
+// the address and their record is consumed, which makes them unusable once
+// used or once the address changes.
+func (s *server) verifyEmail() http.HandlerFunc {
+	type payload struct {
+		Token string `json:"token"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		payload := payload{}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			s.error(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		claims, err := model.ParseEmailVerificationToken(
+			payload.Token,
+			s.refreshKeys.Keyfunc,
+			s.config.Issuer,
+			s.config.Audience,
+		)
+		if err != nil {
+			s.error(w, r, http.StatusBadRequest, errInvalidVerificationToken)
+			return
+		}
+		userId, err := claims.UserId()
+		if err != nil {
+			s.error(w, r, http.StatusBadRequest, errInvalidVerificationToken)
+			return
+		}
+		verification, err := s.store.EmailVerification().Consume(claims.Id)
+		if err != nil || verification.UserId != userId {
+			s.error(w, r, http.StatusBadRequest, errInvalidVerificationToken)
+			return
+		}
+		user, err := s.store.User().GetById(userId)
+		if err != nil || !claims.Verifies(user) {
+			s.error(w, r, http.StatusBadRequest, errInvalidVerificationToken)
+			return
+		}
+
+		err = s.store.User().Update(user.ID, map[string]interface{}{"email_verified": true})
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		s.respond(w, r, http.StatusOK, nil)
+	}
+}
+
+// resendVerificationEmail queues another verification email, the requests
+// above the throttle of the mail queue are refused.
+func (s *server) resendVerificationEmail() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId, err := getAccessClaims(r).UserId()
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		user, err := s.store.User().GetById(userId)
+		if err != nil {
+			s.error(w, r, http.StatusNotFound, errors.New("user not found"))
+			return
+		}
+		if user.EmailVerified {
+			s.error(w, r, http.StatusBadRequest, errors.New("email already verified"))
+			return
+		}
+
+		if !s.queueVerificationEmail(user) {
+			s.error(
+				w, r,
+				http.StatusTooManyRequests,
+				errors.New("verification email already requested, try again later"),
+			)
+			return
+		}
+
+		s.respond(w, r, http.StatusAccepted, nil)
+	}
+}
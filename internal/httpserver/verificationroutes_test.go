@@ -0,0 +1,219 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var mailedLinkRegexp = regexp.MustCompile(`https?://\S+`)
+
+// getTestMailedToken returns the token of the link in the last message of the
+// test outbox.
+func getTestMailedToken(t *testing.T, s *server) string {
+	t.Helper()
+
+	messages := s.mailer.(*testOutbox).Messages()
+	if len(messages) == 0 {
+		t.Fatal("no message sent")
+	}
+	link, err := url.Parse(mailedLinkRegexp.FindString(messages[len(messages)-1].Body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token := link.Query().Get("token")
+	if token == "" {
+		t.Fatal("no token in the message")
+	}
+
+	return token
+}
+
+func requestTestVerifyEmail(t *testing.T, s *server, token string) (int, string) {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	req := s.CreateTestRequest(
+		t, http.MethodPost, "/auth/verify-email",
+		map[string]interface{}{"token": token},
+	)
+	s.ServeHTTP(rec, req)
+
+	res := struct {
+		ErrorMsg string `json:"error"`
+	}{}
+	json.NewDecoder(rec.Body).Decode(&res)
+	return rec.Code, res.ErrorMsg
+}
+
+func TestServer_VerifyEmail(t *testing.T) {
+	s := NewTestServer(t)
+	outbox := newTestOutbox(s)
+
+	rec := httptest.NewRecorder()
+	req := s.CreateTestRequest(
+		t, http.MethodPost, "/auth/register",
+		map[string]interface{}{"email": "test@test.test", "password": "test_password"},
+	)
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	msg := outbox.waitSent(t)
+	assert.Equal(t, "test@test.test", msg.To)
+	assert.Contains(t, msg.Body, "https://dualread.test/verify-email?token=")
+	token := getTestMailedToken(t, s)
+	refreshToken := s.LoginTestSession(t, "test@test.test", "test_password").Value
+
+	testCases := []struct {
+		name             string
+		token            string
+		expectedStatus   int
+		expectedErrorMsg string
+	}{
+		{
+			name:             "invalid token",
+			token:            "invalid",
+			expectedStatus:   http.StatusBadRequest,
+			expectedErrorMsg: "invalid verification token",
+		},
+		{
+			name:             "refresh token",
+			token:            refreshToken,
+			expectedStatus:   http.StatusBadRequest,
+			expectedErrorMsg: "invalid verification token",
+		},
+		{
+			name:           "verification token",
+			token:          token,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:             "used verification token",
+			token:            token,
+			expectedStatus:   http.StatusBadRequest,
+			expectedErrorMsg: "invalid verification token",
+		},
+	}
+
+	for _, tc := range testCases {
+		status, errorMsg := requestTestVerifyEmail(t, s, tc.token)
+		assert.Equal(t, tc.expectedStatus, status, tc.name)
+		assert.Equal(t, tc.expectedErrorMsg, errorMsg, tc.name)
+	}
+
+	user, err := s.store.User().GetByEmail("test@test.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, user.EmailVerified)
+
+	// The token stays spent once the address is unverified again
+	err = s.store.User().Update(user.ID, map[string]interface{}{"email_verified": false})
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, errorMsg := requestTestVerifyEmail(t, s, token)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "invalid verification token", errorMsg)
+}
+
+func TestServer_VerifyEmail_LastTokenOnly(t *testing.T) {
+	s := NewTestServer(t)
+	newTestOutbox(s)
+
+	user := s.CreateTestUser(t, 1, false)[0]
+	if err := s.sendVerificationEmail(user); err != nil {
+		t.Fatal(err)
+	}
+	firstToken := getTestMailedToken(t, s)
+	if err := s.sendVerificationEmail(user); err != nil {
+		t.Fatal(err)
+	}
+
+	status, errorMsg := requestTestVerifyEmail(t, s, firstToken)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "invalid verification token", errorMsg)
+	status, _ = requestTestVerifyEmail(t, s, getTestMailedToken(t, s))
+	assert.Equal(t, http.StatusOK, status)
+}
+
+func TestServer_VerifyEmail_ChangedEmail(t *testing.T) {
+	s := NewTestServer(t)
+	newTestOutbox(s)
+
+	user := s.CreateTestUser(t, 1, false)[0]
+	if err := s.sendVerificationEmail(user); err != nil {
+		t.Fatal(err)
+	}
+	token := getTestMailedToken(t, s)
+	if err := s.store.User().Update(
+		user.ID,
+		map[string]interface{}{"email": "changed@test.test"},
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	status, errorMsg := requestTestVerifyEmail(t, s, token)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "invalid verification token", errorMsg)
+}
+
+func TestServer_ResendVerificationEmail(t *testing.T) {
+	s := NewTestServer(t)
+	outbox := newTestOutbox(s)
+
+	s.CreateTestUser(t, 1, false)
+	accessToken := s.LoginTestUser(t, "test0@test.test", "test_password0")
+
+	resend := func() (int, string) {
+		rec := httptest.NewRecorder()
+		req := s.CreateTestRequest(t, http.MethodPost, "/auth/me/verification-email", nil)
+		req.Header.Add("Authorization", "Bearer "+accessToken)
+		s.ServeHTTP(rec, req)
+
+		res := struct {
+			ErrorMsg string `json:"error"`
+		}{}
+		json.NewDecoder(rec.Body).Decode(&res)
+		return rec.Code, res.ErrorMsg
+	}
+
+	status, errorMsg := resend()
+	assert.Equal(t, http.StatusAccepted, status)
+	assert.Empty(t, errorMsg)
+	outbox.waitSent(t)
+	token := getTestMailedToken(t, s)
+
+	// A second request within the throttle sends nothing
+	status, errorMsg = resend()
+	assert.Equal(t, http.StatusTooManyRequests, status)
+	assert.Equal(t, "verification email already requested, try again later", errorMsg)
+
+	// The password reset emails are throttled on their own
+	rec := httptest.NewRecorder()
+	req := s.CreateTestRequest(
+		t, http.MethodPost, "/auth/password/forgot",
+		map[string]interface{}{"email": "test0@test.test"},
+	)
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.Contains(t, outbox.waitSent(t).Body, "/reset-password?token=")
+
+	status, _ = requestTestVerifyEmail(t, s, token)
+	assert.Equal(t, http.StatusOK, status)
+
+	s.mails.throttle = 0
+	status, errorMsg = resend()
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "email already verified", errorMsg)
+
+	assert.NoError(t, s.Shutdown(context.Background()))
+	assert.Len(t, outbox.Messages(), 2)
+}
@@ -0,0 +1,52 @@
+package mailer
+
+import (
+	"errors"
+	"fmt"
+	"net/smtp"
+	"os"
+)
+
+// Message is a plain text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends the emails of the service, the SMTP implementation is used in
+// production and the outbox in development and tests.
+type Mailer interface {
+	Send(msg *Message) error
+}
+
+// Load returns the mailer selected by MAIL_DRIVER, smtp or outbox. The outbox
+// writes the messages to MAIL_OUTBOX_DIR when it is set. There is no default,
+// so that a deployment missing the setting does not silently stop sending the
+// emails.
+func Load() (Mailer, error) {
+	switch driver := os.Getenv("MAIL_DRIVER"); driver {
+	case "":
+		return nil, errors.New("MAIL_DRIVER is required, smtp or outbox")
+	case "outbox":
+		return NewOutbox(os.Getenv("MAIL_OUTBOX_DIR")), nil
+	case "smtp":
+		from := os.Getenv("MAIL_FROM")
+		host := os.Getenv("SMTP_HOST")
+		if from == "" || host == "" {
+			return nil, errors.New("MAIL_FROM and SMTP_HOST are required by the smtp driver")
+		}
+		port := os.Getenv("SMTP_PORT")
+		if port == "" {
+			port = "587"
+		}
+
+		var auth smtp.Auth
+		if username := os.Getenv("SMTP_USERNAME"); username != "" {
+			auth = smtp.PlainAuth("", username, os.Getenv("SMTP_PASSWORD"), host)
+		}
+		return NewSmtpMailer(host+":"+port, auth, from), nil
+	default:
+		return nil, fmt.Errorf("invalid MAIL_DRIVER: %q", driver)
+	}
+}
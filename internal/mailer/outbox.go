@@ -0,0 +1,52 @@
+package mailer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// outboxCapacity is the number of messages kept by an outbox, the older ones
+// are dropped.
+const outboxCapacity = 100
+
+// Outbox keeps the last messages instead of sending them. Messages are also
+// written to dir, one file each, when it is not empty.
+type Outbox struct {
+	dir      string
+	mu       sync.Mutex
+	messages []*Message
+}
+
+func NewOutbox(dir string) *Outbox {
+	return &Outbox{dir: dir}
+}
+
+func (o *Outbox) Send(msg *Message) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.dir != "" {
+		name := fmt.Sprintf("%d-%d.eml", time.Now().UnixNano(), len(o.messages))
+		content := fmt.Sprintf("To: %s\nSubject: %s\n\n%s\n", msg.To, msg.Subject, msg.Body)
+		if err := os.WriteFile(filepath.Join(o.dir, name), []byte(content), 0600); err != nil {
+			return err
+		}
+	}
+	o.messages = append(o.messages, msg)
+	if len(o.messages) > outboxCapacity {
+		o.messages = append([]*Message{}, o.messages[len(o.messages)-outboxCapacity:]...)
+	}
+
+	return nil
+}
+
+// Messages returns the messages kept so far, the oldest first.
+func (o *Outbox) Messages() []*Message {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return append([]*Message{}, o.messages...)
+}
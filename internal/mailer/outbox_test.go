@@ -0,0 +1,87 @@
+package mailer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutbox_Send(t *testing.T) {
+	dir := t.TempDir()
+	outbox := NewOutbox(dir)
+
+	msg := &Message{To: "test@test.test", Subject: "Subject", Body: "Body"}
+	assert.NoError(t, outbox.Send(msg))
+	assert.Equal(t, []*Message{msg}, outbox.Messages())
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assert.Len(t, files, 1) {
+		content, err := os.ReadFile(files[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.True(t, strings.HasPrefix(string(content), "To: test@test.test\n"))
+		assert.Contains(t, string(content), "Body")
+	}
+}
+
+func TestOutbox_Capacity(t *testing.T) {
+	outbox := NewOutbox("")
+
+	for i := 0; i < outboxCapacity+1; i++ {
+		assert.NoError(t, outbox.Send(&Message{To: fmt.Sprintf("test%d@test.test", i)}))
+	}
+
+	messages := outbox.Messages()
+	if assert.Len(t, messages, outboxCapacity) {
+		assert.Equal(t, "test1@test.test", messages[0].To)
+		assert.Equal(t, fmt.Sprintf("test%d@test.test", outboxCapacity), messages[outboxCapacity-1].To)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	testCases := []struct {
+		name        string
+		env         map[string]string
+		expectedErr bool
+	}{
+		{name: "no driver", env: map[string]string{}, expectedErr: true},
+		{name: "outbox", env: map[string]string{"MAIL_DRIVER": "outbox"}},
+		{
+			name: "smtp",
+			env: map[string]string{
+				"MAIL_DRIVER": "smtp",
+				"MAIL_FROM":   "auth@dualread.test",
+				"SMTP_HOST":   "smtp.dualread.test",
+			},
+		},
+		{
+			name:        "smtp without host",
+			env:         map[string]string{"MAIL_DRIVER": "smtp", "MAIL_FROM": "auth@dualread.test"},
+			expectedErr: true,
+		},
+		{name: "unknown driver", env: map[string]string{"MAIL_DRIVER": "pigeon"}, expectedErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, name := range []string{"MAIL_DRIVER", "MAIL_FROM", "SMTP_HOST"} {
+				t.Setenv(name, tc.env[name])
+			}
+			m, err := Load()
+			if tc.expectedErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.NotNil(t, m)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package mailer
+
+import (
+	"fmt"
+	"net/smtp"
+	"strings"
+)
+
+type SmtpMailer struct {
+	addr string
+	auth smtp.Auth
+	from string
+}
+
+// NewSmtpMailer sends the messages through the server at addr, auth is nil for
+// servers which do not require authentication.
+func NewSmtpMailer(addr string, auth smtp.Auth, from string) *SmtpMailer {
+	return &SmtpMailer{
+		addr: addr,
+		auth: auth,
+		from: from,
+	}
+}
+
+func (m *SmtpMailer) Send(msg *Message) error {
+	return smtp.SendMail(m.addr, m.auth, m.from, []string{msg.To}, m.format(msg))
+}
+
+func (m *SmtpMailer) format(msg *Message) []byte {
+	// Header values are taken from the users, line breaks would let them add
+	// their own headers
+	header := strings.NewReplacer("\r", "", "\n", "")
+	return []byte(fmt.Sprintf(
+		"From: %s\r\nTo: %s\r\nSubject: %s\r\n"+
+			"MIME-Version: 1.0\r\nContent-Type: text/plain; charset=\"utf-8\"\r\n\r\n%s",
+		header.Replace(m.from),
+		header.Replace(msg.To),
+		header.Replace(msg.Subject),
+		msg.Body,
+	))
+}
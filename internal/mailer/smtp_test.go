@@ -0,0 +1,20 @@
+package mailer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSmtpMailer_Format(t *testing.T) {
+	m := NewSmtpMailer("localhost:25", nil, "auth@dualread.test")
+
+	content := string(m.format(&Message{
+		To:      "test@test.test",
+		Subject: "Subject\r\nBcc: other@test.test",
+		Body:    "Body",
+	}))
+	assert.Contains(t, content, "Subject: SubjectBcc: other@test.test\r\n")
+	assert.NotContains(t, content, "\r\nBcc:")
+	assert.Contains(t, content, "\r\n\r\nBody")
+}
@@ -0,0 +1,117 @@
+package model
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/twinj/uuid"
+)
+
+// ApiKeyUse is the token use of the claims built for a request authenticated
+// with an API key.
+const ApiKeyUse = "api_key"
+
+const apiKeyTag = "drk"
+
+// ApiKey is a long lived credential of a user for scripts and integrations.
+// The key is made of a public prefix, used to look the key up, and of a
+// secret. Only the hash of the whole key is persisted, the key itself is only
+// returned when it is issued.
+type ApiKey struct {
+	Id      string `json:"id"`
+	Key     string `json:"key,omitempty"`
+	Prefix  string `json:"prefix"`
+	KeyHash string `json:"-"`
+	UserId  int64  `json:"user_id"`
+	Name    string `json:"name"`
+	// Scope is the space separated list of the scopes granted to the key.
+	Scope   string `json:"scope"`
+	Created int64  `json:"created"`
+	// Expires is zero for keys which do not expire.
+	Expires  int64 `json:"exp"`
+	LastUsed int64 `json:"last_used"`
+}
+
+// NewApiKey issues a key for the user, a zero lifetime issues a key which
+// does not expire.
+func NewApiKey(
+	userId int64,
+	name string,
+	scope string,
+	lifetime time.Duration,
+	now time.Time,
+) (*ApiKey, error) {
+	if name == "" {
+		return nil, errors.New("a required field is empty")
+	}
+	if lifetime < 0 {
+		return nil, errors.New("invalid lifetime")
+	}
+
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	prefix := hex.EncodeToString(b)
+	secret, err := GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	key := apiKeyTag + "_" + prefix + "_" + secret
+
+	k := &ApiKey{
+		Id:      uuid.NewV4().String(),
+		Key:     key,
+		Prefix:  prefix,
+		KeyHash: HashSecret(key),
+		UserId:  userId,
+		Name:    name,
+		Scope:   scope,
+		Created: now.Unix(),
+	}
+	if lifetime > 0 {
+		k.Expires = now.Add(lifetime).Unix()
+	}
+
+	return k, nil
+}
+
+// ApiKeyPrefix returns the prefix of a key presented by a client.
+func ApiKeyPrefix(key string) (string, error) {
+	parts := strings.SplitN(key, "_", 3)
+	if len(parts) != 3 || parts[0] != apiKeyTag || parts[1] == "" || parts[2] == "" {
+		return "", errors.New("invalid api key")
+	}
+
+	return parts[1], nil
+}
+
+func (k *ApiKey) Verify(key string) bool {
+	return verifySecret(k.KeyHash, key)
+}
+
+func (k *ApiKey) Expired(now time.Time) bool {
+	return k.Expires != 0 && now.Unix() >= k.Expires
+}
+
+// Claims describes a request authenticated with the key as if it carried an
+// access token of the user, holding the scope of the key and the current
+// grants of the user.
+func (k *ApiKey) Claims(grants Grants) *AccessClaims {
+	return &AccessClaims{
+		RegisteredClaims: RegisteredClaims{
+			Subject:   strconv.FormatInt(k.UserId, 10),
+			Id:        k.Id,
+			IssuedAt:  k.Created,
+			ExpiresAt: k.Expires,
+			TokenUse:  ApiKeyUse,
+		},
+		Roles:       grants.Roles,
+		Permissions: grants.Permissions,
+		Scope:       k.Scope,
+	}
+}
@@ -0,0 +1,86 @@
+package model
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModel_NewApiKey(t *testing.T) {
+	now := time.Date(2000, time.January, 1, 0, 0, 0, 0, time.Local)
+
+	k, err := NewApiKey(42, "backup script", "profile:read", time.Hour, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NotContains(t, k.KeyHash, k.Key)
+	assert.True(t, k.Verify(k.Key))
+	assert.False(t, k.Verify(k.Key+"x"))
+	assert.Equal(t, now.Add(time.Hour).Unix(), k.Expires)
+	assert.False(t, k.Expired(now))
+	assert.True(t, k.Expired(now.Add(time.Hour)))
+
+	prefix, err := ApiKeyPrefix(k.Key)
+	assert.NoError(t, err)
+	assert.Equal(t, k.Prefix, prefix)
+
+	other, err := NewApiKey(42, "backup script", "", 0, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NotEqual(t, k.Prefix, other.Prefix)
+	assert.False(t, other.Verify(k.Key))
+	assert.Zero(t, other.Expires)
+	assert.False(t, other.Expired(now.Add(100*365*24*time.Hour)))
+
+	_, err = NewApiKey(42, "", "", 0, now)
+	assert.EqualError(t, err, "a required field is empty")
+	_, err = NewApiKey(42, "backup script", "", -time.Hour, now)
+	assert.EqualError(t, err, "invalid lifetime")
+}
+
+func TestModel_ApiKeyPrefix(t *testing.T) {
+	testCases := []struct {
+		key            string
+		expectedPrefix string
+	}{
+		{key: "drk_0a1b2c3d4e5f_secret_with_underscore", expectedPrefix: "0a1b2c3d4e5f"},
+		{key: "drk_0a1b2c3d4e5f_"},
+		{key: "drk__secret"},
+		{key: "abc_0a1b2c3d4e5f_secret"},
+		{key: "eyJhbGciOiJIUzI1NiJ9.e30.sig"},
+	}
+
+	for _, tc := range testCases {
+		prefix, err := ApiKeyPrefix(tc.key)
+		if tc.expectedPrefix == "" {
+			assert.EqualError(t, err, "invalid api key", tc.key)
+		} else {
+			assert.NoError(t, err, tc.key)
+			assert.Equal(t, tc.expectedPrefix, prefix, tc.key)
+		}
+	}
+}
+
+func TestModel_ApiKey_Claims(t *testing.T) {
+	k, err := NewApiKey(42, "backup script", "users:read", 0, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	role, err := NewRole("support", []string{PermissionReadUsers, PermissionWriteUsers})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims := k.Claims(NewGrants([]*Role{role}))
+	userId, err := claims.UserId()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(42), userId)
+	assert.Equal(t, strconv.FormatInt(42, 10), claims.Subject)
+	assert.Equal(t, ApiKeyUse, claims.TokenUse)
+	assert.True(t, claims.HasPermission(PermissionWriteUsers))
+	assert.True(t, claims.HasScope(PermissionReadUsers))
+	assert.False(t, claims.HasScope(PermissionWriteUsers))
+}
@@ -0,0 +1,105 @@
+package model
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"time"
+)
+
+const CodeChallengeMethodS256 = "S256"
+
+// AuthCode is an authorization code issued to a client by the authorize
+// endpoint and exchanged once for tokens. Like refresh tokens, only a keyed
+// hash of the code is persisted.
+//
+// CodeChallenge is the S256 PKCE challenge sent by the client, the verifier it
+// was derived from must be presented with the code. Scope is the space
+// separated scope the user authorized the client for. Nonce is the OpenID
+// Connect nonce of the authorize request, echoed in the id token.
+type AuthCode struct {
+	Code          string
+	CodeHash      string
+	ClientId      string
+	UserId        int64
+	RedirectUri   string
+	CodeChallenge string
+	Audience      Audience
+	Scope         string
+	Nonce         string
+	Expires       int64
+}
+
+func NewAuthCode(
+	clientId string,
+	userId int64,
+	redirectUri string,
+	codeChallenge string,
+	audience Audience,
+	scope string,
+	lifetime time.Duration,
+) (*AuthCode, error) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	return &AuthCode{
+		Code:          secret,
+		ClientId:      clientId,
+		UserId:        userId,
+		RedirectUri:   redirectUri,
+		CodeChallenge: codeChallenge,
+		Audience:      audience,
+		Scope:         scope,
+		Expires:       time.Now().Add(lifetime).Unix(),
+	}, nil
+}
+
+// Hashed returns the copy of the code to persist, holding the hash of the code
+// in place of the code itself.
+func (c *AuthCode) Hashed(key []byte) *AuthCode {
+	hashed := *c
+	hashed.CodeHash = HashToken(key, c.Code)
+	hashed.Code = ""
+	return &hashed
+}
+
+func (c *AuthCode) Expired(now time.Time) bool {
+	return c.Expires <= now.Unix()
+}
+
+// VerifyCodeVerifier checks the verifier against the challenge of the code as
+// described in RFC 7636.
+func (c *AuthCode) VerifyCodeVerifier(verifier string) bool {
+	if !validCodeVerifier(verifier) {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	challenge := base64.RawURLEncoding.EncodeToString(sum[:])
+
+	return subtle.ConstantTimeCompare([]byte(challenge), []byte(c.CodeChallenge)) == 1
+}
+
+// ValidCodeChallenge reports whether the challenge is the encoding of a
+// SHA-256 hash.
+func ValidCodeChallenge(challenge string) bool {
+	b, err := base64.RawURLEncoding.DecodeString(challenge)
+	return err == nil && len(b) == sha256.Size
+}
+
+func validCodeVerifier(verifier string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	for _, c := range verifier {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '.', c == '_', c == '~':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,69 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModel_AuthCode_VerifyCodeVerifier(t *testing.T) {
+	verifier := strings.Repeat("a", 43)
+	sum := sha256.Sum256([]byte(verifier))
+	challenge := base64.RawURLEncoding.EncodeToString(sum[:])
+	assert.True(t, ValidCodeChallenge(challenge))
+	assert.False(t, ValidCodeChallenge("short"))
+
+	code, err := NewAuthCode(
+		"client",
+		1,
+		"https://test.test",
+		challenge,
+		nil,
+		ScopeProfileRead,
+		time.Minute,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NotEmpty(t, code.Code)
+	assert.False(t, code.Expired(time.Now()))
+
+	testCases := []struct {
+		name     string
+		verifier string
+		valid    bool
+	}{
+		{
+			name:     "valid verifier",
+			verifier: verifier,
+			valid:    true,
+		},
+		{
+			name:     "wrong verifier",
+			verifier: strings.Repeat("b", 43),
+			valid:    false,
+		},
+		{
+			name:     "challenge as verifier",
+			verifier: challenge,
+			valid:    false,
+		},
+		{
+			name:     "short verifier",
+			verifier: "a",
+			valid:    false,
+		},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.valid, code.VerifyCodeVerifier(tc.verifier), tc.name)
+	}
+
+	hashed := code.Hashed([]byte("hash_key"))
+	assert.Empty(t, hashed.Code)
+	assert.Equal(t, HashToken([]byte("hash_key"), code.Code), hashed.CodeHash)
+}
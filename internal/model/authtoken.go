@@ -1,32 +1,84 @@
 package model
 
 import (
-	"os"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
 	"github.com/twinj/uuid"
 )
 
+// AuthToken is a signed access or refresh token. Refresh tokens are persisted
+// and grouped in families: every refresh consumes the presented token and
+// issues its successor in the same family. Only a keyed hash of a refresh
+// token is persisted so that the stored tokens cannot be replayed.
+//
+// The session metadata of a refresh token describes the family: Created is
+// the time of the login, while LastUsed, ClientIp and UserAgent are those of
+// the request that issued the token.
 type AuthToken struct {
 	Uuid        string `json:"uuid"`
-	TokenString string `json:"token"`
+	TokenString string `json:"token,omitempty"`
+	TokenHash   string `json:"-"`
 	Expires     int64  `json:"exp"`
+	UserId      int64  `json:"user_id,omitempty"`
+	Family      string `json:"family,omitempty"`
+	Consumed    bool   `json:"consumed,omitempty"`
+	Created     int64  `json:"created,omitempty"`
+	LastUsed    int64  `json:"last_used,omitempty"`
+	ClientIp    string `json:"client_ip,omitempty"`
+	UserAgent   string `json:"user_agent,omitempty"`
 }
 
-func NewAccessToken(user *User) (*AuthToken, error) {
-	token := jwt.New(jwt.SigningMethodHS256)
+// TokenParams are set by the issuer of a token. Scope is the space separated
+// list of the scopes granted to the token. ClientId binds a refresh token to
+// the client it is issued to. IssuedAt overrides the issue time of an access
+// token, which is the current time when zero.
+type TokenParams struct {
+	Issuer   string
+	Audience Audience
+	Lifetime time.Duration
+	Scope    string
+	ClientId string
+	IssuedAt time.Time
+}
+
+// NewAccessToken issues an access token for the session identified by the
+// family of its refresh token, sessionId is empty for tokens without session.
+func NewAccessToken(
+	user *User,
+	grants Grants,
+	sessionId string,
+	key *SigningKey,
+	params TokenParams,
+) (*AuthToken, error) {
 	tokenUuid := uuid.NewV4().String()
-	tokenExpires := time.Now().Add(15 * time.Minute).Unix()
+	now := params.IssuedAt
+	if now.IsZero() {
+		now = time.Now()
+	}
+	tokenExpires := now.Add(params.Lifetime).Unix()
 
-	claims := token.Claims.(jwt.MapClaims)
-	claims["authorized"] = true
-	claims["user_id"] = user.ID
-	claims["access_uuid"] = tokenUuid
-	claims["admin"] = user.Admin
-	claims["exp"] = tokenExpires
+	claims := &AccessClaims{
+		RegisteredClaims: newRegisteredClaims(
+			strconv.FormatInt(user.ID, 10),
+			tokenUuid,
+			AccessTokenUse,
+			params,
+			now.Unix(),
+			tokenExpires,
+		),
+		Roles:       grants.Roles,
+		Permissions: grants.Permissions,
+		SessionId:   sessionId,
+		Scope:       params.Scope,
+		IssuedAtMs:  now.UnixMilli(),
+	}
 
-	tokenString, err := token.SignedString([]byte(os.Getenv("JWT_ACCESS_SECRET")))
+	tokenString, err := key.Sign(jwt.NewWithClaims(key.Method, claims))
 	if err != nil {
 		return nil, err
 	}
@@ -40,19 +92,66 @@ func NewAccessToken(user *User) (*AuthToken, error) {
 	return at, nil
 }
 
-func NewRefreshToken(user *User) (*AuthToken, error) {
-	token := jwt.New(jwt.SigningMethodHS256)
+// NewClientAccessToken issues an access token to a client for itself, the
+// token has no user nor session.
+func NewClientAccessToken(
+	client *Client,
+	key *SigningKey,
+	params TokenParams,
+) (*AuthToken, error) {
+	tokenUuid := uuid.NewV4().String()
+	now := time.Now()
+	tokenExpires := now.Add(params.Lifetime).Unix()
+
+	claims := &AccessClaims{
+		RegisteredClaims: newRegisteredClaims(
+			client.Id,
+			tokenUuid,
+			AccessTokenUse,
+			params,
+			now.Unix(),
+			tokenExpires,
+		),
+		ClientId:   client.Id,
+		Scope:      params.Scope,
+		IssuedAtMs: now.UnixMilli(),
+	}
+
+	tokenString, err := key.Sign(jwt.NewWithClaims(key.Method, claims))
+	if err != nil {
+		return nil, err
+	}
 
+	at := &AuthToken{
+		Uuid:        tokenUuid,
+		TokenString: tokenString,
+		Expires:     tokenExpires,
+	}
+
+	return at, nil
+}
+
+// NewRefreshToken records the issue time next to the expiry so that the
+// lifetime the session was granted can be carried over when it is rotated.
+func NewRefreshToken(user *User, key *SigningKey, params TokenParams) (*AuthToken, error) {
 	tokenUuid := uuid.NewV4().String()
-	tokenExpires := time.Now().Add(15 * time.Minute).Unix()
+	now := time.Now()
+	tokenExpires := now.Add(params.Lifetime).Unix()
 
-	claims := token.Claims.(jwt.MapClaims)
-	claims["user_id"] = user.ID
-	claims["refresh_uuid"] = tokenUuid
-	claims["admin"] = user.Admin
-	claims["exp"] = tokenExpires
+	claims := &RefreshClaims{
+		RegisteredClaims: newRegisteredClaims(
+			strconv.FormatInt(user.ID, 10),
+			tokenUuid,
+			RefreshTokenUse,
+			params,
+			now.Unix(),
+			tokenExpires,
+		),
+		Scope:    params.Scope,
+		ClientId: params.ClientId,
+	}
 
-	tokenString, err := token.SignedString([]byte(os.Getenv("JWT_REFRESH_SECRET")))
+	tokenString, err := key.Sign(jwt.NewWithClaims(key.Method, claims))
 	if err != nil {
 		return nil, err
 	}
@@ -61,7 +160,59 @@ func NewRefreshToken(user *User) (*AuthToken, error) {
 		Uuid:        tokenUuid,
 		TokenString: tokenString,
 		Expires:     tokenExpires,
+		UserId:      user.ID,
+		Family:      tokenUuid,
+		Created:     now.Unix(),
+		LastUsed:    now.Unix(),
 	}
 
 	return rt, nil
 }
+
+// NewIdToken issues the OpenID Connect id token of the user to the client,
+// signed by an access token key so that clients verify it with the JWKS. The
+// scope of params selects its claims.
+func NewIdToken(
+	user *User,
+	clientId string,
+	nonce string,
+	key *SigningKey,
+	params TokenParams,
+) (string, error) {
+	now := time.Now()
+	params.Audience = Audience{clientId}
+	claims := &IdClaims{
+		RegisteredClaims: newRegisteredClaims(
+			strconv.FormatInt(user.ID, 10),
+			uuid.NewV4().String(),
+			IdTokenUse,
+			params,
+			now.Unix(),
+			now.Add(params.Lifetime).Unix(),
+		),
+		Nonce:       nonce,
+		EmailClaims: NewEmailClaims(user, params.Scope),
+	}
+
+	return key.Sign(jwt.NewWithClaims(key.Method, claims))
+}
+
+func HashToken(key []byte, tokenString string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(tokenString))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Hashed returns the copy of the token to persist, holding the hash of the
+// signed token in place of the token itself.
+func (t *AuthToken) Hashed(key []byte) *AuthToken {
+	hashed := *t
+	hashed.TokenHash = HashToken(key, t.TokenString)
+	hashed.TokenString = ""
+	return &hashed
+}
+
+// Matches reports whether tokenString is the token the hash was computed from.
+func (t *AuthToken) Matches(key []byte, tokenString string) bool {
+	return hmac.Equal([]byte(t.TokenHash), []byte(HashToken(key, tokenString)))
+}
@@ -1,6 +1,7 @@
 package model
 
 import (
+	"strconv"
 	"testing"
 	"time"
 
@@ -11,33 +12,120 @@ func TestModel_NewAccessToken(t *testing.T) {
 	u, err := NewUser(
 		"test@test.test",
 		"test_password",
-		false,
 		time.Date(2000, time.January, 1, 0, 0, 0, 0, time.Local),
 	)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	token, err := NewAccessToken(u)
+	key := NewHMACSigningKey("test", "test_secret")
+	params := TokenParams{Issuer: "test", Audience: Audience{"test"}, Lifetime: time.Minute}
+	token, err := NewAccessToken(u, Grants{}, "test_session", key, params)
 	assert.NoError(t, err)
 	assert.NotEmpty(t, token)
+
+	claims, err := ParseAccessToken(token.TokenString, key.Keyfunc, "test", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "test_session", claims.SessionId)
+	assert.Equal(t, token.Uuid, claims.Id)
+	assert.Equal(t, strconv.FormatInt(u.ID, 10), claims.Subject)
+	assert.Empty(t, claims.Roles)
+	assert.False(t, claims.HasPermission(PermissionReadUsers))
+	assert.Equal(t, claims.IssuedAt, claims.IssuedAtMillis()/1000)
+
+	// Tokens issued without iat_ms are taken as issued at the start of iat
+	claims.IssuedAtMs = 0
+	assert.Equal(t, claims.IssuedAt*1000, claims.IssuedAtMillis())
+
+	// An explicit issue time replaces the current time
+	issuedAt := time.Now().Add(time.Millisecond)
+	params.IssuedAt = issuedAt
+	token, err = NewAccessToken(u, Grants{}, "test_session", key, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err = ParseAccessToken(token.TokenString, key.Keyfunc, "test", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, issuedAt.UnixMilli(), claims.IssuedAtMillis())
+	assert.Equal(t, issuedAt.Add(time.Minute).Unix(), token.Expires)
 }
 
 func TestModel_NewRefreshToken(t *testing.T) {
 	u, err := NewUser(
 		"test@test.test",
 		"test_password",
-		false,
 		time.Date(2000, time.January, 1, 0, 0, 0, 0, time.Local),
 	)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	token, err := NewRefreshToken(u)
+	token, err := NewRefreshToken(u, NewHMACSigningKey("test", "test_secret"), TokenParams{Lifetime: time.Hour})
 	if err != nil {
 		t.Fatal(err)
 	}
 	assert.NoError(t, err)
 	assert.NotEmpty(t, token)
 }
+
+func TestModel_AuthToken_Hashed(t *testing.T) {
+	u, err := NewUser(
+		"test@test.test",
+		"test_password",
+		time.Date(2000, time.January, 1, 0, 0, 0, 0, time.Local),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := NewRefreshToken(u, NewHMACSigningKey("test", "test_secret"), TokenParams{Lifetime: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hashed := token.Hashed([]byte("hash_key"))
+	assert.Empty(t, hashed.TokenString)
+	assert.NotContains(t, hashed.TokenHash, token.TokenString)
+	assert.Equal(t, token.Uuid, hashed.Uuid)
+	assert.NotEmpty(t, token.TokenString)
+
+	assert.True(t, hashed.Matches([]byte("hash_key"), token.TokenString))
+	assert.False(t, hashed.Matches([]byte("other_key"), token.TokenString))
+	assert.False(t, hashed.Matches([]byte("hash_key"), token.TokenString+"x"))
+}
+
+func TestModel_NewClientAccessToken(t *testing.T) {
+	c, _, err := NewClient("translation", nil, []string{"tts"}, true, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key := NewHMACSigningKey("test", "test_secret")
+	params := TokenParams{
+		Issuer:   "test",
+		Audience: Audience{"test"},
+		Lifetime: time.Minute,
+		Scope:    "tts",
+	}
+	token, err := NewClientAccessToken(c, key, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims, err := ParseAccessToken(token.TokenString, key.Keyfunc, "test", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, c.Id, claims.Subject)
+	assert.Equal(t, c.Id, claims.ClientId)
+	assert.Equal(t, "tts", claims.Scope)
+	assert.Empty(t, claims.Roles)
+	assert.Empty(t, claims.Permissions)
+	assert.Empty(t, claims.SessionId)
+
+	_, err = claims.UserId()
+	assert.EqualError(t, err, "token has no user")
+}
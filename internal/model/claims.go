@@ -0,0 +1,260 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const (
+	AccessTokenUse  = "access"
+	RefreshTokenUse = "refresh"
+	IdTokenUse      = "id"
+)
+
+// Audience is the aud claim. A single audience is encoded as a string, as
+// allowed by RFC 7519, and both forms are accepted when decoding.
+type Audience []string
+
+func (a Audience) MarshalJSON() ([]byte, error) {
+	if len(a) == 1 {
+		return json.Marshal(a[0])
+	}
+
+	return json.Marshal([]string(a))
+}
+
+func (a *Audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = Audience{single}
+		return nil
+	}
+
+	var multiple []string
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return errors.New("invalid audience claim")
+	}
+	*a = Audience(multiple)
+	return nil
+}
+
+func (a Audience) Contains(audience string) bool {
+	return Contains(a, audience)
+}
+
+// RegisteredClaims are the claims of RFC 7519 shared by every token, the
+// subject is the id of the user, or of the client for the tokens issued to a
+// client for itself, and the id is the uuid of the token.
+type RegisteredClaims struct {
+	Issuer    string   `json:"iss,omitempty"`
+	Subject   string   `json:"sub"`
+	Audience  Audience `json:"aud,omitempty"`
+	ExpiresAt int64    `json:"exp"`
+	NotBefore int64    `json:"nbf,omitempty"`
+	IssuedAt  int64    `json:"iat"`
+	Id        string   `json:"jti"`
+	TokenUse  string   `json:"token_use"`
+}
+
+func newRegisteredClaims(
+	subject string,
+	tokenUuid string,
+	tokenUse string,
+	params TokenParams,
+	issuedAt int64,
+	expiresAt int64,
+) RegisteredClaims {
+	return RegisteredClaims{
+		Issuer:    params.Issuer,
+		Audience:  params.Audience,
+		Subject:   subject,
+		ExpiresAt: expiresAt,
+		NotBefore: issuedAt,
+		IssuedAt:  issuedAt,
+		Id:        tokenUuid,
+		TokenUse:  tokenUse,
+	}
+}
+
+// Valid is called by the jwt parser once the signature has been verified.
+func (c *RegisteredClaims) Valid() error {
+	if c.Subject == "" || c.Id == "" {
+		return errors.New("token is missing required claims")
+	}
+
+	now := jwt.TimeFunc().Unix()
+	if c.ExpiresAt == 0 || now >= c.ExpiresAt {
+		return errors.New("token is expired")
+	}
+	if now < c.NotBefore {
+		return errors.New("token is not valid yet")
+	}
+	if now < c.IssuedAt {
+		return errors.New("token used before issued")
+	}
+
+	return nil
+}
+
+func (c *RegisteredClaims) validTokenUse(tokenUse string) error {
+	if err := c.Valid(); err != nil {
+		return err
+	}
+	if c.TokenUse != tokenUse {
+		return fmt.Errorf("invalid token use: %s", c.TokenUse)
+	}
+
+	return nil
+}
+
+// VerifyIssuerAudience rejects the tokens which were not issued by issuer or
+// are not intended for audience.
+func (c *RegisteredClaims) VerifyIssuerAudience(issuer string, audience string) error {
+	if c.Issuer != issuer {
+		return errors.New("invalid token issuer")
+	}
+	if !c.Audience.Contains(audience) {
+		return errors.New("invalid token audience")
+	}
+
+	return nil
+}
+
+func (c *RegisteredClaims) UserId() (int64, error) {
+	return strconv.ParseInt(c.Subject, 10, 64)
+}
+
+// AccessClaims carry the roles of the user and the permissions they grant at
+// the time the token was issued.
+type AccessClaims struct {
+	RegisteredClaims
+	Roles       []string `json:"roles,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
+	// SessionId is the family of the refresh token the access token was
+	// issued with.
+	SessionId string `json:"sid,omitempty"`
+	// ClientId is only set on the tokens of the client_credentials grant,
+	// whose subject is the client.
+	ClientId string `json:"client_id,omitempty"`
+	// Scope is the space separated list of the scopes granted to the token.
+	Scope string `json:"scope,omitempty"`
+	// IssuedAtMs is the issue time in milliseconds, so that a token issued
+	// right after the user's tokens are revoked is told apart from the
+	// revoked ones.
+	IssuedAtMs int64 `json:"iat_ms,omitempty"`
+}
+
+func (c *AccessClaims) Valid() error {
+	return c.validTokenUse(AccessTokenUse)
+}
+
+// IssuedAtMillis falls back to the start of the second of iat for the tokens
+// issued without iat_ms.
+func (c *AccessClaims) IssuedAtMillis() int64 {
+	if c.IssuedAtMs != 0 {
+		return c.IssuedAtMs
+	}
+
+	return c.IssuedAt * 1000
+}
+
+func (c *AccessClaims) HasPermission(permission string) bool {
+	return Contains(c.Permissions, permission)
+}
+
+func (c *AccessClaims) HasScope(scope string) bool {
+	return hasScope(c.Scope, scope)
+}
+
+// UserId fails for the tokens of clients, which are not issued to a user.
+func (c *AccessClaims) UserId() (int64, error) {
+	if c.ClientId != "" {
+		return 0, errors.New("token has no user")
+	}
+
+	return c.RegisteredClaims.UserId()
+}
+
+type RefreshClaims struct {
+	RegisteredClaims
+	// Scope bounds the scope of the tokens issued when the refresh token is
+	// rotated. Refresh tokens issued before scopes have none and are not
+	// bounded.
+	Scope string `json:"scope,omitempty"`
+	// ClientId is set on the refresh tokens issued to a client, which are
+	// only rotated by the refresh_token grant of that client.
+	ClientId string `json:"client_id,omitempty"`
+}
+
+func (c *RefreshClaims) Valid() error {
+	return c.validTokenUse(RefreshTokenUse)
+}
+
+// EmailClaims are the claims of the email scope of OpenID Connect.
+type EmailClaims struct {
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+}
+
+// NewEmailClaims returns the email claims of the user when the space
+// separated scope contains the email scope, nil otherwise.
+func NewEmailClaims(user *User, scope string) *EmailClaims {
+	if !hasScope(scope, ScopeEmail) {
+		return nil
+	}
+
+	return &EmailClaims{Email: user.Email, EmailVerified: user.EmailVerified}
+}
+
+// IdClaims are the claims of the OpenID Connect id token issued to a client,
+// its audience is the id of the client. Nonce echoes the nonce of the
+// authorize request, the email claims are left out without the email scope.
+type IdClaims struct {
+	RegisteredClaims
+	Nonce string `json:"nonce,omitempty"`
+	*EmailClaims
+}
+
+func (c *IdClaims) Valid() error {
+	return c.validTokenUse(IdTokenUse)
+}
+
+// ParseAccessToken verifies the signature and the claims of an access token
+// issued by issuer for the service identified by audience.
+func ParseAccessToken(
+	tokenString string,
+	keyfunc jwt.Keyfunc,
+	issuer string,
+	audience string,
+) (*AccessClaims, error) {
+	claims := &AccessClaims{}
+	if _, err := jwt.ParseWithClaims(tokenString, claims, keyfunc); err != nil {
+		return nil, err
+	}
+	if err := claims.VerifyIssuerAudience(issuer, audience); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+func ParseRefreshToken(
+	tokenString string,
+	keyfunc jwt.Keyfunc,
+	issuer string,
+	audience string,
+) (*RefreshClaims, error) {
+	claims := &RefreshClaims{}
+	if _, err := jwt.ParseWithClaims(tokenString, claims, keyfunc); err != nil {
+		return nil, err
+	}
+	if err := claims.VerifyIssuerAudience(issuer, audience); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,139 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModel_Audience(t *testing.T) {
+	testCases := []struct {
+		name     string
+		audience Audience
+		encoded  string
+	}{
+		{name: "single audience", audience: Audience{"reader"}, encoded: `"reader"`},
+		{name: "multiple audiences", audience: Audience{"reader", "writer"}, encoded: `["reader","writer"]`},
+	}
+
+	for _, tc := range testCases {
+		encoded, err := json.Marshal(tc.audience)
+		assert.NoError(t, err, tc.name)
+		assert.Equal(t, tc.encoded, string(encoded), tc.name)
+
+		decoded := Audience{}
+		assert.NoError(t, json.Unmarshal(encoded, &decoded), tc.name)
+		assert.Equal(t, tc.audience, decoded, tc.name)
+	}
+
+	assert.Error(t, json.Unmarshal([]byte(`1`), &Audience{}))
+	assert.True(t, Audience{"reader", "writer"}.Contains("writer"))
+	assert.False(t, Audience{"reader"}.Contains("writer"))
+}
+
+func TestModel_ParseTokens(t *testing.T) {
+	u, err := NewUser(
+		"test@test.test",
+		"test_password",
+		time.Date(2000, time.January, 1, 0, 0, 0, 0, time.Local),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u.ID = 42
+	key := NewHMACSigningKey("test", "test_secret")
+
+	at, err := NewAccessToken(u, NewGrants([]*Role{NewAdminRole()}), "test_session", key, TokenParams{
+		Issuer:   "test",
+		Audience: Audience{"reader", "writer"},
+		Lifetime: time.Minute,
+		Scope:    "profile:read users:write",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rt, err := NewRefreshToken(u, key, TokenParams{
+		Issuer:   "test",
+		Audience: Audience{"test"},
+		Lifetime: time.Hour,
+		Scope:    "profile:read",
+		ClientId: "test_client",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	accessClaims, err := ParseAccessToken(at.TokenString, key.Keyfunc, "test", "writer")
+	assert.NoError(t, err)
+	userId, err := accessClaims.UserId()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(42), userId)
+	assert.Equal(t, []string{AdminRoleName}, accessClaims.Roles)
+	assert.True(t, accessClaims.HasPermission(PermissionWriteUsers))
+	assert.True(t, accessClaims.HasScope(PermissionWriteUsers))
+	assert.False(t, accessClaims.HasScope(PermissionReadUsers))
+
+	refreshClaims, err := ParseRefreshToken(rt.TokenString, key.Keyfunc, "test", "test")
+	assert.NoError(t, err)
+	assert.Equal(t, rt.Uuid, refreshClaims.Id)
+	assert.Equal(t, "profile:read", refreshClaims.Scope)
+	assert.Equal(t, "test_client", refreshClaims.ClientId)
+
+	// A token cannot be used in place of the other
+	_, err = ParseAccessToken(rt.TokenString, key.Keyfunc, "test", "test")
+	assert.EqualError(t, err, "invalid token use: refresh")
+	_, err = ParseRefreshToken(at.TokenString, key.Keyfunc, "test", "writer")
+	assert.EqualError(t, err, "invalid token use: access")
+
+	// A token is only accepted by the services of its audience
+	_, err = ParseAccessToken(at.TokenString, key.Keyfunc, "test", "billing")
+	assert.EqualError(t, err, "invalid token audience")
+	_, err = ParseAccessToken(at.TokenString, key.Keyfunc, "other", "reader")
+	assert.EqualError(t, err, "invalid token issuer")
+
+	testCases := []struct {
+		name          string
+		claims        RegisteredClaims
+		expectedError string
+	}{
+		{
+			name: "expired",
+			claims: RegisteredClaims{
+				Subject: "42", Id: "id", TokenUse: AccessTokenUse,
+				IssuedAt: 100, ExpiresAt: 200,
+			},
+			expectedError: "token is expired",
+		},
+		{
+			name: "not valid yet",
+			claims: RegisteredClaims{
+				Subject: "42", Id: "id", TokenUse: AccessTokenUse,
+				NotBefore: time.Now().Add(time.Hour).Unix(),
+				ExpiresAt: time.Now().Add(2 * time.Hour).Unix(),
+			},
+			expectedError: "token is not valid yet",
+		},
+		{
+			name: "missing subject",
+			claims: RegisteredClaims{
+				Id: "id", TokenUse: AccessTokenUse,
+				ExpiresAt: time.Now().Add(time.Hour).Unix(),
+			},
+			expectedError: "token is missing required claims",
+		},
+	}
+
+	for _, tc := range testCases {
+		tokenString, err := key.Sign(
+			jwt.NewWithClaims(key.Method, &AccessClaims{RegisteredClaims: tc.claims}),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = ParseAccessToken(tokenString, key.Keyfunc, "", "")
+		assert.EqualError(t, err, tc.expectedError, tc.name)
+	}
+}
@@ -0,0 +1,115 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/twinj/uuid"
+)
+
+// Client is an application registered to obtain tokens through the OAuth
+// endpoints. Public clients, such as the browser extension and the mobile
+// apps, cannot keep a secret and prove themselves with PKCE. Confidential
+// clients are the back-end services, which authenticate with their secret to
+// obtain tokens for themselves with the client_credentials grant.
+type Client struct {
+	Id           string   `json:"id"`
+	Name         string   `json:"name"`
+	RedirectUris []string `json:"redirect_uris"`
+	// SecretHash is empty for public clients.
+	SecretHash string `json:"-"`
+	// Scopes are the scopes the client can request for itself.
+	Scopes  []string `json:"scopes"`
+	Created int64    `json:"created"`
+}
+
+// NewClient returns the secret of confidential clients, which is not kept
+// anywhere and can only be shown once.
+func NewClient(
+	name string,
+	redirectUris []string,
+	scopes []string,
+	confidential bool,
+	now time.Time,
+) (*Client, string, error) {
+	c := &Client{
+		Id:           uuid.NewV4().String(),
+		Name:         name,
+		RedirectUris: append([]string{}, redirectUris...),
+		Scopes:       append([]string{}, scopes...),
+		Created:      now.Unix(),
+	}
+
+	secret := ""
+	if confidential {
+		var err error
+		secret, err = c.RotateSecret()
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	if err := c.Validate(); err != nil {
+		return nil, "", err
+	}
+
+	return c, secret, nil
+}
+
+// Validate requires absolute redirect uris without fragment, as the authorize
+// endpoint appends its response to their query.
+func (c *Client) Validate() error {
+	if c.Name == "" {
+		return errors.New("a required field is empty")
+	}
+	if len(c.RedirectUris) == 0 && !c.Confidential() {
+		return errors.New("a public client needs at least one redirect uri")
+	}
+	for _, redirectUri := range c.RedirectUris {
+		u, err := url.Parse(redirectUri)
+		if err != nil || !u.IsAbs() || u.Fragment != "" {
+			return fmt.Errorf("invalid redirect uri: %s", redirectUri)
+		}
+	}
+	for _, scope := range c.Scopes {
+		if scope == "" || strings.ContainsAny(scope, " \t\n\"\\") {
+			return fmt.Errorf("invalid scope: %q", scope)
+		}
+	}
+
+	return nil
+}
+
+func (c *Client) Confidential() bool {
+	return c.SecretHash != ""
+}
+
+// RotateSecret replaces the secret of the client and returns the new one.
+func (c *Client) RotateSecret() (string, error) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		return "", err
+	}
+	c.SecretHash = HashSecret(secret)
+
+	return secret, nil
+}
+
+func (c *Client) VerifySecret(secret string) bool {
+	return c.Confidential() && verifySecret(c.SecretHash, secret)
+}
+
+// HasRedirectUri reports whether the uri is registered for the client, uris
+// are compared as strings.
+func (c *Client) HasRedirectUri(redirectUri string) bool {
+	return Contains(c.RedirectUris, redirectUri)
+}
+
+// GrantScope returns the scope of a token requested by the client for itself,
+// every scope of the client when none is requested.
+func (c *Client) GrantScope(requested string) (string, error) {
+	return GrantScope(c.Scopes, requested)
+}
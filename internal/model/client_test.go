@@ -0,0 +1,144 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModel_NewClient(t *testing.T) {
+	testCases := []struct {
+		name             string
+		clientName       string
+		redirectUris     []string
+		scopes           []string
+		confidential     bool
+		expectedErrorMsg string
+	}{
+		{
+			name:             "valid client",
+			clientName:       "extension",
+			redirectUris:     []string{"https://test.test/callback", "dualread://callback"},
+			expectedErrorMsg: "",
+		},
+		{
+			name:             "confidential client",
+			clientName:       "translation",
+			redirectUris:     []string{},
+			scopes:           []string{"tts:read", "billing"},
+			confidential:     true,
+			expectedErrorMsg: "",
+		},
+		{
+			name:             "no redirect uri",
+			clientName:       "extension",
+			redirectUris:     []string{},
+			expectedErrorMsg: "a public client needs at least one redirect uri",
+		},
+		{
+			name:             "relative redirect uri",
+			clientName:       "extension",
+			redirectUris:     []string{"/callback"},
+			expectedErrorMsg: "invalid redirect uri: /callback",
+		},
+		{
+			name:             "redirect uri with fragment",
+			clientName:       "extension",
+			redirectUris:     []string{"https://test.test/#callback"},
+			expectedErrorMsg: "invalid redirect uri: https://test.test/#callback",
+		},
+		{
+			name:             "scope with space",
+			clientName:       "translation",
+			scopes:           []string{"tts read"},
+			confidential:     true,
+			expectedErrorMsg: `invalid scope: "tts read"`,
+		},
+	}
+
+	for _, tc := range testCases {
+		c, secret, err := NewClient(
+			tc.clientName,
+			tc.redirectUris,
+			tc.scopes,
+			tc.confidential,
+			time.Now(),
+		)
+		if tc.expectedErrorMsg != "" {
+			assert.EqualError(t, err, tc.expectedErrorMsg, tc.name)
+			continue
+		}
+
+		assert.NoError(t, err, tc.name)
+		assert.Equal(t, tc.confidential, c.Confidential(), tc.name)
+		assert.Equal(t, tc.confidential, c.VerifySecret(secret), tc.name)
+		for _, redirectUri := range tc.redirectUris {
+			assert.True(t, c.HasRedirectUri(redirectUri), tc.name)
+			assert.False(t, c.HasRedirectUri(redirectUri+"/other"), tc.name)
+		}
+	}
+}
+
+func TestModel_Client_VerifySecret(t *testing.T) {
+	c, secret, err := NewClient("translation", nil, nil, true, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, c.VerifySecret(secret))
+	assert.False(t, c.VerifySecret(secret+"x"))
+	assert.False(t, c.VerifySecret(""))
+
+	rotatedSecret, err := c.RotateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, c.VerifySecret(rotatedSecret))
+	assert.False(t, c.VerifySecret(secret))
+
+	public, _, err := NewClient("extension", []string{"https://test.test"}, nil, false, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, public.VerifySecret(""))
+}
+
+func TestModel_Client_GrantScope(t *testing.T) {
+	c, _, err := NewClient("translation", nil, []string{"tts", "billing"}, true, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name             string
+		requested        string
+		expectedScope    string
+		expectedErrorMsg string
+	}{
+		{
+			name:          "no scope requested",
+			requested:     "",
+			expectedScope: "tts billing",
+		},
+		{
+			name:          "subset",
+			requested:     " billing ",
+			expectedScope: "billing",
+		},
+		{
+			name:             "scope of another client",
+			requested:        "tts admin",
+			expectedErrorMsg: "invalid scope: admin",
+		},
+	}
+
+	for _, tc := range testCases {
+		scope, err := c.GrantScope(tc.requested)
+		if tc.expectedErrorMsg == "" {
+			assert.NoError(t, err, tc.name)
+			assert.Equal(t, tc.expectedScope, scope, tc.name)
+		} else {
+			assert.EqualError(t, err, tc.expectedErrorMsg, tc.name)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package model
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+// sealedKeyPrefix marks the key data encrypted by a KeyCipher.
+const sealedKeyPrefix = "enc:v1:"
+
+var errInvalidSealedKey = errors.New("invalid encrypted signing key")
+
+// KeyCipher encrypts the signing keys at rest with AES-256-GCM under a key
+// encryption key kept out of the database, so that a dump of the database is
+// not enough to forge tokens. The kid is authenticated along with the key so
+// that the key data of one row cannot be moved to another.
+type KeyCipher struct {
+	aead cipher.AEAD
+}
+
+// NewKeyCipher takes the base64 encoded 32 byte key encryption key.
+func NewKeyCipher(encodedKey string) (*KeyCipher, error) {
+	key, err := base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil || len(key) != 32 {
+		return nil, errors.New("key encryption key must be 32 base64 encoded bytes")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &KeyCipher{aead: aead}, nil
+}
+
+func (c *KeyCipher) Seal(kid string, keyData []byte) (string, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := c.aead.Seal(nonce, nonce, keyData, []byte(kid))
+
+	return sealedKeyPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Open returns the key data sealed for the kid.
+func (c *KeyCipher) Open(kid string, data string) ([]byte, error) {
+	if !strings.HasPrefix(data, sealedKeyPrefix) {
+		return nil, errInvalidSealedKey
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(data, sealedKeyPrefix))
+	if err != nil || len(sealed) < c.aead.NonceSize() {
+		return nil, errInvalidSealedKey
+	}
+	nonce, ciphertext := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+	keyData, err := c.aead.Open(nil, nonce, ciphertext, []byte(kid))
+	if err != nil {
+		return nil, errInvalidSealedKey
+	}
+
+	return keyData, nil
+}
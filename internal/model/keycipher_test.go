@@ -0,0 +1,46 @@
+package model
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModel_KeyCipher(t *testing.T) {
+	c, err := NewKeyCipher(base64.StdEncoding.EncodeToString([]byte(strings.Repeat("k", 32))))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sealed, err := c.Seal("kid", []byte("test_secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NotContains(t, sealed, "test_secret")
+
+	keyData, err := c.Open("kid", sealed)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("test_secret"), keyData)
+
+	// The key data is bound to its kid
+	_, err = c.Open("other", sealed)
+	assert.Error(t, err)
+
+	other, err := NewKeyCipher(base64.StdEncoding.EncodeToString([]byte(strings.Repeat("o", 32))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = other.Open("kid", sealed)
+	assert.Error(t, err)
+
+	// Key data that was not encrypted is refused
+	_, err = c.Open("kid", "test_secret")
+	assert.Error(t, err)
+
+	_, err = NewKeyCipher("")
+	assert.Error(t, err)
+	_, err = NewKeyCipher(base64.StdEncoding.EncodeToString([]byte("short")))
+	assert.Error(t, err)
+}
@@ -0,0 +1,221 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const (
+	AccessKeyPurpose  = "access"
+	RefreshKeyPurpose = "refresh"
+)
+
+// KeyRing holds the active signing key together with retired keys that are
+// still accepted for verification until they expire, so that rotating a key
+// does not invalidate the tokens it already signed.
+type KeyRing struct {
+	mu      sync.RWMutex
+	active  *SigningKey
+	retired []*SigningKey
+	// tokenLifetime is the longest lifetime of the tokens signed by the keys
+	tokenLifetime time.Duration
+
+	// reloadMu serializes the reloads triggered by unknown kids
+	reloadMu       sync.Mutex
+	load           func() ([]*SigningKey, error)
+	reloadInterval time.Duration
+	lastReload     time.Time
+}
+
+func NewKeyRing(keys []*SigningKey, tokenLifetime time.Duration) (*KeyRing, error) {
+	r := &KeyRing{tokenLifetime: tokenLifetime}
+	if err := r.Set(keys); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Set replaces the content of the ring. The most recent key that has not been
+// retired becomes the active key, every other key is verification-only.
+//
+// Older keys that were never retired, left by a rotation that did not
+// complete, expire once the tokens they signed before the next key was created
+// have, so that they do not verify tokens forever.
+func (r *KeyRing) Set(keys []*SigningKey) error {
+	sorted := make([]*SigningKey, len(keys))
+	copy(sorted, keys)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Created.After(sorted[j].Created)
+	})
+
+	var active *SigningKey
+	retired := []*SigningKey{}
+	for i, k := range sorted {
+		if active == nil && !k.Retired() {
+			active = k
+			continue
+		}
+		if !k.Retired() {
+			stray := *k
+			stray.Expires = sorted[i-1].Created.Add(r.tokenLifetime)
+			k = &stray
+		}
+		retired = append(retired, k)
+	}
+	if active == nil {
+		return errors.New("key ring has no active key")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.active = active
+	r.retired = retired
+
+	return nil
+}
+
+// SetLoader makes the ring reload its keys with load when a token names an
+// unknown kid, so that the keys rotated by another instance are accepted before
+// the next scheduled refresh. Reloads happen at most once per interval to keep
+// tokens with made up kids from hammering the store.
+func (r *KeyRing) SetLoader(load func() ([]*SigningKey, error), interval time.Duration) {
+	r.reloadMu.Lock()
+	defer r.reloadMu.Unlock()
+
+	r.load = load
+	r.reloadInterval = interval
+}
+
+func (r *KeyRing) Active() *SigningKey {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.active
+}
+
+// Keys returns the active key followed by the retired keys.
+func (r *KeyRing) Keys() []*SigningKey {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return append([]*SigningKey{r.active}, r.retired...)
+}
+
+// Rotate promotes next to active and retires the current active key, which
+// keeps verifying tokens for the overlap duration.
+func (r *KeyRing) Rotate(next *SigningKey, overlap time.Duration, now time.Time) *SigningKey {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	previous := *r.active
+	previous.Expires = now.Add(overlap)
+	r.retired = append([]*SigningKey{&previous}, r.retired...)
+	r.active = next
+
+	return &previous
+}
+
+// Prune drops the retired keys that expired before now and returns them.
+func (r *KeyRing) Prune(now time.Time) []*SigningKey {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	expired := []*SigningKey{}
+	retired := []*SigningKey{}
+	for _, k := range r.retired {
+		if k.Expired(now) {
+			expired = append(expired, k)
+		} else {
+			retired = append(retired, k)
+		}
+	}
+	r.retired = retired
+
+	return expired
+}
+
+// Remove drops a retired key so that the tokens it signed are rejected
+// immediately.
+func (r *KeyRing) Remove(kid string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.active.Kid == kid {
+		return errors.New("cannot remove the active signing key")
+	}
+	for i, k := range r.retired {
+		if k.Kid == kid {
+			r.retired = append(r.retired[:i:i], r.retired[i+1:]...)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown signing key: %s", kid)
+}
+
+func (r *KeyRing) Get(kid string) (*SigningKey, error) {
+	for _, k := range r.Keys() {
+		if k.Kid == kid {
+			return k, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown signing key: %s", kid)
+}
+
+// Keyfunc is passed to jwt.Parse to verify a token with the key named by its
+// kid header. Tokens issued before key identifiers were introduced carry no
+// kid and are checked against the active key.
+func (r *KeyRing) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, ok := token.Header["kid"]
+	if !ok {
+		return r.Active().Keyfunc(token)
+	}
+
+	kidString, ok := kid.(string)
+	if !ok {
+		return nil, errors.New("invalid kid header")
+	}
+
+	k, err := r.Get(kidString)
+	if err != nil {
+		k, err = r.reload(kidString, time.Now())
+		if err != nil {
+			return nil, err
+		}
+	}
+	if k.Expired(time.Now()) {
+		return nil, fmt.Errorf("signing key has expired: %s", kidString)
+	}
+
+	return k.Keyfunc(token)
+}
+
+// reload looks the kid up again after reloading the ring, unless it was
+// reloaded less than reloadInterval ago.
+func (r *KeyRing) reload(kid string, now time.Time) (*SigningKey, error) {
+	r.reloadMu.Lock()
+	defer r.reloadMu.Unlock()
+
+	if r.load == nil || now.Sub(r.lastReload) < r.reloadInterval {
+		// The key may have been loaded by the reload that is rate limiting this one
+		return r.Get(kid)
+	}
+	r.lastReload = now
+
+	keys, err := r.load()
+	if err != nil {
+		return nil, err
+	}
+	if err := r.Set(keys); err != nil {
+		return nil, err
+	}
+
+	return r.Get(kid)
+}
@@ -0,0 +1,159 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestKeyRingKey(t *testing.T, created time.Time) *SigningKey {
+	t.Helper()
+
+	key, err := GenerateSigningKey("EdDSA", created)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key
+}
+
+func TestKeyRing_Set(t *testing.T) {
+	now := time.Date(2000, time.January, 1, 0, 0, 0, 0, time.Local)
+	oldKey := newTestKeyRingKey(t, now.Add(-2*time.Hour))
+	activeKey := newTestKeyRingKey(t, now.Add(-time.Hour))
+	retiredKey := newTestKeyRingKey(t, now)
+	retiredKey.Expires = now.Add(time.Hour)
+
+	ring, err := NewKeyRing([]*SigningKey{oldKey, retiredKey, activeKey}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The old key was never retired, it expires with the tokens it signed
+	// before the active key was created
+	strayKey := *oldKey
+	strayKey.Expires = activeKey.Created.Add(time.Hour)
+	assert.Equal(t, activeKey, ring.Active())
+	assert.Equal(t, []*SigningKey{activeKey, retiredKey, &strayKey}, ring.Keys())
+	assert.False(t, oldKey.Retired(), "the stray key must not be mutated")
+	assert.Len(t, ring.Prune(strayKey.Expires), 1)
+
+	_, err = NewKeyRing([]*SigningKey{retiredKey}, time.Hour)
+	assert.EqualError(t, err, "key ring has no active key")
+}
+
+func TestKeyRing_Rotate(t *testing.T) {
+	now := time.Now()
+	firstKey := newTestKeyRingKey(t, now.Add(-time.Hour))
+	secondKey := newTestKeyRingKey(t, now)
+
+	ring, err := NewKeyRing([]*SigningKey{firstKey}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	oldToken, err := ring.Active().Sign(jwt.New(ring.Active().Method))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	retired := ring.Rotate(secondKey, time.Minute, now)
+	assert.Equal(t, firstKey.Kid, retired.Kid)
+	assert.Equal(t, now.Add(time.Minute), retired.Expires)
+	assert.False(t, firstKey.Retired(), "the previous key must not be mutated")
+	assert.Equal(t, secondKey, ring.Active())
+
+	newToken, err := ring.Active().Sign(jwt.New(ring.Active().Method))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Both keys verify during the overlap window
+	_, err = jwt.Parse(oldToken, ring.Keyfunc)
+	assert.NoError(t, err)
+	_, err = jwt.Parse(newToken, ring.Keyfunc)
+	assert.NoError(t, err)
+
+	assert.Empty(t, ring.Prune(now))
+	expired := ring.Prune(now.Add(time.Minute))
+	assert.Len(t, expired, 1)
+	assert.Equal(t, []*SigningKey{secondKey}, ring.Keys())
+
+	_, err = jwt.Parse(oldToken, ring.Keyfunc)
+	assert.EqualError(t, err, "unknown signing key: "+firstKey.Kid)
+}
+
+func TestKeyRing_Keyfunc(t *testing.T) {
+	now := time.Now()
+	activeKey := newTestKeyRingKey(t, now)
+	expiredKey := newTestKeyRingKey(t, now.Add(-time.Hour))
+	expiredKey.Expires = now.Add(-time.Minute)
+
+	ring, err := NewKeyRing([]*SigningKey{activeKey, expiredKey}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expiredToken, err := expiredKey.Sign(jwt.New(expiredKey.Method))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = jwt.Parse(expiredToken, ring.Keyfunc)
+	assert.EqualError(t, err, "signing key has expired: "+expiredKey.Kid)
+
+	// Tokens issued before kid headers existed are verified by the active key
+	legacyToken, err := jwt.New(activeKey.Method).SignedString(activeKey.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = jwt.Parse(legacyToken, ring.Keyfunc)
+	assert.NoError(t, err)
+
+	assert.EqualError(t, ring.Remove(activeKey.Kid), "cannot remove the active signing key")
+	assert.NoError(t, ring.Remove(expiredKey.Kid))
+	assert.EqualError(t, ring.Remove(expiredKey.Kid), "unknown signing key: "+expiredKey.Kid)
+}
+
+func TestKeyRing_ReloadUnknownKid(t *testing.T) {
+	now := time.Now()
+	activeKey := newTestKeyRingKey(t, now.Add(-time.Minute))
+	ring, err := NewKeyRing([]*SigningKey{activeKey}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The key is rotated by another instance
+	rotatedKey := newTestKeyRingKey(t, now)
+	rotatedToken, err := rotatedKey.Sign(jwt.New(rotatedKey.Method))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = jwt.Parse(rotatedToken, ring.Keyfunc)
+	assert.Error(t, err)
+
+	loads := 0
+	ring.SetLoader(func() ([]*SigningKey, error) {
+		loads++
+		return []*SigningKey{activeKey, rotatedKey}, nil
+	}, time.Hour)
+	_, err = jwt.Parse(rotatedToken, ring.Keyfunc)
+	assert.NoError(t, err)
+	assert.Equal(t, rotatedKey.Kid, ring.Active().Kid)
+	assert.Equal(t, 1, loads)
+
+	// Unknown kids reload the ring at most once per interval
+	unknownKey := newTestKeyRingKey(t, now)
+	unknownToken, err := unknownKey.Sign(jwt.New(unknownKey.Method))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		_, err = jwt.Parse(unknownToken, ring.Keyfunc)
+		assert.Error(t, err)
+	}
+	_, err = jwt.Parse(rotatedToken, ring.Keyfunc)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, loads)
+}
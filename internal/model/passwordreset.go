@@ -0,0 +1,43 @@
+package model
+
+import (
+	"time"
+)
+
+// PasswordReset is a single use token mailed to a user who forgot their
+// password. Like authorization codes, only a keyed hash of the token is
+// persisted.
+type PasswordReset struct {
+	Token     string
+	TokenHash string
+	UserId    int64
+	Created   int64
+	Expires   int64
+}
+
+func NewPasswordReset(userId int64, lifetime time.Duration, now time.Time) (*PasswordReset, error) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	return &PasswordReset{
+		Token:   secret,
+		UserId:  userId,
+		Created: now.Unix(),
+		Expires: now.Add(lifetime).Unix(),
+	}, nil
+}
+
+// Hashed returns the copy of the reset to persist, holding the hash of the
+// token in place of the token itself.
+func (p *PasswordReset) Hashed(key []byte) *PasswordReset {
+	hashed := *p
+	hashed.TokenHash = HashToken(key, p.Token)
+	hashed.Token = ""
+	return &hashed
+}
+
+func (p *PasswordReset) Expired(now time.Time) bool {
+	return p.Expires <= now.Unix()
+}
@@ -0,0 +1,25 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModel_NewPasswordReset(t *testing.T) {
+	now := time.Now()
+	reset, err := NewPasswordReset(1, time.Hour, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NotEmpty(t, reset.Token)
+	assert.Equal(t, int64(1), reset.UserId)
+	assert.False(t, reset.Expired(now))
+	assert.True(t, reset.Expired(now.Add(time.Hour)))
+
+	hashed := reset.Hashed([]byte("test_hash_key"))
+	assert.Empty(t, hashed.Token)
+	assert.Equal(t, HashToken([]byte("test_hash_key"), reset.Token), hashed.TokenHash)
+	assert.NotEmpty(t, reset.Token)
+}
@@ -0,0 +1,118 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Permissions guard the admin routes. They are also rows of the permission
+// table, a new permission needs a migration inserting it.
+const (
+	PermissionReadUsers    = "users:read"
+	PermissionWriteUsers   = "users:write"
+	PermissionDeleteUsers  = "users:delete"
+	PermissionReadTokens   = "tokens:read"
+	PermissionDeleteTokens = "tokens:delete"
+	PermissionRevokeTokens = "tokens:revoke"
+	PermissionReadKeys     = "keys:read"
+	PermissionWriteKeys    = "keys:write"
+	PermissionReadClients  = "clients:read"
+	PermissionWriteClients = "clients:write"
+	PermissionReadRoles    = "roles:read"
+	PermissionWriteRoles   = "roles:write"
+)
+
+var Permissions = []string{
+	PermissionReadUsers,
+	PermissionWriteUsers,
+	PermissionDeleteUsers,
+	PermissionReadTokens,
+	PermissionDeleteTokens,
+	PermissionRevokeTokens,
+	PermissionReadKeys,
+	PermissionWriteKeys,
+	PermissionReadClients,
+	PermissionWriteClients,
+	PermissionReadRoles,
+	PermissionWriteRoles,
+}
+
+// AdminRoleName is the role holding every permission, it is created by the
+// migration which replaced the admin flag of users.
+const AdminRoleName = "admin"
+
+// Role is a named set of permissions granted to users.
+type Role struct {
+	Name        string   `json:"name"`
+	Permissions []string `json:"permissions"`
+}
+
+// NewRole sorts the permissions and drops the duplicates.
+func NewRole(name string, permissions []string) (*Role, error) {
+	r := &Role{
+		Name:        name,
+		Permissions: uniqueSorted(permissions),
+	}
+
+	if err := r.validate(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func NewAdminRole() *Role {
+	return &Role{
+		Name:        AdminRoleName,
+		Permissions: uniqueSorted(Permissions),
+	}
+}
+
+func (r *Role) validate() error {
+	if r.Name == "" || len(r.Name) > 64 || strings.ContainsAny(r.Name, " \t\n/") {
+		return fmt.Errorf("invalid role name: %q", r.Name)
+	}
+	for _, permission := range r.Permissions {
+		if !Contains(Permissions, permission) {
+			return fmt.Errorf("unknown permission: %s", permission)
+		}
+	}
+
+	return nil
+}
+
+func (r *Role) HasPermission(permission string) bool {
+	return Contains(r.Permissions, permission)
+}
+
+// Grants are the roles of a user and the permissions they add up to, as
+// carried by access tokens.
+type Grants struct {
+	Roles       []string
+	Permissions []string
+}
+
+func NewGrants(roles []*Role) Grants {
+	g := Grants{Roles: []string{}, Permissions: []string{}}
+	for _, r := range roles {
+		g.Roles = append(g.Roles, r.Name)
+		g.Permissions = append(g.Permissions, r.Permissions...)
+	}
+	g.Roles = uniqueSorted(g.Roles)
+	g.Permissions = uniqueSorted(g.Permissions)
+
+	return g
+}
+
+func uniqueSorted(values []string) []string {
+	unique := []string{}
+	for _, v := range values {
+		if !Contains(unique, v) {
+			unique = append(unique, v)
+		}
+	}
+	sort.Strings(unique)
+
+	return unique
+}
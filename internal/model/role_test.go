@@ -0,0 +1,81 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModel_NewRole(t *testing.T) {
+	testCases := []struct {
+		name          string
+		roleName      string
+		permissions   []string
+		expected      []string
+		expectedError string
+	}{
+		{
+			name:        "valid",
+			roleName:    "moderator",
+			permissions: []string{PermissionWriteUsers, PermissionReadUsers, PermissionReadUsers},
+			expected:    []string{PermissionReadUsers, PermissionWriteUsers},
+		},
+		{
+			name:     "no permissions",
+			roleName: "nobody",
+			expected: []string{},
+		},
+		{
+			name:          "empty name",
+			roleName:      "",
+			expectedError: `invalid role name: ""`,
+		},
+		{
+			name:          "name with slash",
+			roleName:      "a/b",
+			expectedError: `invalid role name: "a/b"`,
+		},
+		{
+			name:          "unknown permission",
+			roleName:      "moderator",
+			permissions:   []string{"users:fly"},
+			expectedError: "unknown permission: users:fly",
+		},
+	}
+
+	for _, tc := range testCases {
+		r, err := NewRole(tc.roleName, tc.permissions)
+		if tc.expectedError != "" {
+			assert.EqualError(t, err, tc.expectedError, tc.name)
+			continue
+		}
+		assert.NoError(t, err, tc.name)
+		assert.Equal(t, tc.expected, r.Permissions, tc.name)
+	}
+}
+
+func TestModel_NewGrants(t *testing.T) {
+	reader, err := NewRole("reader", []string{PermissionReadUsers, PermissionReadTokens})
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer, err := NewRole("writer", []string{PermissionWriteUsers, PermissionReadUsers})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := NewGrants([]*Role{writer, reader})
+	assert.Equal(t, []string{"reader", "writer"}, g.Roles)
+	assert.Equal(
+		t,
+		[]string{PermissionReadTokens, PermissionReadUsers, PermissionWriteUsers},
+		g.Permissions,
+	)
+
+	g = NewGrants(nil)
+	assert.Empty(t, g.Roles)
+	assert.Empty(t, g.Permissions)
+
+	assert.True(t, NewAdminRole().HasPermission(PermissionWriteRoles))
+	assert.False(t, reader.HasPermission(PermissionWriteUsers))
+}
@@ -0,0 +1,77 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Scopes limit what an access token can be used for. Every user can obtain
+// the user scopes, the permissions of their roles are scopes as well, so that
+// a token can be limited to part of the admin routes.
+//
+// The openid scope of OpenID Connect grants the userinfo route, and has the
+// token endpoint issue an id token along with the access token. The profile
+// and email scopes select the claims of the userinfo response and of the id
+// token. Users have no name, so profile adds no claim to sub for now.
+const (
+	ScopeOpenId        = "openid"
+	ScopeProfile       = "profile"
+	ScopeEmail         = "email"
+	ScopeProfileRead   = "profile:read"
+	ScopeProfileWrite  = "profile:write"
+	ScopeSessionsRead  = "sessions:read"
+	ScopeSessionsWrite = "sessions:write"
+	ScopeApiKeysRead   = "api-keys:read"
+	ScopeApiKeysWrite  = "api-keys:write"
+)
+
+var UserScopes = []string{
+	ScopeOpenId,
+	ScopeProfile,
+	ScopeEmail,
+	ScopeProfileRead,
+	ScopeProfileWrite,
+	ScopeSessionsRead,
+	ScopeSessionsWrite,
+	ScopeApiKeysRead,
+	ScopeApiKeysWrite,
+}
+
+// Scopes returns every scope a user with the grants can obtain.
+func (g Grants) Scopes() []string {
+	return uniqueSorted(append(append([]string{}, UserScopes...), g.Permissions...))
+}
+
+// GrantScope returns the requested scope when every scope in it is allowed,
+// every allowed scope when none is requested.
+func GrantScope(allowed []string, requested string) (string, error) {
+	scopes := strings.Fields(requested)
+	if len(scopes) == 0 {
+		return strings.Join(allowed, " "), nil
+	}
+	for _, scope := range scopes {
+		if !Contains(allowed, scope) {
+			return "", fmt.Errorf("invalid scope: %s", scope)
+		}
+	}
+
+	return strings.Join(uniqueSorted(scopes), " "), nil
+}
+
+// FilterScopes returns the scopes which are part of the space separated scope.
+func FilterScopes(scopes []string, scope string) []string {
+	granted := strings.Fields(scope)
+	filtered := []string{}
+	for _, s := range scopes {
+		if Contains(granted, s) {
+			filtered = append(filtered, s)
+		}
+	}
+
+	return filtered
+}
+
+// hasScope reports whether the space separated scope contains the scope.
+func hasScope(scope string, s string) bool {
+	return Contains(strings.Fields(scope), s)
+}
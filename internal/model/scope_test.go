@@ -0,0 +1,77 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModel_GrantScope(t *testing.T) {
+	role, err := NewRole("support", []string{PermissionReadUsers})
+	if err != nil {
+		t.Fatal(err)
+	}
+	allowed := NewGrants([]*Role{role}).Scopes()
+	assert.Equal(
+		t,
+		[]string{
+			ScopeApiKeysRead,
+			ScopeApiKeysWrite,
+			ScopeEmail,
+			ScopeOpenId,
+			ScopeProfile,
+			ScopeProfileRead,
+			ScopeProfileWrite,
+			ScopeSessionsRead,
+			ScopeSessionsWrite,
+			PermissionReadUsers,
+		},
+		allowed,
+	)
+
+	testCases := []struct {
+		name             string
+		requested        string
+		expectedScope    string
+		expectedErrorMsg string
+	}{
+		{
+			name:      "no scope requested",
+			requested: "",
+			expectedScope: "api-keys:read api-keys:write email openid profile profile:read " +
+				"profile:write sessions:read sessions:write users:read",
+		},
+		{
+			name:          "subset",
+			requested:     "users:read profile:read users:read",
+			expectedScope: "profile:read users:read",
+		},
+		{
+			name:             "permission of another role",
+			requested:        "profile:read users:delete",
+			expectedErrorMsg: "invalid scope: users:delete",
+		},
+	}
+
+	for _, tc := range testCases {
+		scope, err := GrantScope(allowed, tc.requested)
+		if tc.expectedErrorMsg == "" {
+			assert.NoError(t, err, tc.name)
+			assert.Equal(t, tc.expectedScope, scope, tc.name)
+		} else {
+			assert.EqualError(t, err, tc.expectedErrorMsg, tc.name)
+		}
+	}
+}
+
+func TestModel_FilterScopes(t *testing.T) {
+	assert.Equal(
+		t,
+		[]string{ScopeProfileRead, PermissionReadUsers},
+		FilterScopes(
+			[]string{ScopeProfileRead, ScopeProfileWrite, PermissionReadUsers},
+			"users:read profile:read users:delete",
+		),
+	)
+	assert.Empty(t, FilterScopes([]string{ScopeProfileRead}, ""))
+}
@@ -0,0 +1,30 @@
+package model
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// GenerateSecret returns a random url safe string holding 256 bits.
+func GenerateSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashSecret is not keyed nor salted, which is only safe for the high entropy
+// secrets produced by GenerateSecret.
+func HashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func verifySecret(secretHash string, secret string) bool {
+	return subtle.ConstantTimeCompare([]byte(secretHash), []byte(HashSecret(secret))) == 1
+}
@@ -0,0 +1,222 @@
+package model
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/twinj/uuid"
+)
+
+// SigningKey pairs a JWT signing method with the key material used to sign and
+// verify tokens. For HMAC methods both keys hold the shared secret.
+// A retired key has a non-zero Expires and is only used for verification.
+type SigningKey struct {
+	Kid        string
+	Method     jwt.SigningMethod
+	PrivateKey interface{}
+	PublicKey  interface{}
+	Created    time.Time
+	Expires    time.Time
+}
+
+func NewHMACSigningKey(kid string, secret string) *SigningKey {
+	return &SigningKey{
+		Kid:        kid,
+		Method:     jwt.SigningMethodHS256,
+		PrivateKey: []byte(secret),
+		PublicKey:  []byte(secret),
+	}
+}
+
+// LoadSigningKey reads a PEM encoded private key from path and binds it to the
+// signing method named by alg (RS256, ES256 or EdDSA).
+func LoadSigningKey(kid string, alg string, path string) (*SigningKey, error) {
+	pemBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseSigningKey(kid, alg, pemBytes)
+}
+
+// ParseSigningKey is the inverse of EncodePrivateKey: keyData is the raw
+// secret for HS256 and a PEM encoded private key otherwise.
+func ParseSigningKey(kid string, alg string, keyData []byte) (*SigningKey, error) {
+	if alg == jwt.SigningMethodHS256.Alg() {
+		return NewHMACSigningKey(kid, string(keyData)), nil
+	}
+
+	block, _ := pem.Decode(keyData)
+	if block == nil {
+		return nil, errors.New("invalid PEM encoded key")
+	}
+
+	privateKey, err := parsePrivateKey(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewSigningKey(kid, alg, privateKey)
+}
+
+func NewSigningKey(kid string, alg string, privateKey crypto.Signer) (*SigningKey, error) {
+	k := &SigningKey{
+		Kid:        kid,
+		PrivateKey: privateKey,
+		PublicKey:  privateKey.Public(),
+	}
+
+	switch key := privateKey.(type) {
+	case *rsa.PrivateKey:
+		k.Method = jwt.SigningMethodRS256
+		if key.N.BitLen() < 2048 {
+			return nil, errors.New("RSA key must be at least 2048 bits")
+		}
+	case *ecdsa.PrivateKey:
+		k.Method = jwt.SigningMethodES256
+		if key.Curve != elliptic.P256() {
+			return nil, errors.New("ES256 requires a P-256 key")
+		}
+	case ed25519.PrivateKey:
+		k.Method = SigningMethodEdDSA
+	default:
+		return nil, errors.New("unsupported private key type")
+	}
+
+	if alg != k.Method.Alg() {
+		return nil, fmt.Errorf("%s key cannot be used with %s", k.Method.Alg(), alg)
+	}
+
+	return k, nil
+}
+
+// GenerateSigningKey creates a fresh key for alg identified by a random kid.
+func GenerateSigningKey(alg string, now time.Time) (*SigningKey, error) {
+	kid := uuid.NewV4().String()
+
+	var privateKey crypto.Signer
+	var err error
+	switch alg {
+	case jwt.SigningMethodHS256.Alg():
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+		k := NewHMACSigningKey(kid, base64.RawURLEncoding.EncodeToString(secret))
+		k.Created = now
+		return k, nil
+	case jwt.SigningMethodRS256.Alg():
+		privateKey, err = rsa.GenerateKey(rand.Reader, 2048)
+	case jwt.SigningMethodES256.Alg():
+		privateKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case SigningMethodEdDSA.Alg():
+		_, privateKey, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("unsupported signing method: %s", alg)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	k, err := NewSigningKey(kid, alg, privateKey)
+	if err != nil {
+		return nil, err
+	}
+	k.Created = now
+
+	return k, nil
+}
+
+func (k *SigningKey) EncodePrivateKey() ([]byte, error) {
+	if k.Symmetric() {
+		return k.PrivateKey.([]byte), nil
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(k.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// SameKey reports whether both keys hold the same key material for the same
+// algorithm, whatever their kid.
+func (k *SigningKey) SameKey(other *SigningKey) bool {
+	if k.Method.Alg() != other.Method.Alg() {
+		return false
+	}
+	keyData, err := k.EncodePrivateKey()
+	if err != nil {
+		return false
+	}
+	otherKeyData, err := other.EncodePrivateKey()
+	if err != nil {
+		return false
+	}
+
+	return bytes.Equal(keyData, otherKeyData)
+}
+
+func (k *SigningKey) Retired() bool {
+	return !k.Expires.IsZero()
+}
+
+func (k *SigningKey) Expired(now time.Time) bool {
+	return k.Retired() && !now.Before(k.Expires)
+}
+
+// Symmetric reports whether the key is a shared secret that must never be
+// published.
+func (k *SigningKey) Symmetric() bool {
+	_, ok := k.Method.(*jwt.SigningMethodHMAC)
+	return ok
+}
+
+func (k *SigningKey) Sign(token *jwt.Token) (string, error) {
+	token.Header["kid"] = k.Kid
+	return token.SignedString(k.PrivateKey)
+}
+
+// Keyfunc is passed to jwt.Parse to verify a token with this key, rejecting any
+// token signed with a different algorithm.
+func (k *SigningKey) Keyfunc(token *jwt.Token) (interface{}, error) {
+	if token.Method.Alg() != k.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return k.PublicKey, nil
+}
+
+func parsePrivateKey(block *pem.Block) (crypto.Signer, error) {
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, errors.New("unsupported private key type")
+		}
+		return signer, nil
+	}
+
+	return nil, fmt.Errorf("unsupported PEM block type: %s", block.Type)
+}
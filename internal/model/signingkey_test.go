@@ -0,0 +1,216 @@
+package model
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func encodeTestKey(t *testing.T, blockType string, key crypto.Signer) []byte {
+	t.Helper()
+
+	var der []byte
+	var err error
+	switch blockType {
+	case "RSA PRIVATE KEY":
+		der = x509.MarshalPKCS1PrivateKey(key.(*rsa.PrivateKey))
+	case "EC PRIVATE KEY":
+		der, err = x509.MarshalECPrivateKey(key.(*ecdsa.PrivateKey))
+	default:
+		der, err = x509.MarshalPKCS8PrivateKey(key)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+}
+
+func TestModel_ParseSigningKey(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecP384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name             string
+		alg              string
+		pem              []byte
+		expectedKty      string
+		expectedErrorMsg string
+	}{
+		{
+			name:        "RS256 PKCS1",
+			alg:         "RS256",
+			pem:         encodeTestKey(t, "RSA PRIVATE KEY", rsaKey),
+			expectedKty: "RSA",
+		},
+		{
+			name:        "RS256 PKCS8",
+			alg:         "RS256",
+			pem:         encodeTestKey(t, "PRIVATE KEY", rsaKey),
+			expectedKty: "RSA",
+		},
+		{
+			name:        "ES256 SEC1",
+			alg:         "ES256",
+			pem:         encodeTestKey(t, "EC PRIVATE KEY", ecKey),
+			expectedKty: "EC",
+		},
+		{
+			name:        "EdDSA PKCS8",
+			alg:         "EdDSA",
+			pem:         encodeTestKey(t, "PRIVATE KEY", edKey),
+			expectedKty: "OKP",
+		},
+		{
+			name:             "algorithm does not match key",
+			alg:              "ES256",
+			pem:              encodeTestKey(t, "RSA PRIVATE KEY", rsaKey),
+			expectedErrorMsg: "RS256 key cannot be used with ES256",
+		},
+		{
+			name:             "wrong curve",
+			alg:              "ES256",
+			pem:              encodeTestKey(t, "EC PRIVATE KEY", ecP384Key),
+			expectedErrorMsg: "ES256 requires a P-256 key",
+		},
+		{
+			name:             "unsupported algorithm",
+			alg:              "none",
+			pem:              encodeTestKey(t, "PRIVATE KEY", edKey),
+			expectedErrorMsg: "EdDSA key cannot be used with none",
+		},
+		{
+			name:             "invalid PEM",
+			alg:              "RS256",
+			pem:              []byte("invalid"),
+			expectedErrorMsg: "invalid PEM encoded key",
+		},
+	}
+
+	for _, tc := range testCases {
+		key, err := ParseSigningKey("test", tc.alg, tc.pem)
+		if tc.expectedErrorMsg != "" {
+			assert.EqualError(t, err, tc.expectedErrorMsg, tc.name)
+			continue
+		}
+		if !assert.NoError(t, err, tc.name) {
+			continue
+		}
+		assert.False(t, key.Symmetric(), tc.name)
+
+		token := jwt.NewWithClaims(key.Method, jwt.MapClaims{"user_id": 1})
+		tokenString, err := key.Sign(token)
+		assert.NoError(t, err, tc.name)
+
+		parsedToken, err := jwt.Parse(tokenString, key.Keyfunc)
+		assert.NoError(t, err, tc.name)
+		assert.True(t, parsedToken.Valid, tc.name)
+
+		jwk, err := key.JWK()
+		assert.NoError(t, err, tc.name)
+		assert.Equal(t, tc.expectedKty, jwk.Kty, tc.name)
+		assert.Equal(t, tc.alg, jwk.Alg, tc.name)
+	}
+}
+
+func TestModel_SigningKeyRejectsOtherAlgorithm(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := NewSigningKey("test", "EdDSA", edKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hmacKey := NewHMACSigningKey("test", "test_secret")
+	tokenString, err := hmacKey.Sign(jwt.New(hmacKey.Method))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = jwt.Parse(tokenString, key.Keyfunc)
+	assert.EqualError(t, err, "unexpected signing method: HS256")
+
+	assert.True(t, hmacKey.Symmetric())
+	_, err = hmacKey.JWK()
+	assert.EqualError(t, err, "key cannot be published")
+}
+
+func TestModel_GenerateSigningKey(t *testing.T) {
+	now := time.Date(2000, time.January, 1, 0, 0, 0, 0, time.Local)
+
+	for _, alg := range []string{"HS256", "RS256", "ES256", "EdDSA"} {
+		key, err := GenerateSigningKey(alg, now)
+		if !assert.NoError(t, err, alg) {
+			continue
+		}
+		assert.NotEmpty(t, key.Kid, alg)
+		assert.Equal(t, now, key.Created, alg)
+		assert.False(t, key.Retired(), alg)
+
+		// The persisted form must restore an equivalent key
+		keyData, err := key.EncodePrivateKey()
+		assert.NoError(t, err, alg)
+		parsedKey, err := ParseSigningKey(key.Kid, alg, keyData)
+		assert.NoError(t, err, alg)
+
+		tokenString, err := key.Sign(jwt.New(key.Method))
+		assert.NoError(t, err, alg)
+		token, err := jwt.Parse(tokenString, parsedKey.Keyfunc)
+		assert.NoError(t, err, alg)
+		assert.Equal(t, key.Kid, token.Header["kid"], alg)
+	}
+
+	_, err := GenerateSigningKey("none", now)
+	assert.EqualError(t, err, "unsupported signing method: none")
+}
+
+func TestModel_SigningKeySameKey(t *testing.T) {
+	now := time.Now()
+	key, err := GenerateSigningKey("ES256", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyData, err := key.EncodePrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsedKey, err := ParseSigningKey("other_kid", "ES256", keyData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, key.SameKey(parsedKey))
+
+	otherKey, err := GenerateSigningKey("ES256", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, key.SameKey(otherKey))
+	assert.False(t, NewHMACSigningKey("kid", "secret").SameKey(key))
+	assert.True(t, NewHMACSigningKey("kid", "secret").SameKey(NewHMACSigningKey("other", "secret")))
+}
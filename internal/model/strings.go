@@ -0,0 +1,12 @@
+package model
+
+// Contains reports whether value is one of values.
+func Contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
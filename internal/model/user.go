@@ -9,25 +9,23 @@ import (
 type User struct {
 	ID              int64  `json:"id"`
 	Email           string `json:"email"`
-	Password        string `json:"password"`
+	Password        string `json:"-"`
 	Active          bool   `json:"active"`
 	EmailVerified   bool   `json:"email_verified"`
 	EmailSubscribed bool   `json:"email_subscribed"`
-	Admin           bool   `json:"admin"`
 	// Move to user monitoring service?
 	Created    time.Time `json:"created"`
 	LastLogin  time.Time `json:"last_login"`
 	LastAction time.Time `json:"last_action"`
 }
 
-func NewUser(email string, password string, admin bool, now time.Time) (*User, error) {
+func NewUser(email string, password string, now time.Time) (*User, error) {
 	u := &User{
 		Email:           email,
 		Password:        password,
 		Active:          true,
 		EmailVerified:   false,
 		EmailSubscribed: true,
-		Admin:           admin,
 		Created:         now,
 		LastLogin:       now,
 		LastAction:      now,
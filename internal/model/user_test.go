@@ -1,6 +1,7 @@
 package model
 
 import (
+	"encoding/json"
 	"testing"
 	"time"
 
@@ -55,7 +56,6 @@ func TestUser_Validate(t *testing.T) {
 		user, err := NewUser(
 			tc.credentials.Email,
 			tc.credentials.Password,
-			false,
 			time.Date(2000, time.January, 1, 0, 0, 0, 0, time.Local),
 		)
 
@@ -68,3 +68,16 @@ func TestUser_Validate(t *testing.T) {
 		}
 	}
 }
+
+func TestUser_MarshalOmitsPassword(t *testing.T) {
+	u, err := NewUser("test@test.test", "test_password_hash", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := json.Marshal(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NotContains(t, string(b), "password")
+}
@@ -0,0 +1,99 @@
+package model
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/twinj/uuid"
+)
+
+const EmailVerificationTokenUse = "email_verification"
+
+// EmailVerificationClaims are the claims of the token sent to the address of
+// a user to verify it. The token is bound to the address, so it is spent once
+// the address is verified or changed.
+//
+// The token is also single use: its id is persisted as an EmailVerification,
+// which is consumed when the address is verified.
+type EmailVerificationClaims struct {
+	RegisteredClaims
+	Email string `json:"email"`
+}
+
+func (c *EmailVerificationClaims) Valid() error {
+	if err := c.validTokenUse(EmailVerificationTokenUse); err != nil {
+		return err
+	}
+	if c.Email == "" {
+		return errors.New("token is missing required claims")
+	}
+
+	return nil
+}
+
+// Verifies reports whether the token verifies the current address of the user.
+func (c *EmailVerificationClaims) Verifies(user *User) bool {
+	return !user.EmailVerified &&
+		c.Subject == strconv.FormatInt(user.ID, 10) &&
+		c.Email == user.Email
+}
+
+// EmailVerification is the record of a verification token, without which the
+// token is refused.
+type EmailVerification struct {
+	TokenId string
+	UserId  int64
+	Expires int64
+}
+
+// NewEmailVerificationToken returns the token along with the record to
+// persist for it.
+func NewEmailVerificationToken(
+	user *User,
+	key *SigningKey,
+	params TokenParams,
+) (*EmailVerification, string, error) {
+	now := time.Now()
+	verification := &EmailVerification{
+		TokenId: uuid.NewV4().String(),
+		UserId:  user.ID,
+		Expires: now.Add(params.Lifetime).Unix(),
+	}
+	claims := &EmailVerificationClaims{
+		RegisteredClaims: newRegisteredClaims(
+			strconv.FormatInt(user.ID, 10),
+			verification.TokenId,
+			EmailVerificationTokenUse,
+			params,
+			now.Unix(),
+			verification.Expires,
+		),
+		Email: user.Email,
+	}
+
+	token, err := key.Sign(jwt.NewWithClaims(key.Method, claims))
+	if err != nil {
+		return nil, "", err
+	}
+
+	return verification, token, nil
+}
+
+func ParseEmailVerificationToken(
+	tokenString string,
+	keyfunc jwt.Keyfunc,
+	issuer string,
+	audience string,
+) (*EmailVerificationClaims, error) {
+	claims := &EmailVerificationClaims{}
+	if _, err := jwt.ParseWithClaims(tokenString, claims, keyfunc); err != nil {
+		return nil, err
+	}
+	if err := claims.VerifyIssuerAudience(issuer, audience); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
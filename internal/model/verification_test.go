@@ -0,0 +1,53 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModel_NewEmailVerificationToken(t *testing.T) {
+	u, err := NewUser(
+		"test@test.test",
+		"test_password",
+		time.Date(2000, time.January, 1, 0, 0, 0, 0, time.Local),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u.ID = 1
+
+	key := NewHMACSigningKey("test", "test_secret")
+	params := TokenParams{Issuer: "test", Audience: Audience{"test"}, Lifetime: time.Minute}
+	verification, token, err := NewEmailVerificationToken(u, key, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims, err := ParseEmailVerificationToken(token, key.Keyfunc, "test", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "test@test.test", claims.Email)
+	assert.Equal(t, verification.TokenId, claims.Id)
+	assert.Equal(t, u.ID, verification.UserId)
+	assert.Equal(t, claims.ExpiresAt, verification.Expires)
+	assert.True(t, claims.Verifies(u))
+
+	// The token is spent once the address is verified or changed
+	changed := *u
+	changed.Email = "other@test.test"
+	assert.False(t, claims.Verifies(&changed))
+	verified := *u
+	verified.EmailVerified = true
+	assert.False(t, claims.Verifies(&verified))
+
+	// Refresh tokens signed with the same key are not verification tokens
+	rt, err := NewRefreshToken(u, key, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ParseEmailVerificationToken(rt.TokenString, key.Keyfunc, "test", "test")
+	assert.Error(t, err)
+}
@@ -0,0 +1,100 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStore_InsertApiKey(t *testing.T, s Store) {
+	testUsers := CreateTestUser(t, s, 2, false)
+	testKeys, plainKeys := CreateTestApiKey(t, s, testUsers[0], 2, time.Hour)
+	CreateTestApiKey(t, s, testUsers[1], 1, 0)
+
+	k, err := s.ApiKey().GetById(testKeys[1].Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, testKeys[1], k)
+	assert.Empty(t, k.Key)
+	assert.True(t, k.Verify(plainKeys[1]))
+
+	k, err = s.ApiKey().GetByPrefix(testKeys[0].Prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, testKeys[0], k)
+
+	keys, err := s.ApiKey().GetByUser(testUsers[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, testKeys, keys)
+
+	_, err = s.ApiKey().GetByPrefix("missing")
+	assert.Error(t, err)
+}
+
+func TestStore_TouchApiKey(t *testing.T, s Store) {
+	testUser := CreateTestUser(t, s, 1, false)[0]
+	testKeys, _ := CreateTestApiKey(t, s, testUser, 2, 0)
+
+	assert.NoError(t, s.ApiKey().Touch(testKeys[0].Id, 42))
+	assert.Error(t, s.ApiKey().Touch("5c7f6d5e-8bd9-4a3e-9a39-1f3f0b1f5a01", 42))
+
+	k, err := s.ApiKey().GetById(testKeys[0].Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, int64(42), k.LastUsed)
+	k, err = s.ApiKey().GetById(testKeys[1].Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Zero(t, k.LastUsed)
+}
+
+func TestStore_DeleteApiKey(t *testing.T, s Store) {
+	testUsers := CreateTestUser(t, s, 2, false)
+	testKeys, _ := CreateTestApiKey(t, s, testUsers[0], 2, 0)
+	otherKeys, _ := CreateTestApiKey(t, s, testUsers[1], 1, 0)
+
+	assert.NoError(t, s.ApiKey().Delete(testKeys[0].Id))
+	assert.Error(t, s.ApiKey().Delete(testKeys[0].Id))
+	keys, err := s.ApiKey().GetByUser(testUsers[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, testKeys[1:], keys)
+
+	assert.NoError(t, s.ApiKey().DeleteByUser(testUsers[0].ID))
+	keys, err = s.ApiKey().GetByUser(testUsers[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, keys)
+	keys, err = s.ApiKey().GetByUser(testUsers[1].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, otherKeys, keys)
+}
+
+func TestStore_DeleteExpiredApiKeys(t *testing.T, s Store) {
+	testUser := CreateTestUser(t, s, 1, false)[0]
+	expiredKeys, _ := CreateTestApiKey(t, s, testUser, 1, time.Millisecond)
+	activeKeys, _ := CreateTestApiKey(t, s, testUser, 1, time.Hour)
+	permanentKeys, _ := CreateTestApiKey(t, s, testUser, 1, 0)
+
+	count, err := s.ApiKey().DeleteExpired(time.Now().Add(time.Minute).Unix())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	_, err = s.ApiKey().GetById(expiredKeys[0].Id)
+	assert.Error(t, err)
+	_, err = s.ApiKey().GetById(activeKeys[0].Id)
+	assert.NoError(t, err)
+	_, err = s.ApiKey().GetById(permanentKeys[0].Id)
+	assert.NoError(t, err)
+}
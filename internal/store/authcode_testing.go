@@ -0,0 +1,40 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStore_ConsumeAuthCode(t *testing.T, s Store) {
+	testUser := CreateTestUser(t, s, 1, false)[0]
+	testClient := CreateTestClient(t, s, 1)[0]
+	testCode, _ := CreateTestAuthCode(t, s, testClient, testUser, time.Minute)
+
+	code, err := s.AuthCode().Consume(testCode.CodeHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, testCode, code)
+
+	// A code can only be consumed once
+	_, err = s.AuthCode().Consume(testCode.CodeHash)
+	assert.Error(t, err)
+}
+
+func TestStore_DeleteExpiredAuthCodes(t *testing.T, s Store) {
+	testUser := CreateTestUser(t, s, 1, false)[0]
+	testClient := CreateTestClient(t, s, 1)[0]
+	expiredCode, _ := CreateTestAuthCode(t, s, testClient, testUser, -time.Minute)
+	activeCode, _ := CreateTestAuthCode(t, s, testClient, testUser, time.Minute)
+
+	count, err := s.AuthCode().DeleteExpired(time.Now().Unix())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	_, err = s.AuthCode().Consume(expiredCode.CodeHash)
+	assert.Error(t, err)
+	_, err = s.AuthCode().Consume(activeCode.CodeHash)
+	assert.NoError(t, err)
+}
@@ -9,11 +9,14 @@ import (
 
 func TestStore_InsertRefreshToken(t *testing.T, s Store) {
 	testUser := CreateTestUser(t, s, 1, false)[0]
-	testToken, err := model.NewRefreshToken(testUser)
+	testToken, err := model.NewRefreshToken(testUser, GetTestSigningKey(t), GetTestTokenParams())
 	if err != nil {
 		t.Fatal(err)
 	}
-	err = s.AuthToken().Insert(testToken.Uuid, testToken.TokenString, testToken.Expires)
+	testToken = testToken.Hashed(GetTestTokenHashKey())
+	testToken.ClientIp = "192.0.2.1"
+	testToken.UserAgent = "test-agent"
+	err = s.AuthToken().Insert(testToken)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -23,6 +26,8 @@ func TestStore_InsertRefreshToken(t *testing.T, s Store) {
 		t.Fatal(err)
 	}
 	assert.Equal(t, testToken, insertedToken)
+	assert.Empty(t, insertedToken.TokenString)
+	assert.NotEmpty(t, insertedToken.TokenHash)
 }
 
 func TestStore_GetAllToken(t *testing.T, s Store) {
@@ -97,3 +102,113 @@ func TestStore_DeleteToken(t *testing.T, s Store) {
 
 	assert.NotContains(t, tokens, testTokenToRemove)
 }
+
+func TestStore_ConsumeToken(t *testing.T, s Store) {
+	testUser := CreateTestUser(t, s, 1, false)[0]
+	testToken := CreateTestToken(t, s, 1, testUser)[0]
+
+	consumed, err := s.AuthToken().Consume(testToken.Uuid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, consumed)
+
+	token, err := s.AuthToken().GetById(testToken.Uuid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, token.Consumed)
+
+	consumed, err = s.AuthToken().Consume(testToken.Uuid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, consumed)
+}
+
+func TestStore_DeleteTokenFamily(t *testing.T, s Store) {
+	testUser := CreateTestUser(t, s, 1, false)[0]
+	testTokens := CreateTestToken(t, s, 3, testUser)
+
+	familyToken, err := model.NewRefreshToken(testUser, GetTestSigningKey(t), GetTestTokenParams())
+	if err != nil {
+		t.Fatal(err)
+	}
+	familyToken = familyToken.Hashed(GetTestTokenHashKey())
+	familyToken.Family = testTokens[0].Family
+	if err := s.AuthToken().Insert(familyToken); err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.AuthToken().DeleteFamily(testTokens[0].Family)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tokens, err := s.AuthToken().GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, testTokens[1:], tokens)
+}
+
+func TestStore_DeleteTokensByUser(t *testing.T, s Store) {
+	testUsers := CreateTestUser(t, s, 2, false)
+	CreateTestToken(t, s, 3, testUsers[0])
+	otherUserTokens := CreateTestToken(t, s, 2, testUsers[1])
+
+	err := s.AuthToken().DeleteByUser(testUsers[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tokens, err := s.AuthToken().GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, otherUserTokens, tokens)
+}
+
+func TestStore_GetTokensByUser(t *testing.T, s Store) {
+	testUsers := CreateTestUser(t, s, 2, false)
+	userTokens := CreateTestToken(t, s, 3, testUsers[0])
+	CreateTestToken(t, s, 2, testUsers[1])
+
+	tokens, err := s.AuthToken().GetByUser(testUsers[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.ElementsMatch(t, userTokens, tokens)
+
+	tokens, err = s.AuthToken().GetByUser(testUsers[1].ID + 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, tokens)
+}
+
+func TestStore_DeleteExpiredTokens(t *testing.T, s Store) {
+	testUser := CreateTestUser(t, s, 1, false)[0]
+	testTokens := CreateTestToken(t, s, 3, testUser)
+
+	count, err := s.AuthToken().DeleteExpired(testTokens[0].Expires - 1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+
+	expiredToken := *testTokens[0]
+	expiredToken.Uuid = "5c7f6d5e-8bd9-4a3e-9a39-1f3f0b1f5a03"
+	expiredToken.Expires = 100
+	if err := s.AuthToken().Insert(&expiredToken); err != nil {
+		t.Fatal(err)
+	}
+
+	count, err = s.AuthToken().DeleteExpired(100)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	tokens, err := s.AuthToken().GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, testTokens, tokens)
+}
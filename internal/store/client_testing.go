@@ -0,0 +1,89 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStore_InsertClient(t *testing.T, s Store) {
+	testClients := CreateTestClient(t, s, 2)
+
+	client, err := s.Client().GetById(testClients[1].Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, testClients[1], client)
+
+	clients, err := s.Client().GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, testClients, clients)
+}
+
+func TestStore_InsertServiceClient(t *testing.T, s Store) {
+	testClient, secret := CreateTestServiceClient(t, s, []string{"tts", "billing"})
+
+	client, err := s.Client().GetById(testClient.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, testClient, client)
+	assert.True(t, client.VerifySecret(secret))
+}
+
+func TestStore_UpdateClient(t *testing.T, s Store) {
+	testClients := CreateTestClient(t, s, 2)
+
+	updatedClient := *testClients[0]
+	updatedClient.Name = "updated_client"
+	updatedClient.RedirectUris = []string{"https://updated.test/callback"}
+	updatedClient.Scopes = []string{"tts"}
+	secret, err := updatedClient.RotateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Client().Update(&updatedClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := s.Client().GetById(testClients[0].Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, &updatedClient, client)
+	assert.True(t, client.VerifySecret(secret))
+
+	client, err = s.Client().GetById(testClients[1].Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, testClients[1], client)
+
+	updatedClient.Id = "5c7f6d5e-8bd9-4a3e-9a39-1f3f0b1f5a01"
+	err = s.Client().Update(&updatedClient)
+	assert.Error(t, err)
+}
+
+func TestStore_DeleteClient(t *testing.T, s Store) {
+	testClients := CreateTestClient(t, s, 2)
+
+	err := s.Client().Delete(testClients[0].Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = s.Client().GetById(testClients[0].Id)
+	assert.Error(t, err)
+
+	clients, err := s.Client().GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, testClients[1:], clients)
+
+	err = s.Client().Delete(testClients[0].Id)
+	assert.Error(t, err)
+}
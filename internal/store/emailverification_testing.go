@@ -0,0 +1,54 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStore_ConsumeEmailVerification(t *testing.T, s Store) {
+	testUser := CreateTestUser(t, s, 1, false)[0]
+	testVerification := CreateTestEmailVerification(t, s, testUser, time.Hour)
+
+	verification, err := s.EmailVerification().Consume(testVerification.TokenId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, testVerification, verification)
+
+	// A token can only be used once
+	_, err = s.EmailVerification().Consume(testVerification.TokenId)
+	assert.Error(t, err)
+}
+
+func TestStore_DeleteEmailVerificationsByUser(t *testing.T, s Store) {
+	testUsers := CreateTestUser(t, s, 2, false)
+	firstVerification := CreateTestEmailVerification(t, s, testUsers[0], time.Hour)
+	secondVerification := CreateTestEmailVerification(t, s, testUsers[0], time.Hour)
+	otherVerification := CreateTestEmailVerification(t, s, testUsers[1], time.Hour)
+
+	assert.NoError(t, s.EmailVerification().DeleteByUser(testUsers[0].ID))
+
+	_, err := s.EmailVerification().Consume(firstVerification.TokenId)
+	assert.Error(t, err)
+	_, err = s.EmailVerification().Consume(secondVerification.TokenId)
+	assert.Error(t, err)
+	_, err = s.EmailVerification().Consume(otherVerification.TokenId)
+	assert.NoError(t, err)
+}
+
+func TestStore_DeleteExpiredEmailVerifications(t *testing.T, s Store) {
+	testUser := CreateTestUser(t, s, 1, false)[0]
+	expiredVerification := CreateTestEmailVerification(t, s, testUser, -time.Minute)
+	activeVerification := CreateTestEmailVerification(t, s, testUser, time.Minute)
+
+	count, err := s.EmailVerification().DeleteExpired(time.Now().Unix())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	_, err = s.EmailVerification().Consume(expiredVerification.TokenId)
+	assert.Error(t, err)
+	_, err = s.EmailVerification().Consume(activeVerification.TokenId)
+	assert.NoError(t, err)
+}
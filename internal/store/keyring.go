@@ -0,0 +1,65 @@
+package store
+
+import (
+	"errors"
+	"time"
+
+	"github.com/anoobz/dualread/auth/internal/model"
+)
+
+// keyRingReloadInterval is the shortest time between the reloads of a key ring
+// triggered by tokens signed with an unknown key.
+const keyRingReloadInterval = 10 * time.Second
+
+// LoadKeyRing builds the key ring for purpose from the keys persisted in repo.
+// When none exist yet, the fallback key from the service configuration is
+// persisted as the first active key. When the algorithm of the configuration
+// changed, the ring is rotated to the fallback key so that the change takes
+// effect. tokenLifetime is the longest lifetime of the tokens signed by the
+// keys.
+func LoadKeyRing(
+	repo SigningKeyRepo,
+	purpose string,
+	fallback *model.SigningKey,
+	tokenLifetime time.Duration,
+	now time.Time,
+) (*model.KeyRing, error) {
+	keys, err := repo.GetAll(purpose)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(keys) == 0 {
+		fallback.Created = now
+		if err := repo.Insert(purpose, fallback); err != nil {
+			return nil, err
+		}
+		keys = append(keys, fallback)
+	}
+
+	ring, err := model.NewKeyRing(keys, tokenLifetime)
+	if err != nil {
+		return nil, err
+	}
+
+	active := ring.Active()
+	if active.Method.Alg() != fallback.Method.Alg() {
+		fallback.Created = now
+		err := repo.Rotate(purpose, active.Kid, fallback, now.Add(tokenLifetime))
+		if err != nil && !errors.Is(err, ErrKeyRotated) {
+			return nil, err
+		}
+		keys, err := repo.GetAll(purpose)
+		if err != nil {
+			return nil, err
+		}
+		if err := ring.Set(keys); err != nil {
+			return nil, err
+		}
+	}
+	ring.SetLoader(func() ([]*model.SigningKey, error) {
+		return repo.GetAll(purpose)
+	}, keyRingReloadInterval)
+
+	return ring, nil
+}
@@ -0,0 +1,99 @@
+package mockstore
+
+import (
+	"errors"
+
+	"github.com/anoobz/dualread/auth/internal/model"
+)
+
+type MockApiKeyRepo struct {
+	apiKeys []*model.ApiKey
+}
+
+func (r *MockApiKeyRepo) GetById(id string) (*model.ApiKey, error) {
+	for _, k := range r.apiKeys {
+		if k.Id == id {
+			return k, nil
+		}
+	}
+
+	return nil, errors.New("sql: no rows in result set")
+}
+
+func (r *MockApiKeyRepo) GetByPrefix(prefix string) (*model.ApiKey, error) {
+	for _, k := range r.apiKeys {
+		if k.Prefix == prefix {
+			return k, nil
+		}
+	}
+
+	return nil, errors.New("sql: no rows in result set")
+}
+
+func (r *MockApiKeyRepo) GetByUser(userId int64) ([]*model.ApiKey, error) {
+	keys := []*model.ApiKey{}
+	for _, k := range r.apiKeys {
+		if k.UserId == userId {
+			keys = append(keys, k)
+		}
+	}
+
+	return keys, nil
+}
+
+func (r *MockApiKeyRepo) Insert(key *model.ApiKey) error {
+	if _, err := r.GetByPrefix(key.Prefix); err == nil {
+		return errors.New("api key prefix already exists")
+	}
+
+	k := *key
+	k.Key = ""
+	r.apiKeys = append(r.apiKeys, &k)
+	return nil
+}
+
+func (r *MockApiKeyRepo) Touch(id string, lastUsed int64) error {
+	k, err := r.GetById(id)
+	if err != nil {
+		return err
+	}
+	k.LastUsed = lastUsed
+
+	return nil
+}
+
+func (r *MockApiKeyRepo) Delete(id string) error {
+	for i, k := range r.apiKeys {
+		if k.Id == id {
+			r.apiKeys = append(r.apiKeys[:i], r.apiKeys[i+1:]...)
+			return nil
+		}
+	}
+
+	return errors.New("sql: no rows in result set")
+}
+
+func (r *MockApiKeyRepo) DeleteByUser(userId int64) error {
+	keys := []*model.ApiKey{}
+	for _, k := range r.apiKeys {
+		if k.UserId != userId {
+			keys = append(keys, k)
+		}
+	}
+	r.apiKeys = keys
+
+	return nil
+}
+
+func (r *MockApiKeyRepo) DeleteExpired(now int64) (int64, error) {
+	keys := []*model.ApiKey{}
+	for _, k := range r.apiKeys {
+		if k.Expires == 0 || k.Expires > now {
+			keys = append(keys, k)
+		}
+	}
+	deletedCount := int64(len(r.apiKeys) - len(keys))
+	r.apiKeys = keys
+
+	return deletedCount, nil
+}
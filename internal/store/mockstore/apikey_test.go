@@ -0,0 +1,31 @@
+package mockstore
+
+import (
+	"testing"
+
+	"github.com/anoobz/dualread/auth/internal/store"
+)
+
+func TestStore_InsertApiKey(t *testing.T) {
+	s := CreateTestStore(t)
+
+	store.TestStore_InsertApiKey(t, s)
+}
+
+func TestStore_TouchApiKey(t *testing.T) {
+	s := CreateTestStore(t)
+
+	store.TestStore_TouchApiKey(t, s)
+}
+
+func TestStore_DeleteApiKey(t *testing.T) {
+	s := CreateTestStore(t)
+
+	store.TestStore_DeleteApiKey(t, s)
+}
+
+func TestStore_DeleteExpiredApiKeys(t *testing.T) {
+	s := CreateTestStore(t)
+
+	store.TestStore_DeleteExpiredApiKeys(t, s)
+}
@@ -0,0 +1,41 @@
+package mockstore
+
+import (
+	"errors"
+
+	"github.com/anoobz/dualread/auth/internal/model"
+)
+
+type MockAuthCodeRepo struct {
+	authCodes []*model.AuthCode
+}
+
+func (r *MockAuthCodeRepo) Insert(code *model.AuthCode) error {
+	c := *code
+	r.authCodes = append(r.authCodes, &c)
+	return nil
+}
+
+func (r *MockAuthCodeRepo) Consume(codeHash string) (*model.AuthCode, error) {
+	for i, c := range r.authCodes {
+		if c.CodeHash == codeHash {
+			r.authCodes = append(r.authCodes[:i], r.authCodes[i+1:]...)
+			return c, nil
+		}
+	}
+
+	return nil, errors.New("sql: no rows in result set")
+}
+
+func (r *MockAuthCodeRepo) DeleteExpired(now int64) (int64, error) {
+	codes := []*model.AuthCode{}
+	for _, c := range r.authCodes {
+		if c.Expires > now {
+			codes = append(codes, c)
+		}
+	}
+	deletedCount := int64(len(r.authCodes) - len(codes))
+	r.authCodes = codes
+
+	return deletedCount, nil
+}
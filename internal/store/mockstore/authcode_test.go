@@ -0,0 +1,19 @@
+package mockstore
+
+import (
+	"testing"
+
+	"github.com/anoobz/dualread/auth/internal/store"
+)
+
+func TestStore_ConsumeAuthCode(t *testing.T) {
+	s := CreateTestStore(t)
+
+	store.TestStore_ConsumeAuthCode(t, s)
+}
+
+func TestStore_DeleteExpiredAuthCodes(t *testing.T) {
+	s := CreateTestStore(t)
+
+	store.TestStore_DeleteExpiredAuthCodes(t, s)
+}
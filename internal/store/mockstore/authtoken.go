@@ -11,17 +11,9 @@ type MockAuthTokenRepo struct {
 	authTokens []*model.AuthToken
 }
 
-func (r *MockAuthTokenRepo) Insert(
-	uuid string,
-	tokenString string,
-	expires int64,
-) error {
-	t := &model.AuthToken{
-		Uuid:        uuid,
-		TokenString: tokenString,
-		Expires:     expires,
-	}
-	r.authTokens = append(r.authTokens, t)
+func (r *MockAuthTokenRepo) Insert(token *model.AuthToken) error {
+	t := *token
+	r.authTokens = append(r.authTokens, &t)
 	return nil
 }
 
@@ -55,6 +47,68 @@ func (r *MockAuthTokenRepo) GetPage(page uint64) ([]*model.AuthToken, error) {
 	return tokens, nil
 }
 
+func (r *MockAuthTokenRepo) GetByUser(userId int64) ([]*model.AuthToken, error) {
+	tokens := []*model.AuthToken{}
+	for _, t := range r.authTokens {
+		if t.UserId == userId {
+			tokens = append(tokens, t)
+		}
+	}
+
+	return tokens, nil
+}
+
+func (r *MockAuthTokenRepo) Consume(id string) (bool, error) {
+	for _, t := range r.authTokens {
+		if t.Uuid == id {
+			if t.Consumed {
+				return false, nil
+			}
+			t.Consumed = true
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (r *MockAuthTokenRepo) DeleteFamily(family string) error {
+	tokens := []*model.AuthToken{}
+	for _, t := range r.authTokens {
+		if t.Family != family {
+			tokens = append(tokens, t)
+		}
+	}
+	r.authTokens = tokens
+
+	return nil
+}
+
+func (r *MockAuthTokenRepo) DeleteByUser(userId int64) error {
+	tokens := []*model.AuthToken{}
+	for _, t := range r.authTokens {
+		if t.UserId != userId {
+			tokens = append(tokens, t)
+		}
+	}
+	r.authTokens = tokens
+
+	return nil
+}
+
+func (r *MockAuthTokenRepo) DeleteExpired(now int64) (int64, error) {
+	tokens := []*model.AuthToken{}
+	for _, t := range r.authTokens {
+		if t.Expires > now {
+			tokens = append(tokens, t)
+		}
+	}
+	deletedCount := int64(len(r.authTokens) - len(tokens))
+	r.authTokens = tokens
+
+	return deletedCount, nil
+}
+
 func (r *MockAuthTokenRepo) Delete(id string) error {
 	for i, t := range r.authTokens {
 		if t.Uuid == id {
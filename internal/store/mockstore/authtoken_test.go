@@ -29,3 +29,33 @@ func TestStore_DeleteToken(t *testing.T) {
 
 	store.TestStore_DeleteToken(t, s)
 }
+
+func TestStore_ConsumeToken(t *testing.T) {
+	s := CreateTestStore(t)
+
+	store.TestStore_ConsumeToken(t, s)
+}
+
+func TestStore_DeleteTokenFamily(t *testing.T) {
+	s := CreateTestStore(t)
+
+	store.TestStore_DeleteTokenFamily(t, s)
+}
+
+func TestStore_DeleteTokensByUser(t *testing.T) {
+	s := CreateTestStore(t)
+
+	store.TestStore_DeleteTokensByUser(t, s)
+}
+
+func TestStore_GetTokensByUser(t *testing.T) {
+	s := CreateTestStore(t)
+
+	store.TestStore_GetTokensByUser(t, s)
+}
+
+func TestStore_DeleteExpiredTokens(t *testing.T) {
+	s := CreateTestStore(t)
+
+	store.TestStore_DeleteExpiredTokens(t, s)
+}
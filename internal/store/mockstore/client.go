@@ -0,0 +1,56 @@
+package mockstore
+
+import (
+	"errors"
+
+	"github.com/anoobz/dualread/auth/internal/model"
+)
+
+type MockClientRepo struct {
+	clients []*model.Client
+}
+
+func (r *MockClientRepo) GetById(id string) (*model.Client, error) {
+	for _, c := range r.clients {
+		if c.Id == id {
+			return c, nil
+		}
+	}
+
+	return nil, errors.New("sql: no rows in result set")
+}
+
+func (r *MockClientRepo) GetAll() ([]*model.Client, error) {
+	return r.clients, nil
+}
+
+func (r *MockClientRepo) Insert(client *model.Client) error {
+	c := *client
+	r.clients = append(r.clients, &c)
+	return nil
+}
+
+func (r *MockClientRepo) Update(client *model.Client) error {
+	for _, c := range r.clients {
+		if c.Id == client.Id {
+			c.Name = client.Name
+			c.RedirectUris = client.RedirectUris
+			c.SecretHash = client.SecretHash
+			c.Scopes = client.Scopes
+			return nil
+		}
+	}
+
+	return errors.New("sql: no rows in result set")
+}
+
+func (r *MockClientRepo) Delete(id string) error {
+	for i, c := range r.clients {
+		if c.Id == id {
+			r.clients = append(r.clients[:i], r.clients[i+1:]...)
+			return nil
+		}
+	}
+
+	return errors.New("sql: no rows in result set")
+}
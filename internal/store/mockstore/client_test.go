@@ -0,0 +1,31 @@
+package mockstore
+
+import (
+	"testing"
+
+	"github.com/anoobz/dualread/auth/internal/store"
+)
+
+func TestStore_InsertClient(t *testing.T) {
+	s := CreateTestStore(t)
+
+	store.TestStore_InsertClient(t, s)
+}
+
+func TestStore_DeleteClient(t *testing.T) {
+	s := CreateTestStore(t)
+
+	store.TestStore_DeleteClient(t, s)
+}
+
+func TestStore_InsertServiceClient(t *testing.T) {
+	s := CreateTestStore(t)
+
+	store.TestStore_InsertServiceClient(t, s)
+}
+
+func TestStore_UpdateClient(t *testing.T) {
+	s := CreateTestStore(t)
+
+	store.TestStore_UpdateClient(t, s)
+}
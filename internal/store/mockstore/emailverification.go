@@ -0,0 +1,53 @@
+package mockstore
+
+import (
+	"errors"
+
+	"github.com/anoobz/dualread/auth/internal/model"
+)
+
+type MockEmailVerificationRepo struct {
+	verifications []*model.EmailVerification
+}
+
+func (r *MockEmailVerificationRepo) Insert(verification *model.EmailVerification) error {
+	v := *verification
+	r.verifications = append(r.verifications, &v)
+	return nil
+}
+
+func (r *MockEmailVerificationRepo) Consume(tokenId string) (*model.EmailVerification, error) {
+	for i, v := range r.verifications {
+		if v.TokenId == tokenId {
+			r.verifications = append(r.verifications[:i], r.verifications[i+1:]...)
+			return v, nil
+		}
+	}
+
+	return nil, errors.New("sql: no rows in result set")
+}
+
+func (r *MockEmailVerificationRepo) DeleteByUser(userId int64) error {
+	verifications := []*model.EmailVerification{}
+	for _, v := range r.verifications {
+		if v.UserId != userId {
+			verifications = append(verifications, v)
+		}
+	}
+	r.verifications = verifications
+
+	return nil
+}
+
+func (r *MockEmailVerificationRepo) DeleteExpired(now int64) (int64, error) {
+	verifications := []*model.EmailVerification{}
+	for _, v := range r.verifications {
+		if v.Expires > now {
+			verifications = append(verifications, v)
+		}
+	}
+	deletedCount := int64(len(r.verifications) - len(verifications))
+	r.verifications = verifications
+
+	return deletedCount, nil
+}
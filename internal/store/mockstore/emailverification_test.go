@@ -0,0 +1,25 @@
+package mockstore
+
+import (
+	"testing"
+
+	"github.com/anoobz/dualread/auth/internal/store"
+)
+
+func TestStore_ConsumeEmailVerification(t *testing.T) {
+	s := CreateTestStore(t)
+
+	store.TestStore_ConsumeEmailVerification(t, s)
+}
+
+func TestStore_DeleteEmailVerificationsByUser(t *testing.T) {
+	s := CreateTestStore(t)
+
+	store.TestStore_DeleteEmailVerificationsByUser(t, s)
+}
+
+func TestStore_DeleteExpiredEmailVerifications(t *testing.T) {
+	s := CreateTestStore(t)
+
+	store.TestStore_DeleteExpiredEmailVerifications(t, s)
+}
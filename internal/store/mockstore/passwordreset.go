@@ -0,0 +1,53 @@
+package mockstore
+
+import (
+	"errors"
+
+	"github.com/anoobz/dualread/auth/internal/model"
+)
+
+type MockPasswordResetRepo struct {
+	resets []*model.PasswordReset
+}
+
+func (r *MockPasswordResetRepo) Insert(reset *model.PasswordReset) error {
+	p := *reset
+	r.resets = append(r.resets, &p)
+	return nil
+}
+
+func (r *MockPasswordResetRepo) Consume(tokenHash string) (*model.PasswordReset, error) {
+	for i, p := range r.resets {
+		if p.TokenHash == tokenHash {
+			r.resets = append(r.resets[:i], r.resets[i+1:]...)
+			return p, nil
+		}
+	}
+
+	return nil, errors.New("sql: no rows in result set")
+}
+
+func (r *MockPasswordResetRepo) DeleteByUser(userId int64) error {
+	resets := []*model.PasswordReset{}
+	for _, p := range r.resets {
+		if p.UserId != userId {
+			resets = append(resets, p)
+		}
+	}
+	r.resets = resets
+
+	return nil
+}
+
+func (r *MockPasswordResetRepo) DeleteExpired(now int64) (int64, error) {
+	resets := []*model.PasswordReset{}
+	for _, p := range r.resets {
+		if p.Expires > now {
+			resets = append(resets, p)
+		}
+	}
+	deletedCount := int64(len(r.resets) - len(resets))
+	r.resets = resets
+
+	return deletedCount, nil
+}
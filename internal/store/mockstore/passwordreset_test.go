@@ -0,0 +1,25 @@
+package mockstore
+
+import (
+	"testing"
+
+	"github.com/anoobz/dualread/auth/internal/store"
+)
+
+func TestStore_ConsumePasswordReset(t *testing.T) {
+	s := CreateTestStore(t)
+
+	store.TestStore_ConsumePasswordReset(t, s)
+}
+
+func TestStore_DeletePasswordResetsByUser(t *testing.T) {
+	s := CreateTestStore(t)
+
+	store.TestStore_DeletePasswordResetsByUser(t, s)
+}
+
+func TestStore_DeleteExpiredPasswordResets(t *testing.T) {
+	s := CreateTestStore(t)
+
+	store.TestStore_DeleteExpiredPasswordResets(t, s)
+}
@@ -0,0 +1,80 @@
+package mockstore
+
+import "sync"
+
+// MockRevocationRepo is safe for concurrent use since it is consulted by the
+// access token middleware on every request.
+type MockRevocationRepo struct {
+	mu            sync.Mutex
+	revokedTokens map[string]int64
+	revokedUsers  map[int64]mockRevokedUser
+}
+
+type mockRevokedUser struct {
+	revokedAtMs int64
+	expires     int64
+}
+
+func NewMockRevocationRepo() *MockRevocationRepo {
+	return &MockRevocationRepo{
+		revokedTokens: map[string]int64{},
+		revokedUsers:  map[int64]mockRevokedUser{},
+	}
+}
+
+func (r *MockRevocationRepo) RevokeAccessToken(accessUuid string, expires int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.revokedTokens[accessUuid]; !ok {
+		r.revokedTokens[accessUuid] = expires
+	}
+	return nil
+}
+
+func (r *MockRevocationRepo) RevokeUser(userId int64, revokedAtMs int64, expires int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.revokedUsers[userId] = mockRevokedUser{revokedAtMs: revokedAtMs, expires: expires}
+	return nil
+}
+
+func (r *MockRevocationRepo) IsRevoked(
+	accessUuid string,
+	userId int64,
+	issuedAtMs int64,
+) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.revokedTokens[accessUuid]; ok {
+		return true, nil
+	}
+	if u, ok := r.revokedUsers[userId]; ok && u.revokedAtMs >= issuedAtMs {
+		return true, nil
+	}
+
+	return false, nil
+}
+
+func (r *MockRevocationRepo) DeleteExpired(now int64) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var deletedCount int64
+	for accessUuid, expires := range r.revokedTokens {
+		if expires <= now {
+			delete(r.revokedTokens, accessUuid)
+			deletedCount++
+		}
+	}
+	for userId, u := range r.revokedUsers {
+		if u.expires <= now {
+			delete(r.revokedUsers, userId)
+			deletedCount++
+		}
+	}
+
+	return deletedCount, nil
+}
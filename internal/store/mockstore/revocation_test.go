@@ -0,0 +1,25 @@
+package mockstore
+
+import (
+	"testing"
+
+	"github.com/anoobz/dualread/auth/internal/store"
+)
+
+func TestStore_RevokeAccessToken(t *testing.T) {
+	s := CreateTestStore(t)
+
+	store.TestStore_RevokeAccessToken(t, s)
+}
+
+func TestStore_RevokeUser(t *testing.T) {
+	s := CreateTestStore(t)
+
+	store.TestStore_RevokeUser(t, s)
+}
+
+func TestStore_DeleteExpiredRevocations(t *testing.T) {
+	s := CreateTestStore(t)
+
+	store.TestStore_DeleteExpiredRevocations(t, s)
+}
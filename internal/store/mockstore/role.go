@@ -0,0 +1,139 @@
+package mockstore
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/anoobz/dualread/auth/internal/model"
+	"github.com/anoobz/dualread/auth/internal/store"
+)
+
+type MockRoleRepo struct {
+	roles     []*model.Role
+	userRoles map[int64][]string
+	// users tells the active admins apart, like the join of the database
+	users *MockUserRepo
+}
+
+func NewMockRoleRepo() *MockRoleRepo {
+	return &MockRoleRepo{
+		userRoles: map[int64][]string{},
+	}
+}
+
+func (r *MockRoleRepo) GetAll() ([]*model.Role, error) {
+	roles := append([]*model.Role{}, r.roles...)
+	sort.Slice(roles, func(i, j int) bool {
+		return roles[i].Name < roles[j].Name
+	})
+
+	return roles, nil
+}
+
+func (r *MockRoleRepo) GetByName(name string) (*model.Role, error) {
+	for _, role := range r.roles {
+		if role.Name == name {
+			return role, nil
+		}
+	}
+
+	return nil, errors.New("sql: no rows in result set")
+}
+
+func (r *MockRoleRepo) Insert(role *model.Role) error {
+	if _, err := r.GetByName(role.Name); err == nil {
+		return errors.New("role already exists")
+	}
+
+	newRole := *role
+	newRole.Permissions = append([]string{}, role.Permissions...)
+	r.roles = append(r.roles, &newRole)
+	return nil
+}
+
+func (r *MockRoleRepo) Update(role *model.Role) error {
+	storedRole, err := r.GetByName(role.Name)
+	if err != nil {
+		return err
+	}
+	storedRole.Permissions = append([]string{}, role.Permissions...)
+
+	return nil
+}
+
+func (r *MockRoleRepo) Delete(name string) error {
+	for i, role := range r.roles {
+		if role.Name == name {
+			r.roles = append(r.roles[:i], r.roles[i+1:]...)
+			for userId, names := range r.userRoles {
+				r.userRoles[userId] = removeString(names, name)
+			}
+			return nil
+		}
+	}
+
+	return errors.New("sql: no rows in result set")
+}
+
+func (r *MockRoleRepo) GetByUser(userId int64) ([]*model.Role, error) {
+	roles := []*model.Role{}
+	for _, name := range r.userRoles[userId] {
+		role, err := r.GetByName(name)
+		if err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+	sort.Slice(roles, func(i, j int) bool {
+		return roles[i].Name < roles[j].Name
+	})
+
+	return roles, nil
+}
+
+func (r *MockRoleRepo) SetUserRoles(userId int64, names []string) error {
+	for _, name := range names {
+		if _, err := r.GetByName(name); err != nil {
+			return err
+		}
+	}
+	adminCount := r.adminCount()
+	previousNames := r.userRoles[userId]
+	r.userRoles[userId] = append([]string{}, names...)
+	if adminCount > 0 && r.adminCount() == 0 {
+		r.userRoles[userId] = previousNames
+		return store.ErrLastAdmin
+	}
+
+	return nil
+}
+
+// adminCount counts the active holders of the admin role.
+func (r *MockRoleRepo) adminCount() int {
+	count := 0
+	for userId, names := range r.userRoles {
+		if r.users != nil {
+			if u, err := r.users.GetById(userId); err != nil || !u.Active {
+				continue
+			}
+		}
+		for _, name := range names {
+			if name == model.AdminRoleName {
+				count++
+			}
+		}
+	}
+
+	return count
+}
+
+func removeString(values []string, value string) []string {
+	kept := []string{}
+	for _, v := range values {
+		if v != value {
+			kept = append(kept, v)
+		}
+	}
+
+	return kept
+}
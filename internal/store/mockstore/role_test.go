@@ -0,0 +1,37 @@
+package mockstore
+
+import (
+	"testing"
+
+	"github.com/anoobz/dualread/auth/internal/store"
+)
+
+func TestStore_InsertRole(t *testing.T) {
+	s := CreateTestStore(t)
+
+	store.TestStore_InsertRole(t, s)
+}
+
+func TestStore_UpdateRole(t *testing.T) {
+	s := CreateTestStore(t)
+
+	store.TestStore_UpdateRole(t, s)
+}
+
+func TestStore_DeleteRole(t *testing.T) {
+	s := CreateTestStore(t)
+
+	store.TestStore_DeleteRole(t, s)
+}
+
+func TestStore_SetUserRoles(t *testing.T) {
+	s := CreateTestStore(t)
+
+	store.TestStore_SetUserRoles(t, s)
+}
+
+func TestStore_SetUserRoles_LastAdmin(t *testing.T) {
+	s := CreateTestStore(t)
+
+	store.TestStore_SetUserRoles_LastAdmin(t, s)
+}
@@ -0,0 +1,80 @@
+package mockstore
+
+import (
+	"errors"
+	"time"
+
+	"github.com/anoobz/dualread/auth/internal/model"
+	"github.com/anoobz/dualread/auth/internal/store"
+)
+
+type mockSigningKey struct {
+	purpose string
+	key     *model.SigningKey
+}
+
+type MockSigningKeyRepo struct {
+	signingKeys []*mockSigningKey
+}
+
+func (r *MockSigningKeyRepo) GetAll(purpose string) ([]*model.SigningKey, error) {
+	keys := []*model.SigningKey{}
+	for _, k := range r.signingKeys {
+		if k.purpose == purpose {
+			keys = append(keys, k.key)
+		}
+	}
+
+	return keys, nil
+}
+
+func (r *MockSigningKeyRepo) Insert(purpose string, key *model.SigningKey) error {
+	for _, k := range r.signingKeys {
+		if k.key.Kid == key.Kid {
+			return errors.New("duplicate signing key")
+		}
+	}
+
+	stored := *key
+	r.signingKeys = append(r.signingKeys, &mockSigningKey{purpose: purpose, key: &stored})
+	return nil
+}
+
+func (r *MockSigningKeyRepo) Rotate(
+	purpose string,
+	activeKid string,
+	next *model.SigningKey,
+	expires time.Time,
+) error {
+	var active *model.SigningKey
+	for _, k := range r.signingKeys {
+		if k.purpose == purpose && !k.key.Retired() &&
+			(active == nil || k.key.Created.After(active.Created)) {
+			active = k.key
+		}
+	}
+	if active == nil || active.Kid != activeKid {
+		return store.ErrKeyRotated
+	}
+
+	for _, k := range r.signingKeys {
+		if k.purpose == purpose && !k.key.Retired() {
+			retired := *k.key
+			retired.Expires = expires
+			k.key = &retired
+		}
+	}
+
+	return r.Insert(purpose, next)
+}
+
+func (r *MockSigningKeyRepo) Delete(kid string) error {
+	for i, k := range r.signingKeys {
+		if k.key.Kid == kid {
+			r.signingKeys = append(r.signingKeys[:i], r.signingKeys[i+1:]...)
+			return nil
+		}
+	}
+
+	return errors.New("")
+}
@@ -0,0 +1,31 @@
+package mockstore
+
+import (
+	"testing"
+
+	"github.com/anoobz/dualread/auth/internal/store"
+)
+
+func TestStore_InsertSigningKey(t *testing.T) {
+	s := CreateTestStore(t)
+
+	store.TestStore_InsertSigningKey(t, s)
+}
+
+func TestStore_RotateSigningKey(t *testing.T) {
+	s := CreateTestStore(t)
+
+	store.TestStore_RotateSigningKey(t, s)
+}
+
+func TestStore_LoadKeyRing(t *testing.T) {
+	s := CreateTestStore(t)
+
+	store.TestStore_LoadKeyRing(t, s)
+}
+
+func TestStore_DeleteSigningKey(t *testing.T) {
+	s := CreateTestStore(t)
+
+	store.TestStore_DeleteSigningKey(t, s)
+}
@@ -3,14 +3,33 @@ package mockstore
 import "github.com/anoobz/dualread/auth/internal/store"
 
 type MockStore struct {
-	userRepo      *MockUserRepo
-	authTokenRepo *MockAuthTokenRepo
+	userRepo          *MockUserRepo
+	authTokenRepo     *MockAuthTokenRepo
+	signingKeyRepo    *MockSigningKeyRepo
+	revocationRepo    *MockRevocationRepo
+	clientRepo        *MockClientRepo
+	authCodeRepo      *MockAuthCodeRepo
+	roleRepo          *MockRoleRepo
+	apiKeyRepo        *MockApiKeyRepo
+	passwordResetRepo *MockPasswordResetRepo
+	verificationRepo  *MockEmailVerificationRepo
 }
 
 func NewMockStore() *MockStore {
+	roleRepo := NewMockRoleRepo()
+	userRepo := &MockUserRepo{roles: roleRepo}
+	roleRepo.users = userRepo
 	return &MockStore{
-		userRepo:      &MockUserRepo{},
-		authTokenRepo: &MockAuthTokenRepo{},
+		userRepo:          userRepo,
+		authTokenRepo:     &MockAuthTokenRepo{},
+		signingKeyRepo:    &MockSigningKeyRepo{},
+		revocationRepo:    NewMockRevocationRepo(),
+		clientRepo:        &MockClientRepo{},
+		authCodeRepo:      &MockAuthCodeRepo{},
+		roleRepo:          roleRepo,
+		apiKeyRepo:        &MockApiKeyRepo{},
+		passwordResetRepo: &MockPasswordResetRepo{},
+		verificationRepo:  &MockEmailVerificationRepo{},
 	}
 }
 
@@ -21,3 +40,35 @@ func (s *MockStore) User() store.UserRepo {
 func (s *MockStore) AuthToken() store.AuthTokenRepo {
 	return s.authTokenRepo
 }
+
+func (s *MockStore) SigningKey() store.SigningKeyRepo {
+	return s.signingKeyRepo
+}
+
+func (s *MockStore) Revocation() store.RevocationRepo {
+	return s.revocationRepo
+}
+
+func (s *MockStore) Client() store.ClientRepo {
+	return s.clientRepo
+}
+
+func (s *MockStore) AuthCode() store.AuthCodeRepo {
+	return s.authCodeRepo
+}
+
+func (s *MockStore) Role() store.RoleRepo {
+	return s.roleRepo
+}
+
+func (s *MockStore) ApiKey() store.ApiKeyRepo {
+	return s.apiKeyRepo
+}
+
+func (s *MockStore) PasswordReset() store.PasswordResetRepo {
+	return s.passwordResetRepo
+}
+
+func (s *MockStore) EmailVerification() store.EmailVerificationRepo {
+	return s.verificationRepo
+}
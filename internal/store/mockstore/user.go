@@ -11,6 +11,9 @@ import (
 
 type MockUserRepo struct {
 	users []*model.User
+	// roles are unassigned along with the deleted users, like the foreign key
+	// of the database cascades
+	roles *MockRoleRepo
 }
 
 func (r *MockUserRepo) GetById(id int64) (*model.User, error) {
@@ -65,10 +68,9 @@ func (r *MockUserRepo) GetPage(page uint64) ([]*model.User, error) {
 func (r *MockUserRepo) Insert(
 	email string,
 	password string,
-	admin bool,
 	now time.Time,
 ) (*model.User, error) {
-	u, err := model.NewUser(email, password, admin, now)
+	u, err := model.NewUser(email, password, now)
 	if err != nil {
 		return nil, err
 	}
@@ -83,6 +85,15 @@ func (r *MockUserRepo) Update(id int64, clauses map[string]interface{}) error {
 	for i, u := range r.users {
 		if u.ID == id {
 			updatedUser := r.users[i]
+			if active, ok := clauses["active"]; ok && active == false && u.Active {
+				adminCount := r.roles.adminCount()
+				u.Active = false
+				if adminCount > 0 && r.roles.adminCount() == 0 {
+					u.Active = true
+					return store.ErrLastAdmin
+				}
+				u.Active = true
+			}
 			for key, value := range clauses {
 				switch key {
 				case "email":
@@ -102,8 +113,6 @@ func (r *MockUserRepo) Update(id int64, clauses map[string]interface{}) error {
 					updatedUser.EmailVerified = value.(bool)
 				case "email_subscribed":
 					updatedUser.EmailSubscribed = value.(bool)
-				case "admin":
-					updatedUser.Admin = value.(bool)
 				}
 			}
 			return nil
@@ -116,6 +125,13 @@ func (r *MockUserRepo) Update(id int64, clauses map[string]interface{}) error {
 func (r *MockUserRepo) Delete(id int64) error {
 	for i, u := range r.users {
 		if u.ID == id {
+			adminCount := r.roles.adminCount()
+			names := r.roles.userRoles[id]
+			delete(r.roles.userRoles, id)
+			if adminCount > 0 && r.roles.adminCount() == 0 {
+				r.roles.userRoles[id] = names
+				return store.ErrLastAdmin
+			}
 			r.users = append(r.users[:i], r.users[i+1:]...)
 			return nil
 		}
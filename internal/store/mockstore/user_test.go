@@ -47,3 +47,15 @@ func TestStore_DeleteUser(t *testing.T) {
 
 	store.TestStore_DeleteUser(t, s)
 }
+
+func TestStore_DeleteUser_LastAdmin(t *testing.T) {
+	s := CreateTestStore(t)
+
+	store.TestStore_DeleteUser_LastAdmin(t, s)
+}
+
+func TestStore_UpdateUser_LastAdmin(t *testing.T) {
+	s := CreateTestStore(t)
+
+	store.TestStore_UpdateUser_LastAdmin(t, s)
+}
@@ -0,0 +1,54 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStore_ConsumePasswordReset(t *testing.T, s Store) {
+	testUser := CreateTestUser(t, s, 1, false)[0]
+	testReset, _ := CreateTestPasswordReset(t, s, testUser, time.Hour)
+
+	reset, err := s.PasswordReset().Consume(testReset.TokenHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, testReset, reset)
+
+	// A token can only be used once
+	_, err = s.PasswordReset().Consume(testReset.TokenHash)
+	assert.Error(t, err)
+}
+
+func TestStore_DeletePasswordResetsByUser(t *testing.T, s Store) {
+	testUsers := CreateTestUser(t, s, 2, false)
+	firstReset, _ := CreateTestPasswordReset(t, s, testUsers[0], time.Hour)
+	secondReset, _ := CreateTestPasswordReset(t, s, testUsers[0], time.Hour)
+	otherReset, _ := CreateTestPasswordReset(t, s, testUsers[1], time.Hour)
+
+	assert.NoError(t, s.PasswordReset().DeleteByUser(testUsers[0].ID))
+
+	_, err := s.PasswordReset().Consume(firstReset.TokenHash)
+	assert.Error(t, err)
+	_, err = s.PasswordReset().Consume(secondReset.TokenHash)
+	assert.Error(t, err)
+	_, err = s.PasswordReset().Consume(otherReset.TokenHash)
+	assert.NoError(t, err)
+}
+
+func TestStore_DeleteExpiredPasswordResets(t *testing.T, s Store) {
+	testUser := CreateTestUser(t, s, 1, false)[0]
+	expiredReset, _ := CreateTestPasswordReset(t, s, testUser, -time.Minute)
+	activeReset, _ := CreateTestPasswordReset(t, s, testUser, time.Minute)
+
+	count, err := s.PasswordReset().DeleteExpired(time.Now().Unix())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	_, err = s.PasswordReset().Consume(expiredReset.TokenHash)
+	assert.Error(t, err)
+	_, err = s.PasswordReset().Consume(activeReset.TokenHash)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,184 @@
+package psqlstore
+
+import (
+	"database/sql"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/anoobz/dualread/auth/internal/model"
+	"github.com/anoobz/dualread/auth/internal/store"
+)
+
+var apiKeyColumns = []string{
+	"id",
+	"prefix",
+	"key_hash",
+	"user_id",
+	"name",
+	"scope",
+	"created",
+	"expires",
+	"last_used",
+}
+
+type SqlApiKeyRepo struct {
+	db   *sql.DB
+	psql squirrel.StatementBuilderType
+}
+
+func NewSqlApiKeyRepo(
+	db *sql.DB,
+	psql squirrel.StatementBuilderType,
+) *SqlApiKeyRepo {
+	return &SqlApiKeyRepo{
+		db:   db,
+		psql: psql,
+	}
+}
+
+func (r *SqlApiKeyRepo) GetById(id string) (*model.ApiKey, error) {
+	row := r.psql.Select(apiKeyColumns...).
+		From("api_key").
+		Where("id = ?", id).
+		QueryRow()
+	k, err := apiKeyFromRow(row)
+	if err != nil {
+		return nil, err
+	}
+
+	return k, nil
+}
+
+func (r *SqlApiKeyRepo) GetByPrefix(prefix string) (*model.ApiKey, error) {
+	row := r.psql.Select(apiKeyColumns...).
+		From("api_key").
+		Where("prefix = ?", prefix).
+		QueryRow()
+	k, err := apiKeyFromRow(row)
+	if err != nil {
+		return nil, err
+	}
+
+	return k, nil
+}
+
+func (r *SqlApiKeyRepo) GetByUser(userId int64) ([]*model.ApiKey, error) {
+	rows, err := r.psql.Select(apiKeyColumns...).
+		From("api_key").
+		Where("user_id = ?", userId).
+		OrderBy("created").
+		Query()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []*model.ApiKey{}
+	for rows.Next() {
+		k, err := apiKeyFromRow(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+
+	return keys, nil
+}
+
+func (r *SqlApiKeyRepo) Insert(key *model.ApiKey) error {
+	_, err := r.psql.Insert("api_key").
+		Columns(apiKeyColumns...).
+		Values(
+			key.Id,
+			key.Prefix,
+			key.KeyHash,
+			key.UserId,
+			key.Name,
+			key.Scope,
+			key.Created,
+			key.Expires,
+			key.LastUsed,
+		).
+		Exec()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *SqlApiKeyRepo) Touch(id string, lastUsed int64) error {
+	res, err := r.psql.Update("api_key").
+		Set("last_used", lastUsed).
+		Where("id = ?", id).
+		Exec()
+	if err != nil {
+		return err
+	}
+
+	updatedRowCount, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if updatedRowCount == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func (r *SqlApiKeyRepo) Delete(id string) error {
+	res, err := r.psql.Delete("api_key").Where("id = ?", id).Exec()
+	if err != nil {
+		return err
+	}
+
+	deletedRowCount, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if deletedRowCount == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func (r *SqlApiKeyRepo) DeleteByUser(userId int64) error {
+	_, err := r.psql.Delete("api_key").Where("user_id = ?", userId).Exec()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DeleteExpired leaves the keys which do not expire, whose expiry is zero.
+func (r *SqlApiKeyRepo) DeleteExpired(now int64) (int64, error) {
+	res, err := r.psql.Delete("api_key").
+		Where("expires <> 0 AND expires <= ?", now).
+		Exec()
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+func apiKeyFromRow(row store.Row) (*model.ApiKey, error) {
+	k := &model.ApiKey{}
+	if err := row.Scan(
+		&k.Id,
+		&k.Prefix,
+		&k.KeyHash,
+		&k.UserId,
+		&k.Name,
+		&k.Scope,
+		&k.Created,
+		&k.Expires,
+		&k.LastUsed,
+	); err != nil {
+		return nil, err
+	}
+
+	return k, nil
+}
@@ -0,0 +1,103 @@
+package psqlstore
+
+import (
+	"database/sql"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/anoobz/dualread/auth/internal/model"
+	"github.com/lib/pq"
+)
+
+type SqlAuthCodeRepo struct {
+	db   *sql.DB
+	psql squirrel.StatementBuilderType
+}
+
+func NewSqlAuthCodeRepo(
+	db *sql.DB,
+	psql squirrel.StatementBuilderType,
+) *SqlAuthCodeRepo {
+	return &SqlAuthCodeRepo{
+		db:   db,
+		psql: psql,
+	}
+}
+
+func (r *SqlAuthCodeRepo) Insert(code *model.AuthCode) error {
+	// A nil slice would be stored as NULL
+	audience := []string{}
+	audience = append(audience, code.Audience...)
+
+	_, err := r.psql.Insert("authorization_code").
+		Columns(
+			"code_hash",
+			"client_id",
+			"user_id",
+			"redirect_uri",
+			"code_challenge",
+			"audience",
+			"scope",
+			"nonce",
+			"expires",
+		).
+		Values(
+			code.CodeHash,
+			code.ClientId,
+			code.UserId,
+			code.RedirectUri,
+			code.CodeChallenge,
+			pq.Array(audience),
+			code.Scope,
+			code.Nonce,
+			code.Expires,
+		).
+		Exec()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Consume deletes the row and reads it back in the same statement so that
+// concurrent exchanges of a code cannot both succeed.
+func (r *SqlAuthCodeRepo) Consume(codeHash string) (*model.AuthCode, error) {
+	query, args, err := r.psql.Delete("authorization_code").
+		Where("code_hash = ?", codeHash).
+		Suffix(
+			"RETURNING code_hash, client_id, user_id, redirect_uri, " +
+				"code_challenge, audience, scope, nonce, expires",
+		).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	c := &model.AuthCode{}
+	audience := []string{}
+	if err := r.db.QueryRow(query, args...).Scan(
+		&c.CodeHash,
+		&c.ClientId,
+		&c.UserId,
+		&c.RedirectUri,
+		&c.CodeChallenge,
+		pq.Array(&audience),
+		&c.Scope,
+		&c.Nonce,
+		&c.Expires,
+	); err != nil {
+		return nil, err
+	}
+	c.Audience = audience
+
+	return c, nil
+}
+
+func (r *SqlAuthCodeRepo) DeleteExpired(now int64) (int64, error) {
+	res, err := r.psql.Delete("authorization_code").Where("expires <= ?", now).Exec()
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
@@ -0,0 +1,21 @@
+package psqlstore
+
+import (
+	"testing"
+
+	"github.com/anoobz/dualread/auth/internal/store"
+)
+
+func TestStore_ConsumeAuthCode(t *testing.T) {
+	s, dbTearUp := CreateTestStore(t)
+	defer dbTearUp("authorization_code", "oauth_client", "users")
+
+	store.TestStore_ConsumeAuthCode(t, s)
+}
+
+func TestStore_DeleteExpiredAuthCodes(t *testing.T) {
+	s, dbTearUp := CreateTestStore(t)
+	defer dbTearUp("authorization_code", "oauth_client", "users")
+
+	store.TestStore_DeleteExpiredAuthCodes(t, s)
+}
@@ -9,6 +9,19 @@ import (
 	"github.com/anoobz/dualread/auth/internal/store"
 )
 
+var authTokenColumns = []string{
+	"id",
+	"token_hash",
+	"expires",
+	"family",
+	"consumed",
+	"user_id",
+	"created",
+	"last_used",
+	"client_ip",
+	"user_agent",
+}
+
 type SqlAuthTokenRepo struct {
 	db   *sql.DB
 	psql squirrel.StatementBuilderType
@@ -24,14 +37,21 @@ func NewSqlAuthTokenRepo(
 	}
 }
 
-func (r *SqlAuthTokenRepo) Insert(
-	uuid string,
-	tokenString string,
-	expires int64,
-) error {
+func (r *SqlAuthTokenRepo) Insert(token *model.AuthToken) error {
 	_, err := r.psql.Insert("refresh_token").
-		Columns("id", "token_string", "expires").
-		Values(uuid, tokenString, expires).
+		Columns(authTokenColumns...).
+		Values(
+			token.Uuid,
+			token.TokenHash,
+			token.Expires,
+			token.Family,
+			token.Consumed,
+			token.UserId,
+			token.Created,
+			token.LastUsed,
+			token.ClientIp,
+			token.UserAgent,
+		).
 		Exec()
 
 	if err != nil {
@@ -42,7 +62,7 @@ func (r *SqlAuthTokenRepo) Insert(
 }
 
 func (r *SqlAuthTokenRepo) GetAll() ([]*model.AuthToken, error) {
-	rows, err := r.psql.Select("*").From("refresh_token").Query()
+	rows, err := r.psql.Select(authTokenColumns...).From("refresh_token").Query()
 	if err != nil {
 		return nil, err
 	}
@@ -61,7 +81,7 @@ func (r *SqlAuthTokenRepo) GetAll() ([]*model.AuthToken, error) {
 }
 
 func (r *SqlAuthTokenRepo) GetPage(page uint64) ([]*model.AuthToken, error) {
-	rows, err := r.psql.Select("*").
+	rows, err := r.psql.Select(authTokenColumns...).
 		From("refresh_token").
 		Limit(store.PAGE_COUNT).
 		Offset(page * store.PAGE_COUNT).
@@ -88,7 +108,10 @@ func (r *SqlAuthTokenRepo) GetPage(page uint64) ([]*model.AuthToken, error) {
 }
 
 func (r *SqlAuthTokenRepo) GetById(id string) (*model.AuthToken, error) {
-	row := r.psql.Select("*").From("refresh_token").Where("id = ?", id).QueryRow()
+	row := r.psql.Select(authTokenColumns...).
+		From("refresh_token").
+		Where("id = ?", id).
+		QueryRow()
 	t, err := tokenFromRow(row)
 	if err != nil {
 		return nil, err
@@ -97,6 +120,45 @@ func (r *SqlAuthTokenRepo) GetById(id string) (*model.AuthToken, error) {
 	return t, nil
 }
 
+func (r *SqlAuthTokenRepo) GetByUser(userId int64) ([]*model.AuthToken, error) {
+	rows, err := r.psql.Select(authTokenColumns...).
+		From("refresh_token").
+		Where("user_id = ?", userId).
+		Query()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []*model.AuthToken{}
+	for rows.Next() {
+		t, err := tokenFromRow(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+
+	return tokens, nil
+}
+
+func (r *SqlAuthTokenRepo) Consume(id string) (bool, error) {
+	res, err := r.psql.Update("refresh_token").
+		Set("consumed", true).
+		Where("id = ? AND consumed = ?", id, false).
+		Exec()
+	if err != nil {
+		return false, err
+	}
+
+	updatedRowCount, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return updatedRowCount == 1, nil
+}
+
 func (r *SqlAuthTokenRepo) Delete(id string) error {
 	_, err := r.psql.Delete("refresh_token").Where("id = ?", id).Exec()
 	if err != nil {
@@ -106,9 +168,47 @@ func (r *SqlAuthTokenRepo) Delete(id string) error {
 	return nil
 }
 
+func (r *SqlAuthTokenRepo) DeleteFamily(family string) error {
+	_, err := r.psql.Delete("refresh_token").Where("family = ?", family).Exec()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *SqlAuthTokenRepo) DeleteByUser(userId int64) error {
+	_, err := r.psql.Delete("refresh_token").Where("user_id = ?", userId).Exec()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *SqlAuthTokenRepo) DeleteExpired(now int64) (int64, error) {
+	res, err := r.psql.Delete("refresh_token").Where("expires <= ?", now).Exec()
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
 func tokenFromRow(row store.Row) (*model.AuthToken, error) {
 	t := &model.AuthToken{}
-	if err := row.Scan(&t.Uuid, &t.TokenString, &t.Expires); err != nil {
+	if err := row.Scan(
+		&t.Uuid,
+		&t.TokenHash,
+		&t.Expires,
+		&t.Family,
+		&t.Consumed,
+		&t.UserId,
+		&t.Created,
+		&t.LastUsed,
+		&t.ClientIp,
+		&t.UserAgent,
+	); err != nil {
 		return nil, err
 	}
 
@@ -33,3 +33,38 @@ func TestStore_DeleteToken(t *testing.T) {
 
 	store.TestStore_DeleteToken(t, s)
 }
+
+func TestStore_ConsumeToken(t *testing.T) {
+	s, dbTearUp := CreateTestStore(t)
+	defer dbTearUp("refresh_token", "users")
+
+	store.TestStore_ConsumeToken(t, s)
+}
+
+func TestStore_DeleteTokenFamily(t *testing.T) {
+	s, dbTearUp := CreateTestStore(t)
+	defer dbTearUp("refresh_token", "users")
+
+	store.TestStore_DeleteTokenFamily(t, s)
+}
+
+func TestStore_DeleteTokensByUser(t *testing.T) {
+	s, dbTearUp := CreateTestStore(t)
+	defer dbTearUp("refresh_token", "users")
+
+	store.TestStore_DeleteTokensByUser(t, s)
+}
+
+func TestStore_GetTokensByUser(t *testing.T) {
+	s, dbTearUp := CreateTestStore(t)
+	defer dbTearUp("refresh_token", "users")
+
+	store.TestStore_GetTokensByUser(t, s)
+}
+
+func TestStore_DeleteExpiredTokens(t *testing.T) {
+	s, dbTearUp := CreateTestStore(t)
+	defer dbTearUp("refresh_token", "users")
+
+	store.TestStore_DeleteExpiredTokens(t, s)
+}
@@ -0,0 +1,144 @@
+package psqlstore
+
+import (
+	"database/sql"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/anoobz/dualread/auth/internal/model"
+	"github.com/anoobz/dualread/auth/internal/store"
+	"github.com/lib/pq"
+)
+
+var clientColumns = []string{
+	"id",
+	"name",
+	"redirect_uris",
+	"secret_hash",
+	"scopes",
+	"created",
+}
+
+type SqlClientRepo struct {
+	db   *sql.DB
+	psql squirrel.StatementBuilderType
+}
+
+func NewSqlClientRepo(
+	db *sql.DB,
+	psql squirrel.StatementBuilderType,
+) *SqlClientRepo {
+	return &SqlClientRepo{
+		db:   db,
+		psql: psql,
+	}
+}
+
+func (r *SqlClientRepo) GetById(id string) (*model.Client, error) {
+	row := r.psql.Select(clientColumns...).
+		From("oauth_client").
+		Where("id = ?", id).
+		QueryRow()
+	c, err := clientFromRow(row)
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (r *SqlClientRepo) GetAll() ([]*model.Client, error) {
+	rows, err := r.psql.Select(clientColumns...).
+		From("oauth_client").
+		OrderBy("created").
+		Query()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	clients := []*model.Client{}
+	for rows.Next() {
+		c, err := clientFromRow(rows)
+		if err != nil {
+			return nil, err
+		}
+		clients = append(clients, c)
+	}
+
+	return clients, nil
+}
+
+func (r *SqlClientRepo) Insert(client *model.Client) error {
+	_, err := r.psql.Insert("oauth_client").
+		Columns(clientColumns...).
+		Values(
+			client.Id,
+			client.Name,
+			pq.Array(client.RedirectUris),
+			client.SecretHash,
+			pq.Array(client.Scopes),
+			client.Created,
+		).
+		Exec()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *SqlClientRepo) Update(client *model.Client) error {
+	res, err := r.psql.Update("oauth_client").
+		Set("name", client.Name).
+		Set("redirect_uris", pq.Array(client.RedirectUris)).
+		Set("secret_hash", client.SecretHash).
+		Set("scopes", pq.Array(client.Scopes)).
+		Where("id = ?", client.Id).
+		Exec()
+	if err != nil {
+		return err
+	}
+
+	updatedRowCount, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if updatedRowCount == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func (r *SqlClientRepo) Delete(id string) error {
+	res, err := r.psql.Delete("oauth_client").Where("id = ?", id).Exec()
+	if err != nil {
+		return err
+	}
+
+	deletedRowCount, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if deletedRowCount == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func clientFromRow(row store.Row) (*model.Client, error) {
+	c := &model.Client{}
+	if err := row.Scan(
+		&c.Id,
+		&c.Name,
+		pq.Array(&c.RedirectUris),
+		&c.SecretHash,
+		pq.Array(&c.Scopes),
+		&c.Created,
+	); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
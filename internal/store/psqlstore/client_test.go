@@ -0,0 +1,35 @@
+package psqlstore
+
+import (
+	"testing"
+
+	"github.com/anoobz/dualread/auth/internal/store"
+)
+
+func TestStore_InsertClient(t *testing.T) {
+	s, dbTearUp := CreateTestStore(t)
+	defer dbTearUp("oauth_client")
+
+	store.TestStore_InsertClient(t, s)
+}
+
+func TestStore_DeleteClient(t *testing.T) {
+	s, dbTearUp := CreateTestStore(t)
+	defer dbTearUp("oauth_client")
+
+	store.TestStore_DeleteClient(t, s)
+}
+
+func TestStore_InsertServiceClient(t *testing.T) {
+	s, dbTearUp := CreateTestStore(t)
+	defer dbTearUp("oauth_client")
+
+	store.TestStore_InsertServiceClient(t, s)
+}
+
+func TestStore_UpdateClient(t *testing.T) {
+	s, dbTearUp := CreateTestStore(t)
+	defer dbTearUp("oauth_client")
+
+	store.TestStore_UpdateClient(t, s)
+}
@@ -0,0 +1,72 @@
+package psqlstore
+
+import (
+	"database/sql"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/anoobz/dualread/auth/internal/model"
+)
+
+type SqlEmailVerificationRepo struct {
+	db   *sql.DB
+	psql squirrel.StatementBuilderType
+}
+
+func NewSqlEmailVerificationRepo(
+	db *sql.DB,
+	psql squirrel.StatementBuilderType,
+) *SqlEmailVerificationRepo {
+	return &SqlEmailVerificationRepo{
+		db:   db,
+		psql: psql,
+	}
+}
+
+func (r *SqlEmailVerificationRepo) Insert(verification *model.EmailVerification) error {
+	_, err := r.psql.Insert("email_verification").
+		Columns("token_id", "user_id", "expires").
+		Values(verification.TokenId, verification.UserId, verification.Expires).
+		Exec()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Consume deletes the row and reads it back in the same statement so that
+// concurrent uses of a token cannot both succeed.
+func (r *SqlEmailVerificationRepo) Consume(tokenId string) (*model.EmailVerification, error) {
+	query, args, err := r.psql.Delete("email_verification").
+		Where("token_id = ?", tokenId).
+		Suffix("RETURNING token_id, user_id, expires").
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	v := &model.EmailVerification{}
+	if err := r.db.QueryRow(query, args...).Scan(
+		&v.TokenId,
+		&v.UserId,
+		&v.Expires,
+	); err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+func (r *SqlEmailVerificationRepo) DeleteByUser(userId int64) error {
+	_, err := r.psql.Delete("email_verification").Where("user_id = ?", userId).Exec()
+	return err
+}
+
+func (r *SqlEmailVerificationRepo) DeleteExpired(now int64) (int64, error) {
+	res, err := r.psql.Delete("email_verification").Where("expires <= ?", now).Exec()
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
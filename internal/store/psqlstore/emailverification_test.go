@@ -0,0 +1,28 @@
+package psqlstore
+
+import (
+	"testing"
+
+	"github.com/anoobz/dualread/auth/internal/store"
+)
+
+func TestStore_ConsumeEmailVerification(t *testing.T) {
+	s, dbTearUp := CreateTestStore(t)
+	defer dbTearUp("email_verification", "users")
+
+	store.TestStore_ConsumeEmailVerification(t, s)
+}
+
+func TestStore_DeleteEmailVerificationsByUser(t *testing.T) {
+	s, dbTearUp := CreateTestStore(t)
+	defer dbTearUp("email_verification", "users")
+
+	store.TestStore_DeleteEmailVerificationsByUser(t, s)
+}
+
+func TestStore_DeleteExpiredEmailVerifications(t *testing.T) {
+	s, dbTearUp := CreateTestStore(t)
+	defer dbTearUp("email_verification", "users")
+
+	store.TestStore_DeleteExpiredEmailVerifications(t, s)
+}
@@ -0,0 +1,73 @@
+package psqlstore
+
+import (
+	"database/sql"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/anoobz/dualread/auth/internal/model"
+)
+
+type SqlPasswordResetRepo struct {
+	db   *sql.DB
+	psql squirrel.StatementBuilderType
+}
+
+func NewSqlPasswordResetRepo(
+	db *sql.DB,
+	psql squirrel.StatementBuilderType,
+) *SqlPasswordResetRepo {
+	return &SqlPasswordResetRepo{
+		db:   db,
+		psql: psql,
+	}
+}
+
+func (r *SqlPasswordResetRepo) Insert(reset *model.PasswordReset) error {
+	_, err := r.psql.Insert("password_reset").
+		Columns("token_hash", "user_id", "created", "expires").
+		Values(reset.TokenHash, reset.UserId, reset.Created, reset.Expires).
+		Exec()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Consume deletes the row and reads it back in the same statement so that
+// concurrent uses of a token cannot both succeed.
+func (r *SqlPasswordResetRepo) Consume(tokenHash string) (*model.PasswordReset, error) {
+	query, args, err := r.psql.Delete("password_reset").
+		Where("token_hash = ?", tokenHash).
+		Suffix("RETURNING token_hash, user_id, created, expires").
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	p := &model.PasswordReset{}
+	if err := r.db.QueryRow(query, args...).Scan(
+		&p.TokenHash,
+		&p.UserId,
+		&p.Created,
+		&p.Expires,
+	); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+func (r *SqlPasswordResetRepo) DeleteByUser(userId int64) error {
+	_, err := r.psql.Delete("password_reset").Where("user_id = ?", userId).Exec()
+	return err
+}
+
+func (r *SqlPasswordResetRepo) DeleteExpired(now int64) (int64, error) {
+	res, err := r.psql.Delete("password_reset").Where("expires <= ?", now).Exec()
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
@@ -0,0 +1,28 @@
+package psqlstore
+
+import (
+	"testing"
+
+	"github.com/anoobz/dualread/auth/internal/store"
+)
+
+func TestStore_ConsumePasswordReset(t *testing.T) {
+	s, dbTearUp := CreateTestStore(t)
+	defer dbTearUp("password_reset", "users")
+
+	store.TestStore_ConsumePasswordReset(t, s)
+}
+
+func TestStore_DeletePasswordResetsByUser(t *testing.T) {
+	s, dbTearUp := CreateTestStore(t)
+	defer dbTearUp("password_reset", "users")
+
+	store.TestStore_DeletePasswordResetsByUser(t, s)
+}
+
+func TestStore_DeleteExpiredPasswordResets(t *testing.T) {
+	s, dbTearUp := CreateTestStore(t)
+	defer dbTearUp("password_reset", "users")
+
+	store.TestStore_DeleteExpiredPasswordResets(t, s)
+}
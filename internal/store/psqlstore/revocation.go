@@ -0,0 +1,97 @@
+package psqlstore
+
+import (
+	"database/sql"
+
+	"github.com/Masterminds/squirrel"
+)
+
+type SqlRevocationRepo struct {
+	db   *sql.DB
+	psql squirrel.StatementBuilderType
+}
+
+func NewSqlRevocationRepo(
+	db *sql.DB,
+	psql squirrel.StatementBuilderType,
+) *SqlRevocationRepo {
+	return &SqlRevocationRepo{
+		db:   db,
+		psql: psql,
+	}
+}
+
+func (r *SqlRevocationRepo) RevokeAccessToken(accessUuid string, expires int64) error {
+	_, err := r.psql.Insert("revoked_access_token").
+		Columns("access_uuid", "expires").
+		Values(accessUuid, expires).
+		Suffix("ON CONFLICT (access_uuid) DO NOTHING").
+		Exec()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *SqlRevocationRepo) RevokeUser(userId int64, revokedAtMs int64, expires int64) error {
+	_, err := r.psql.Insert("revoked_user").
+		Columns("user_id", "revoked_at_ms", "expires").
+		Values(userId, revokedAtMs, expires).
+		Suffix(
+			"ON CONFLICT (user_id) DO UPDATE " +
+				"SET revoked_at_ms = EXCLUDED.revoked_at_ms, expires = EXCLUDED.expires",
+		).
+		Exec()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *SqlRevocationRepo) IsRevoked(
+	accessUuid string,
+	userId int64,
+	issuedAtMs int64,
+) (bool, error) {
+	var tokenCount int
+	if err := r.psql.Select("count(*)").
+		From("revoked_access_token").
+		Where("access_uuid = ?", accessUuid).
+		QueryRow().
+		Scan(&tokenCount); err != nil {
+		return false, err
+	}
+	if tokenCount > 0 {
+		return true, nil
+	}
+
+	var userCount int
+	if err := r.psql.Select("count(*)").
+		From("revoked_user").
+		Where("user_id = ? AND revoked_at_ms >= ?", userId, issuedAtMs).
+		QueryRow().
+		Scan(&userCount); err != nil {
+		return false, err
+	}
+
+	return userCount > 0, nil
+}
+
+func (r *SqlRevocationRepo) DeleteExpired(now int64) (int64, error) {
+	var deletedRowCount int64
+	for _, table := range []string{"revoked_access_token", "revoked_user"} {
+		res, err := r.psql.Delete(table).Where("expires <= ?", now).Exec()
+		if err != nil {
+			return deletedRowCount, err
+		}
+		count, err := res.RowsAffected()
+		if err != nil {
+			return deletedRowCount, err
+		}
+		deletedRowCount += count
+	}
+
+	return deletedRowCount, nil
+}
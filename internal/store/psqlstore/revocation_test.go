@@ -0,0 +1,28 @@
+package psqlstore
+
+import (
+	"testing"
+
+	"github.com/anoobz/dualread/auth/internal/store"
+)
+
+func TestStore_RevokeAccessToken(t *testing.T) {
+	s, dbTearUp := CreateTestStore(t)
+	defer dbTearUp("revoked_access_token", "revoked_user", "users")
+
+	store.TestStore_RevokeAccessToken(t, s)
+}
+
+func TestStore_RevokeUser(t *testing.T) {
+	s, dbTearUp := CreateTestStore(t)
+	defer dbTearUp("revoked_access_token", "revoked_user", "users")
+
+	store.TestStore_RevokeUser(t, s)
+}
+
+func TestStore_DeleteExpiredRevocations(t *testing.T) {
+	s, dbTearUp := CreateTestStore(t)
+	defer dbTearUp("revoked_access_token", "revoked_user", "users")
+
+	store.TestStore_DeleteExpiredRevocations(t, s)
+}
@@ -0,0 +1,263 @@
+package psqlstore
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/anoobz/dualread/auth/internal/model"
+	"github.com/anoobz/dualread/auth/internal/store"
+	"github.com/lib/pq"
+)
+
+// roleColumns aggregate the permissions of a role, roles without permission
+// get an empty array rather than an array of NULL.
+var roleColumns = []string{
+	"role.name",
+	"COALESCE(array_agg(role_permission.permission ORDER BY role_permission.permission) " +
+		"FILTER (WHERE role_permission.permission IS NOT NULL), '{}')",
+}
+
+type SqlRoleRepo struct {
+	db   *sql.DB
+	psql squirrel.StatementBuilderType
+}
+
+func NewSqlRoleRepo(
+	db *sql.DB,
+	psql squirrel.StatementBuilderType,
+) *SqlRoleRepo {
+	return &SqlRoleRepo{
+		db:   db,
+		psql: psql,
+	}
+}
+
+func (r *SqlRoleRepo) selectRoles() squirrel.SelectBuilder {
+	return r.psql.Select(roleColumns...).
+		From("role").
+		LeftJoin("role_permission ON role_permission.role_name = role.name").
+		GroupBy("role.name").
+		OrderBy("role.name")
+}
+
+func (r *SqlRoleRepo) GetAll() ([]*model.Role, error) {
+	return rolesFromQuery(r.selectRoles())
+}
+
+func (r *SqlRoleRepo) GetByName(name string) (*model.Role, error) {
+	row := r.selectRoles().Where("role.name = ?", name).QueryRow()
+	role, err := roleFromRow(row)
+	if err != nil {
+		return nil, err
+	}
+
+	return role, nil
+}
+
+func (r *SqlRoleRepo) Insert(role *model.Role) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = r.psql.Insert("role").
+		Columns("name").
+		Values(role.Name).
+		RunWith(tx).
+		Exec()
+	if err != nil {
+		return err
+	}
+	if err := r.insertPermissions(tx, role); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *SqlRoleRepo) Update(role *model.Role) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Locks the role so that concurrent updates do not mix their permissions
+	var name string
+	err = r.psql.Select("name").
+		From("role").
+		Where("name = ?", role.Name).
+		Suffix("FOR UPDATE").
+		RunWith(tx).
+		QueryRow().
+		Scan(&name)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.psql.Delete("role_permission").
+		Where("role_name = ?", role.Name).
+		RunWith(tx).
+		Exec()
+	if err != nil {
+		return err
+	}
+	if err := r.insertPermissions(tx, role); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *SqlRoleRepo) insertPermissions(tx *sql.Tx, role *model.Role) error {
+	if len(role.Permissions) == 0 {
+		return nil
+	}
+
+	query := r.psql.Insert("role_permission").Columns("role_name", "permission")
+	for _, permission := range role.Permissions {
+		query = query.Values(role.Name, permission)
+	}
+	if _, err := query.RunWith(tx).Exec(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Delete also unassigns the role from its users.
+func (r *SqlRoleRepo) Delete(name string) error {
+	res, err := r.psql.Delete("role").Where("name = ?", name).Exec()
+	if err != nil {
+		return err
+	}
+
+	deletedRowCount, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if deletedRowCount == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func (r *SqlRoleRepo) GetByUser(userId int64) ([]*model.Role, error) {
+	return rolesFromQuery(r.selectRoles().
+		Join("user_role ON user_role.role_name = role.name").
+		Where("user_role.user_id = ?", userId),
+	)
+}
+
+func (r *SqlRoleRepo) SetUserRoles(userId int64, names []string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Locks the admin role so that concurrent changes cannot each remove one of
+	// the last two admins
+	adminCount, err := lockAdminCount(r.psql, tx)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.psql.Delete("user_role").
+		Where("user_id = ?", userId).
+		RunWith(tx).
+		Exec()
+	if err != nil {
+		return err
+	}
+
+	if len(names) > 0 {
+		query := r.psql.Insert("user_role").Columns("user_id", "role_name")
+		for _, name := range names {
+			query = query.Values(userId, name)
+		}
+		if _, err := query.RunWith(tx).Exec(); err != nil {
+			return err
+		}
+	}
+
+	if adminCount > 0 {
+		remainingCount, err := countAdmins(r.psql, tx)
+		if err != nil {
+			return err
+		}
+		if remainingCount == 0 {
+			return store.ErrLastAdmin
+		}
+	}
+
+	return tx.Commit()
+}
+
+// lockAdminCount locks the admin role until the end of tx and returns the
+// number of its users, zero when the role does not exist. Every change which
+// can remove an admin takes the lock, users and roles alike.
+func lockAdminCount(psql squirrel.StatementBuilderType, tx *sql.Tx) (int, error) {
+	var name string
+	err := psql.Select("name").
+		From("role").
+		Where("name = ?", model.AdminRoleName).
+		Suffix("FOR UPDATE").
+		RunWith(tx).
+		QueryRow().
+		Scan(&name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return countAdmins(psql, tx)
+}
+
+// countAdmins counts the active holders of the admin role, the inactive ones
+// cannot sign in.
+func countAdmins(psql squirrel.StatementBuilderType, tx *sql.Tx) (int, error) {
+	count := 0
+	err := psql.Select("COUNT(*)").
+		From("user_role").
+		Join("users ON users.id = user_role.user_id").
+		Where("user_role.role_name = ? AND users.active", model.AdminRoleName).
+		RunWith(tx).
+		QueryRow().
+		Scan(&count)
+
+	return count, err
+}
+
+func rolesFromQuery(query squirrel.SelectBuilder) ([]*model.Role, error) {
+	rows, err := query.Query()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := []*model.Role{}
+	for rows.Next() {
+		role, err := roleFromRow(rows)
+		if err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+
+	return roles, nil
+}
+
+func roleFromRow(row store.Row) (*model.Role, error) {
+	role := &model.Role{Permissions: []string{}}
+	if err := row.Scan(&role.Name, pq.Array(&role.Permissions)); err != nil {
+		return nil, err
+	}
+
+	return role, nil
+}
@@ -0,0 +1,42 @@
+package psqlstore
+
+import (
+	"testing"
+
+	"github.com/anoobz/dualread/auth/internal/store"
+)
+
+func TestStore_InsertRole(t *testing.T) {
+	s, dbTearUp := CreateTestStore(t)
+	defer dbTearUp("role", "users")
+
+	store.TestStore_InsertRole(t, s)
+}
+
+func TestStore_UpdateRole(t *testing.T) {
+	s, dbTearUp := CreateTestStore(t)
+	defer dbTearUp("role", "users")
+
+	store.TestStore_UpdateRole(t, s)
+}
+
+func TestStore_DeleteRole(t *testing.T) {
+	s, dbTearUp := CreateTestStore(t)
+	defer dbTearUp("role", "users")
+
+	store.TestStore_DeleteRole(t, s)
+}
+
+func TestStore_SetUserRoles(t *testing.T) {
+	s, dbTearUp := CreateTestStore(t)
+	defer dbTearUp("role", "users")
+
+	store.TestStore_SetUserRoles(t, s)
+}
+
+func TestStore_SetUserRoles_LastAdmin(t *testing.T) {
+	s, dbTearUp := CreateTestStore(t)
+	defer dbTearUp("role", "users")
+
+	store.TestStore_SetUserRoles_LastAdmin(t, s)
+}
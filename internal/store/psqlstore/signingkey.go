@@ -0,0 +1,179 @@
+package psqlstore
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/anoobz/dualread/auth/internal/model"
+	"github.com/anoobz/dualread/auth/internal/store"
+)
+
+// SqlSigningKeyRepo persists the key data encrypted by keyCipher.
+type SqlSigningKeyRepo struct {
+	db        *sql.DB
+	psql      squirrel.StatementBuilderType
+	keyCipher *model.KeyCipher
+}
+
+func NewSqlSigningKeyRepo(
+	db *sql.DB,
+	psql squirrel.StatementBuilderType,
+	keyCipher *model.KeyCipher,
+) *SqlSigningKeyRepo {
+	return &SqlSigningKeyRepo{
+		db:        db,
+		psql:      psql,
+		keyCipher: keyCipher,
+	}
+}
+
+func (r *SqlSigningKeyRepo) GetAll(purpose string) ([]*model.SigningKey, error) {
+	rows, err := r.psql.Select("kid", "alg", "key_data", "created", "expires").
+		From("signing_key").
+		Where("purpose = ?", purpose).
+		OrderBy("created").
+		Query()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []*model.SigningKey{}
+	for rows.Next() {
+		k, err := r.signingKeyFromRow(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+func (r *SqlSigningKeyRepo) sealKeyData(key *model.SigningKey) (string, error) {
+	keyData, err := key.EncodePrivateKey()
+	if err != nil {
+		return "", err
+	}
+
+	return r.keyCipher.Seal(key.Kid, keyData)
+}
+
+func (r *SqlSigningKeyRepo) Insert(purpose string, key *model.SigningKey) error {
+	keyData, err := r.sealKeyData(key)
+	if err != nil {
+		return err
+	}
+
+	var expires interface{}
+	if key.Retired() {
+		expires = key.Expires
+	}
+
+	_, err = r.psql.Insert("signing_key").
+		Columns("kid", "purpose", "alg", "key_data", "created", "expires").
+		Values(key.Kid, purpose, key.Method.Alg(), keyData, key.Created, expires).
+		Exec()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *SqlSigningKeyRepo) Rotate(
+	purpose string,
+	activeKid string,
+	next *model.SigningKey,
+	expires time.Time,
+) error {
+	keyData, err := r.sealKeyData(next)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// The lock is held by the transaction across every instance
+	_, err = tx.Exec("SELECT pg_advisory_xact_lock(hashtext($1))", "signing_key:"+purpose)
+	if err != nil {
+		return err
+	}
+
+	var currentKid string
+	err = r.psql.Select("kid").
+		From("signing_key").
+		Where("purpose = ? AND expires IS NULL", purpose).
+		OrderBy("created DESC").
+		Limit(1).
+		RunWith(tx).
+		QueryRow().
+		Scan(&currentKid)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	if currentKid != activeKid {
+		return store.ErrKeyRotated
+	}
+
+	_, err = r.psql.Update("signing_key").
+		Set("expires", expires).
+		Where("purpose = ? AND expires IS NULL", purpose).
+		RunWith(tx).
+		Exec()
+	if err != nil {
+		return err
+	}
+	_, err = r.psql.Insert("signing_key").
+		Columns("kid", "purpose", "alg", "key_data", "created", "expires").
+		Values(next.Kid, purpose, next.Method.Alg(), keyData, next.Created, nil).
+		RunWith(tx).
+		Exec()
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *SqlSigningKeyRepo) Delete(kid string) error {
+	_, err := r.psql.Delete("signing_key").Where("kid = ?", kid).Exec()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *SqlSigningKeyRepo) signingKeyFromRow(row store.Row) (*model.SigningKey, error) {
+	var kid, alg, sealedKeyData string
+	var created time.Time
+	var expires sql.NullTime
+	if err := row.Scan(&kid, &alg, &sealedKeyData, &created, &expires); err != nil {
+		return nil, err
+	}
+
+	keyData, err := r.keyCipher.Open(kid, sealedKeyData)
+	if err != nil {
+		return nil, err
+	}
+	k, err := model.ParseSigningKey(kid, alg, keyData)
+	if err != nil {
+		return nil, err
+	}
+	k.Created = created.Local()
+	if expires.Valid {
+		k.Expires = expires.Time.Local()
+	}
+
+	return k, nil
+}
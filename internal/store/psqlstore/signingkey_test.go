@@ -0,0 +1,78 @@
+package psqlstore
+
+import (
+	"testing"
+
+	"github.com/anoobz/dualread/auth/internal/model"
+	"github.com/anoobz/dualread/auth/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStore_InsertSigningKey(t *testing.T) {
+	s, dbTearUp := CreateTestStore(t)
+	defer dbTearUp("signing_key")
+
+	store.TestStore_InsertSigningKey(t, s)
+}
+
+func TestStore_RotateSigningKey(t *testing.T) {
+	s, dbTearUp := CreateTestStore(t)
+	defer dbTearUp("signing_key")
+
+	store.TestStore_RotateSigningKey(t, s)
+}
+
+func TestStore_LoadKeyRing(t *testing.T) {
+	s, dbTearUp := CreateTestStore(t)
+	defer dbTearUp("signing_key")
+
+	store.TestStore_LoadKeyRing(t, s)
+}
+
+func TestStore_DeleteSigningKey(t *testing.T) {
+	s, dbTearUp := CreateTestStore(t)
+	defer dbTearUp("signing_key")
+
+	store.TestStore_DeleteSigningKey(t, s)
+}
+
+func TestStore_SigningKeyEncryptedAtRest(t *testing.T) {
+	s, dbTearUp := CreateTestStore(t)
+	defer dbTearUp("signing_key")
+
+	testKey := model.NewHMACSigningKey("test_kid", "test_secret")
+	testKey.Created = store.GetTestNow(t)
+	if err := s.SigningKey().Insert(model.AccessKeyPurpose, testKey); err != nil {
+		t.Fatal(err)
+	}
+
+	keyData := ""
+	err := s.signingKeyRepo.db.QueryRow(
+		"SELECT key_data FROM signing_key WHERE kid = $1",
+		testKey.Kid,
+	).Scan(&keyData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NotContains(t, keyData, "test_secret")
+
+	keys, err := s.SigningKey().GetAll(model.AccessKeyPurpose)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assert.Len(t, keys, 1) {
+		assert.Equal(t, testKey.PrivateKey, keys[0].PrivateKey)
+	}
+
+	// Key data that was not encrypted is refused
+	_, err = s.signingKeyRepo.db.Exec(
+		"UPDATE signing_key SET key_data = $1 WHERE kid = $2",
+		"test_secret",
+		testKey.Kid,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.SigningKey().GetAll(model.AccessKeyPurpose)
+	assert.Error(t, err)
+}
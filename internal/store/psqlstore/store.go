@@ -4,21 +4,39 @@ import (
 	"database/sql"
 
 	"github.com/Masterminds/squirrel"
+	"github.com/anoobz/dualread/auth/internal/model"
 	"github.com/anoobz/dualread/auth/internal/store"
 )
 
 type SqlStore struct {
-	userRepo      *SqlUserRepo
-	authTokenRepo *SqlAuthTokenRepo
+	userRepo          *SqlUserRepo
+	authTokenRepo     *SqlAuthTokenRepo
+	signingKeyRepo    *SqlSigningKeyRepo
+	revocationRepo    *SqlRevocationRepo
+	clientRepo        *SqlClientRepo
+	authCodeRepo      *SqlAuthCodeRepo
+	roleRepo          *SqlRoleRepo
+	apiKeyRepo        *SqlApiKeyRepo
+	passwordResetRepo *SqlPasswordResetRepo
+	verificationRepo  *SqlEmailVerificationRepo
 }
 
 func NewSqlStore(
 	db *sql.DB,
 	psql squirrel.StatementBuilderType,
+	keyCipher *model.KeyCipher,
 ) *SqlStore {
 	return &SqlStore{
-		userRepo:      NewSqlUserRepo(db, psql),
-		authTokenRepo: NewSqlAuthTokenRepo(db, psql),
+		userRepo:          NewSqlUserRepo(db, psql),
+		authTokenRepo:     NewSqlAuthTokenRepo(db, psql),
+		signingKeyRepo:    NewSqlSigningKeyRepo(db, psql, keyCipher),
+		revocationRepo:    NewSqlRevocationRepo(db, psql),
+		clientRepo:        NewSqlClientRepo(db, psql),
+		authCodeRepo:      NewSqlAuthCodeRepo(db, psql),
+		roleRepo:          NewSqlRoleRepo(db, psql),
+		apiKeyRepo:        NewSqlApiKeyRepo(db, psql),
+		passwordResetRepo: NewSqlPasswordResetRepo(db, psql),
+		verificationRepo:  NewSqlEmailVerificationRepo(db, psql),
 	}
 }
 
@@ -29,3 +47,35 @@ func (s *SqlStore) User() store.UserRepo {
 func (s *SqlStore) AuthToken() store.AuthTokenRepo {
 	return s.authTokenRepo
 }
+
+func (s *SqlStore) SigningKey() store.SigningKeyRepo {
+	return s.signingKeyRepo
+}
+
+func (s *SqlStore) Revocation() store.RevocationRepo {
+	return s.revocationRepo
+}
+
+func (s *SqlStore) Client() store.ClientRepo {
+	return s.clientRepo
+}
+
+func (s *SqlStore) AuthCode() store.AuthCodeRepo {
+	return s.authCodeRepo
+}
+
+func (s *SqlStore) Role() store.RoleRepo {
+	return s.roleRepo
+}
+
+func (s *SqlStore) ApiKey() store.ApiKeyRepo {
+	return s.apiKeyRepo
+}
+
+func (s *SqlStore) PasswordReset() store.PasswordResetRepo {
+	return s.passwordResetRepo
+}
+
+func (s *SqlStore) EmailVerification() store.EmailVerificationRepo {
+	return s.verificationRepo
+}
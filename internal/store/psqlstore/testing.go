@@ -8,9 +8,13 @@ import (
 	"time"
 
 	"github.com/Masterminds/squirrel"
+	"github.com/anoobz/dualread/auth/internal/model"
 	"github.com/anoobz/dualread/auth/internal/store"
 )
 
+// testKeyEncryptionKey is the base64 encoding of 32 bytes.
+const testKeyEncryptionKey = "dGVzdF9rZXlfZW5jcnlwdGlvbl9rZXlfMzJfYnl0ZXM="
+
 func CreateTestStore(t *testing.T) (*SqlStore, func(...string)) {
 	t.Helper()
 
@@ -31,7 +35,11 @@ func CreateTestStore(t *testing.T) (*SqlStore, func(...string)) {
 
 	statementBuilder := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar).
 		RunWith(db)
-	store := NewSqlStore(db, statementBuilder)
+	keyCipher, err := model.NewKeyCipher(testKeyEncryptionKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := NewSqlStore(db, statementBuilder, keyCipher)
 
 	return store, func(tables ...string) {
 		if len(tables) > 0 {
@@ -11,6 +11,18 @@ import (
 	"github.com/anoobz/dualread/auth/internal/store"
 )
 
+var userColumns = []string{
+	"id",
+	"email",
+	"password",
+	"active",
+	"email_verified",
+	"email_subscribed",
+	"created",
+	"last_login",
+	"last_action",
+}
+
 type SqlUserRepo struct {
 	db   *sql.DB
 	psql squirrel.StatementBuilderType
@@ -24,7 +36,7 @@ func NewSqlUserRepo(db *sql.DB, psql squirrel.StatementBuilderType) *SqlUserRepo
 }
 
 func (r *SqlUserRepo) GetById(id int64) (*model.User, error) {
-	row := r.psql.Select("*").From("users").
+	row := r.psql.Select(userColumns...).From("users").
 		Where("id = ?", id).QueryRow()
 	u, err := userFromRow(row)
 
@@ -36,7 +48,7 @@ func (r *SqlUserRepo) GetById(id int64) (*model.User, error) {
 }
 
 func (r *SqlUserRepo) GetAll() ([]*model.User, error) {
-	rows, err := r.psql.Select("*").From("users").Query()
+	rows, err := r.psql.Select(userColumns...).From("users").Query()
 	if err != nil {
 		return nil, err
 	}
@@ -60,7 +72,7 @@ func (r *SqlUserRepo) GetByEmail(email string) (*model.User, error) {
 		return nil, err
 	}
 
-	row := r.psql.Select("*").From("users").
+	row := r.psql.Select(userColumns...).From("users").
 		Where("email = ?", email).QueryRow()
 	u, err := userFromRow(row)
 
@@ -72,7 +84,7 @@ func (r *SqlUserRepo) GetByEmail(email string) (*model.User, error) {
 }
 
 func (r *SqlUserRepo) GetPage(page uint64) ([]*model.User, error) {
-	rows, err := r.psql.Select("*").
+	rows, err := r.psql.Select(userColumns...).
 		From("users").
 		Limit(store.PAGE_COUNT).
 		Offset(page * store.PAGE_COUNT).
@@ -101,10 +113,9 @@ func (r *SqlUserRepo) GetPage(page uint64) ([]*model.User, error) {
 func (r *SqlUserRepo) Insert(
 	email string,
 	password string,
-	admin bool,
 	now time.Time,
 ) (*model.User, error) {
-	u, err := model.NewUser(email, password, admin, now)
+	u, err := model.NewUser(email, password, now)
 	if err != nil {
 		return nil, err
 	}
@@ -115,7 +126,6 @@ func (r *SqlUserRepo) Insert(
 			"active",
 			"email_verified",
 			"email_subscribed",
-			"admin",
 			"created",
 			"last_login",
 			"last_action").
@@ -124,7 +134,6 @@ func (r *SqlUserRepo) Insert(
 			u.Active,
 			u.EmailVerified,
 			u.EmailSubscribed,
-			u.Admin,
 			u.Created,
 			u.LastLogin,
 			u.LastAction).
@@ -152,9 +161,26 @@ func (r *SqlUserRepo) Update(id int64, clauses map[string]interface{}) error {
 		}
 	}
 
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Like Delete, a deactivation locks the admin role so that concurrent
+	// changes cannot each remove one of the last two admins
+	adminCount := 0
+	if active, ok := clauses["active"]; ok && active == false {
+		adminCount, err = lockAdminCount(r.psql, tx)
+		if err != nil {
+			return err
+		}
+	}
+
 	res, err := r.psql.Update("users").
 		SetMap(clauses).
 		Where("id = ?", id).
+		RunWith(tx).
 		Exec()
 	if err != nil {
 		return err
@@ -167,16 +193,50 @@ func (r *SqlUserRepo) Update(id int64, clauses map[string]interface{}) error {
 	if updatedRowCount == 0 {
 		return errors.New("user not found")
 	}
-	return nil
+
+	if adminCount > 0 {
+		remainingCount, err := countAdmins(r.psql, tx)
+		if err != nil {
+			return err
+		}
+		if remainingCount == 0 {
+			return store.ErrLastAdmin
+		}
+	}
+
+	return tx.Commit()
 }
 
 func (r *SqlUserRepo) Delete(id int64) error {
-	_, err := r.psql.Delete("users").Where("id = ?", id).Exec()
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Locks the admin role so that concurrent deletions cannot each remove one
+	// of the last two admins
+	adminCount, err := lockAdminCount(r.psql, tx)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.psql.Delete("users").Where("id = ?", id).RunWith(tx).Exec()
 	if err != nil {
 		return err
 	}
 
-	return nil
+	if adminCount > 0 {
+		remainingCount, err := countAdmins(r.psql, tx)
+		if err != nil {
+			return err
+		}
+		if remainingCount == 0 {
+			return store.ErrLastAdmin
+		}
+	}
+
+	return tx.Commit()
 }
 
 func userFromRow(row store.Row) (*model.User, error) {
@@ -191,7 +251,6 @@ func userFromRow(row store.Row) (*model.User, error) {
 		&u.Active,
 		&u.EmailVerified,
 		&u.EmailSubscribed,
-		&u.Admin,
 		&created,
 		&lastLogin,
 		&lastAction,
@@ -54,3 +54,17 @@ func TestStore_DeleteUser(t *testing.T) {
 
 	store.TestStore_DeleteUser(t, s)
 }
+
+func TestStore_DeleteUser_LastAdmin(t *testing.T) {
+	s, dbTearUp := CreateTestStore(t)
+	defer dbTearUp("role", "users")
+
+	store.TestStore_DeleteUser_LastAdmin(t, s)
+}
+
+func TestStore_UpdateUser_LastAdmin(t *testing.T) {
+	s, dbTearUp := CreateTestStore(t)
+	defer dbTearUp("role", "users")
+
+	store.TestStore_UpdateUser_LastAdmin(t, s)
+}
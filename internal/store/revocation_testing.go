@@ -0,0 +1,121 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStore_RevokeAccessToken(t *testing.T, s Store) {
+	testUser := CreateTestUser(t, s, 1, false)[0]
+	revokedUuid := "5c7f6d5e-8bd9-4a3e-9a39-1f3f0b1f5a01"
+	validUuid := "5c7f6d5e-8bd9-4a3e-9a39-1f3f0b1f5a02"
+
+	err := s.Revocation().RevokeAccessToken(revokedUuid, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Revoking twice is not an error
+	err = s.Revocation().RevokeAccessToken(revokedUuid, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	revoked, err := s.Revocation().IsRevoked(revokedUuid, testUser.ID, 10)
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+
+	revoked, err = s.Revocation().IsRevoked(validUuid, testUser.ID, 10)
+	assert.NoError(t, err)
+	assert.False(t, revoked)
+}
+
+func TestStore_RevokeUser(t *testing.T, s Store) {
+	testUsers := CreateTestUser(t, s, 2, false)
+	accessUuid := "5c7f6d5e-8bd9-4a3e-9a39-1f3f0b1f5a01"
+
+	err := s.Revocation().RevokeUser(testUsers[0].ID, 50, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name     string
+		userId   int64
+		issuedAt int64
+		revoked  bool
+	}{
+		{
+			name:     "issued before revocation",
+			userId:   testUsers[0].ID,
+			issuedAt: 49,
+			revoked:  true,
+		},
+		{
+			name:     "issued on revocation",
+			userId:   testUsers[0].ID,
+			issuedAt: 50,
+			revoked:  true,
+		},
+		{
+			name:     "issued after revocation",
+			userId:   testUsers[0].ID,
+			issuedAt: 51,
+			revoked:  false,
+		},
+		{
+			name:     "other user",
+			userId:   testUsers[1].ID,
+			issuedAt: 49,
+			revoked:  false,
+		},
+	}
+
+	for _, tc := range testCases {
+		revoked, err := s.Revocation().IsRevoked(accessUuid, tc.userId, tc.issuedAt)
+		assert.NoError(t, err, tc.name)
+		assert.Equal(t, tc.revoked, revoked, tc.name)
+	}
+
+	// A later revocation replaces the previous one
+	err = s.Revocation().RevokeUser(testUsers[0].ID, 60, 110)
+	if err != nil {
+		t.Fatal(err)
+	}
+	revoked, err := s.Revocation().IsRevoked(accessUuid, testUsers[0].ID, 55)
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+}
+
+func TestStore_DeleteExpiredRevocations(t *testing.T, s Store) {
+	testUsers := CreateTestUser(t, s, 2, false)
+	expiredUuid := "5c7f6d5e-8bd9-4a3e-9a39-1f3f0b1f5a01"
+	activeUuid := "5c7f6d5e-8bd9-4a3e-9a39-1f3f0b1f5a02"
+
+	for _, err := range []error{
+		s.Revocation().RevokeAccessToken(expiredUuid, 100),
+		s.Revocation().RevokeAccessToken(activeUuid, 200),
+		s.Revocation().RevokeUser(testUsers[0].ID, 50, 100),
+		s.Revocation().RevokeUser(testUsers[1].ID, 50, 200),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	count, err := s.Revocation().DeleteExpired(100)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	revoked, err := s.Revocation().IsRevoked(expiredUuid, testUsers[0].ID, 10)
+	assert.NoError(t, err)
+	assert.False(t, revoked)
+
+	revoked, err = s.Revocation().IsRevoked(activeUuid, testUsers[0].ID, 10)
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+
+	revoked, err = s.Revocation().IsRevoked(expiredUuid, testUsers[1].ID, 10)
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+}
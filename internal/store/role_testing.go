@@ -0,0 +1,182 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/anoobz/dualread/auth/internal/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStore_InsertRole(t *testing.T, s Store) {
+	testRoles := CreateTestRole(
+		t, s, 2,
+		[]string{model.PermissionReadUsers, model.PermissionReadTokens},
+	)
+	emptyRole, err := model.NewRole("empty_role", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Role().Insert(emptyRole); err != nil {
+		t.Fatal(err)
+	}
+
+	role, err := s.Role().GetByName(testRoles[1].Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, testRoles[1], role)
+
+	roles, err := s.Role().GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []*model.Role{emptyRole, testRoles[0], testRoles[1]}, roles)
+
+	assert.Error(t, s.Role().Insert(testRoles[0]))
+	_, err = s.Role().GetByName("missing_role")
+	assert.Error(t, err)
+}
+
+func TestStore_UpdateRole(t *testing.T, s Store) {
+	testRoles := CreateTestRole(t, s, 2, []string{model.PermissionReadUsers})
+
+	updatedRole, err := model.NewRole(
+		testRoles[0].Name,
+		[]string{model.PermissionWriteUsers, model.PermissionDeleteUsers},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Role().Update(updatedRole); err != nil {
+		t.Fatal(err)
+	}
+
+	role, err := s.Role().GetByName(testRoles[0].Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, updatedRole, role)
+
+	role, err = s.Role().GetByName(testRoles[1].Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, testRoles[1], role)
+
+	missingRole, err := model.NewRole("missing_role", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Error(t, s.Role().Update(missingRole))
+}
+
+func TestStore_DeleteRole(t *testing.T, s Store) {
+	testRoles := CreateTestRole(t, s, 2, []string{model.PermissionReadUsers})
+	testUser := CreateTestUser(t, s, 1, false)[0]
+	err := s.Role().SetUserRoles(testUser.ID, []string{testRoles[0].Name, testRoles[1].Name})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NoError(t, s.Role().Delete(testRoles[0].Name))
+	assert.Error(t, s.Role().Delete(testRoles[0].Name))
+
+	roles, err := s.Role().GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []*model.Role{testRoles[1]}, roles)
+
+	roles, err = s.Role().GetByUser(testUser.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []*model.Role{testRoles[1]}, roles)
+}
+
+func TestStore_SetUserRoles(t *testing.T, s Store) {
+	testRoles := CreateTestRole(t, s, 3, []string{model.PermissionReadUsers})
+	testUsers := CreateTestUser(t, s, 2, false)
+
+	roles, err := s.Role().GetByUser(testUsers[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, roles)
+
+	err = s.Role().SetUserRoles(testUsers[0].ID, []string{testRoles[2].Name, testRoles[0].Name})
+	if err != nil {
+		t.Fatal(err)
+	}
+	roles, err = s.Role().GetByUser(testUsers[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []*model.Role{testRoles[0], testRoles[2]}, roles)
+
+	// The roles are replaced
+	err = s.Role().SetUserRoles(testUsers[0].ID, []string{testRoles[1].Name})
+	if err != nil {
+		t.Fatal(err)
+	}
+	roles, err = s.Role().GetByUser(testUsers[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []*model.Role{testRoles[1]}, roles)
+
+	// An unknown role leaves the roles unchanged
+	err = s.Role().SetUserRoles(testUsers[0].ID, []string{testRoles[0].Name, "missing_role"})
+	assert.Error(t, err)
+	roles, err = s.Role().GetByUser(testUsers[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []*model.Role{testRoles[1]}, roles)
+
+	roles, err = s.Role().GetByUser(testUsers[1].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, roles)
+
+	err = s.Role().SetUserRoles(testUsers[0].ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roles, err = s.Role().GetByUser(testUsers[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, roles)
+}
+
+func TestStore_SetUserRoles_LastAdmin(t *testing.T, s Store) {
+	adminRole := CreateTestAdminRole(t, s)
+	testRoles := CreateTestRole(t, s, 1, []string{model.PermissionReadUsers})
+	testUsers := CreateTestUser(t, s, 2, false)
+
+	err := s.Role().SetUserRoles(testUsers[0].ID, []string{adminRole.Name})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Role().SetUserRoles(testUsers[1].ID, []string{adminRole.Name})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.Role().SetUserRoles(testUsers[0].ID, []string{testRoles[0].Name})
+	assert.NoError(t, err)
+
+	// The last admin keeps the admin role
+	err = s.Role().SetUserRoles(testUsers[1].ID, []string{testRoles[0].Name})
+	assert.ErrorIs(t, err, ErrLastAdmin)
+	roles, err := s.Role().GetByUser(testUsers[1].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []*model.Role{adminRole}, roles)
+
+	err = s.Role().SetUserRoles(testUsers[1].ID, []string{adminRole.Name, testRoles[0].Name})
+	assert.NoError(t, err)
+}
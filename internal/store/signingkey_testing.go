@@ -0,0 +1,161 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/anoobz/dualread/auth/internal/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func CreateTestSigningKey(
+	t *testing.T,
+	s Store,
+	count int,
+	purpose string,
+) []*model.SigningKey {
+	t.Helper()
+
+	keys := []*model.SigningKey{}
+	for i := 0; i < count; i++ {
+		key, err := model.GenerateSigningKey(
+			"EdDSA",
+			GetTestNow(t).Add(time.Duration(i)*time.Hour),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := s.SigningKey().Insert(purpose, key); err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
+func assertSigningKeysEqual(t *testing.T, expected, actual []*model.SigningKey) {
+	t.Helper()
+
+	if !assert.Len(t, actual, len(expected)) {
+		return
+	}
+	for i := range expected {
+		assert.Equal(t, expected[i].Kid, actual[i].Kid)
+		assert.Equal(t, expected[i].Method, actual[i].Method)
+		assert.Equal(t, expected[i].PrivateKey, actual[i].PrivateKey)
+		assert.True(t, expected[i].Created.Equal(actual[i].Created))
+		assert.True(t, expected[i].Expires.Equal(actual[i].Expires))
+	}
+}
+
+func TestStore_InsertSigningKey(t *testing.T, s Store) {
+	accessKeys := CreateTestSigningKey(t, s, 2, model.AccessKeyPurpose)
+	refreshKeys := CreateTestSigningKey(t, s, 1, model.RefreshKeyPurpose)
+
+	keys, err := s.SigningKey().GetAll(model.AccessKeyPurpose)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSigningKeysEqual(t, accessKeys, keys)
+
+	keys, err = s.SigningKey().GetAll(model.RefreshKeyPurpose)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSigningKeysEqual(t, refreshKeys, keys)
+}
+
+func TestStore_RotateSigningKey(t *testing.T, s Store) {
+	testKeys := CreateTestSigningKey(t, s, 2, model.AccessKeyPurpose)
+	refreshKey := CreateTestSigningKey(t, s, 1, model.RefreshKeyPurpose)[0]
+	next, err := model.GenerateSigningKey("EdDSA", GetTestNow(t).Add(2*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expires := GetTestNow(t).Add(3 * time.Hour)
+
+	// Only the active key can be rotated
+	err = s.SigningKey().Rotate(model.AccessKeyPurpose, testKeys[0].Kid, next, expires)
+	assert.ErrorIs(t, err, ErrKeyRotated)
+
+	err = s.SigningKey().Rotate(model.AccessKeyPurpose, testKeys[1].Kid, next, expires)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every other key is retired, including the one never retired before
+	keys, err := s.SigningKey().GetAll(model.AccessKeyPurpose)
+	if err != nil {
+		t.Fatal(err)
+	}
+	retiredKeys := []*model.SigningKey{}
+	for _, k := range testKeys {
+		retired := *k
+		retired.Expires = expires
+		retiredKeys = append(retiredKeys, &retired)
+	}
+	assertSigningKeysEqual(t, append(retiredKeys, next), keys)
+
+	keys, err = s.SigningKey().GetAll(model.RefreshKeyPurpose)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSigningKeysEqual(t, []*model.SigningKey{refreshKey}, keys)
+
+	err = s.SigningKey().Rotate(model.AccessKeyPurpose, testKeys[1].Kid, next, expires)
+	assert.ErrorIs(t, err, ErrKeyRotated)
+}
+
+func TestStore_DeleteSigningKey(t *testing.T, s Store) {
+	testKeys := CreateTestSigningKey(t, s, 3, model.AccessKeyPurpose)
+
+	err := s.SigningKey().Delete(testKeys[1].Kid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := s.SigningKey().GetAll(model.AccessKeyPurpose)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSigningKeysEqual(t, []*model.SigningKey{testKeys[0], testKeys[2]}, keys)
+}
+
+func TestStore_LoadKeyRing(t *testing.T, s Store) {
+	now := GetTestNow(t)
+	hmacKey := model.NewHMACSigningKey("hmac_kid", "test_secret")
+
+	// The fallback key seeds the empty ring
+	ring, err := LoadKeyRing(s.SigningKey(), model.AccessKeyPurpose, hmacKey, time.Hour, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, hmacKey.Kid, ring.Active().Kid)
+
+	// The stored key is kept while the algorithm is unchanged
+	otherKey := model.NewHMACSigningKey("other_kid", "other_secret")
+	ring, err = LoadKeyRing(s.SigningKey(), model.AccessKeyPurpose, otherKey, time.Hour, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, hmacKey.Kid, ring.Active().Kid)
+
+	// A new algorithm replaces the stored key
+	ecKey, err := model.GenerateSigningKey("ES256", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	later := now.Add(time.Minute)
+	ring, err = LoadKeyRing(s.SigningKey(), model.AccessKeyPurpose, ecKey, time.Hour, later)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, ecKey.Kid, ring.Active().Kid)
+	keys := ring.Keys()
+	if assert.Len(t, keys, 2) {
+		assert.Equal(t, hmacKey.Kid, keys[1].Kid)
+		assert.True(t, later.Add(time.Hour).Equal(keys[1].Expires))
+	}
+}
@@ -1,6 +1,7 @@
 package store
 
 import (
+	"errors"
 	"time"
 
 	"github.com/anoobz/dualread/auth/internal/model"
@@ -10,6 +11,15 @@ const (
 	PAGE_COUNT = 20
 )
 
+// ErrLastAdmin is returned when a change would leave no active user with the
+// admin role, locking every administrator out. Changing the roles of the user,
+// deactivating and deleting the user are all refused.
+var ErrLastAdmin = errors.New("the last admin cannot lose the admin role")
+
+// ErrKeyRotated is returned when the active signing key was already rotated,
+// by another instance of the service.
+var ErrKeyRotated = errors.New("the signing key was already rotated")
+
 type UserRepo interface {
 	GetById(id int64) (*model.User, error)
 	GetByEmail(email string) (*model.User, error)
@@ -18,10 +28,13 @@ type UserRepo interface {
 	Insert(
 		email string,
 		password string,
-		admin bool,
 		now time.Time,
 	) (*model.User, error)
+	// Update fails with ErrLastAdmin when it deactivates the last active
+	// holder of the admin role
 	Update(id int64, clauses map[string]interface{}) error
+	// Delete also unassigns the roles of the user, it fails with ErrLastAdmin
+	// when the user is the last active holder of the admin role
 	Delete(id int64) error
 }
 
@@ -29,15 +42,117 @@ type AuthTokenRepo interface {
 	GetById(id string) (*model.AuthToken, error)
 	GetAll() ([]*model.AuthToken, error)
 	GetPage(pageId uint64) ([]*model.AuthToken, error)
-	Insert(
-		uuid string,
-		tokenString string,
-		expires int64,
-	) error
+	Insert(token *model.AuthToken) error
+	// Consume marks the token as used and reports false when it already was
+	Consume(id string) (bool, error)
 	Delete(id string) error
+	DeleteFamily(family string) error
+	GetByUser(userId int64) ([]*model.AuthToken, error)
+	DeleteByUser(userId int64) error
+	DeleteExpired(now int64) (int64, error)
+}
+
+type SigningKeyRepo interface {
+	GetAll(purpose string) ([]*model.SigningKey, error)
+	Insert(purpose string, key *model.SigningKey) error
+	// Rotate inserts next as the active key of the purpose and retires every
+	// other key that is not retired yet, they expire at expires. It fails with
+	// ErrKeyRotated unless activeKid is still the active key, rotations of
+	// concurrent instances are serialized.
+	Rotate(purpose string, activeKid string, next *model.SigningKey, expires time.Time) error
+	Delete(kid string) error
+}
+
+// RevocationRepo is the deny list of access tokens consulted on every
+// authenticated request. Entries are kept until the tokens they cover expire.
+type RevocationRepo interface {
+	RevokeAccessToken(accessUuid string, expires int64) error
+	// RevokeUser revokes every access token of the user issued at or before
+	// revokedAtMs. Both times are in milliseconds, so that the tokens issued
+	// in the same second right after the revocation remain valid.
+	RevokeUser(userId int64, revokedAtMs int64, expires int64) error
+	IsRevoked(accessUuid string, userId int64, issuedAtMs int64) (bool, error)
+	DeleteExpired(now int64) (int64, error)
+}
+
+// ClientRepo holds the applications registered for the OAuth flows.
+type ClientRepo interface {
+	GetById(id string) (*model.Client, error)
+	GetAll() ([]*model.Client, error)
+	Insert(client *model.Client) error
+	// Update replaces every field of the client but its id and creation time
+	Update(client *model.Client) error
+	Delete(id string) error
+}
+
+// AuthCodeRepo holds the authorization codes until they are exchanged.
+type AuthCodeRepo interface {
+	Insert(code *model.AuthCode) error
+	// Consume deletes the code and returns it, so that a code can only be
+	// exchanged once
+	Consume(codeHash string) (*model.AuthCode, error)
+	DeleteExpired(now int64) (int64, error)
+}
+
+// RoleRepo holds the roles and the roles assigned to users. Roles are listed
+// by name.
+type RoleRepo interface {
+	GetAll() ([]*model.Role, error)
+	GetByName(name string) (*model.Role, error)
+	Insert(role *model.Role) error
+	// Update replaces the permissions of the role
+	Update(role *model.Role) error
+	Delete(name string) error
+	GetByUser(userId int64) ([]*model.Role, error)
+	// SetUserRoles replaces the roles of the user, it fails with ErrLastAdmin
+	// when the user is the last active holder of the admin role and loses it
+	SetUserRoles(userId int64, names []string) error
+}
+
+// ApiKeyRepo holds the API keys of users. The keys are persisted without
+// their plain key, only with its hash.
+type ApiKeyRepo interface {
+	GetById(id string) (*model.ApiKey, error)
+	GetByPrefix(prefix string) (*model.ApiKey, error)
+	// GetByUser lists the keys of the user by creation time
+	GetByUser(userId int64) ([]*model.ApiKey, error)
+	Insert(key *model.ApiKey) error
+	// Touch records the last use of the key
+	Touch(id string, lastUsed int64) error
+	Delete(id string) error
+	DeleteByUser(userId int64) error
+	DeleteExpired(now int64) (int64, error)
+}
+
+// PasswordResetRepo holds the hashes of the password reset tokens.
+type PasswordResetRepo interface {
+	Insert(reset *model.PasswordReset) error
+	// Consume deletes the reset and returns it, so that a token can only be
+	// used once
+	Consume(tokenHash string) (*model.PasswordReset, error)
+	DeleteByUser(userId int64) error
+	DeleteExpired(now int64) (int64, error)
+}
+
+// EmailVerificationRepo holds the records of the email verification tokens.
+type EmailVerificationRepo interface {
+	Insert(verification *model.EmailVerification) error
+	// Consume deletes the record and returns it, so that a token can only be
+	// used once
+	Consume(tokenId string) (*model.EmailVerification, error)
+	DeleteByUser(userId int64) error
+	DeleteExpired(now int64) (int64, error)
 }
 
 type Store interface {
 	User() UserRepo
 	AuthToken() AuthTokenRepo
+	SigningKey() SigningKeyRepo
+	Revocation() RevocationRepo
+	Client() ClientRepo
+	AuthCode() AuthCodeRepo
+	Role() RoleRepo
+	ApiKey() ApiKeyRepo
+	PasswordReset() PasswordResetRepo
+	EmailVerification() EmailVerificationRepo
 }
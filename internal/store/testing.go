@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/anoobz/dualread/auth/internal/model"
+	"github.com/twinj/uuid"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -31,18 +32,57 @@ func CreateTestUser(t *testing.T, s Store, count int, admin bool) []*model.User
 		u, err := s.User().Insert(
 			fmt.Sprintf("test%d@test.test", i),
 			string(encryptedPassword),
-			admin,
 			testTime,
 		)
 		if err != nil {
 			t.Fatal(err)
 		}
+		if admin {
+			CreateTestAdminRole(t, s)
+			if err := s.Role().SetUserRoles(u.ID, []string{model.AdminRoleName}); err != nil {
+				t.Fatal(err)
+			}
+		}
 		newUsers = append(newUsers, u)
 	}
 
 	return newUsers
 }
 
+// CreateTestAdminRole inserts the admin role unless the store already holds it.
+func CreateTestAdminRole(t *testing.T, s Store) *model.Role {
+	t.Helper()
+
+	if r, err := s.Role().GetByName(model.AdminRoleName); err == nil {
+		return r
+	}
+	r := model.NewAdminRole()
+	if err := s.Role().Insert(r); err != nil {
+		t.Fatal(err)
+	}
+
+	return r
+}
+
+// CreateTestRole inserts roles named test_role%d, each with the permissions.
+func CreateTestRole(t *testing.T, s Store, count int, permissions []string) []*model.Role {
+	t.Helper()
+
+	roles := []*model.Role{}
+	for i := 0; i < count; i++ {
+		r, err := model.NewRole(fmt.Sprintf("test_role%d", i), permissions)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Role().Insert(r); err != nil {
+			t.Fatal(err)
+		}
+		roles = append(roles, r)
+	}
+
+	return roles
+}
+
 func CreateTestToken(
 	t *testing.T,
 	s Store,
@@ -51,12 +91,13 @@ func CreateTestToken(
 ) []*model.AuthToken {
 	tokens := []*model.AuthToken{}
 	for i := 0; i < count; i++ {
-		token, err := model.NewRefreshToken(user)
+		token, err := model.NewRefreshToken(user, GetTestSigningKey(t), GetTestTokenParams())
 		if err != nil {
 			t.Fatal(err)
 		}
+		token = token.Hashed(GetTestTokenHashKey())
 
-		err = s.AuthToken().Insert(token.Uuid, token.TokenString, token.Expires)
+		err = s.AuthToken().Insert(token)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -66,7 +107,188 @@ func CreateTestToken(
 	return tokens
 }
 
+func GetTestTokenParams() model.TokenParams {
+	return model.TokenParams{
+		Issuer:   "test",
+		Audience: model.Audience{"test"},
+		Lifetime: time.Hour,
+	}
+}
+
+func GetTestTokenHashKey() []byte {
+	return []byte("test_hash_key")
+}
+
+func GetTestSigningKey(t *testing.T) *model.SigningKey {
+	t.Helper()
+	return model.NewHMACSigningKey("test", "test_secret")
+}
+
 func GetTestNow(t *testing.T) time.Time {
 	t.Helper()
 	return time.Date(2000, time.January, 1, 0, 0, 0, 0, time.Local)
 }
+
+func CreateTestClient(t *testing.T, s Store, count int) []*model.Client {
+	t.Helper()
+
+	clients := []*model.Client{}
+	for i := 0; i < count; i++ {
+		c, _, err := model.NewClient(
+			fmt.Sprintf("test_client%d", i),
+			[]string{fmt.Sprintf("https://client%d.test/callback", i)},
+			[]string{},
+			false,
+			GetTestNow(t).Add(time.Duration(i)*time.Second),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Client().Insert(c); err != nil {
+			t.Fatal(err)
+		}
+		clients = append(clients, c)
+	}
+
+	return clients
+}
+
+// CreateTestServiceClient persists a confidential client and returns it
+// along with its secret.
+func CreateTestServiceClient(
+	t *testing.T,
+	s Store,
+	scopes []string,
+) (*model.Client, string) {
+	t.Helper()
+
+	c, secret, err := model.NewClient("test_service", []string{}, scopes, true, GetTestNow(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Client().Insert(c); err != nil {
+		t.Fatal(err)
+	}
+
+	return c, secret
+}
+
+// CreateTestAuthCode persists a code for the client's first redirect uri and
+// returns it hashed, along with the plain code.
+func CreateTestAuthCode(
+	t *testing.T,
+	s Store,
+	client *model.Client,
+	user *model.User,
+	lifetime time.Duration,
+) (*model.AuthCode, string) {
+	t.Helper()
+
+	code, err := model.NewAuthCode(
+		client.Id,
+		user.ID,
+		client.RedirectUris[0],
+		GetTestCodeChallenge(),
+		model.Audience{"test"},
+		model.ScopeProfileRead,
+		lifetime,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code.Nonce = "test_nonce"
+	hashed := code.Hashed(GetTestTokenHashKey())
+	if err := s.AuthCode().Insert(hashed); err != nil {
+		t.Fatal(err)
+	}
+
+	return hashed, code.Code
+}
+
+// CreateTestApiKey persists keys of the user created a second apart, and
+// returns them as persisted along with their plain keys.
+func CreateTestApiKey(
+	t *testing.T,
+	s Store,
+	user *model.User,
+	count int,
+	lifetime time.Duration,
+) ([]*model.ApiKey, []string) {
+	t.Helper()
+
+	keys := []*model.ApiKey{}
+	plainKeys := []string{}
+	for i := 0; i < count; i++ {
+		k, err := model.NewApiKey(
+			user.ID,
+			fmt.Sprintf("test_key%d", i),
+			"profile:read",
+			lifetime,
+			time.Now().Add(time.Duration(i)*time.Second),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		plainKeys = append(plainKeys, k.Key)
+		k.Key = ""
+		if err := s.ApiKey().Insert(k); err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, k)
+	}
+
+	return keys, plainKeys
+}
+
+// CreateTestPasswordReset persists a reset of the user and returns it hashed,
+// along with the plain token.
+func CreateTestPasswordReset(
+	t *testing.T,
+	s Store,
+	user *model.User,
+	lifetime time.Duration,
+) (*model.PasswordReset, string) {
+	t.Helper()
+
+	reset, err := model.NewPasswordReset(user.ID, lifetime, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	hashed := reset.Hashed(GetTestTokenHashKey())
+	if err := s.PasswordReset().Insert(hashed); err != nil {
+		t.Fatal(err)
+	}
+
+	return hashed, reset.Token
+}
+
+// CreateTestEmailVerification persists the record of a verification token of
+// the user and returns it.
+func CreateTestEmailVerification(
+	t *testing.T,
+	s Store,
+	user *model.User,
+	lifetime time.Duration,
+) *model.EmailVerification {
+	t.Helper()
+
+	verification := &model.EmailVerification{
+		TokenId: uuid.NewV4().String(),
+		UserId:  user.ID,
+		Expires: time.Now().Add(lifetime).Unix(),
+	}
+	if err := s.EmailVerification().Insert(verification); err != nil {
+		t.Fatal(err)
+	}
+
+	return verification
+}
+
+// GetTestCodeVerifier is the PKCE verifier of GetTestCodeChallenge.
+func GetTestCodeVerifier() string {
+	return "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+}
+
+func GetTestCodeChallenge() string {
+	return "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+}
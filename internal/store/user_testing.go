@@ -142,7 +142,7 @@ func TestStore_CreateUser(t *testing.T, s Store) {
 		},
 	}
 	for _, tc := range testCases {
-		u, err := s.User().Insert(tc.email, tc.password, false, GetTestNow(t))
+		u, err := s.User().Insert(tc.email, tc.password, GetTestNow(t))
 		if tc.expectedErrorString == "" {
 			assert.Equal(t, tc.email, u.Email)
 			assert.Equal(t, tc.password, u.Password)
@@ -171,7 +171,6 @@ func TestStore_UpdateUser(t *testing.T, s Store) {
 				"active":           false,
 				"email_verified":   true,
 				"email_subscribed": false,
-				"admin":            true,
 			},
 			expectedErrorString: "",
 		},
@@ -229,8 +228,6 @@ func TestStore_UpdateUser(t *testing.T, s Store) {
 					assert.Equal(t, value, u.EmailVerified)
 				case "email_subscribed":
 					assert.Equal(t, value, u.EmailSubscribed)
-				case "admin":
-					assert.Equal(t, value, u.Admin)
 				}
 			}
 
@@ -251,3 +248,45 @@ func TestStore_DeleteUser(t *testing.T, s Store) {
 	_, err = s.User().GetById(test_user.ID)
 	assert.Error(t, err, "sql: no rows in result set")
 }
+
+func TestStore_DeleteUser_LastAdmin(t *testing.T, s Store) {
+	testUsers := CreateTestUser(t, s, 2, true)
+
+	err := s.User().Delete(testUsers[0].ID)
+	assert.NoError(t, err)
+
+	// The last admin cannot be deleted
+	err = s.User().Delete(testUsers[1].ID)
+	assert.ErrorIs(t, err, ErrLastAdmin)
+	_, err = s.User().GetById(testUsers[1].ID)
+	assert.NoError(t, err)
+	roles, err := s.Role().GetByUser(testUsers[1].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []*model.Role{model.NewAdminRole()}, roles)
+}
+
+func TestStore_UpdateUser_LastAdmin(t *testing.T, s Store) {
+	testUsers := CreateTestUser(t, s, 2, true)
+	deactivate := map[string]interface{}{"active": false}
+
+	err := s.User().Update(testUsers[0].ID, deactivate)
+	assert.NoError(t, err)
+
+	// The last active admin cannot be deactivated
+	err = s.User().Update(testUsers[1].ID, deactivate)
+	assert.ErrorIs(t, err, ErrLastAdmin)
+	user, err := s.User().GetById(testUsers[1].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, user.Active)
+
+	// Nor lose the admin role to an inactive admin
+	err = s.Role().SetUserRoles(testUsers[1].ID, []string{})
+	assert.ErrorIs(t, err, ErrLastAdmin)
+
+	err = s.User().Update(testUsers[1].ID, map[string]interface{}{"email_verified": true})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS signing_key (
+    kid varchar (64) PRIMARY KEY,
+    purpose varchar (16) not null,
+    alg varchar (16) not null,
+    key_data text not null,
+    created TIMESTAMPTZ not null,
+    expires TIMESTAMPTZ
+);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS refresh_token_family_idx;
+ALTER TABLE refresh_token
+    DROP COLUMN IF EXISTS family,
+    DROP COLUMN IF EXISTS consumed;
@@ -0,0 +1,6 @@
+ALTER TABLE refresh_token
+    ADD COLUMN IF NOT EXISTS family uuid,
+    ADD COLUMN IF NOT EXISTS consumed boolean not null default false;
+UPDATE refresh_token SET family = id WHERE family IS NULL;
+ALTER TABLE refresh_token ALTER COLUMN family SET NOT NULL;
+CREATE INDEX IF NOT EXISTS refresh_token_family_idx ON refresh_token (family);
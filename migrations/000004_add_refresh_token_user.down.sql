@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS refresh_token_user_id_idx;
+ALTER TABLE refresh_token DROP COLUMN IF EXISTS user_id;
@@ -0,0 +1,6 @@
+-- Existing tokens cannot be linked to their owner and are short lived,
+-- so they are dropped and their users have to log in again
+DELETE FROM refresh_token;
+ALTER TABLE refresh_token
+    ADD COLUMN IF NOT EXISTS user_id bigint not null REFERENCES users (id) ON DELETE CASCADE;
+CREATE INDEX IF NOT EXISTS refresh_token_user_id_idx ON refresh_token (user_id);
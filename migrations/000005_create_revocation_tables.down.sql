@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS revoked_access_token;
+DROP TABLE IF EXISTS revoked_user;
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS revoked_access_token (
+    access_uuid uuid PRIMARY KEY,
+    expires BIGINT not null
+);
+CREATE TABLE IF NOT EXISTS revoked_user (
+    user_id bigint PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    revoked_at_ms BIGINT not null,
+    expires BIGINT not null
+);
+CREATE INDEX IF NOT EXISTS revoked_access_token_expires_idx ON revoked_access_token (expires);
+CREATE INDEX IF NOT EXISTS revoked_user_expires_idx ON revoked_user (expires);
@@ -0,0 +1,5 @@
+-- The hashes cannot be turned back into tokens, so the sessions are dropped
+-- and their users have to log in again
+DELETE FROM refresh_token;
+ALTER TABLE refresh_token DROP COLUMN IF EXISTS token_hash;
+ALTER TABLE refresh_token ADD COLUMN IF NOT EXISTS token_string varchar (250);
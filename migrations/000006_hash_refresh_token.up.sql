@@ -0,0 +1,5 @@
+-- The hash key is not known to the database, so the tokens stored since 000004
+-- are dropped rather than hashed and their users have to log in again
+DELETE FROM refresh_token;
+ALTER TABLE refresh_token DROP COLUMN IF EXISTS token_string;
+ALTER TABLE refresh_token ADD COLUMN IF NOT EXISTS token_hash char (64) not null;
@@ -0,0 +1,5 @@
+ALTER TABLE refresh_token
+    DROP COLUMN IF EXISTS user_agent,
+    DROP COLUMN IF EXISTS client_ip,
+    DROP COLUMN IF EXISTS last_used,
+    DROP COLUMN IF EXISTS created;
@@ -0,0 +1,5 @@
+ALTER TABLE refresh_token
+    ADD COLUMN IF NOT EXISTS created BIGINT not null default 0,
+    ADD COLUMN IF NOT EXISTS last_used BIGINT not null default 0,
+    ADD COLUMN IF NOT EXISTS client_ip varchar (45) not null default '',
+    ADD COLUMN IF NOT EXISTS user_agent varchar (512) not null default '';
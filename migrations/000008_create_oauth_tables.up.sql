@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS oauth_client (
+    id uuid PRIMARY KEY,
+    name varchar (255) not null,
+    redirect_uris text[] not null default '{}',
+    created BIGINT not null
+);
+CREATE TABLE IF NOT EXISTS authorization_code (
+    code_hash char (64) PRIMARY KEY,
+    client_id uuid not null REFERENCES oauth_client (id) ON DELETE CASCADE,
+    user_id bigint not null REFERENCES users (id) ON DELETE CASCADE,
+    redirect_uri text not null,
+    code_challenge varchar (64) not null,
+    nonce text not null default '',
+    audience text[] not null default '{}',
+    expires BIGINT not null
+);
+CREATE INDEX IF NOT EXISTS authorization_code_expires_idx ON authorization_code (expires);
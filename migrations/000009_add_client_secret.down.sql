@@ -0,0 +1,3 @@
+ALTER TABLE oauth_client
+    DROP COLUMN IF EXISTS scopes,
+    DROP COLUMN IF EXISTS secret_hash;
@@ -0,0 +1,3 @@
+ALTER TABLE oauth_client
+    ADD COLUMN IF NOT EXISTS secret_hash varchar (64) not null default '',
+    ADD COLUMN IF NOT EXISTS scopes text[] not null default '{}';
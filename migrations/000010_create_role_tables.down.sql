@@ -0,0 +1,9 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS admin boolean;
+UPDATE users SET admin = EXISTS (
+    SELECT 1 FROM user_role
+    WHERE user_role.user_id = users.id AND user_role.role_name = 'admin'
+);
+DROP TABLE IF EXISTS user_role;
+DROP TABLE IF EXISTS role_permission;
+DROP TABLE IF EXISTS role;
+DROP TABLE IF EXISTS permission;
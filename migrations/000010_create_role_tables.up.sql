@@ -0,0 +1,38 @@
+CREATE TABLE IF NOT EXISTS permission (
+    name varchar (64) PRIMARY KEY
+);
+INSERT INTO permission (name) VALUES
+    ('users:read'),
+    ('users:write'),
+    ('users:delete'),
+    ('tokens:read'),
+    ('tokens:delete'),
+    ('tokens:revoke'),
+    ('keys:read'),
+    ('keys:write'),
+    ('clients:read'),
+    ('clients:write'),
+    ('roles:read'),
+    ('roles:write')
+ON CONFLICT DO NOTHING;
+CREATE TABLE IF NOT EXISTS role (
+    name varchar (64) PRIMARY KEY
+);
+CREATE TABLE IF NOT EXISTS role_permission (
+    role_name varchar (64) not null REFERENCES role (name) ON DELETE CASCADE,
+    permission varchar (64) not null REFERENCES permission (name) ON DELETE CASCADE,
+    PRIMARY KEY (role_name, permission)
+);
+CREATE TABLE IF NOT EXISTS user_role (
+    user_id bigint not null REFERENCES users (id) ON DELETE CASCADE,
+    role_name varchar (64) not null REFERENCES role (name) ON DELETE CASCADE,
+    PRIMARY KEY (user_id, role_name)
+);
+INSERT INTO role (name) VALUES ('admin') ON CONFLICT DO NOTHING;
+INSERT INTO role_permission (role_name, permission)
+    SELECT 'admin', name FROM permission
+ON CONFLICT DO NOTHING;
+INSERT INTO user_role (user_id, role_name)
+    SELECT id, 'admin' FROM users WHERE admin
+ON CONFLICT DO NOTHING;
+ALTER TABLE users DROP COLUMN IF EXISTS admin;
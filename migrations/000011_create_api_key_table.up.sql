@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS api_key (
+    id uuid PRIMARY KEY,
+    prefix varchar (16) not null unique,
+    key_hash char (64) not null,
+    user_id bigint not null REFERENCES users (id) ON DELETE CASCADE,
+    name varchar (255) not null,
+    scope text not null default '',
+    created BIGINT not null,
+    expires BIGINT not null default 0,
+    last_used BIGINT not null default 0
+);
+CREATE INDEX IF NOT EXISTS api_key_user_id_idx ON api_key (user_id);
+CREATE INDEX IF NOT EXISTS api_key_expires_idx ON api_key (expires);
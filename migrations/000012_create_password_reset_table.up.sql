@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS password_reset (
+    token_hash char (64) PRIMARY KEY,
+    user_id bigint not null REFERENCES users (id) ON DELETE CASCADE,
+    created BIGINT not null,
+    expires BIGINT not null
+);
+CREATE INDEX IF NOT EXISTS password_reset_user_id_idx ON password_reset (user_id);
+CREATE INDEX IF NOT EXISTS password_reset_expires_idx ON password_reset (expires);
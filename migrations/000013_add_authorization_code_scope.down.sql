@@ -0,0 +1 @@
+ALTER TABLE authorization_code DROP COLUMN IF EXISTS scope;
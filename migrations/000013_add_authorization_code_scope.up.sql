@@ -0,0 +1 @@
+ALTER TABLE authorization_code ADD COLUMN IF NOT EXISTS scope text NOT NULL DEFAULT '';
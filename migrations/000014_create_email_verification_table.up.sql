@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS email_verification (
+    token_id uuid PRIMARY KEY,
+    user_id bigint not null REFERENCES users (id) ON DELETE CASCADE,
+    expires BIGINT not null
+);
+CREATE INDEX IF NOT EXISTS email_verification_user_id_idx ON email_verification (user_id);
+CREATE INDEX IF NOT EXISTS email_verification_expires_idx ON email_verification (expires);